@@ -0,0 +1,180 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// LogsDroppedMetric is the counter of the log records dropped by the
+// asynchronous log path because its buffer was full.
+const LogsDroppedMetric = "kgsotel.logs.dropped"
+
+// WithAsyncLogs returns an Option to make the OTLP log path asynchronous:
+// the records are queued in a buffer of bufferSize records and processed
+// (scrubbed, reshaped and batched) by a background goroutine, so a slow
+// collector or processor never adds latency to the logging calls. When the
+// buffer is full, the new records are dropped and counted by the
+// kgsotel.logs.dropped counter and the dropped logs of the diagnostics. The
+// console logs are not affected.
+func WithAsyncLogs(bufferSize int) Option {
+	return optionFunc(func(cfg *config) {
+		if bufferSize > 0 {
+			cfg.AsyncLogBuffer = bufferSize
+		}
+	})
+}
+
+// asyncItem is a record to process, or a marker signaled when the records
+// queued before it are processed.
+type asyncItem struct {
+	ctx    context.Context
+	record sdklog.Record
+	marker chan struct{}
+}
+
+// asyncProcessor queues the records for its processors, run in the
+// background.
+type asyncProcessor struct {
+	processors []sdklog.Processor
+	queue      chan asyncItem
+	dropped    metric.Int64Counter
+	status     *pipelineStatus
+	done       chan struct{}
+
+	// mu guards the queue against its closing by Shutdown.
+	mu     sync.RWMutex
+	closed bool
+}
+
+var _ sdklog.Processor = (*asyncProcessor)(nil)
+
+func newAsyncProcessor(bufferSize int, mp metric.MeterProvider, status *pipelineStatus, processors ...sdklog.Processor) *asyncProcessor {
+	dropped, err := mp.Meter(modulePath).Int64Counter(LogsDroppedMetric,
+		metric.WithDescription("Counts the log records dropped because the buffer of the asynchronous log path was full."),
+		metric.WithUnit("{record}"))
+	if err != nil {
+		otel.Handle(err)
+		dropped = noop.Int64Counter{}
+	}
+	p := &asyncProcessor{
+		processors: processors,
+		queue:      make(chan asyncItem, bufferSize),
+		dropped:    dropped,
+		status:     status,
+		done:       make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// run processes the queued records until the queue is closed.
+func (p *asyncProcessor) run() {
+	defer close(p.done)
+	for item := range p.queue {
+		if item.marker != nil {
+			close(item.marker)
+			continue
+		}
+		for _, next := range p.processors {
+			if err := next.OnEmit(item.ctx, &item.record); err != nil {
+				otel.Handle(err)
+			}
+		}
+	}
+}
+
+// OnEmit queues a copy of the record, or drops it if the buffer is full.
+func (p *asyncProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.drop(ctx, 1)
+		return nil
+	}
+	select {
+	case p.queue <- asyncItem{ctx: context.WithoutCancel(ctx), record: r.Clone()}:
+	default:
+		p.drop(ctx, 1)
+	}
+	return nil
+}
+
+// drop counts n dropped records.
+func (p *asyncProcessor) drop(ctx context.Context, n int) {
+	p.dropped.Add(ctx, int64(n))
+	if p.status != nil {
+		p.status.recordDropped(SignalLogs, n)
+	}
+}
+
+// drain waits until the records queued so far are processed.
+func (p *asyncProcessor) drain(ctx context.Context) error {
+	marker := make(chan struct{})
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return nil
+	}
+	select {
+	case p.queue <- asyncItem{marker: marker}:
+		p.mu.RUnlock()
+	case <-ctx.Done():
+		p.mu.RUnlock()
+		return ctx.Err()
+	}
+
+	select {
+	case <-marker:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ForceFlush processes the queued records and flushes the processors.
+func (p *asyncProcessor) ForceFlush(ctx context.Context) error {
+	err := p.drain(ctx)
+	for _, next := range p.processors {
+		err = errors.Join(err, next.ForceFlush(ctx))
+	}
+	return err
+}
+
+// Shutdown processes the queued records and shuts the processors down. The
+// records not processed before ctx is done, or emitted afterwards, are
+// dropped.
+func (p *asyncProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	var err error
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		// The records left in the buffer are dropped.
+		n := 0
+		for item := range p.queue {
+			if item.marker == nil {
+				n++
+			}
+		}
+		p.drop(ctx, n)
+	}
+	for _, next := range p.processors {
+		err = errors.Join(err, next.Shutdown(ctx))
+	}
+	return err
+}
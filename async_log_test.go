@@ -0,0 +1,94 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// blockingProcessor blocks the first record until it is released.
+type blockingProcessor struct {
+	recordingProcessor
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if len(p.records) == 0 {
+		close(p.started)
+		<-p.release
+	}
+	return p.recordingProcessor.OnEmit(ctx, r)
+}
+
+func TestAsyncProcessor(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	status := &pipelineStatus{}
+	slow := &blockingProcessor{started: make(chan struct{}), release: make(chan struct{})}
+	p := newAsyncProcessor(2, mp, status, slow)
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(p))
+	logger := lp.Logger("test")
+
+	emit := func(body string) {
+		var r log.Record
+		r.SetBody(log.StringValue(body))
+		logger.Emit(context.Background(), r)
+	}
+	emit("first")
+	<-slow.started
+	// The buffer holds two records while the first one is processed.
+	for _, body := range []string{"a", "b", "c", "d"} {
+		emit(body)
+	}
+	oteltest.AssertSumValue(t, reader, LogsDroppedMetric, nil, int64(2))
+	assert.Equal(t, int64(2), status.droppedLogs.Load())
+
+	close(slow.release)
+	require.NoError(t, lp.ForceFlush(context.Background()))
+	var bodies []string
+	for _, r := range slow.records {
+		bodies = append(bodies, r.Body().AsString())
+	}
+	assert.Equal(t, []string{"first", "a", "b"}, bodies)
+
+	require.NoError(t, lp.Shutdown(context.Background()))
+	emit("after shutdown")
+	assert.Len(t, slow.records, 3)
+}
+
+func TestAsyncProcessorShutdownDeadline(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	status := &pipelineStatus{}
+	slow := &blockingProcessor{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(slow.release)
+	p := newAsyncProcessor(4, mp, status, slow)
+
+	emit := func(body string) {
+		var r sdklog.Record
+		r.SetBody(log.StringValue(body))
+		require.NoError(t, p.OnEmit(context.Background(), &r))
+	}
+	emit("first")
+	<-slow.started
+	emit("a")
+	emit("b")
+
+	// The first record hangs past the deadline: the buffered records and
+	// the ones emitted afterwards are dropped.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+	emit("after shutdown")
+	assert.Equal(t, int64(3), status.droppedLogs.Load())
+	oteltest.AssertSumValue(t, reader, LogsDroppedMetric, nil, int64(3))
+}
@@ -0,0 +1,139 @@
+package kgsotel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"kgs/otel/internal/killswitch"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// The attributes of the summary span of a batch.
+const (
+	BatchSizeKey            = attribute.Key("kgs.batch.size")
+	BatchSucceededKey       = attribute.Key("kgs.batch.succeeded")
+	BatchFailedKey          = attribute.Key("kgs.batch.failed")
+	BatchSlowestItemKey     = attribute.Key("kgs.batch.slowest.item")
+	BatchSlowestDurationKey = attribute.Key("kgs.batch.slowest.duration_ms")
+	BatchItemKey            = attribute.Key("kgs.batch.item")
+)
+
+const (
+	// maxBatchLinks bounds the links of a batch span to the traces of its items.
+	maxBatchLinks = 128
+	// maxBatchFailureEvents bounds the events of the failed items.
+	maxBatchFailureEvents = 10
+)
+
+// Batch is the summary span of the processing of many items, accumulating
+// their outcomes instead of tracing each of them in its own span. It is safe
+// for concurrent use.
+type Batch struct {
+	span trace.Span
+
+	mu        sync.Mutex
+	succeeded int
+	failed    int
+	slowestID string
+	slowest   time.Duration
+	links     int
+}
+
+// StartBatch starts the summary span of the processing of size items:
+//
+//	ctx, batch := kgsotel.StartBatch(ctx, "import invoices", len(invoices))
+//	defer batch.End()
+//	for _, inv := range invoices {
+//		done := batch.StartItem(inv.ID)
+//		done(importInvoice(ctx, inv))
+//	}
+//
+// The span gets the number of succeeded and failed items, the slowest item,
+// an event for each of the first failures and links to the traces of the
+// items added by LinkItem. It is an error if any item failed.
+func StartBatch(ctx context.Context, name string, size int) (context.Context, *Batch) {
+	if killswitch.Disabled() {
+		return ctx, &Batch{span: noop.Span{}}
+	}
+	ctx, span := otel.Tracer(modulePath).Start(ctx, name, trace.WithAttributes(BatchSizeKey.Int(size)))
+	return ctx, &Batch{span: span}
+}
+
+// Span returns the summary span of the batch.
+func (b *Batch) Span() trace.Span {
+	return b.span
+}
+
+// StartItem starts timing the item with the ID and returns the function
+// recording its outcome, a failure if err is not nil.
+func (b *Batch) StartItem(id string) func(err error) {
+	start := time.Now()
+	return func(err error) {
+		b.Record(id, time.Since(start), err)
+	}
+}
+
+// Record records the outcome of the item with the ID, processed in d.
+func (b *Batch) Record(id string, d time.Duration, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if d > b.slowest || b.succeeded+b.failed == 0 {
+		b.slowestID, b.slowest = id, d
+	}
+	if err == nil {
+		b.succeeded++
+		return
+	}
+	b.failed++
+	if b.failed <= maxBatchFailureEvents {
+		b.span.AddEvent("item failed", trace.WithAttributes(
+			BatchItemKey.String(id),
+			attribute.String("error.message", err.Error()),
+		))
+	}
+}
+
+// LinkItem links the batch span to the trace of an item, e.g. the context
+// propagated with a queued message. Only the first 128 valid links are kept.
+func (b *Batch) LinkItem(sc trace.SpanContext, attrs ...attribute.KeyValue) {
+	if !sc.IsValid() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.links >= maxBatchLinks {
+		return
+	}
+	b.links++
+	b.span.AddLink(trace.Link{SpanContext: sc, Attributes: attrs})
+}
+
+// End sets the summary of the outcomes on the batch span and ends it.
+func (b *Batch) End(opts ...trace.SpanEndOption) {
+	b.mu.Lock()
+	attrs := []attribute.KeyValue{
+		BatchSucceededKey.Int(b.succeeded),
+		BatchFailedKey.Int(b.failed),
+	}
+	if b.succeeded+b.failed > 0 {
+		attrs = append(attrs,
+			BatchSlowestItemKey.String(b.slowestID),
+			BatchSlowestDurationKey.Float64(float64(b.slowest)/float64(time.Millisecond)),
+		)
+	}
+	if b.failed > 0 {
+		b.span.SetStatus(codes.Error, fmt.Sprintf("%d of %d items failed", b.failed, b.succeeded+b.failed))
+	}
+	b.mu.Unlock()
+
+	b.span.SetAttributes(attrs...)
+	b.span.End(opts...)
+}
@@ -0,0 +1,52 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestBatch(t *testing.T) {
+	restoreGlobals(t)
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+
+	_, producer := tp.Tracer("test").Start(context.Background(), "publish")
+	producer.End()
+
+	_, batch := StartBatch(context.Background(), "import invoices", 3)
+	batch.Record("inv-1", 5*time.Millisecond, nil)
+	batch.Record("inv-2", 20*time.Millisecond, errors.New("duplicate"))
+	batch.StartItem("inv-3")(nil)
+	batch.LinkItem(producer.SpanContext())
+	batch.LinkItem(trace.SpanContext{})
+	batch.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	span := spans[1]
+	assert.Equal(t, "import invoices", span.Name())
+	attrs := span.Attributes()
+	assert.Contains(t, attrs, BatchSizeKey.Int(3))
+	assert.Contains(t, attrs, BatchSucceededKey.Int(2))
+	assert.Contains(t, attrs, BatchFailedKey.Int(1))
+	assert.Contains(t, attrs, BatchSlowestItemKey.String("inv-2"))
+	assert.Contains(t, attrs, BatchSlowestDurationKey.Float64(20))
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, "1 of 3 items failed", span.Status().Description)
+
+	require.Len(t, span.Events(), 1)
+	assert.Contains(t, span.Events()[0].Attributes, BatchItemKey.String("inv-2"))
+	require.Len(t, span.Links(), 1)
+	assert.Equal(t, producer.SpanContext().TraceID(), span.Links()[0].SpanContext.TraceID())
+}
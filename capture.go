@@ -0,0 +1,253 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// captureExt is the extension of the files holding a captured trace.
+const captureExt = ".json"
+
+// captureQueueSize bounds the traces waiting to be written; the traces
+// completed while it is full are dropped.
+const captureQueueSize = 256
+
+// SpanMatcher selects the traces to capture: a trace is captured if any of
+// its spans matches.
+type SpanMatcher func(sdktrace.ReadOnlySpan) bool
+
+// MatchTraceIDs returns a SpanMatcher selecting the traces with the IDs.
+func MatchTraceIDs(ids ...trace.TraceID) SpanMatcher {
+	return func(s sdktrace.ReadOnlySpan) bool {
+		return slices.Contains(ids, s.SpanContext().TraceID())
+	}
+}
+
+// MatchErrors returns a SpanMatcher selecting the traces with a failed span.
+func MatchErrors() SpanMatcher {
+	return func(s sdktrace.ReadOnlySpan) bool {
+		return s.Status().Code == codes.Error
+	}
+}
+
+// WithTraceCapture returns an Option to write the traces selected by match
+// to dir, one OTLP JSON file per trace named after the trace ID. The files
+// are written in the background; the spans of a trace completed by several
+// local roots, e.g. two requests of the trace served by the service, are
+// merged in its file. The captured traces can be sent to another collector
+// with ReplayTraces. Only the spans sampled by the tracer provider are
+// captured.
+func WithTraceCapture(dir string, match SpanMatcher) Option {
+	return optionFunc(func(cfg *config) {
+		if dir != "" && match != nil {
+			cfg.CaptureDir = dir
+			cfg.CaptureMatcher = match
+		}
+	})
+}
+
+// captureItem is a trace to write, or a marker signaled when the traces
+// queued before it are written.
+type captureItem struct {
+	spans  []sdktrace.ReadOnlySpan
+	marker chan struct{}
+}
+
+// captureProcessor writes the matching traces to files once their local
+// root span ends, in the background.
+type captureProcessor struct {
+	match    SpanMatcher
+	exporter *otlptrace.Exporter
+	status   *pipelineStatus
+	queue    chan captureItem
+	done     chan struct{}
+
+	// mu guards the traces, and the queue against its closing by Shutdown.
+	mu     sync.Mutex
+	traces traceAssembler
+	closed bool
+}
+
+var _ sdktrace.SpanProcessor = (*captureProcessor)(nil)
+
+func newCaptureProcessor(ctx context.Context, dir string, match SpanMatcher, status *pipelineStatus) (*captureProcessor, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create capture dir: %w", err)
+	}
+	exporter, err := otlptrace.New(ctx, captureClient{dir: dir})
+	if err != nil {
+		return nil, err
+	}
+	p := &captureProcessor{
+		match:    match,
+		exporter: exporter,
+		status:   status,
+		queue:    make(chan captureItem, captureQueueSize),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// run writes the queued traces until the queue is closed.
+func (p *captureProcessor) run() {
+	defer close(p.done)
+	for item := range p.queue {
+		if item.marker != nil {
+			close(item.marker)
+			continue
+		}
+		if err := p.exporter.ExportSpans(context.Background(), item.spans); err != nil {
+			p.status.recordExportError(SignalTraces, fmt.Errorf("capture trace: %w", err), 0)
+		}
+	}
+}
+
+// OnStart does nothing, the spans are captured when they end.
+func (p *captureProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd adds the span to its trace, and queues the trace if it is complete
+// and matches, or drops it if the queue is full.
+func (p *captureProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	spans, ok := p.traces.add(s)
+	if !ok || p.closed || !slices.ContainsFunc(spans, p.match) {
+		return
+	}
+	select {
+	case p.queue <- captureItem{spans: spans}:
+	default:
+		p.status.recordDropped(SignalTraces, len(spans))
+	}
+}
+
+// ForceFlush waits until the traces queued so far are written.
+func (p *captureProcessor) ForceFlush(ctx context.Context) error {
+	marker := make(chan struct{})
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	select {
+	case p.queue <- captureItem{marker: marker}:
+		p.mu.Unlock()
+	case <-ctx.Done():
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+
+	select {
+	case <-marker:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown writes the queued traces and drops the traces whose root span
+// did not end.
+func (p *captureProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.traces.reset()
+	close(p.queue)
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.exporter.Shutdown(ctx)
+}
+
+// captureClient is an otlptrace.Client writing the spans of a trace to a file.
+type captureClient struct {
+	dir string
+}
+
+func (c captureClient) Start(context.Context) error { return nil }
+
+func (c captureClient) Stop(context.Context) error { return nil }
+
+// UploadTraces writes the spans to a file named after their trace ID,
+// after the spans of the trace already written.
+func (c captureClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	name := filepath.Join(c.dir, hex.EncodeToString(firstTraceID(spans))+captureExt)
+	req := &coltracepb.ExportTraceServiceRequest{}
+	if data, err := os.ReadFile(name); err == nil {
+		if err := protojson.Unmarshal(data, req); err != nil {
+			return fmt.Errorf("merge %s: %w", name, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	req.ResourceSpans = append(req.ResourceSpans, spans...)
+	data, err := protojson.MarshalOptions{Multiline: true}.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(name+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
+
+func firstTraceID(spans []*tracepb.ResourceSpans) []byte {
+	for _, rs := range spans {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				return s.GetTraceId()
+			}
+		}
+	}
+	return nil
+}
+
+// ReplayTraces sends the traces captured with WithTraceCapture to the
+// collector at endpoint, keeping their IDs and timestamps. Headers can be
+// added with metadata.AppendToOutgoingContext.
+func ReplayTraces(ctx context.Context, endpoint string, paths ...string) error {
+	conn, err := initConn(endpoint, newConfig())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := coltracepb.NewTraceServiceClient(conn)
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("replay %s: %w", path, err)
+		}
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := protojson.Unmarshal(data, req); err != nil {
+			return fmt.Errorf("replay %s: %w", path, err)
+		}
+		if _, err := client.Export(ctx, req); err != nil {
+			return fmt.Errorf("replay %s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kgs/otel/otlptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCaptureAndReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+
+	capture, err := newCaptureProcessor(ctx, dir, MatchErrors(), &pipelineStatus{})
+	require.NoError(t, err)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(capture))
+	tracer := tp.Tracer("test")
+
+	// A failed trace is captured with all its spans.
+	ctx1, root := tracer.Start(ctx, "checkout")
+	_, child := tracer.Start(ctx1, "charge")
+	child.SetStatus(codes.Error, errors.New("declined").Error())
+	child.End()
+	root.End()
+
+	// A successful trace is not.
+	_, ok := tracer.Start(ctx, "browse")
+	ok.End()
+
+	require.NoError(t, capture.ForceFlush(ctx))
+	files, err := filepath.Glob(filepath.Join(dir, "*"+captureExt))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, root.SpanContext().TraceID().String()+captureExt, filepath.Base(files[0]))
+
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+
+	require.NoError(t, ReplayTraces(ctx, collector.Addr, files...))
+	spans := collector.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, root.SpanContext().TraceID().String(), hex.EncodeToString(spans[0].GetTraceId()))
+
+	assert.Error(t, ReplayTraces(ctx, collector.Addr, filepath.Join(dir, "missing.json")))
+}
+
+func TestCaptureMergesLocalRoots(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dir := t.TempDir()
+
+	capture, err := newCaptureProcessor(ctx, dir, MatchErrors(), &pipelineStatus{})
+	require.NoError(t, err)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(capture))
+	tracer := tp.Tracer("test")
+
+	// Two requests of the same trace served by the service.
+	parent := trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+	for _, name := range []string{"reserve", "charge"} {
+		_, span := tracer.Start(parent, name)
+		span.SetStatus(codes.Error, "failed")
+		span.End()
+	}
+	require.NoError(t, capture.Shutdown(ctx))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+captureExt))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+	require.NoError(t, ReplayTraces(ctx, collector.Addr, files...))
+	var names []string
+	for _, s := range collector.Spans() {
+		names = append(names, s.GetName())
+	}
+	assert.Equal(t, []string{"reserve", "charge"}, names)
+}
@@ -0,0 +1,146 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ErrCircuitOpen is returned by the exporters while the circuit breaker is
+// open. The telemetry of the short-circuited exports is dropped, unless
+// the disk buffer is enabled (see WithDiskBuffer).
+var ErrCircuitOpen = errors.New("kgsotel: exporter circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// String will return the name for the state.
+func (s breakerState) String() string {
+	return [...]string{"closed", "open", "half-open"}[s]
+}
+
+// circuitBreaker short-circuits the exports after consecutive failures, and
+// lets a single export through once the cooldown expires to probe whether the
+// collector recovered.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow reports whether an export may be attempted.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		// Probe the collector with this export.
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// A probe is already in flight.
+		return false
+	default:
+		return true
+	}
+}
+
+// done records the result of an attempted export.
+func (b *circuitBreaker) done(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) currentState() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// do runs the export if the breaker allows it. A nil breaker always allows
+// it.
+func (b *circuitBreaker) do(export func() error) error {
+	if b == nil {
+		return export()
+	}
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := export()
+	b.done(err)
+	return err
+}
+
+// breakerSpanExporter short-circuits the exports of the wrapped exporter.
+type breakerSpanExporter struct {
+	sdktrace.SpanExporter
+	breaker *circuitBreaker
+}
+
+// ExportSpans exports the spans unless the circuit is open.
+func (e breakerSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.breaker.do(func() error {
+		return e.SpanExporter.ExportSpans(ctx, spans)
+	})
+}
+
+// breakerMetricExporter short-circuits the exports of the wrapped exporter.
+type breakerMetricExporter struct {
+	sdkmetric.Exporter
+	breaker *circuitBreaker
+}
+
+// Export exports the metrics unless the circuit is open.
+func (e breakerMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.breaker.do(func() error {
+		return e.Exporter.Export(ctx, rm)
+	})
+}
+
+// breakerLogExporter short-circuits the exports of the wrapped exporter.
+type breakerLogExporter struct {
+	sdklog.Exporter
+	breaker *circuitBreaker
+}
+
+// Export exports the log records unless the circuit is open.
+func (e breakerLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return e.breaker.do(func() error {
+		return e.Exporter.Export(ctx, records)
+	})
+}
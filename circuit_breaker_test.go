@@ -0,0 +1,33 @@
+package kgsotel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(2, 50*time.Millisecond)
+	fail := func() error { return errors.New("unavailable") }
+	succeed := func() error { return nil }
+
+	assert.Error(t, b.do(fail))
+	assert.Equal(t, breakerClosed, b.currentState())
+	assert.Error(t, b.do(fail))
+	assert.Equal(t, breakerOpen, b.currentState())
+
+	// Short-circuited while open.
+	assert.ErrorIs(t, b.do(succeed), ErrCircuitOpen)
+
+	// A failed probe opens the circuit again.
+	time.Sleep(60 * time.Millisecond)
+	assert.Error(t, b.do(fail))
+	assert.Equal(t, breakerOpen, b.currentState())
+
+	// A successful probe closes it.
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, b.do(succeed))
+	assert.Equal(t, breakerClosed, b.currentState())
+}
@@ -0,0 +1,105 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorCodeKey is the attribute of the code of a CodedError.
+const ErrorCodeKey = "error.code"
+
+// CodedError is an error with a code of the error taxonomy of the services,
+// e.g. "ORDER_NOT_FOUND", and fields. When it is logged with Error or
+// recorded with RecordError, directly or wrapped, its code and fields are
+// promoted to attributes of the span and fields of the log.
+type CodedError struct {
+	Code    string
+	Message string
+	Fields  []Field
+	// Cause is the wrapped error, if any.
+	Cause error
+}
+
+// NewError returns a CodedError.
+func NewError(code, message string, fields ...Field) *CodedError {
+	return &CodedError{Code: code, Message: message, Fields: fields}
+}
+
+// WrapError returns a CodedError wrapping the cause.
+func WrapError(cause error, code, message string, fields ...Field) *CodedError {
+	return &CodedError{Code: code, Message: message, Fields: fields, Cause: cause}
+}
+
+// Error returns the message, followed by the cause if any.
+func (e *CodedError) Error() string {
+	if e.Cause == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Cause.Error()
+}
+
+// Unwrap returns the cause.
+func (e *CodedError) Unwrap() error {
+	return e.Cause
+}
+
+// ErrorAttributes returns the code and the fields of the CodedErrors in the
+// chain of err as attributes, the outermost first, or nil if there are none.
+func ErrorAttributes(err error) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, f := range errorFields(err) {
+		attrs = append(attrs, fieldAttribute(f))
+	}
+	return attrs
+}
+
+// errorFields returns the code and the fields of the CodedErrors in the
+// chain of err.
+func errorFields(err error) []Field {
+	var fields []Field
+	for err != nil {
+		var ce *CodedError
+		if !errors.As(err, &ce) {
+			break
+		}
+		if len(fields) == 0 {
+			fields = append(fields, Field{Key: ErrorCodeKey, Value: ce.Code})
+		}
+		fields = append(fields, ce.Fields...)
+		err = ce.Cause
+	}
+	return fields
+}
+
+// withErrorFields appends the codes and the fields of the CodedErrors of the
+// fields.
+func withErrorFields(fields []Field) []Field {
+	var promoted []Field
+	for _, f := range fields {
+		if err, ok := f.Value.(error); ok {
+			promoted = append(promoted, errorFields(err)...)
+		}
+	}
+	if len(promoted) == 0 {
+		return fields
+	}
+	return append(fields[:len(fields):len(fields)], promoted...)
+}
+
+// RecordError records err as an exception event on the span of ctx, with
+// the code and the fields of its CodedErrors, which are also set on the
+// span, and marks the span as failed.
+func RecordError(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	attrs := ErrorAttributes(err)
+	span.RecordError(err, trace.WithAttributes(attrs...))
+	span.SetAttributes(attrs...)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,56 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCodedError(t *testing.T) {
+	cause := errors.New("no rows")
+	err := fmt.Errorf("get order: %w", WrapError(cause, "ORDER_NOT_FOUND", "order missing", NewFiled("order.id", 42)))
+	assert.Equal(t, "get order: order missing: no rows", err.Error())
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String(ErrorCodeKey, "ORDER_NOT_FOUND"),
+		attribute.Int("order.id", 42),
+	}, ErrorAttributes(err))
+	assert.Nil(t, ErrorAttributes(cause))
+}
+
+func TestRecordError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(context.Background(), "op")
+	RecordError(ctx, NewError("PAYMENT_DECLINED", "card declined", NewFiled("payment.provider", "acme")))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), attribute.String(ErrorCodeKey, "PAYMENT_DECLINED"))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("payment.provider", "acme"))
+	require.Len(t, spans[0].Events(), 1)
+	assert.Contains(t, spans[0].Events()[0].Attributes, attribute.String(ErrorCodeKey, "PAYMENT_DECLINED"))
+}
+
+func TestErrorPromotesCodedError(t *testing.T) {
+	restoreGlobals(t)
+	core, logs := observer.New(zap.ErrorLevel)
+	zap.ReplaceGlobals(zap.New(core))
+
+	Error(context.Background(), "checkout failed", NewFiled("error", NewError("OUT_OF_STOCK", "sold out", NewFiled("sku", "A1"))))
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "OUT_OF_STOCK", entries[0].ContextMap()[ErrorCodeKey])
+	assert.Equal(t, "A1", entries[0].ContextMap()["sku"])
+}
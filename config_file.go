@@ -0,0 +1,223 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"kgs/otel/internal/defaults"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables overriding the values of the configuration file.
+const (
+	envServiceName     = "KGSOTEL_SERVICE_NAME"
+	envEndpoint        = "KGSOTEL_ENDPOINT"
+	envSampleRatio     = "KGSOTEL_SAMPLE_RATIO"
+	envHeaders         = "KGSOTEL_HEADERS"
+	envLogLevel        = "KGSOTEL_LOG_LEVEL"
+	envDisabledSignals = "KGSOTEL_DISABLED_SIGNALS"
+	envMetricInterval  = "KGSOTEL_METRIC_INTERVAL"
+)
+
+// FileConfig is the telemetry configuration loaded from a YAML or JSON file.
+type FileConfig struct {
+	ServiceName     string            `yaml:"service_name" json:"service_name"`
+	Environment     Environment       `yaml:"environment" json:"environment"`
+	Endpoint        string            `yaml:"endpoint" json:"endpoint"`
+	Headers         map[string]string `yaml:"headers" json:"headers"`
+	Sampler         SamplerConfig     `yaml:"sampler" json:"sampler"`
+	Log             LogConfig         `yaml:"log" json:"log"`
+	DisabledSignals []Signal          `yaml:"disabled_signals" json:"disabled_signals"`
+	MetricInterval  string            `yaml:"metric_interval" json:"metric_interval"`
+	Middleware      MiddlewareConfig  `yaml:"middleware" json:"middleware"`
+	// SLA are the SLA targets of the operations, e.g. "/checkout": 300ms.
+	SLA map[string]string `yaml:"sla" json:"sla"`
+}
+
+// SamplerConfig configures the trace sampler.
+type SamplerConfig struct {
+	// Ratio is the fraction of the root spans to sample.
+	// A nil ratio samples every span.
+	Ratio *float64 `yaml:"ratio" json:"ratio"`
+}
+
+// LogConfig configures the global logger.
+type LogConfig struct {
+	// Level is the minimum level of the logs, e.g. debug, info or warn.
+	Level string `yaml:"level" json:"level"`
+	// SpanEventLevel is the minimum level of the logs added as span events.
+	SpanEventLevel string `yaml:"span_event_level" json:"span_event_level"`
+}
+
+// MiddlewareConfig holds the defaults for the gin and gRPC middlewares.
+type MiddlewareConfig struct {
+	Gin struct {
+		ExcludedPaths []string `yaml:"excluded_paths" json:"excluded_paths"`
+	} `yaml:"gin" json:"gin"`
+	GRPC struct {
+		ExcludedMethods []string `yaml:"excluded_methods" json:"excluded_methods"`
+	} `yaml:"grpc" json:"grpc"`
+}
+
+// InitFromConfig loads the configuration file at path, applies the KGSOTEL_*
+// environment variable overrides and initializes the telemetry with it.
+// Extra options are applied after the ones from the file. The middleware
+// defaults of the file are set once the telemetry is initialized.
+func InitFromConfig(ctx context.Context, path string, opts ...Option) (
+	shutdown func(context.Context) error, err error) {
+	// Like InitTelemetry, a no-op shutdown is returned on error.
+	noop := func(context.Context) error { return nil }
+	fc, err := LoadConfig(path)
+	if err != nil {
+		return noop, err
+	}
+
+	fileOpts, err := fc.Options()
+	if err != nil {
+		return noop, err
+	}
+
+	shutdown, err = InitTelemetry(ctx, fc.ServiceName, fc.Endpoint, append(fileOpts, opts...)...)
+	if err != nil {
+		return shutdown, err
+	}
+	// The middleware defaults are only changed by a successful init.
+	defaults.SetMiddleware(defaults.Middleware{
+		GinExcludedPaths:    fc.Middleware.Gin.ExcludedPaths,
+		GRPCExcludedMethods: fc.Middleware.GRPC.ExcludedMethods,
+	})
+	return shutdown, nil
+}
+
+// LoadConfig reads the configuration file at path and applies the KGSOTEL_*
+// environment variable overrides. Files ending in .json are decoded as JSON,
+// everything else as YAML.
+func LoadConfig(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	fc := &FileConfig{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, fc)
+	} else {
+		err = yaml.Unmarshal(data, fc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+
+	if err := fc.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	return fc, nil
+}
+
+// Options converts the configuration into InitTelemetry options.
+func (fc *FileConfig) Options() ([]Option, error) {
+	var opts []Option
+
+	if fc.Environment != "" {
+		opts = append(opts, WithEnvironment(fc.Environment))
+	}
+	if len(fc.Headers) > 0 {
+		opts = append(opts, WithHeaders(fc.Headers))
+	}
+	if fc.Sampler.Ratio != nil {
+		opts = append(opts, WithSampleRatio(*fc.Sampler.Ratio))
+	}
+	if fc.Log.Level != "" {
+		level, err := zapcore.ParseLevel(fc.Log.Level)
+		if err != nil {
+			return nil, fmt.Errorf("parse log level: %w", err)
+		}
+		opts = append(opts, WithLogLevel(level))
+	}
+	if fc.Log.SpanEventLevel != "" {
+		level, err := zapcore.ParseLevel(fc.Log.SpanEventLevel)
+		if err != nil {
+			return nil, fmt.Errorf("parse span event level: %w", err)
+		}
+		opts = append(opts, WithSpanEventLevel(level))
+	}
+	if len(fc.DisabledSignals) > 0 {
+		opts = append(opts, WithDisabledSignals(fc.DisabledSignals...))
+	}
+	if fc.MetricInterval != "" {
+		interval, err := time.ParseDuration(fc.MetricInterval)
+		if err != nil {
+			return nil, fmt.Errorf("parse metric interval: %w", err)
+		}
+		opts = append(opts, WithMetricInterval(interval))
+	}
+	if len(fc.SLA) > 0 {
+		targets := make(map[string]time.Duration, len(fc.SLA))
+		for name, v := range fc.SLA {
+			target, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("parse SLA target of %s: %w", name, err)
+			}
+			targets[name] = target
+		}
+		opts = append(opts, WithSLATargets(targets))
+	}
+
+	return opts, nil
+}
+
+// applyEnv overrides the configuration with the KGSOTEL_* environment variables.
+func (fc *FileConfig) applyEnv() error {
+	if v, ok := os.LookupEnv(envServiceName); ok {
+		fc.ServiceName = v
+	}
+	if v, ok := os.LookupEnv(envEnvironment); ok {
+		fc.Environment = Environment(v)
+	}
+	if v, ok := os.LookupEnv(envEndpoint); ok {
+		fc.Endpoint = v
+	}
+	if v, ok := os.LookupEnv(envSampleRatio); ok {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", envSampleRatio, err)
+		}
+		fc.Sampler.Ratio = &ratio
+	}
+	if v, ok := os.LookupEnv(envHeaders); ok {
+		// Headers are formatted as comma separated key=value pairs.
+		fc.Headers = make(map[string]string)
+		for _, pair := range strings.Split(v, ",") {
+			key, value, found := strings.Cut(pair, "=")
+			if !found {
+				return fmt.Errorf("parse %s: invalid header %q", envHeaders, pair)
+			}
+			fc.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if v, ok := os.LookupEnv(envLogLevel); ok {
+		fc.Log.Level = v
+	}
+	if v, ok := os.LookupEnv(envDisabledSignals); ok {
+		fc.DisabledSignals = nil
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				fc.DisabledSignals = append(fc.DisabledSignals, Signal(s))
+			}
+		}
+	}
+	if v, ok := os.LookupEnv(envMetricInterval); ok {
+		fc.MetricInterval = v
+	}
+
+	return nil
+}
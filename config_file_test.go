@@ -0,0 +1,103 @@
+package kgsotel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kgs/otel/internal/defaults"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	path := writeConfig(t, "otel.yaml", `
+service_name: svc
+endpoint: localhost:4317
+headers:
+  authorization: token
+sampler:
+  ratio: 0.25
+log:
+  level: warn
+disabled_signals: [metrics]
+metric_interval: 10s
+middleware:
+  gin:
+    excluded_paths: [/healthz]
+sla:
+  /checkout: 300ms
+`)
+
+	fc, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "svc", fc.ServiceName)
+	assert.Equal(t, "localhost:4317", fc.Endpoint)
+	assert.Equal(t, map[string]string{"authorization": "token"}, fc.Headers)
+	require.NotNil(t, fc.Sampler.Ratio)
+	assert.Equal(t, 0.25, *fc.Sampler.Ratio)
+	assert.Equal(t, []Signal{SignalMetrics}, fc.DisabledSignals)
+	assert.Equal(t, []string{"/healthz"}, fc.Middleware.Gin.ExcludedPaths)
+
+	opts, err := fc.Options()
+	require.NoError(t, err)
+	cfg := newConfig(opts...)
+	assert.Equal(t, "warn", cfg.LogLevel.String())
+	assert.True(t, cfg.DisabledSignals[SignalMetrics])
+	assert.Equal(t, "10s", cfg.MetricInterval.String())
+	assert.Equal(t, map[string]time.Duration{"/checkout": 300 * time.Millisecond}, cfg.SLATargets)
+}
+
+func TestLoadConfigJSONWithEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "otel.json", `{"service_name": "svc", "endpoint": "localhost:4317"}`)
+	t.Setenv(envEndpoint, "collector:4317")
+	t.Setenv(envHeaders, "a=1, b=2")
+
+	fc, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "svc", fc.ServiceName)
+	assert.Equal(t, "collector:4317", fc.Endpoint)
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, fc.Headers)
+}
+
+func TestLoadConfigInvalidLevel(t *testing.T) {
+	path := writeConfig(t, "otel.yaml", "log:\n  level: loud\n")
+
+	fc, err := LoadConfig(path)
+	require.NoError(t, err)
+	_, err = fc.Options()
+	assert.Error(t, err)
+}
+
+func TestInitFromConfigError(t *testing.T) {
+	ctx := context.Background()
+	before := defaults.GetMiddleware()
+
+	// Every error path returns a no-op shutdown.
+	shutdown, err := InitFromConfig(ctx, filepath.Join(t.TempDir(), "missing.yaml"))
+	require.Error(t, err)
+	require.NotNil(t, shutdown)
+	assert.NoError(t, shutdown(ctx))
+
+	shutdown, err = InitFromConfig(ctx, writeConfig(t, "otel.yaml", "log:\n  level: loud\n"))
+	require.Error(t, err)
+	require.NotNil(t, shutdown)
+	assert.NoError(t, shutdown(ctx))
+
+	// A failed init leaves the middleware defaults unchanged.
+	shutdown, err = InitFromConfig(ctx, writeConfig(t, "otel.yaml", "middleware:\n  gin:\n    excluded_paths: [/healthz]\n"))
+	require.Error(t, err)
+	require.NotNil(t, shutdown)
+	assert.NoError(t, shutdown(ctx))
+	assert.Equal(t, before, defaults.GetMiddleware())
+}
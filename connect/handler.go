@@ -0,0 +1,34 @@
+// Package otelconnect instruments the connect-go services and clients with
+// the spans and rpc.* metrics of otelgrpc, for the Connect, gRPC and
+// gRPC-Web protocols.
+//
+// The handlers and clients of connect-go are HTTP handlers and clients, so
+// the package wraps them at the HTTP level and reads the status of the RPCs
+// from the protocol, without depending on connect-go.
+package otelconnect
+
+import (
+	"net/http"
+
+	"kgs/otel/internal/httprpc"
+	"kgs/otel/internal/killswitch"
+)
+
+// NewHandler wraps the handler of a connect-go service so each RPC it
+// serves is a server span, measured by the rpc.server.* metrics:
+//
+//	mux.Handle(otelconnect.NewHandler(userv1connect.NewUserServiceHandler(svc)))
+//
+// It takes the path returned by the generated constructors with the handler,
+// so both can be passed to mux.Handle.
+func NewHandler(path string, h http.Handler, opts ...Option) (string, http.Handler) {
+	return path, Handler(h, opts...)
+}
+
+// Handler wraps the handler of a connect-go service, like NewHandler.
+func Handler(h http.Handler, opts ...Option) http.Handler {
+	if killswitch.Disabled() {
+		return h
+	}
+	return httprpc.Handler(h, connectProtocol{}, httprpc.NewConfig(ScopeName, "server", opts))
+}
@@ -0,0 +1,87 @@
+package otelconnect
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// userService answers like a connect-go handler: GetUser succeeds,
+// DeleteUser fails with a Connect error, and the gRPC requests get their
+// status in the trailers.
+var userService = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.ReadAll(r.Body)
+	if protocol(r.Header) == protocolGRPC {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Status", "14")
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", "database down")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/DeleteUser") {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"code":"not_found","message":"no such user"}`)
+		return
+	}
+	_, _ = io.WriteString(w, `{"name":"ada"}`)
+})
+
+func TestHandler(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	path, h := NewHandler("/acme.user.v1.UserService/", userService,
+		WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))
+	assert.Equal(t, "/acme.user.v1.UserService/", path)
+
+	for _, tc := range []struct {
+		method, contentType string
+	}{
+		{"GetUser", "application/json"},
+		{"DeleteUser", "application/json"},
+		{"ListUsers", "application/grpc+proto"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/acme.user.v1.UserService/"+tc.method, strings.NewReader(`{"id":1}`))
+		req.Header.Set("Content-Type", tc.contentType)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	get, ok := rec.Span("acme.user.v1.UserService/GetUser")
+	require.True(t, ok)
+	assert.Equal(t, trace.SpanKindServer, get.SpanKind())
+	assert.Contains(t, get.Attributes(), semconv.RPCService("acme.user.v1.UserService"))
+	assert.Contains(t, get.Attributes(), semconv.RPCSystemKey.String("connect_rpc"))
+	assert.Contains(t, get.Attributes(), GRPCStatusCodeKey.Int(0))
+
+	del, ok := rec.Span("acme.user.v1.UserService/DeleteUser")
+	require.True(t, ok)
+	assert.Contains(t, del.Attributes(), GRPCStatusCodeKey.Int(5))
+	assert.Equal(t, codes.Unset, del.Status().Code, "NotFound is not a server error")
+
+	list, ok := rec.Span("acme.user.v1.UserService/ListUsers")
+	require.True(t, ok)
+	assert.Contains(t, list.Attributes(), semconv.RPCSystemKey.String("grpc"))
+	assert.Contains(t, list.Attributes(), GRPCStatusCodeKey.Int(14))
+	assert.Equal(t, codes.Error, list.Status().Code)
+	assert.Equal(t, "database down", list.Status().Description)
+
+	m, ok := rec.Metric(context.Background(), "rpc.server.duration")
+	require.True(t, ok)
+	assert.Equal(t, "ms", m.Unit)
+	oteltest.AssertHistogramCount(t, rec, "rpc.server.request.size", []attribute.KeyValue{
+		semconv.RPCService("acme.user.v1.UserService"),
+		semconv.RPCMethod("GetUser"),
+		semconv.RPCSystemKey.String("connect_rpc"),
+		GRPCStatusCodeKey.Int(0),
+	}, 1)
+}
@@ -0,0 +1,37 @@
+package otelconnect
+
+import (
+	"kgs/otel/internal/httprpc"
+)
+
+// Option applies an option value for the instrumentation.
+type Option = httprpc.Option
+
+// The options are shared by the instrumentations of the RPC frameworks
+// running over HTTP.
+var (
+	// WithTelemetry returns an Option to use the tracer provider, the meter
+	// provider and the propagator of the telemetry pipeline instead of the
+	// global ones.
+	WithTelemetry = httprpc.WithTelemetry
+	// WithTracerProvider returns an Option to use the tracer provider.
+	// If none is specified, the global provider is used.
+	WithTracerProvider = httprpc.WithTracerProvider
+	// WithMeterProvider returns an Option to use the meter provider.
+	// If none is specified, the global provider is used.
+	WithMeterProvider = httprpc.WithMeterProvider
+	// WithPropagators returns an Option to use the propagators.
+	// If none are specified, the global ones are used.
+	WithPropagators = httprpc.WithPropagators
+	// WithSpanAttributes returns an Option to add attributes to the spans.
+	WithSpanAttributes = httprpc.WithSpanAttributes
+	// WithMetricAttributes returns an Option to add attributes to every
+	// recorded datapoint.
+	WithMetricAttributes = httprpc.WithMetricAttributes
+	// WithDurationBuckets returns an Option to use explicit bucket
+	// boundaries (in milliseconds) for the RPC duration histogram.
+	WithDurationBuckets = httprpc.WithDurationBuckets
+	// WithSizeBuckets returns an Option to use explicit bucket boundaries
+	// (in bytes) for the request and response size histograms.
+	WithSizeBuckets = httprpc.WithSizeBuckets
+)
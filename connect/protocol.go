@@ -0,0 +1,140 @@
+package otelconnect
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kgs/otel/internal/httprpc"
+
+	grpcCodes "google.golang.org/grpc/codes"
+)
+
+const (
+	// ScopeName is the instrumentation scope name.
+	ScopeName = "kgs/otel/connect"
+	// GRPCStatusCodeKey is the numeric status code of the RPC, the same for
+	// the three protocols as the codes of Connect are the codes of gRPC.
+	GRPCStatusCodeKey = httprpc.StatusCodeKey
+)
+
+// The protocols served by connect-go, as rpc.system values.
+const (
+	protocolConnect = "connect_rpc"
+	protocolGRPC    = "grpc"
+	protocolGRPCWeb = "grpc_web"
+)
+
+// connectProtocol reads the RPCs of the Connect, gRPC and gRPC-Web
+// protocols.
+type connectProtocol struct{}
+
+// System returns the protocol of the request from its content type.
+func (connectProtocol) System(r *http.Request) string {
+	return protocol(r.Header)
+}
+
+func (connectProtocol) Procedure(r *http.Request) string {
+	return r.URL.Path
+}
+
+// KeepErrorBody keeps the error body of the unary Connect RPCs.
+func (connectProtocol) KeepErrorBody(system string, status int) bool {
+	return system == protocolConnect && status != http.StatusOK
+}
+
+func (connectProtocol) Status(system string, status int, header http.Header, body []byte) (grpcCodes.Code, string) {
+	return statusCode(system, status, header, body)
+}
+
+// protocol returns the protocol of the request from its content type.
+func protocol(h http.Header) string {
+	ct := h.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(ct, "application/grpc-web"):
+		return protocolGRPCWeb
+	case strings.HasPrefix(ct, "application/grpc"):
+		return protocolGRPC
+	default:
+		return protocolConnect
+	}
+}
+
+// connectCodes are the codes of the Connect protocol by name.
+var connectCodes = map[string]grpcCodes.Code{
+	"canceled":            grpcCodes.Canceled,
+	"unknown":             grpcCodes.Unknown,
+	"invalid_argument":    grpcCodes.InvalidArgument,
+	"deadline_exceeded":   grpcCodes.DeadlineExceeded,
+	"not_found":           grpcCodes.NotFound,
+	"already_exists":      grpcCodes.AlreadyExists,
+	"permission_denied":   grpcCodes.PermissionDenied,
+	"resource_exhausted":  grpcCodes.ResourceExhausted,
+	"failed_precondition": grpcCodes.FailedPrecondition,
+	"aborted":             grpcCodes.Aborted,
+	"out_of_range":        grpcCodes.OutOfRange,
+	"unimplemented":       grpcCodes.Unimplemented,
+	"internal":            grpcCodes.Internal,
+	"unavailable":         grpcCodes.Unavailable,
+	"data_loss":           grpcCodes.DataLoss,
+	"unauthenticated":     grpcCodes.Unauthenticated,
+}
+
+// statusCode returns the code and the message of the RPC from the HTTP
+// status, the headers and trailers, and the error body of the response.
+func statusCode(protocol string, status int, header http.Header, body []byte) (grpcCodes.Code, string) {
+	if protocol != protocolConnect {
+		s := header.Get("Grpc-Status")
+		if s == "" {
+			s = header.Get(http.TrailerPrefix + "Grpc-Status")
+		}
+		if s == "" {
+			// The trailers of gRPC-Web are at the end of the body.
+			return httpCode(status), ""
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil {
+			return grpcCodes.Unknown, ""
+		}
+		msg := header.Get("Grpc-Message")
+		if msg == "" {
+			msg = header.Get(http.TrailerPrefix + "Grpc-Message")
+		}
+		return grpcCodes.Code(code), msg
+	}
+	if status == http.StatusOK {
+		return grpcCodes.OK, ""
+	}
+	var connectErr struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &connectErr) == nil {
+		if code, ok := connectCodes[connectErr.Code]; ok {
+			return code, connectErr.Message
+		}
+	}
+	return httpCode(status), ""
+}
+
+// httpCode returns the code of an HTTP status without error details, as
+// the Connect protocol maps it.
+func httpCode(status int) grpcCodes.Code {
+	switch status {
+	case http.StatusOK:
+		return grpcCodes.OK
+	case http.StatusBadRequest:
+		return grpcCodes.Internal
+	case http.StatusUnauthorized:
+		return grpcCodes.Unauthenticated
+	case http.StatusForbidden:
+		return grpcCodes.PermissionDenied
+	case http.StatusNotFound:
+		return grpcCodes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return grpcCodes.Unavailable
+	default:
+		return grpcCodes.Unknown
+	}
+}
@@ -0,0 +1,23 @@
+package otelconnect
+
+import (
+	"net/http"
+
+	"kgs/otel/internal/httprpc"
+)
+
+// Transport is an http.RoundTripper making each RPC of a connect-go client
+// a client span, measured by the rpc.client.* metrics:
+//
+//	client := userv1connect.NewUserServiceClient(
+//		&http.Client{Transport: otelconnect.NewTransport(nil)}, baseURL)
+//
+// The span ends at the end of the response body, once the trailers are
+// received.
+type Transport = httprpc.Transport
+
+// NewTransport returns a Transport wrapping base, or http.DefaultTransport
+// if base is nil.
+func NewTransport(base http.RoundTripper, opts ...Option) *Transport {
+	return httprpc.NewTransport(base, connectProtocol{}, httprpc.NewConfig(ScopeName, "client", opts))
+}
@@ -0,0 +1,75 @@
+package otelconnect
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTransport(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	opts := []Option{
+		WithTracerProvider(rec.TracerProvider),
+		WithMeterProvider(rec.MeterProvider),
+		WithPropagators(propagation.TraceContext{}),
+	}
+	srv := httptest.NewServer(Handler(userService, opts...))
+	defer srv.Close()
+	client := &http.Client{Transport: NewTransport(nil, opts...)}
+
+	call := func(method, contentType string) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost,
+			srv.URL+"/acme.user.v1.UserService/"+method, strings.NewReader(`{"id":1}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_, _ = io.ReadAll(resp.Body)
+		require.NoError(t, resp.Body.Close())
+	}
+	call("DeleteUser", "application/json")
+	call("ListUsers", "application/grpc")
+
+	var clients, servers []string
+	for _, s := range rec.Spans() {
+		if s.SpanKind() == trace.SpanKindClient {
+			clients = append(clients, s.Name())
+		} else {
+			servers = append(servers, s.Name())
+		}
+	}
+	assert.ElementsMatch(t, clients, servers)
+
+	var clientSpan, serverParent trace.SpanContext
+	for _, s := range rec.Spans() {
+		if s.Name() != "acme.user.v1.UserService/DeleteUser" {
+			continue
+		}
+		assert.Contains(t, s.Attributes(), GRPCStatusCodeKey.Int(5))
+		if s.SpanKind() == trace.SpanKindClient {
+			clientSpan = s.SpanContext()
+			assert.Equal(t, codes.Error, s.Status().Code)
+			assert.Equal(t, "no such user", s.Status().Description)
+		} else {
+			serverParent = s.Parent()
+		}
+	}
+	assert.Equal(t, clientSpan.SpanID(), serverParent.SpanID())
+
+	for _, s := range rec.Spans() {
+		if s.Name() == "acme.user.v1.UserService/ListUsers" && s.SpanKind() == trace.SpanKindClient {
+			assert.Contains(t, s.Attributes(), GRPCStatusCodeKey.Int(14))
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Package otelcron instruments the jobs of a robfig/cron scheduler: each run
+// is a root span with the job name, the schedule and the outcome, measured
+// by duration, failure, skipped-run and overlapping-run metrics.
+//
+// The package only depends on the Run method of cron.Job, so it does not
+// import the scheduler.
+package otelcron
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ScopeName is the instrumentation scope name.
+	ScopeName = "kgs/otel/cron"
+
+	// JobNameKey is the name of the job.
+	JobNameKey = attribute.Key("cron.job.name")
+	// ScheduleKey is the schedule expression of the job, e.g. "*/5 * * * *".
+	ScheduleKey = attribute.Key("cron.job.schedule")
+	// OutcomeKey is the outcome of a run: success, failure, panic or skipped.
+	OutcomeKey = attribute.Key("cron.job.outcome")
+)
+
+// The outcomes of a run.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomePanic   = "panic"
+	OutcomeSkipped = "skipped"
+)
+
+// Job is a scheduled job, e.g. a cron.Job or a cron.FuncJob.
+type Job interface {
+	Run()
+}
+
+// JobFunc is a job function, failing if it returns an error.
+type JobFunc func(ctx context.Context) error
+
+// TracedJob is a job running each time in a root span.
+type TracedJob struct {
+	name  string
+	run   JobFunc
+	cfg   *config
+	attrs []attribute.KeyValue
+
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	failures metric.Int64Counter
+	skipped  metric.Int64Counter
+	overlaps metric.Int64Counter
+
+	running atomic.Int32
+}
+
+// NewJob returns a job running fn in a root span named after the job, for
+// the scheduler to run on the schedule. The schedule expression is only
+// recorded.
+//
+//	c.AddJob("@every 5m", otelcron.NewJob("cleanup", "@every 5m", cleanup))
+func NewJob(name, schedule string, fn JobFunc, opts ...Option) *TracedJob {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otel.GetMeterProvider()
+	}
+
+	j := &TracedJob{
+		name:   name,
+		run:    fn,
+		cfg:    cfg,
+		attrs:  []attribute.KeyValue{JobNameKey.String(name), ScheduleKey.String(schedule)},
+		tracer: cfg.TracerProvider.Tracer(ScopeName),
+	}
+	meter := cfg.MeterProvider.Meter(ScopeName)
+
+	var err error
+	// Measure the duration of the runs by outcome.
+	j.duration, err = meter.Float64Histogram("cron.job.duration",
+		metric.WithDescription("Measures the duration of the job runs."),
+		metric.WithUnit("ms"))
+	if err != nil {
+		otel.Handle(err)
+		if j.duration == nil {
+			j.duration = noop.Float64Histogram{}
+		}
+	}
+
+	// Count the runs returning an error or panicking.
+	j.failures, err = meter.Int64Counter("cron.job.failures",
+		metric.WithDescription("Counts the failed job runs."),
+		metric.WithUnit("{run}"))
+	if err != nil {
+		otel.Handle(err)
+		if j.failures == nil {
+			j.failures = noop.Int64Counter{}
+		}
+	}
+
+	// Count the runs skipped while the previous run is still running.
+	j.skipped, err = meter.Int64Counter("cron.job.skipped",
+		metric.WithDescription("Counts the job runs skipped while the previous run was still running."),
+		metric.WithUnit("{run}"))
+	if err != nil {
+		otel.Handle(err)
+		if j.skipped == nil {
+			j.skipped = noop.Int64Counter{}
+		}
+	}
+
+	// Count the runs started while the previous run is still running.
+	j.overlaps, err = meter.Int64Counter("cron.job.overlapping",
+		metric.WithDescription("Counts the job runs started while the previous run was still running."),
+		metric.WithUnit("{run}"))
+	if err != nil {
+		otel.Handle(err)
+		if j.overlaps == nil {
+			j.overlaps = noop.Int64Counter{}
+		}
+	}
+
+	return j
+}
+
+// Wrap returns the job running in a root span. A panic of the job is its
+// failure, and is propagated once recorded.
+//
+//	c.AddJob(spec, otelcron.Wrap("report", spec, cron.FuncJob(sendReport)))
+func Wrap(name, schedule string, job Job, opts ...Option) *TracedJob {
+	return NewJob(name, schedule, func(context.Context) error {
+		job.Run()
+		return nil
+	}, opts...)
+}
+
+// Run runs the job once, in a root span.
+func (j *TracedJob) Run() {
+	ctx := context.Background()
+	if j.running.Add(1) > 1 {
+		if j.cfg.SkipIfRunning {
+			j.running.Add(-1)
+			j.skipped.Add(ctx, 1, j.metricOption())
+			_, span := j.start(ctx)
+			span.SetAttributes(OutcomeKey.String(OutcomeSkipped))
+			span.AddEvent("previous run still running")
+			span.End()
+			return
+		}
+		j.overlaps.Add(ctx, 1, j.metricOption())
+	}
+	defer j.running.Add(-1)
+
+	ctx, span := j.start(ctx)
+	start := time.Now()
+	outcome := OutcomeSuccess
+	defer func() {
+		r := recover()
+		if r != nil {
+			err := fmt.Errorf("panic: %v", r)
+			span.RecordError(err, trace.WithStackTrace(true))
+			span.SetStatus(codes.Error, err.Error())
+			outcome = OutcomePanic
+		}
+		span.SetAttributes(OutcomeKey.String(outcome))
+		span.End()
+		j.record(ctx, outcome, float64(time.Since(start))/float64(time.Millisecond))
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	if err := j.run(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		outcome = OutcomeFailure
+	}
+}
+
+func (j *TracedJob) start(ctx context.Context) (context.Context, trace.Span) {
+	return j.tracer.Start(ctx, j.name,
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(j.attrs...),
+	)
+}
+
+// record records the metrics of a run.
+func (j *TracedJob) record(ctx context.Context, outcome string, elapsed float64) {
+	if outcome != OutcomeSuccess {
+		j.failures.Add(ctx, 1, j.metricOption())
+	}
+	attrs := append(j.metricAttrs(), OutcomeKey.String(outcome))
+	j.duration.Record(ctx, elapsed, metric.WithAttributeSet(attribute.NewSet(attrs...)))
+}
+
+func (j *TracedJob) metricAttrs() []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(j.cfg.MetricAttributes)+2)
+	attrs = append(attrs, j.attrs[0])
+	return append(attrs, j.cfg.MetricAttributes...)
+}
+
+func (j *TracedJob) metricOption() metric.AddOption {
+	return metric.WithAttributeSet(attribute.NewSet(j.metricAttrs()...))
+}
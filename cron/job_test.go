@@ -0,0 +1,117 @@
+package otelcron
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestJob(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	opts := []Option{WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider)}
+	fail := true
+	job := NewJob("cleanup", "@every 5m", func(ctx context.Context) error {
+		if fail {
+			return errors.New("disk full")
+		}
+		return nil
+	}, opts...)
+
+	job.Run()
+	fail = false
+	job.Run()
+
+	spans := rec.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "cleanup", spans[0].Name())
+	assert.False(t, spans[0].Parent().IsValid())
+	assert.Contains(t, spans[0].Attributes(), ScheduleKey.String("@every 5m"))
+	assert.Contains(t, spans[0].Attributes(), OutcomeKey.String(OutcomeFailure))
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Contains(t, spans[1].Attributes(), OutcomeKey.String(OutcomeSuccess))
+
+	oteltest.AssertSumValue(t, rec, "cron.job.failures", []attribute.KeyValue{JobNameKey.String("cleanup")}, int64(1))
+}
+
+func TestJobPanic(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	job := Wrap("report", "0 * * * *", jobFunc(func() { panic("boom") }),
+		WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))
+
+	assert.PanicsWithValue(t, "boom", job.Run)
+	span, ok := rec.Span("report")
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), OutcomeKey.String(OutcomePanic))
+	oteltest.AssertSumValue(t, rec, "cron.job.failures", []attribute.KeyValue{JobNameKey.String("report")}, int64(1))
+}
+
+func TestJobSkipIfStillRunning(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	started, release := make(chan struct{}), make(chan struct{})
+	runs := 0
+	job := NewJob("sync", "@every 1s", func(ctx context.Context) error {
+		runs++
+		close(started)
+		<-release
+		return nil
+	}, WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider), WithSkipIfStillRunning(true))
+
+	done := make(chan struct{})
+	go func() {
+		job.Run()
+		close(done)
+	}()
+	<-started
+	job.Run()
+	close(release)
+	<-done
+
+	assert.Equal(t, 1, runs)
+	spans := rec.Spans()
+	require.Len(t, spans, 2)
+	assert.Contains(t, spans[0].Attributes(), OutcomeKey.String(OutcomeSkipped))
+	oteltest.AssertSumValue(t, rec, "cron.job.skipped", []attribute.KeyValue{JobNameKey.String("sync")}, int64(1))
+}
+
+func TestJobOverlapping(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	started, release := make(chan struct{}), make(chan struct{})
+	var runs atomic.Int32
+	job := NewJob("sync", "@every 1s", func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return nil
+	}, WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))
+
+	done := make(chan struct{})
+	go func() {
+		job.Run()
+		close(done)
+	}()
+	<-started
+	job.Run()
+	close(release)
+	<-done
+
+	assert.Equal(t, int32(2), runs.Load())
+	for _, span := range rec.Spans() {
+		assert.Contains(t, span.Attributes(), OutcomeKey.String(OutcomeSuccess))
+	}
+	oteltest.AssertSumValue(t, rec, "cron.job.overlapping", []attribute.KeyValue{JobNameKey.String("sync")}, int64(1))
+	_, ok := rec.Metric(context.Background(), "cron.job.skipped")
+	assert.False(t, ok, "no run is skipped")
+}
+
+type jobFunc func()
+
+func (f jobFunc) Run() { f() }
@@ -0,0 +1,65 @@
+package otelcron
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// config is a group of options for the scheduled jobs.
+type config struct {
+	TracerProvider   trace.TracerProvider
+	MeterProvider    metric.MeterProvider
+	MetricAttributes []attribute.KeyValue
+	SkipIfRunning    bool
+}
+
+// Option applies an option value for a config.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithTracerProvider returns an Option to use the tracer provider.
+// If none is specified, the global provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.TracerProvider = provider
+		}
+	})
+}
+
+// WithMeterProvider returns an Option to use the meter provider.
+// If none is specified, the global provider is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.MeterProvider = provider
+		}
+	})
+}
+
+// WithMetricAttributes returns an Option to add attributes to every
+// recorded datapoint.
+func WithMetricAttributes(attrs ...attribute.KeyValue) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.MetricAttributes = attrs
+	})
+}
+
+// WithSkipIfStillRunning returns an Option to skip the runs starting while
+// the previous run of the job is still running, like the
+// cron.SkipIfStillRunning wrapper. The skipped runs are counted by
+// cron.job.skipped; without this option, they overlap the previous run and
+// are counted by cron.job.overlapping.
+func WithSkipIfStillRunning(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SkipIfRunning = enabled
+	})
+}
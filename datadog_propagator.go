@@ -0,0 +1,134 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The Datadog headers.
+const (
+	datadogTraceIDHeader  = "x-datadog-trace-id"
+	datadogParentIDHeader = "x-datadog-parent-id"
+	datadogPriorityHeader = "x-datadog-sampling-priority"
+	datadogTagsHeader     = "x-datadog-tags"
+
+	// datadogTraceIDHighTag holds the upper 64 bits of a 128-bit trace ID,
+	// in hex, in the x-datadog-tags header.
+	datadogTraceIDHighTag = "_dd.p.tid"
+)
+
+// DatadogMode selects the directions in which the Datadog headers are
+// propagated.
+type DatadogMode int
+
+const (
+	// DatadogExtract continues the traces of the incoming Datadog headers.
+	DatadogExtract DatadogMode = 1 << iota
+	// DatadogInject adds the Datadog headers to the outgoing requests.
+	DatadogInject
+
+	DatadogExtractInject = DatadogExtract | DatadogInject
+)
+
+// WithDatadogPropagation returns an Option to propagate the trace context in
+// the x-datadog-* headers too, so the traces stay connected with the
+// services instrumented by Datadog. When a request has both, the W3C
+// traceparent header wins.
+func WithDatadogPropagation(mode DatadogMode) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.DatadogPropagation = mode
+	})
+}
+
+// NewDatadogPropagator returns a propagator of the trace context in the
+// x-datadog-* headers. Datadog trace IDs are 64-bit: the upper half of the
+// trace ID is carried in the _dd.p.tid tag. A trace without sampling
+// priority is considered sampled, as the decision is left to the receiver.
+func NewDatadogPropagator(mode DatadogMode) propagation.TextMapPropagator {
+	return datadogPropagator{mode: mode}
+}
+
+type datadogPropagator struct {
+	mode DatadogMode
+}
+
+var _ propagation.TextMapPropagator = datadogPropagator{}
+
+// Inject sets the Datadog headers of the span context of ctx.
+func (p datadogPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if p.mode&DatadogInject == 0 || !sc.IsValid() {
+		return
+	}
+	traceID := sc.TraceID()
+	spanID := sc.SpanID()
+	carrier.Set(datadogTraceIDHeader, strconv.FormatUint(binary.BigEndian.Uint64(traceID[8:]), 10))
+	carrier.Set(datadogParentIDHeader, strconv.FormatUint(binary.BigEndian.Uint64(spanID[:]), 10))
+	if high := traceID[:8]; binary.BigEndian.Uint64(high) != 0 {
+		carrier.Set(datadogTagsHeader, datadogTraceIDHighTag+"="+hex.EncodeToString(high))
+	}
+	priority := "0"
+	if sc.IsSampled() {
+		priority = "1"
+	}
+	carrier.Set(datadogPriorityHeader, priority)
+}
+
+// Extract returns ctx with the remote span context of the Datadog headers.
+func (p datadogPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	if p.mode&DatadogExtract == 0 {
+		return ctx
+	}
+	low, err := strconv.ParseUint(carrier.Get(datadogTraceIDHeader), 10, 64)
+	if err != nil || low == 0 {
+		return ctx
+	}
+	parent, err := strconv.ParseUint(carrier.Get(datadogParentIDHeader), 10, 64)
+	if err != nil || parent == 0 {
+		return ctx
+	}
+
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(traceID[8:], low)
+	binary.BigEndian.PutUint64(spanID[:], parent)
+	if high, ok := datadogTraceIDHigh(carrier.Get(datadogTagsHeader)); ok {
+		copy(traceID[:8], high)
+	}
+
+	flags := trace.FlagsSampled
+	if priority, err := strconv.Atoi(carrier.Get(datadogPriorityHeader)); err == nil && priority <= 0 {
+		flags = 0
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
+
+// Fields returns the headers the propagator sets.
+func (p datadogPropagator) Fields() []string {
+	return []string{datadogTraceIDHeader, datadogParentIDHeader, datadogPriorityHeader, datadogTagsHeader}
+}
+
+// datadogTraceIDHigh returns the upper 64 bits of the trace ID from the
+// x-datadog-tags header, a comma-separated list of key=value.
+func datadogTraceIDHigh(tags string) ([]byte, bool) {
+	for _, tag := range strings.Split(tags, ",") {
+		k, v, ok := strings.Cut(tag, "=")
+		if !ok || strings.TrimSpace(k) != datadogTraceIDHighTag {
+			continue
+		}
+		high, err := hex.DecodeString(strings.TrimSpace(v))
+		return high, err == nil && len(high) == 8
+	}
+	return nil, false
+}
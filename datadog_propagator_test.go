@@ -0,0 +1,69 @@
+package kgsotel
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDatadogPropagatorRoundTrip(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+
+	p := NewDatadogPropagator(DatadogExtractInject)
+	header := http.Header{}
+	p.Inject(ctx, propagation.HeaderCarrier(header))
+	assert.Equal(t, "9532127138774266268", header.Get("x-datadog-trace-id"))
+	assert.Equal(t, "13235353014750950193", header.Get("x-datadog-parent-id"))
+	assert.Equal(t, "_dd.p.tid=0af7651916cd43dd", header.Get("x-datadog-tags"))
+	assert.Equal(t, "1", header.Get("x-datadog-sampling-priority"))
+
+	sc := trace.SpanContextFromContext(p.Extract(context.Background(), propagation.HeaderCarrier(header)))
+	assert.Equal(t, traceID, sc.TraceID())
+	assert.Equal(t, spanID, sc.SpanID())
+	assert.True(t, sc.IsSampled())
+	assert.True(t, sc.IsRemote())
+}
+
+func TestDatadogPropagatorExtract(t *testing.T) {
+	p := NewDatadogPropagator(DatadogExtract)
+	extract := func(h map[string]string) trace.SpanContext {
+		return trace.SpanContextFromContext(p.Extract(context.Background(), propagation.MapCarrier(h)))
+	}
+
+	sc := extract(map[string]string{"x-datadog-trace-id": "1", "x-datadog-parent-id": "2"})
+	assert.Equal(t, "00000000000000000000000000000001", sc.TraceID().String())
+	assert.Equal(t, "0000000000000002", sc.SpanID().String())
+	assert.True(t, sc.IsSampled())
+
+	sc = extract(map[string]string{"x-datadog-trace-id": "1", "x-datadog-parent-id": "2", "x-datadog-sampling-priority": "-1"})
+	assert.True(t, sc.IsValid())
+	assert.False(t, sc.IsSampled())
+
+	assert.False(t, extract(map[string]string{"x-datadog-trace-id": "1"}).IsValid())
+	assert.False(t, extract(map[string]string{"x-datadog-trace-id": "x", "x-datadog-parent-id": "2"}).IsValid())
+
+	// The propagator only extracts.
+	carrier := propagation.MapCarrier{}
+	p.Inject(trace.ContextWithSpanContext(context.Background(), sc), carrier)
+	assert.Empty(t, carrier)
+}
+
+func TestDatadogPropagationPrefersW3C(t *testing.T) {
+	p := newPropagator(newConfig(WithDatadogPropagation(DatadogExtract)))
+
+	carrier := propagation.MapCarrier{"x-datadog-trace-id": "1", "x-datadog-parent-id": "2"}
+	sc := trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+	assert.Equal(t, "0000000000000002", sc.SpanID().String())
+
+	carrier["traceparent"] = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	sc = trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+	assert.Equal(t, "b7ad6b7169203331", sc.SpanID().String())
+}
@@ -0,0 +1,117 @@
+package kgsotel
+
+import (
+	"context"
+	"sync/atomic"
+
+	"kgs/otel/internal/killswitch"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DebugBaggageKey is the baggage member flagging a request for debugging.
+const DebugBaggageKey = "kgs-debug"
+
+// ContextWithDebug returns a copy of ctx flagged for debugging: its logs are
+// written down to the debug level whatever the level of the global logger,
+// and its spans are sampled. The flag is a baggage member, so it is
+// propagated to the downstream services.
+func ContextWithDebug(ctx context.Context) context.Context {
+	m, err := baggage.NewMember(DebugBaggageKey, "1")
+	if err != nil {
+		return ctx
+	}
+	b, err := baggage.FromContext(ctx).SetMember(m)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// ContextWithoutDebug returns a copy of ctx without the debug flag, e.g. the
+// one propagated by an untrusted caller.
+func ContextWithoutDebug(ctx context.Context) context.Context {
+	b := baggage.FromContext(ctx)
+	if b.Member(DebugBaggageKey).Key() == "" {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, b.DeleteMember(DebugBaggageKey))
+}
+
+// DebugEnabled reports whether ctx is flagged for debugging.
+func DebugEnabled(ctx context.Context) bool {
+	switch baggage.FromContext(ctx).Member(DebugBaggageKey).Value() {
+	case "1", "true":
+		return true
+	default:
+		return false
+	}
+}
+
+// debugLogger is the global logger without the level, used for the
+// contexts flagged for debugging.
+var debugLogger atomic.Pointer[zap.Logger]
+
+func setDebugLogger(l *zap.Logger) {
+	debugLogger.Store(l)
+}
+
+// loggerFor returns the logger of the logs of ctx.
+func loggerFor(ctx context.Context) *zap.Logger {
+	if DebugEnabled(ctx) {
+		if l := debugLogger.Load(); l != nil {
+			return l
+		}
+	}
+	return zap.L()
+}
+
+// levelCore applies the level of the global logger to its cores, which
+// write all the levels; the logger of the debugged contexts skips it.
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// withoutLevel returns the logger without its level core, the outermost
+// core of the loggers of initLogger.
+func withoutLevel(l *zap.Logger) *zap.Logger {
+	return l.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		if lc, ok := c.(*levelCore); ok {
+			return lc.Core
+		}
+		return c
+	}))
+}
+
+// Debug logs the message at the debug level, like Info. The span event is
+// only added with WithSpanEventLevel(zapcore.DebugLevel).
+func Debug(ctx context.Context, message string, fields ...Field) {
+	if killswitch.Disabled() {
+		return
+	}
+	logger := loggerFor(ctx)
+	if !logger.Core().Enabled(zapcore.DebugLevel) {
+		return
+	}
+	span, zapFields := setSpanAttrsAndZapFields(ctx, fields...)
+	addSpanEvent(span, zapcore.DebugLevel, message)
+	logger.Debug(message, zapFields...)
+}
@@ -0,0 +1,79 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	lognoop "go.opentelemetry.io/otel/log/noop"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContextWithDebug(t *testing.T) {
+	restoreGlobals(t)
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := initLogger("svc", lognoop.NewLoggerProvider(), zap.NewAtomicLevelAt(zapcore.WarnLevel), loggerConfig{}, core)
+	zap.ReplaceGlobals(logger)
+	setDebugLogger(withoutLevel(logger))
+
+	ctx := context.Background()
+	Debug(ctx, "hidden")
+	Info(ctx, "hidden")
+	Warn(ctx, "shown")
+	assert.False(t, DebugEnabled(ctx))
+
+	ctx = ContextWithDebug(ctx)
+	assert.True(t, DebugEnabled(ctx))
+	Debug(ctx, "debugged")
+	FromContext(ctx).Info("debugged")
+
+	var messages []string
+	for _, e := range logs.All() {
+		messages = append(messages, e.Level.String()+" "+e.Message)
+	}
+	assert.Equal(t, []string{"warn shown", "debug debugged", "info debugged"}, messages)
+}
+
+func TestDebugForcesSampling(t *testing.T) {
+	s := (&dynamicSampler{}).with(sdktrace.NeverSample())
+	assert.Equal(t, sdktrace.Drop, s.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background()}).Decision)
+	assert.Equal(t, sdktrace.RecordAndSample, s.ShouldSample(sdktrace.SamplingParameters{ParentContext: ContextWithDebug(context.Background())}).Decision)
+}
+
+// countingCore counts the entries written through it.
+type countingCore struct {
+	zapcore.Core
+	n *int
+}
+
+func (c countingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	*c.n++
+	return c.Core.Check(ent, ce)
+}
+
+func TestContextWithDebugWrappedCore(t *testing.T) {
+	restoreGlobals(t)
+	core, logs := observer.New(zapcore.DebugLevel)
+	var n int
+	logger := initLogger("svc", lognoop.NewLoggerProvider(), zap.NewAtomicLevelAt(zapcore.WarnLevel), loggerConfig{
+		options: []zap.Option{zap.WrapCore(func(c zapcore.Core) zapcore.Core { return countingCore{Core: c, n: &n} })},
+	}, core)
+	zap.ReplaceGlobals(logger)
+	setDebugLogger(withoutLevel(logger))
+
+	Debug(context.Background(), "hidden")
+	Debug(ContextWithDebug(context.Background()), "debugged")
+	if assert.Equal(t, 1, logs.Len()) {
+		assert.Equal(t, "debugged", logs.All()[0].Message)
+	}
+	assert.Equal(t, 1, n, "the option wraps the cores")
+}
+
+func TestContextWithoutDebug(t *testing.T) {
+	ctx := ContextWithDebug(context.Background())
+	assert.False(t, DebugEnabled(ContextWithoutDebug(ctx)))
+	assert.Equal(t, context.Background(), ContextWithoutDebug(context.Background()))
+}
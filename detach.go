@@ -0,0 +1,15 @@
+package kgsotel
+
+import "context"
+
+// Detach returns a context keeping the span, the baggage and the other
+// values of ctx, but not its deadline and cancellation, for the work
+// continuing in the background after the response is sent:
+//
+//	go sendReceipt(kgsotel.Detach(c.Request.Context()), order)
+//
+// The spans started from it stay in the trace of the request, and are not
+// interrupted when the request ends. Bound the work with its own timeout.
+func Detach(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
@@ -0,0 +1,33 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDetach(t *testing.T) {
+	member, err := baggage.NewMember("tenant", "acme")
+	require.NoError(t, err)
+	bag, err := baggage.New(member)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(baggage.ContextWithBaggage(context.Background(), bag), time.Minute)
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(ctx, "request")
+	detached := Detach(ctx)
+	cancel()
+	span.End()
+
+	assert.Error(t, ctx.Err())
+	assert.NoError(t, detached.Err())
+	_, ok := detached.Deadline()
+	assert.False(t, ok)
+	assert.Equal(t, span.SpanContext(), trace.SpanContextFromContext(detached))
+	assert.Equal(t, "acme", baggage.FromContext(detached).Member("tenant").Value())
+}
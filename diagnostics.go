@@ -0,0 +1,336 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+)
+
+// modulePath is the module path of this package, used to find its build info.
+const modulePath = "kgs/otel"
+
+// diag is the health of the telemetry pipeline created by InitTelemetry.
+var diag = &pipelineStatus{}
+
+// pipelineStatus records the health of the exporters.
+type pipelineStatus struct {
+	conn    atomic.Pointer[grpc.ClientConn]
+	health  connHealth
+	breaker atomic.Pointer[circuitBreaker]
+
+	droppedSpans atomic.Int64
+	droppedLogs  atomic.Int64
+
+	logQueue  atomic.Pointer[asyncProcessor]
+	metrics   atomic.Pointer[pipelineMetrics]
+	readiness atomic.Pointer[readinessThresholds]
+
+	mu          sync.Mutex
+	lastErr     error
+	lastErrAt   time.Time
+	lastErrFrom Signal
+	failing     map[Signal]failureRun
+}
+
+// recordExportError stores the error of an export of n items of the signal.
+func (s *pipelineStatus) recordExportError(signal Signal, err error, n int) {
+	s.recordDropped(signal, n)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastErr = err
+	s.lastErrAt = time.Now()
+	s.lastErrFrom = signal
+
+	run, ok := s.failing[signal]
+	if !ok {
+		run.since = s.lastErrAt
+	}
+	run.last = s.lastErrAt
+	if s.failing == nil {
+		s.failing = make(map[Signal]failureRun)
+	}
+	s.failing[signal] = run
+}
+
+// recordExportSuccess ends the run of failed exports of the signal, unless
+// an error was recorded since start: the disk buffer records the failures
+// but hides them from the exporters.
+func (s *pipelineStatus) recordExportSuccess(signal Signal, start time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if run, ok := s.failing[signal]; ok && run.last.Before(start) {
+		delete(s.failing, signal)
+	}
+}
+
+// diagSpanExporter records the results of the exports of the wrapped exporter.
+type diagSpanExporter struct {
+	sdktrace.SpanExporter
+	status *pipelineStatus
+}
+
+// ExportSpans exports the spans and records the result.
+func (e diagSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.status.recordExport(ctx, SignalTraces, len(spans), float64(time.Since(start))/float64(time.Millisecond), err)
+	if err != nil {
+		e.status.recordExportError(SignalTraces, err, len(spans))
+	} else {
+		e.status.recordExportSuccess(SignalTraces, start)
+	}
+	return err
+}
+
+// diagMetricExporter records the results of the exports of the wrapped exporter.
+type diagMetricExporter struct {
+	sdkmetric.Exporter
+	status *pipelineStatus
+}
+
+// Export exports the metrics and records the result.
+func (e diagMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	e.status.recordExport(ctx, SignalMetrics, 0, float64(time.Since(start))/float64(time.Millisecond), err)
+	if err != nil {
+		e.status.recordExportError(SignalMetrics, err, 0)
+	} else {
+		e.status.recordExportSuccess(SignalMetrics, start)
+	}
+	return err
+}
+
+// diagLogExporter records the results of the exports of the wrapped exporter.
+type diagLogExporter struct {
+	sdklog.Exporter
+	status *pipelineStatus
+}
+
+// Export exports the log records and records the result.
+func (e diagLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.status.recordExport(ctx, SignalLogs, len(records), float64(time.Since(start))/float64(time.Millisecond), err)
+	if err != nil {
+		e.status.recordExportError(SignalLogs, err, len(records))
+	} else {
+		e.status.recordExportSuccess(SignalLogs, start)
+	}
+	return err
+}
+
+// Diagnostics is the snapshot of the telemetry health served by DiagnosticsHandler.
+type Diagnostics struct {
+	Collector       CollectorStatus `json:"collector"`
+	Health          HealthStatus    `json:"health"`
+	LastExportError *ExportError    `json:"last_export_error,omitempty"`
+	CircuitBreaker  string          `json:"circuit_breaker,omitempty"`
+	DroppedSpans    int64           `json:"dropped_spans"`
+	DroppedLogs     int64           `json:"dropped_logs"`
+	Sampler         string          `json:"sampler"`
+	LogLevel        string          `json:"log_level"`
+	Build           BuildInfo       `json:"build"`
+}
+
+// CollectorStatus is the state of the connection to the collector.
+type CollectorStatus struct {
+	Target string `json:"target,omitempty"`
+	State  string `json:"state"`
+}
+
+// ExportError is the last error returned by an exporter.
+type ExportError struct {
+	Signal  Signal    `json:"signal"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// BuildInfo is the build information of the kgsotel package.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"go_version"`
+}
+
+// GetDiagnostics returns a snapshot of the telemetry health.
+func GetDiagnostics() Diagnostics {
+	d := Diagnostics{
+		Collector:    CollectorStatus{State: "NOT_INITIALIZED"},
+		Health:       diag.health.snapshot(),
+		DroppedSpans: diag.droppedSpans.Load(),
+		DroppedLogs:  diag.droppedLogs.Load(),
+		Sampler:      SamplerDescription(),
+		LogLevel:     LogLevel().String(),
+		Build:        buildInfo(),
+	}
+
+	if conn := diag.conn.Load(); conn != nil {
+		d.Collector = CollectorStatus{
+			Target: conn.Target(),
+			State:  conn.GetState().String(),
+		}
+	}
+
+	if breaker := diag.breaker.Load(); breaker != nil {
+		d.CircuitBreaker = breaker.currentState().String()
+	}
+
+	diag.mu.Lock()
+	if diag.lastErr != nil {
+		d.LastExportError = &ExportError{
+			Signal:  diag.lastErrFrom,
+			Message: diag.lastErr.Error(),
+			Time:    diag.lastErrAt,
+		}
+	}
+	diag.mu.Unlock()
+
+	return d
+}
+
+// buildInfo returns the version of the kgsotel module linked in the binary.
+func buildInfo() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{Version: "unknown"}
+	}
+
+	bi := BuildInfo{Version: "unknown", GoVersion: info.GoVersion}
+	if info.Main.Path == modulePath {
+		bi.Version = info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			bi.Version = dep.Version
+		}
+	}
+	return bi
+}
+
+// DiagnosticsOption configures the handler returned by DiagnosticsHandler.
+type DiagnosticsOption func(*diagnosticsConfig)
+
+type diagnosticsConfig struct {
+	authorize func(*http.Request) bool
+}
+
+// AuthorizeUpdates returns a DiagnosticsOption to accept the PUT requests
+// changing the sampler and the log level for which authorize returns true,
+// e.g. checking a token or the source address. The other ones are answered
+// 403.
+func AuthorizeUpdates(authorize func(*http.Request) bool) DiagnosticsOption {
+	return func(cfg *diagnosticsConfig) {
+		cfg.authorize = authorize
+	}
+}
+
+// DiagnosticsHandler returns a handler serving the telemetry health as JSON.
+// It can be mounted on gin with gin.WrapH or served on a separate port with
+// ServeDiagnostics.
+//
+// With AuthorizeUpdates, a PUT request changes the sampler and log level at
+// runtime with the sample_ratio and log_level query parameters, e.g.
+// PUT /debug/telemetry?sample_ratio=1&log_level=debug. Without it, the
+// handler is read-only.
+func DiagnosticsHandler(opts ...DiagnosticsOption) http.Handler {
+	var cfg diagnosticsConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	allow := "GET"
+	if cfg.authorize != nil {
+		allow = "GET, PUT"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+		case r.Method == http.MethodPut && cfg.authorize != nil:
+			if !cfg.authorize(r) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			if err := applyRuntimeParams(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", allow)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(GetDiagnostics())
+	})
+}
+
+// applyRuntimeParams applies the sample_ratio and log_level query parameters.
+func applyRuntimeParams(r *http.Request) error {
+	query := r.URL.Query()
+
+	var (
+		ratio    float64
+		level    zapcore.Level
+		hasRatio = query.Has("sample_ratio")
+		hasLevel = query.Has("log_level")
+		err      error
+	)
+	if !hasRatio && !hasLevel {
+		return errors.New("sample_ratio or log_level is required")
+	}
+	if hasRatio {
+		if ratio, err = strconv.ParseFloat(query.Get("sample_ratio"), 64); err != nil || ratio < 0 || ratio > 1 {
+			return errors.New("sample_ratio must be a number between 0 and 1")
+		}
+	}
+	if hasLevel {
+		if level, err = zapcore.ParseLevel(query.Get("log_level")); err != nil {
+			return err
+		}
+	}
+
+	// Only apply the changes once all the parameters are valid.
+	if hasRatio {
+		SetSampleRatio(ratio)
+	}
+	if hasLevel {
+		SetLogLevel(level)
+	}
+	return nil
+}
+
+// ServeDiagnostics serves DiagnosticsHandler with the options on addr at
+// /debug/telemetry until ctx is done.
+func ServeDiagnostics(ctx context.Context, addr string, opts ...DiagnosticsOption) error {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/telemetry", DiagnosticsHandler(opts...))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
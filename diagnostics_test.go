@@ -0,0 +1,75 @@
+package kgsotel
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDiagnosticsHandler(t *testing.T) {
+	defer SetSampler(sdktrace.AlwaysSample())
+	defer SetLogLevel(LogLevel())
+
+	diag.recordExportError(SignalTraces, errors.New("unavailable"), 3)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/debug/telemetry?sample_ratio=0.5&log_level=warn", nil)
+	DiagnosticsHandler(AuthorizeUpdates(allowAll)).ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var d Diagnostics
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &d))
+	assert.GreaterOrEqual(t, d.DroppedSpans, int64(3))
+	require.NotNil(t, d.LastExportError)
+	assert.Equal(t, SignalTraces, d.LastExportError.Signal)
+	assert.Equal(t, "unavailable", d.LastExportError.Message)
+	assert.Contains(t, d.Sampler, "TraceIDRatioBased{0.5}")
+	assert.Equal(t, "warn", d.LogLevel)
+}
+
+func TestDiagnosticsHandlerInvalidParams(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/debug/telemetry?sample_ratio=2", nil)
+	DiagnosticsHandler(AuthorizeUpdates(allowAll)).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func allowAll(*http.Request) bool { return true }
+
+func TestDiagnosticsHandlerUpdates(t *testing.T) {
+	defer SetLogLevel(LogLevel())
+	level := LogLevel()
+
+	// The handler is read-only by default.
+	rec := httptest.NewRecorder()
+	DiagnosticsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/debug/telemetry?log_level=fatal", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET", rec.Header().Get("Allow"))
+	assert.Equal(t, level, LogLevel())
+
+	handler := DiagnosticsHandler(AuthorizeUpdates(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer admin"
+	}))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/debug/telemetry?log_level=fatal", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, level, LogLevel())
+
+	req := httptest.NewRequest(http.MethodPut, "/debug/telemetry?log_level=fatal", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, zapcore.FatalLevel, LogLevel())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/telemetry", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "the reads are not authorized")
+}
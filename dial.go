@@ -0,0 +1,74 @@
+package kgsotel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Dialer opens the connections to the collector.
+type Dialer func(ctx context.Context, addr string) (net.Conn, error)
+
+// WithDialer returns an Option to open the connections to the collector with
+// dialer, e.g. through a tunnel. The endpoint is passed to the dialer as is,
+// without resolving it, unless it has a scheme (e.g. dns:///). A collector
+// listening on a Unix socket does not need a dialer: use a unix:///path
+// endpoint.
+func WithDialer(dialer Dialer) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.Dialer = dialer
+	})
+}
+
+// isUnixEndpoint reports whether the endpoint is a Unix socket.
+func isUnixEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "unix:") || strings.HasPrefix(endpoint, "unix-abstract:")
+}
+
+// collectorTarget returns the gRPC target of the endpoint. The endpoints
+// reached through a dialer are passed to it as is.
+func collectorTarget(endpoint string, dialer Dialer) string {
+	if dialer != nil && !strings.Contains(endpoint, ":///") && !isUnixEndpoint(endpoint) {
+		return "passthrough:///" + endpoint
+	}
+	return endpoint
+}
+
+func initConn(otelUrl string, cfg *config) (*grpc.ClientConn, error) {
+	dialer := cfg.Dialer
+	proxy, err := collectorProxy(otelUrl, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("init conn: %w", err)
+	}
+	if proxy != nil {
+		dialer = proxyDialer(proxy, nil)
+	}
+
+	creds := insecure.NewCredentials()
+	if cfg.TLS != nil {
+		if creds, err = cfg.TLS.credentials(); err != nil {
+			return nil, fmt.Errorf("init conn: %w", err)
+		}
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if dialer != nil {
+		opts = append(opts, grpc.WithContextDialer(dialer))
+	}
+	if cfg.TokenSource != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(&tokenCredentials{src: cfg.TokenSource}))
+	}
+	opts = append(opts, cfg.DialOptions...)
+
+	// Create a new gRPC client connection
+	conn, err := grpc.NewClient(collectorTarget(otelUrl, dialer), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("init conn: %w", err)
+	}
+
+	return conn, nil
+}
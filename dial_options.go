@@ -0,0 +1,16 @@
+package kgsotel
+
+import (
+	"google.golang.org/grpc"
+)
+
+// WithCollectorDialOptions returns an Option to pass extra options to the
+// gRPC connection to the collector, e.g. keepalive parameters, a default
+// service config with a load-balancing policy, or client interceptors. They
+// are applied after the options of the package, so they can override them,
+// e.g. the transport credentials.
+func WithCollectorDialOptions(opts ...grpc.DialOption) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.DialOptions = append(cfg.DialOptions, opts...)
+	})
+}
@@ -0,0 +1,42 @@
+package kgsotel
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kgs/otel/otlptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+func TestWithCollectorDialOptions(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+
+	var calls atomic.Int32
+	interceptor := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		calls.Add(1)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	exportSpan(t, collector.Addr, WithCollectorDialOptions(grpc.WithChainUnaryInterceptor(interceptor)))
+	assert.Len(t, collector.Spans(), 1)
+	assert.Positive(t, calls.Load())
+}
+
+func TestWithCollectorDialOptionsServiceConfig(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+
+	exportSpan(t, collector.Addr, WithCollectorDialOptions(
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin": {}}]}`),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: time.Minute, Timeout: time.Second}),
+	))
+	assert.Len(t, collector.Spans(), 1)
+}
@@ -0,0 +1,52 @@
+package kgsotel
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kgs/otel/otlptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// exportSpan exports a span through a new pipeline sending to endpoint.
+func exportSpan(t *testing.T, endpoint string, opts ...Option) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tel, err := New(ctx, Config{ServiceName: "svc", Endpoint: endpoint, Options: opts})
+	require.NoError(t, err)
+	defer tel.Shutdown(ctx)
+
+	_, span := tel.TracerProvider().Tracer("test").Start(ctx, "op")
+	span.End()
+	require.NoError(t, tel.Flush(ctx))
+}
+
+func TestUnixSocketEndpoint(t *testing.T) {
+	collector, err := otlptest.NewCollector(otlptest.WithUnixSocket(filepath.Join(t.TempDir(), "otlp.sock")))
+	require.NoError(t, err)
+	defer collector.Close()
+
+	exportSpan(t, collector.Addr)
+	assert.Len(t, collector.Spans(), 1)
+}
+
+func TestWithDialer(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+
+	var dialed atomic.Value
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		dialed.Store(addr)
+		return (&net.Dialer{}).DialContext(ctx, "tcp", collector.Addr)
+	}
+	exportSpan(t, "collector.invalid:4317", WithDialer(dialer))
+	assert.Len(t, collector.Spans(), 1)
+	assert.Equal(t, "collector.invalid:4317", dialed.Load())
+}
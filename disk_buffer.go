@@ -0,0 +1,297 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kgs/otel/internal/otlpconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// diskQueueExt is the extension of the files holding a queued request.
+const diskQueueExt = ".pb"
+
+// diskQueueSendTimeout bounds the sending of each queued request.
+const diskQueueSendTimeout = 10 * time.Second
+
+// diskQueue is a bounded directory of serialized export requests. Each
+// request is written to its own file, and the oldest files are removed first
+// when the size limit is reached.
+type diskQueue struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	seq      uint64
+	closed   bool
+	draining atomic.Bool
+
+	// ctx is canceled by close to stop the background drain.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newDiskQueue(dir string, maxBytes int64) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create disk buffer: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &diskQueue{dir: dir, maxBytes: maxBytes, ctx: ctx, cancel: cancel}, nil
+}
+
+// push appends the request to the queue. The file is written under a
+// temporary name first, so a crash never leaves a partial request behind.
+func (q *diskQueue) push(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	name := filepath.Join(q.dir, fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), q.seq, diskQueueExt))
+	if err := os.WriteFile(name+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+
+	return q.trim()
+}
+
+// files returns the queued files, oldest first.
+func (q *diskQueue) files() ([]string, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), diskQueueExt) {
+			names = append(names, filepath.Join(q.dir, e.Name()))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// trim removes the oldest files until the queue fits in maxBytes.
+func (q *diskQueue) trim() error {
+	names, err := q.files()
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int64, len(names))
+	var total int64
+	for i, name := range names {
+		if info, err := os.Stat(name); err == nil {
+			sizes[i] = info.Size()
+			total += sizes[i]
+		}
+	}
+	for i := 0; total > q.maxBytes && i < len(names); i++ {
+		if err := os.Remove(names[i]); err == nil || errors.Is(err, os.ErrNotExist) {
+			total -= sizes[i]
+		}
+	}
+	return nil
+}
+
+// drain sends the queued requests oldest first, removing each one once sent.
+// The requests rejected for good by the collector are removed too, so they
+// do not block the ones behind them; the drain stops at the first transient
+// failure. Only one drain runs at a time.
+func (q *diskQueue) drain(send func([]byte) error) error {
+	if !q.draining.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer q.draining.Store(false)
+
+	q.mu.Lock()
+	names, err := q.files()
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if errors.Is(err, os.ErrNotExist) {
+			// Removed by trim in the meantime.
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := send(data); err != nil {
+			if !isPermanentExportError(err) {
+				return err
+			}
+			otel.Handle(fmt.Errorf("drop buffered request rejected by the collector: %w", err))
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// isPermanentExportError reports whether the collector rejected the request
+// itself, so sending it again fails the same way. The other errors, e.g.
+// Unavailable or an open circuit breaker, are transient.
+func isPermanentExportError(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.FailedPrecondition, codes.Unimplemented:
+		return true
+	default:
+		return false
+	}
+}
+
+// drainInBackground sends the queued requests in a goroutine, so the
+// export having reached the collector does not wait for the backlog. Each
+// request is sent with a timeout and the drain stops when the queue is
+// closed.
+func (q *diskQueue) drainInBackground(send func(context.Context, []byte) error) {
+	// The check and the Add happen under the lock of close, so close never
+	// waits while a drain is being added.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.draining.Load() {
+		return
+	}
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		_ = q.drain(func(data []byte) error {
+			ctx, cancel := context.WithTimeout(q.ctx, diskQueueSendTimeout)
+			defer cancel()
+			return send(ctx, data)
+		})
+	}()
+}
+
+// close stops the background drain and waits for it. The requests not sent
+// stay on disk for the next start.
+func (q *diskQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.cancel()
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// persist writes the request of a failed export to the queue. The export
+// error is recorded on the status but not returned, since the telemetry
+// is not lost.
+func persist(queue *diskQueue, status *pipelineStatus, signal Signal, req proto.Message, exportErr error) error {
+	data, err := proto.Marshal(req)
+	if err == nil {
+		err = queue.push(data)
+	}
+	if err != nil {
+		return errors.Join(exportErr, fmt.Errorf("buffer %s to disk: %w", signal, err))
+	}
+
+	status.recordExportError(signal, exportErr, 0)
+	return nil
+}
+
+// persistentTraceClient buffers the spans of the failed uploads on disk and
+// uploads them once the collector accepts requests again. The uploads
+// rejected by the circuit breaker are buffered too.
+type persistentTraceClient struct {
+	otlptrace.Client
+	queue   *diskQueue
+	status  *pipelineStatus
+	breaker *circuitBreaker
+}
+
+// UploadTraces uploads the spans, buffering them on disk on failure.
+func (c *persistentTraceClient) UploadTraces(ctx context.Context, spans []*tracepb.ResourceSpans) error {
+	err := c.breaker.do(func() error {
+		return c.Client.UploadTraces(ctx, spans)
+	})
+	if err != nil {
+		return persist(c.queue, c.status, SignalTraces, &coltracepb.ExportTraceServiceRequest{ResourceSpans: spans}, err)
+	}
+
+	// The collector is reachable, send the buffered spans.
+	c.queue.drainInBackground(func(ctx context.Context, data []byte) error {
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(data, req); err != nil {
+			// Skip the corrupted request instead of blocking the queue.
+			return nil
+		}
+		return c.breaker.do(func() error {
+			return c.Client.UploadTraces(ctx, req.ResourceSpans)
+		})
+	})
+	return nil
+}
+
+// Stop stops the drain of the buffered spans, then the client.
+func (c *persistentTraceClient) Stop(ctx context.Context) error {
+	c.queue.close()
+	return c.Client.Stop(ctx)
+}
+
+// persistentLogExporter buffers the records of the failed exports on disk
+// and sends them once the collector accepts requests again. The exports
+// rejected by the circuit breaker are buffered too.
+type persistentLogExporter struct {
+	sdklog.Exporter
+	client  collogspb.LogsServiceClient
+	headers metadata.MD
+	queue   *diskQueue
+	status  *pipelineStatus
+	breaker *circuitBreaker
+}
+
+// Export exports the records, buffering them on disk on failure.
+func (e *persistentLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	err := e.breaker.do(func() error {
+		return e.Exporter.Export(ctx, records)
+	})
+	if err != nil {
+		return persist(e.queue, e.status, SignalLogs, &collogspb.ExportLogsServiceRequest{ResourceLogs: otlpconv.ResourceLogs(records)}, err)
+	}
+
+	// The collector is reachable, send the buffered records.
+	e.queue.drainInBackground(func(ctx context.Context, data []byte) error {
+		req := &collogspb.ExportLogsServiceRequest{}
+		if err := proto.Unmarshal(data, req); err != nil {
+			// Skip the corrupted request instead of blocking the queue.
+			return nil
+		}
+		return e.breaker.do(func() error {
+			_, err := e.client.Export(metadata.NewOutgoingContext(ctx, e.headers), req)
+			return err
+		})
+	})
+	return nil
+}
+
+// Shutdown stops the drain of the buffered records, then the exporter.
+func (e *persistentLogExporter) Shutdown(ctx context.Context) error {
+	e.queue.close()
+	return e.Exporter.Shutdown(ctx)
+}
@@ -0,0 +1,240 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDiskQueue(t *testing.T) {
+	q, err := newDiskQueue(t.TempDir(), 8)
+	require.NoError(t, err)
+
+	require.NoError(t, q.push([]byte("aaaa")))
+	require.NoError(t, q.push([]byte("bbbb")))
+	// Exceeds the limit, the oldest request is dropped.
+	require.NoError(t, q.push([]byte("cccc")))
+
+	// A failed send keeps the request in the queue.
+	assert.Error(t, q.drain(func([]byte) error { return errors.New("unavailable") }))
+
+	var sent []string
+	require.NoError(t, q.drain(func(data []byte) error {
+		sent = append(sent, string(data))
+		return nil
+	}))
+	assert.Equal(t, []string{"bbbb", "cccc"}, sent)
+
+	names, err := q.files()
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+func TestDiskQueuePermanentError(t *testing.T) {
+	q, err := newDiskQueue(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	for _, data := range []string{"bad", "good", "later"} {
+		require.NoError(t, q.push([]byte(data)))
+	}
+
+	// The rejected request is dropped, the transient failure stops the drain.
+	var sent []string
+	assert.Error(t, q.drain(func(data []byte) error {
+		switch string(data) {
+		case "bad":
+			return status.Error(codes.InvalidArgument, "malformed request")
+		case "later":
+			return status.Error(codes.Unavailable, "unavailable")
+		}
+		sent = append(sent, string(data))
+		return nil
+	}))
+	assert.Equal(t, []string{"good"}, sent)
+
+	names, err := q.files()
+	require.NoError(t, err)
+	require.Len(t, names, 1)
+	data, err := os.ReadFile(names[0])
+	require.NoError(t, err)
+	assert.Equal(t, "later", string(data))
+}
+
+func TestDiskQueueCloseWhileDraining(t *testing.T) {
+	q, err := newDiskQueue(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	require.NoError(t, q.push([]byte("a")))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				q.drainInBackground(func(context.Context, []byte) error { return errors.New("unavailable") })
+			}
+		}()
+	}
+	q.close()
+	wg.Wait()
+	// No drain starts once closed.
+	q.drainInBackground(func(context.Context, []byte) error {
+		t.Error("drained after close")
+		return nil
+	})
+	q.close()
+}
+
+// flakyTraceClient fails the uploads while err is set.
+type flakyTraceClient struct {
+	mu      sync.Mutex
+	err     error
+	uploads [][]*tracepb.ResourceSpans
+}
+
+func (c *flakyTraceClient) Start(context.Context) error { return nil }
+func (c *flakyTraceClient) Stop(context.Context) error  { return nil }
+
+func (c *flakyTraceClient) UploadTraces(_ context.Context, spans []*tracepb.ResourceSpans) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.uploads = append(c.uploads, spans)
+	return nil
+}
+
+func (c *flakyTraceClient) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+func (c *flakyTraceClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.uploads)
+}
+
+func TestPersistentTraceClient(t *testing.T) {
+	ctx := context.Background()
+	queue, err := newDiskQueue(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	upstream := &flakyTraceClient{err: errors.New("unavailable")}
+	breaker := newCircuitBreaker(1, time.Millisecond)
+	client := &persistentTraceClient{upstream, queue, &pipelineStatus{}, breaker}
+	defer client.Stop(ctx)
+
+	spans := func(name string) []*tracepb.ResourceSpans {
+		return []*tracepb.ResourceSpans{{ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{Name: name}}}}}}
+	}
+	// The failed upload opens the circuit, the next one is rejected by the
+	// breaker: both are buffered instead of dropped.
+	require.NoError(t, client.UploadTraces(ctx, spans("failed")))
+	require.Equal(t, breakerOpen, breaker.currentState())
+	require.NoError(t, client.UploadTraces(ctx, spans("rejected")))
+	names, err := queue.files()
+	require.NoError(t, err)
+	assert.Len(t, names, 2)
+
+	// Once the collector recovers, the buffered spans are replayed in order.
+	upstream.setErr(nil)
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, client.UploadTraces(ctx, spans("recovered")))
+	require.Eventually(t, func() bool { return upstream.count() == 3 }, 5*time.Second, time.Millisecond)
+	upstream.mu.Lock()
+	var got []string
+	for _, u := range upstream.uploads {
+		got = append(got, u[0].ScopeSpans[0].Spans[0].Name)
+	}
+	upstream.mu.Unlock()
+	assert.Equal(t, []string{"recovered", "failed", "rejected"}, got)
+	require.Eventually(t, func() bool {
+		names, _ := queue.files()
+		return len(names) == 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+// flakyLogExporter fails the exports while err is set.
+type flakyLogExporter struct {
+	sdklog.Exporter
+	mu  sync.Mutex
+	err error
+}
+
+func (e *flakyLogExporter) Export(context.Context, []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+func (e *flakyLogExporter) Shutdown(context.Context) error { return nil }
+
+// recordingLogsClient records the replayed requests.
+type recordingLogsClient struct {
+	mu   sync.Mutex
+	reqs []*collogspb.ExportLogsServiceRequest
+}
+
+func (c *recordingLogsClient) Export(_ context.Context, req *collogspb.ExportLogsServiceRequest, _ ...grpc.CallOption) (*collogspb.ExportLogsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reqs = append(c.reqs, req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func (c *recordingLogsClient) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.reqs)
+}
+
+func TestPersistentLogExporter(t *testing.T) {
+	ctx := context.Background()
+	queue, err := newDiskQueue(t.TempDir(), 1<<20)
+	require.NoError(t, err)
+	upstream := &flakyLogExporter{err: errors.New("unavailable")}
+	replay := &recordingLogsClient{}
+	breaker := newCircuitBreaker(1, time.Millisecond)
+	exporter := &persistentLogExporter{
+		Exporter: upstream,
+		client:   replay,
+		queue:    queue,
+		status:   &pipelineStatus{},
+		breaker:  breaker,
+	}
+	defer exporter.Shutdown(ctx)
+
+	var record sdklog.Record
+	record.SetBody(log.StringValue("buffered"))
+	require.NoError(t, exporter.Export(ctx, []sdklog.Record{record}))
+	require.Equal(t, breakerOpen, breaker.currentState())
+	require.NoError(t, exporter.Export(ctx, []sdklog.Record{record}))
+	names, err := queue.files()
+	require.NoError(t, err)
+	assert.Len(t, names, 2)
+
+	upstream.mu.Lock()
+	upstream.err = nil
+	upstream.mu.Unlock()
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, exporter.Export(ctx, []sdklog.Record{record}))
+	require.Eventually(t, func() bool { return replay.count() == 2 }, 5*time.Second, time.Millisecond)
+	replay.mu.Lock()
+	defer replay.mu.Unlock()
+	body := replay.reqs[0].ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body.GetStringValue()
+	assert.Equal(t, "buffered", body)
+}
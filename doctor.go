@@ -0,0 +1,286 @@
+package kgsotel
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logpb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// doctorTimeout bounds each check of Doctor.
+const doctorTimeout = 5 * time.Second
+
+// doctorServiceName is the service.name of the test telemetry sent by Doctor.
+const doctorServiceName = "kgsotel-doctor"
+
+// DoctorCheck is the result of a step of the connectivity check.
+type DoctorCheck struct {
+	Name string
+	OK   bool
+	// Detail explains the result and, on failure, what to check.
+	Detail   string
+	Duration time.Duration
+}
+
+// DoctorReport is the result of Doctor.
+type DoctorReport struct {
+	Endpoint string
+	Checks   []DoctorCheck
+}
+
+// OK reports whether every check passed.
+func (r DoctorReport) OK() bool {
+	for _, c := range r.Checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the report, one check per line.
+func (r DoctorReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "collector %s\n", r.Endpoint)
+	for _, c := range r.Checks {
+		mark := "ok  "
+		if !c.OK {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(&b, "  [%s] %-8s %s (%s)\n", mark, c.Name, c.Detail, c.Duration.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// Doctor checks the connectivity to the collector at endpoint step by step:
+// DNS resolution, TCP connection, TLS negotiation, then the export of a test
+// span, metric and log record, and reports which step fails. The headers of
+// WithHeaders are sent with the exports, and the connection is opened with
+// the options of WithDialer, WithProxy, WithTLSFiles and
+// WithCollectorDialOptions.
+func Doctor(ctx context.Context, endpoint string, opts ...Option) DoctorReport {
+	cfg := newConfig(opts...)
+	report := DoctorReport{Endpoint: endpoint}
+	run := func(name string, check func(ctx context.Context) (string, error)) bool {
+		ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+		defer cancel()
+		start := time.Now()
+		detail, err := check(ctx)
+		if err != nil {
+			detail = err.Error()
+		}
+		report.Checks = append(report.Checks, DoctorCheck{Name: name, OK: err == nil, Detail: detail, Duration: time.Since(start)})
+		return err == nil
+	}
+
+	// The connections through a dialer or a Unix socket are only checked by
+	// the gRPC client.
+	proxy, err := collectorProxy(endpoint, cfg)
+	switch {
+	case err != nil:
+		run("proxy", func(context.Context) (string, error) { return "", err })
+		return report
+	case proxy != nil:
+		if !run("proxy", func(ctx context.Context) (string, error) {
+			conn, err := proxyDialer(proxy, nil)(ctx, endpoint)
+			if err != nil {
+				return "", fmt.Errorf("cannot open a tunnel through %s: %w", proxy.Redacted(), err)
+			}
+			conn.Close()
+			return "tunnel opened through " + proxy.Redacted(), nil
+		}) {
+			return report
+		}
+	case cfg.Dialer == nil && !isUnixEndpoint(endpoint):
+		if !doctorNetwork(endpoint, cfg.TLS != nil, run) {
+			return report
+		}
+	}
+
+	conn, err := initConn(endpoint, cfg)
+	if err != nil {
+		run("grpc", func(context.Context) (string, error) { return "", err })
+		return report
+	}
+	defer conn.Close()
+	if !run("grpc", func(ctx context.Context) (string, error) {
+		if err := probeConn(ctx, conn, doctorTimeout); err != nil {
+			return "", err
+		}
+		return "connection ready", nil
+	}) {
+		return report
+	}
+
+	ctx = metadata.NewOutgoingContext(ctx, metadata.New(cfg.Headers))
+	run(string(SignalTraces), func(ctx context.Context) (string, error) {
+		resp, err := coltracepb.NewTraceServiceClient(conn).Export(ctx, doctorTraces())
+		if err != nil {
+			return "", exportHint(SignalTraces, err)
+		}
+		if n := resp.GetPartialSuccess().GetRejectedSpans(); n > 0 {
+			return "", fmt.Errorf("the collector rejected %d span: %s", n, resp.GetPartialSuccess().GetErrorMessage())
+		}
+		return "test span accepted", nil
+	})
+	run(string(SignalMetrics), func(ctx context.Context) (string, error) {
+		resp, err := colmetricpb.NewMetricsServiceClient(conn).Export(ctx, doctorMetrics())
+		if err != nil {
+			return "", exportHint(SignalMetrics, err)
+		}
+		if n := resp.GetPartialSuccess().GetRejectedDataPoints(); n > 0 {
+			return "", fmt.Errorf("the collector rejected %d data point: %s", n, resp.GetPartialSuccess().GetErrorMessage())
+		}
+		return "test metric accepted", nil
+	})
+	run(string(SignalLogs), func(ctx context.Context) (string, error) {
+		resp, err := collogspb.NewLogsServiceClient(conn).Export(ctx, doctorLogs())
+		if err != nil {
+			return "", exportHint(SignalLogs, err)
+		}
+		if n := resp.GetPartialSuccess().GetRejectedLogRecords(); n > 0 {
+			return "", fmt.Errorf("the collector rejected %d log record: %s", n, resp.GetPartialSuccess().GetErrorMessage())
+		}
+		return "test log record accepted", nil
+	})
+	return report
+}
+
+// doctorNetwork checks the DNS resolution, the TCP connection and the TLS
+// negotiation with the collector at endpoint, and reports whether the
+// connection can be used. useTLS tells whether the pipeline uses TLS.
+func doctorNetwork(endpoint string, useTLS bool, run func(name string, check func(ctx context.Context) (string, error)) bool) bool {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return run("endpoint", func(context.Context) (string, error) {
+			return "", fmt.Errorf("invalid endpoint, expected host:port: %w", err)
+		})
+	}
+
+	ok := run("dns", func(ctx context.Context) (string, error) {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve %s: %w", host, err)
+		}
+		return "resolved to " + strings.Join(addrs, ", "), nil
+	})
+	ok = ok && run("tcp", func(ctx context.Context) (string, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return "", fmt.Errorf("cannot connect, check the collector is running and the port is open: %w", err)
+		}
+		conn.Close()
+		return "connected", nil
+	})
+	if !ok {
+		return false
+	}
+	run("tls", func(ctx context.Context) (string, error) {
+		conn, err := (&tls.Dialer{Config: &tls.Config{ServerName: host, InsecureSkipVerify: true}}).DialContext(ctx, "tcp", endpoint)
+		if err == nil {
+			conn.Close()
+		}
+		switch {
+		case err != nil && useTLS:
+			return "", fmt.Errorf("the pipeline uses TLS but the collector does not negotiate it: %w", err)
+		case err != nil:
+			return "the collector expects plaintext, which the pipeline uses", nil
+		case useTLS:
+			return "the collector negotiates TLS, which the pipeline uses", nil
+		default:
+			return "", errors.New("the collector expects TLS but the pipeline connects in plaintext")
+		}
+	})
+	return true
+}
+
+// exportHint explains the most common causes of the export error.
+func exportHint(signal Signal, err error) error {
+	switch status.Code(err) {
+	case codes.Unimplemented:
+		return fmt.Errorf("the collector has no OTLP %s pipeline: %w", signal, err)
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return fmt.Errorf("the collector refused the credentials, check the headers: %w", err)
+	case codes.Unavailable:
+		return fmt.Errorf("the collector is unavailable, check it speaks OTLP gRPC on this port: %w", err)
+	case codes.ResourceExhausted:
+		return fmt.Errorf("the collector is overloaded or rate limited: %w", err)
+	default:
+		return err
+	}
+}
+
+func doctorResource() *resourcepb.Resource {
+	return &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{
+		Key:   "service.name",
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: doctorServiceName}},
+	}}}
+}
+
+func doctorTraces() *coltracepb.ExportTraceServiceRequest {
+	traceID, spanID := make([]byte, 16), make([]byte, 8)
+	_, _ = rand.Read(traceID)
+	_, _ = rand.Read(spanID)
+	now := uint64(time.Now().UnixNano())
+	return &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+		Resource: doctorResource(),
+		ScopeSpans: []*tracepb.ScopeSpans{{
+			Scope: &commonpb.InstrumentationScope{Name: scopeName},
+			Spans: []*tracepb.Span{{
+				TraceId:           traceID,
+				SpanId:            spanID,
+				Name:              "kgsotel.doctor",
+				Kind:              tracepb.Span_SPAN_KIND_INTERNAL,
+				StartTimeUnixNano: now,
+				EndTimeUnixNano:   now,
+			}},
+		}},
+	}}}
+}
+
+func doctorMetrics() *colmetricpb.ExportMetricsServiceRequest {
+	return &colmetricpb.ExportMetricsServiceRequest{ResourceMetrics: []*metricpb.ResourceMetrics{{
+		Resource: doctorResource(),
+		ScopeMetrics: []*metricpb.ScopeMetrics{{
+			Scope: &commonpb.InstrumentationScope{Name: scopeName},
+			Metrics: []*metricpb.Metric{{
+				Name: "kgsotel.doctor",
+				Data: &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: []*metricpb.NumberDataPoint{{
+					TimeUnixNano: uint64(time.Now().UnixNano()),
+					Value:        &metricpb.NumberDataPoint_AsInt{AsInt: 1},
+				}}}},
+			}},
+		}},
+	}}}
+}
+
+func doctorLogs() *collogspb.ExportLogsServiceRequest {
+	return &collogspb.ExportLogsServiceRequest{ResourceLogs: []*logpb.ResourceLogs{{
+		Resource: doctorResource(),
+		ScopeLogs: []*logpb.ScopeLogs{{
+			Scope: &commonpb.InstrumentationScope{Name: scopeName},
+			LogRecords: []*logpb.LogRecord{{
+				TimeUnixNano:   uint64(time.Now().UnixNano()),
+				SeverityNumber: logpb.SeverityNumber_SEVERITY_NUMBER_INFO,
+				SeverityText:   "INFO",
+				Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "kgsotel doctor test record"}},
+			}},
+		}},
+	}}}
+}
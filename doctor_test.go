@@ -0,0 +1,70 @@
+package kgsotel
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"kgs/otel/otlptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDoctor(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+
+	report := Doctor(context.Background(), collector.Addr, WithHeaders(map[string]string{"api-key": "secret"}))
+	assert.True(t, report.OK(), report.String())
+
+	var names []string
+	for _, c := range report.Checks {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"dns", "tcp", "tls", "grpc", "traces", "metrics", "logs"}, names)
+	assert.Len(t, collector.Spans(), 1)
+	assert.Len(t, collector.Metrics(), 1)
+	assert.Len(t, collector.LogRecords(), 1)
+	for _, e := range collector.Exports() {
+		assert.Equal(t, []string{"secret"}, e.Metadata.Get("api-key"))
+	}
+}
+
+func TestDoctorExportError(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+	collector.SetError(status.Error(codes.Unauthenticated, "bad key"))
+
+	report := Doctor(context.Background(), collector.Addr)
+	require.False(t, report.OK())
+	last := report.Checks[len(report.Checks)-1]
+	assert.Equal(t, "logs", last.Name)
+	assert.False(t, last.OK)
+	assert.Contains(t, last.Detail, "check the headers")
+}
+
+func TestDoctorUnreachable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	report := Doctor(context.Background(), addr)
+	require.False(t, report.OK())
+	require.Len(t, report.Checks, 2)
+	assert.True(t, report.Checks[0].OK)
+	assert.Equal(t, "tcp", report.Checks[1].Name)
+	assert.False(t, report.Checks[1].OK)
+}
+
+func TestDoctorInvalidEndpoint(t *testing.T) {
+	report := Doctor(context.Background(), "localhost")
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "endpoint", report.Checks[0].Name)
+	assert.False(t, report.OK())
+}
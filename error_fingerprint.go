@@ -0,0 +1,81 @@
+package kgsotel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync/atomic"
+)
+
+// ErrorFingerprintKey is the field of the fingerprint of the errors logged
+// with Error.
+const ErrorFingerprintKey = "error.fingerprint"
+
+// WithErrorFingerprint returns an Option to add a fingerprint to the errors
+// logged with Error, as the error.fingerprint span attribute and log field.
+// The identical failures get the same fingerprint, for the backends which do
+// not group them: it hashes the type of the error, its message without the
+// IDs and numbers, and the function logging it.
+func WithErrorFingerprint(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ErrorFingerprint = enabled
+	})
+}
+
+// errorFingerprint is whether the global pipeline fingerprints the errors.
+var errorFingerprint atomic.Bool
+
+func setErrorFingerprint(v bool) {
+	errorFingerprint.Store(v)
+}
+
+// volatilePatterns match the parts of the messages which vary between the
+// occurrences of an error, most specific first.
+var volatilePatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>"},
+	{regexp.MustCompile(`\b(0x)?[0-9a-fA-F]{8,}\b`), "<hex>"},
+	{regexp.MustCompile(`\d+(\.\d+)?`), "<n>"},
+}
+
+// normalizeMessage replaces the IDs, quoted values and numbers of message.
+func normalizeMessage(message string) string {
+	for _, p := range volatilePatterns {
+		message = p.re.ReplaceAllString(message, p.repl)
+	}
+	return message
+}
+
+// fingerprint returns the fingerprint of the error logged with the message
+// and the fields by the function frame.
+func fingerprint(message string, fields []Field, frame string) string {
+	typ, text := "", message
+	for _, f := range fields {
+		if err, ok := f.Value.(error); ok {
+			// The innermost error is the cause, the others add context.
+			for inner := errors.Unwrap(err); inner != nil; inner = errors.Unwrap(err) {
+				err = inner
+			}
+			typ, text = fmt.Sprintf("%T", err), err.Error()
+			break
+		}
+	}
+	sum := sha256.Sum256([]byte(typ + "\x00" + normalizeMessage(text) + "\x00" + frame))
+	return hex.EncodeToString(sum[:8])
+}
+
+// withFingerprint returns the fields with the fingerprint of the error, if
+// enabled. skip is the number of frames above the logging function.
+func withFingerprint(message string, fields []Field, skip int) []Field {
+	if !errorFingerprint.Load() {
+		return fields
+	}
+	fp := fingerprint(message, fields, callerFuncName(skip+1))
+	return append(slices.Clip(fields), NewFiled(ErrorFingerprintKey, fp))
+}
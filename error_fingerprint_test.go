@@ -0,0 +1,48 @@
+package kgsotel
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	assert.Equal(t, "order <n> of user <str> not found (request <uuid>, trace <hex>)",
+		normalizeMessage(`order 1234 of user "ada" not found (request 3f2b8c1e-9d4a-4c7b-8e2f-1a2b3c4d5e6f, trace 4bf92f3577b34da6)`))
+}
+
+func TestErrorFingerprint(t *testing.T) {
+	restoreGlobals(t)
+	core, logs := observer.New(zap.ErrorLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	setErrorFingerprint(true)
+
+	ctx := context.Background()
+	for _, id := range []int{17, 42} {
+		err := fmt.Errorf("load order: %w", &fs.PathError{Op: "open", Path: fmt.Sprintf("/orders/%d", id), Err: fs.ErrNotExist})
+		Error(ctx, "failed", NewFiled("error", err))
+	}
+	// Another function logging the same error.
+	func() {
+		Error(ctx, "failed", NewFiled("error", &fs.PathError{Op: "open", Path: "/orders/1", Err: fs.ErrNotExist}))
+	}()
+	Error(ctx, "timeout after 30s")
+
+	entries := logs.All()
+	require.Len(t, entries, 4)
+	fp := func(i int) string { return entries[i].ContextMap()[ErrorFingerprintKey].(string) }
+	assert.Len(t, fp(0), 16)
+	assert.Equal(t, fp(0), fp(1), "the same failure")
+	assert.NotEqual(t, fp(0), fp(2), "logged by another function")
+	assert.NotEqual(t, fp(0), fp(3))
+
+	setErrorFingerprint(false)
+	Error(ctx, "failed")
+	assert.NotContains(t, logs.All()[4].ContextMap(), ErrorFingerprintKey)
+}
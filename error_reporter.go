@@ -0,0 +1,81 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorReport is an error logged with Error.
+type ErrorReport struct {
+	Message string
+	// Err is the first error of the fields, or the message as an error.
+	Err     error
+	TraceID trace.TraceID
+	SpanID  trace.SpanID
+	// Fields holds the other fields of the log.
+	Fields map[string]any
+}
+
+// ErrorReporter forwards the errors logged with Error to an error tracker,
+// e.g. Sentry. It is called synchronously, so it should not block. If it
+// also implements Flush(context.Context) error, it is flushed when the
+// telemetry is shut down.
+type ErrorReporter interface {
+	ReportError(ctx context.Context, report ErrorReport)
+}
+
+// ErrorReporterFunc is a function used as an ErrorReporter.
+type ErrorReporterFunc func(ctx context.Context, report ErrorReport)
+
+// ReportError calls f.
+func (f ErrorReporterFunc) ReportError(ctx context.Context, report ErrorReport) {
+	f(ctx, report)
+}
+
+// WithErrorReporter returns an Option to forward the errors logged with
+// Error to the reporter, with the trace and span IDs of the context.
+func WithErrorReporter(reporter ErrorReporter) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ErrorReporter = reporter
+	})
+}
+
+// errorReporterHolder wraps the reporter, which may be nil, for atomic.Pointer.
+type errorReporterHolder struct {
+	reporter ErrorReporter
+}
+
+// errorReporter is the reporter of the global pipeline.
+var errorReporter atomic.Pointer[errorReporterHolder]
+
+func setErrorReporter(r ErrorReporter) {
+	errorReporter.Store(&errorReporterHolder{reporter: r})
+}
+
+// reportError forwards the error logged with Error to the global reporter.
+func reportError(ctx context.Context, span trace.Span, message string, fields []Field) {
+	h := errorReporter.Load()
+	if h == nil || h.reporter == nil {
+		return
+	}
+	report := ErrorReport{
+		Message: message,
+		TraceID: span.SpanContext().TraceID(),
+		SpanID:  span.SpanContext().SpanID(),
+		Fields:  make(map[string]any, len(fields)),
+	}
+	for _, f := range fields {
+		if err, ok := f.Value.(error); ok && report.Err == nil {
+			report.Err = err
+			continue
+		}
+		report.Fields[f.Key] = f.Value
+	}
+	if report.Err == nil {
+		report.Err = errors.New(message)
+	}
+	h.reporter.ReportError(ctx, report)
+}
@@ -0,0 +1,62 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestErrorReporter(t *testing.T) {
+	var reports []ErrorReport
+	setErrorReporter(ErrorReporterFunc(func(_ context.Context, r ErrorReport) {
+		reports = append(reports, r)
+	}))
+	defer setErrorReporter(nil)
+
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	defer otel.SetTracerProvider(prev)
+
+	ctx, span := StartTrace(context.Background())
+	defer span.End()
+	boom := errors.New("boom")
+	Error(ctx, "charge failed", NewFiled("err", boom), NewFiled("order", 7))
+	Error(ctx, "no error field")
+	Warn(ctx, "not reported")
+
+	require.Len(t, reports, 2)
+	assert.Equal(t, "charge failed", reports[0].Message)
+	assert.Same(t, boom, reports[0].Err)
+	assert.Equal(t, span.SpanContext().TraceID(), reports[0].TraceID)
+	assert.Equal(t, span.SpanContext().SpanID(), reports[0].SpanID)
+	assert.Equal(t, map[string]any{"order": 7}, reports[0].Fields)
+	assert.EqualError(t, reports[1].Err, "no error field")
+}
+
+type flushingReporter struct {
+	flushed bool
+}
+
+func (r *flushingReporter) ReportError(context.Context, ErrorReport) {}
+
+func (r *flushingReporter) Flush(context.Context) error {
+	r.flushed = true
+	return nil
+}
+
+func TestErrorReporterFlushedOnShutdown(t *testing.T) {
+	ctx := context.Background()
+	reporter := &flushingReporter{}
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Options:     []Option{WithStdoutExporters(true), WithErrorReporter(reporter)},
+	})
+	require.NoError(t, err)
+	require.NoError(t, tel.Shutdown(ctx))
+	assert.True(t, reporter.flushed)
+}
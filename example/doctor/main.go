@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	kgsotel "kgs/otel"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", "localhost:4317", "collector OTLP gRPC endpoint")
+	headers := flag.String("headers", "", "comma separated key=value headers sent with the exports")
+	flag.Parse()
+
+	h := make(map[string]string)
+	for _, kv := range strings.Split(*headers, ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			h[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	report := kgsotel.Doctor(context.Background(), *endpoint, kgsotel.WithHeaders(h))
+	fmt.Print(report)
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,43 @@
+package kgsotel
+
+import (
+	"context"
+	"os"
+
+	"kgs/otel/internal/killswitch"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// exit terminates the process, replaced in the tests.
+var exit = os.Exit
+
+// noExitHook writes the fatal logs without exiting, so the telemetry can be
+// flushed first.
+type noExitHook struct{}
+
+func (noExitHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {}
+
+// Fatal logs the message at the fatal level like Error, marks the span of
+// the context as failed and ends it, flushes the traces, metrics and logs of
+// the global pipeline within the shutdown timeout, then exits with status 1.
+// Unlike zap.L().Fatal, the batched telemetry is exported before the process
+// dies.
+func Fatal(ctx context.Context, message string, fields ...Field) {
+	if killswitch.Disabled() {
+		zap.L().Fatal(message, plainZapFields(withErrorFields(fields))...)
+		return
+	}
+	fields = withFingerprint(message, withErrorFields(fields), 2)
+	span, zapFields := setSpanAttrsAndZapFields(ctx, fields...)
+	addSpanEvent(span, zapcore.FatalLevel, message)
+	span.SetStatus(codes.Error, message)
+	span.End()
+	loggerFor(ctx).WithOptions(zap.WithFatalHook(noExitHook{})).Fatal(message, zapFields...)
+	reportError(ctx, span, message, fields)
+
+	flushGlobal(ctx)
+	exit(1)
+}
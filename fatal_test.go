@@ -0,0 +1,53 @@
+package kgsotel
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFatal(t *testing.T) {
+	restoreGlobals(t)
+	core, logs := observer.New(zap.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+
+	ctx := context.Background()
+	reporter := &flushingReporter{}
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Options:     []Option{WithStdoutExporters(true), WithErrorReporter(reporter)},
+	})
+	require.NoError(t, err)
+	defer tel.Shutdown(ctx)
+	current.Store(tel)
+	defer current.Store(nil)
+
+	var code int
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	recorder := tracetest.NewSpanRecorder()
+	ctx, _ = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(ctx, "main")
+	Fatal(ctx, "config missing", NewFiled("path", "/etc/app.yaml"))
+
+	assert.Equal(t, 1, code)
+	assert.True(t, reporter.flushed)
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.FatalLevel, entries[0].Level)
+	assert.Equal(t, "/etc/app.yaml", entries[0].ContextMap()["path"])
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, "config missing", spans[0].Status().Description)
+}
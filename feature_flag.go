@@ -0,0 +1,57 @@
+package kgsotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The feature flag event and the attributes which are not part of the
+// semantic conventions of the package.
+const (
+	featureFlagEvent     = "feature_flag"
+	FeatureFlagReasonKey = attribute.Key("feature_flag.evaluation.reason")
+	FeatureFlagErrorKey  = attribute.Key("feature_flag.evaluation.error.message")
+)
+
+// FlagEvaluation is the result of the evaluation of a feature flag.
+type FlagEvaluation struct {
+	// Key is the key of the flag.
+	Key string
+	// Variant is the variant served, e.g. "on" or "blue".
+	Variant string
+	// ProviderName is the name of the feature flag provider.
+	ProviderName string
+	// Reason is why the variant was served, e.g. "TARGETING_MATCH", if known.
+	Reason string
+	// Err is the error of the evaluation, if any.
+	Err error
+}
+
+// RecordFlagEvaluation adds the evaluation as a feature_flag event to the
+// span of ctx, following the semantic conventions of the feature flags, so
+// the behavior changes can be correlated with the flag rollouts in the
+// traces. It is meant to be called from the after hook of a feature flag
+// SDK, e.g. an OpenFeature hook.
+func RecordFlagEvaluation(ctx context.Context, ev FlagEvaluation) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	attrs := []attribute.KeyValue{semconv.FeatureFlagKey(ev.Key)}
+	if ev.ProviderName != "" {
+		attrs = append(attrs, semconv.FeatureFlagProviderName(ev.ProviderName))
+	}
+	if ev.Variant != "" {
+		attrs = append(attrs, semconv.FeatureFlagVariant(ev.Variant))
+	}
+	if ev.Reason != "" {
+		attrs = append(attrs, FeatureFlagReasonKey.String(ev.Reason))
+	}
+	if ev.Err != nil {
+		attrs = append(attrs, FeatureFlagErrorKey.String(ev.Err.Error()))
+	}
+	span.AddEvent(featureFlagEvent, trace.WithAttributes(attrs...))
+}
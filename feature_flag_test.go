@@ -0,0 +1,37 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecordFlagEvaluation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(context.Background(), "checkout")
+	RecordFlagEvaluation(ctx, FlagEvaluation{Key: "new-checkout", Variant: "on", ProviderName: "flagd", Reason: "TARGETING_MATCH"})
+	RecordFlagEvaluation(ctx, FlagEvaluation{Key: "dark-mode", Err: errors.New("flag not found")})
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	events := spans[0].Events()
+	require.Len(t, events, 2)
+	assert.Equal(t, "feature_flag", events[0].Name)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("feature_flag.key", "new-checkout"),
+		attribute.String("feature_flag.provider_name", "flagd"),
+		attribute.String("feature_flag.variant", "on"),
+		FeatureFlagReasonKey.String("TARGETING_MATCH"),
+	}, events[0].Attributes)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("feature_flag.key", "dark-mode"),
+		FeatureFlagErrorKey.String("flag not found"),
+	}, events[1].Attributes)
+}
@@ -0,0 +1,127 @@
+// Package otelflags registers the standard telemetry flags of the command
+// line services and turns them into InitTelemetry options, so every CLI
+// names and wires them the same way. It works with the flag package and
+// with pflag, whose flag sets cobra commands return.
+package otelflags
+
+import (
+	"context"
+	"fmt"
+	kgsotel "kgs/otel"
+	"os"
+	"strconv"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FlagSet is the part of a *flag.FlagSet or a *pflag.FlagSet, e.g.
+// cmd.PersistentFlags() of a cobra command, the flags are registered with.
+type FlagSet interface {
+	StringVar(p *string, name string, value string, usage string)
+	BoolVar(p *bool, name string, value bool, usage string)
+}
+
+// The names of the flags.
+const (
+	FlagServiceName    = "otel-service-name"
+	FlagServiceVersion = "otel-service-version"
+	FlagEndpoint       = "otel-endpoint"
+	FlagEnvironment    = "otel-environment"
+	FlagSampleRatio    = "otel-sample-ratio"
+	FlagInsecure       = "otel-insecure"
+	FlagTLSCA          = "otel-tls-ca"
+	FlagTLSCert        = "otel-tls-cert"
+	FlagTLSKey         = "otel-tls-key"
+	FlagDisabled       = "otel-disabled"
+	FlagLogLevel       = "log-level"
+)
+
+// Flags holds the values of the telemetry flags.
+type Flags struct {
+	ServiceName    string
+	ServiceVersion string
+	Endpoint       string
+	Environment    string
+	SampleRatio    string
+	Insecure       bool
+	TLSCA          string
+	TLSCert        string
+	TLSKey         string
+	Disabled       bool
+	LogLevel       string
+}
+
+// Register registers the telemetry flags with fs, the service name
+// defaulting to serviceName, and returns their values once parsed:
+//
+//	tf := otelflags.Register(cmd.PersistentFlags(), "billing-cli")
+//	...
+//	shutdown, err := tf.Init(ctx)
+//
+// The service name and the endpoint default to the KGSOTEL_SERVICE_NAME and
+// KGSOTEL_ENDPOINT environment variables when set. The sample ratio, the
+// environment and the log level are left to the environment preset unless
+// given.
+func Register(fs FlagSet, serviceName string) *Flags {
+	f := &Flags{}
+	if v, ok := os.LookupEnv("KGSOTEL_SERVICE_NAME"); ok {
+		serviceName = v
+	}
+	fs.StringVar(&f.ServiceName, FlagServiceName, serviceName, "name of the service in the telemetry")
+	fs.StringVar(&f.ServiceVersion, FlagServiceVersion, "", "version of the service in the telemetry")
+	fs.StringVar(&f.Endpoint, FlagEndpoint, os.Getenv("KGSOTEL_ENDPOINT"), "address of the OpenTelemetry collector, e.g. otel-collector:4317")
+	fs.StringVar(&f.Environment, FlagEnvironment, "", "environment preset of the telemetry: dev, staging or prod")
+	fs.StringVar(&f.SampleRatio, FlagSampleRatio, "", "fraction of the root spans to sample, from 0 to 1")
+	fs.BoolVar(&f.Insecure, FlagInsecure, true, "connect to the collector without TLS")
+	fs.StringVar(&f.TLSCA, FlagTLSCA, "", "CA file verifying the collector certificate, the system CAs if empty")
+	fs.StringVar(&f.TLSCert, FlagTLSCert, "", "client certificate file for mutual TLS with the collector")
+	fs.StringVar(&f.TLSKey, FlagTLSKey, "", "client key file for mutual TLS with the collector")
+	fs.BoolVar(&f.Disabled, FlagDisabled, false, "turn the telemetry into no-ops")
+	fs.StringVar(&f.LogLevel, FlagLogLevel, "", "minimum level of the logs: debug, info, warn or error")
+	return f
+}
+
+// Options converts the values of the flags into InitTelemetry options.
+func (f *Flags) Options() ([]kgsotel.Option, error) {
+	var opts []kgsotel.Option
+	if f.Environment != "" {
+		opts = append(opts, kgsotel.WithEnvironment(kgsotel.Environment(f.Environment)))
+	}
+	if f.ServiceVersion != "" {
+		opts = append(opts, kgsotel.WithServiceVersion(f.ServiceVersion))
+	}
+	if f.SampleRatio != "" {
+		ratio, err := strconv.ParseFloat(f.SampleRatio, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid --%s %q: must be a number from 0 to 1", FlagSampleRatio, f.SampleRatio)
+		}
+		opts = append(opts, kgsotel.WithSampleRatio(ratio))
+	}
+	if !f.Insecure || f.TLSCA != "" || f.TLSCert != "" {
+		if (f.TLSCert == "") != (f.TLSKey == "") {
+			return nil, fmt.Errorf("--%s and --%s must be set together", FlagTLSCert, FlagTLSKey)
+		}
+		opts = append(opts, kgsotel.WithTLSFiles(f.TLSCert, f.TLSKey, f.TLSCA))
+	}
+	if f.Disabled {
+		opts = append(opts, kgsotel.WithDisabled(true))
+	}
+	if f.LogLevel != "" {
+		level, err := zapcore.ParseLevel(f.LogLevel)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", FlagLogLevel, err)
+		}
+		opts = append(opts, kgsotel.WithLogLevel(level))
+	}
+	return opts, nil
+}
+
+// Init initializes the telemetry with the values of the flags. Extra
+// options are applied after the ones of the flags.
+func (f *Flags) Init(ctx context.Context, opts ...kgsotel.Option) (shutdown func(context.Context) error, err error) {
+	flagOpts, err := f.Options()
+	if err != nil {
+		return func(context.Context) error { return nil }, err
+	}
+	return kgsotel.InitTelemetry(ctx, f.ServiceName, f.Endpoint, append(flagOpts, opts...)...)
+}
@@ -0,0 +1,49 @@
+package otelflags
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	t.Setenv("KGSOTEL_ENDPOINT", "collector:4317")
+	fs := flag.NewFlagSet("cli", flag.ContinueOnError)
+	f := Register(fs, "billing-cli")
+
+	require.NoError(t, fs.Parse([]string{"--otel-sample-ratio=0.25", "--log-level=warn", "--otel-insecure=false"}))
+	assert.Equal(t, "billing-cli", f.ServiceName)
+	assert.Equal(t, "collector:4317", f.Endpoint)
+	assert.False(t, f.Insecure)
+
+	opts, err := f.Options()
+	require.NoError(t, err)
+	assert.Len(t, opts, 3, "sample ratio, TLS and log level")
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	fs := flag.NewFlagSet("cli", flag.ContinueOnError)
+	f := Register(fs, "billing-cli")
+	require.NoError(t, fs.Parse(nil))
+
+	opts, err := f.Options()
+	require.NoError(t, err)
+	assert.Empty(t, opts, "the environment preset decides")
+}
+
+func TestOptionsErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"--otel-sample-ratio=2"},
+		{"--otel-sample-ratio=half"},
+		{"--log-level=loud"},
+		{"--otel-tls-cert=client.pem"},
+	} {
+		fs := flag.NewFlagSet("cli", flag.ContinueOnError)
+		f := Register(fs, "billing-cli")
+		require.NoError(t, fs.Parse(args))
+		_, err := f.Options()
+		assert.Error(t, err, args)
+	}
+}
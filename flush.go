@@ -0,0 +1,19 @@
+package kgsotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// FlushMetrics collects and exports all the metrics recorded so far,
+// without waiting for the next export interval. It is meant for short-lived
+// batch jobs that need to push their final datapoints before exiting.
+// It is a no-op if the global meter provider is not an SDK provider.
+func FlushMetrics(ctx context.Context) error {
+	if sdkMP, ok := otel.GetMeterProvider().(*sdkmetric.MeterProvider); ok {
+		return sdkMP.ForceFlush(ctx)
+	}
+	return nil
+}
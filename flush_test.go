@@ -0,0 +1,78 @@
+package kgsotel
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// recordingExporter records the names of the exported metrics.
+type recordingExporter struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (e *recordingExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (e *recordingExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (e *recordingExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			e.names = append(e.names, m.Name)
+		}
+	}
+	return nil
+}
+
+func (e *recordingExporter) ForceFlush(context.Context) error { return nil }
+func (e *recordingExporter) Shutdown(context.Context) error   { return nil }
+
+func TestFlushMetrics(t *testing.T) {
+	ctx := context.Background()
+	exporter := &recordingExporter{}
+	// The periodic export would not happen before the end of the test.
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(time.Hour))))
+	defer mp.Shutdown(ctx)
+	global := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	t.Cleanup(func() { otel.SetMeterProvider(global) })
+
+	processed, err := otel.Meter("test").Int64Counter("job.processed")
+	require.NoError(t, err)
+	processed.Add(ctx, 42)
+	require.NoError(t, FlushMetrics(ctx))
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	assert.Contains(t, exporter.names, "job.processed")
+}
+
+func TestEnvironmentMetricInterval(t *testing.T) {
+	opts := []Option{
+		WithMetricInterval(time.Minute),
+		WithEnvironmentMetricInterval(EnvironmentStaging, 10*time.Second),
+		WithEnvironmentMetricInterval(EnvironmentProd, 30*time.Second),
+	}
+
+	t.Setenv(envEnvironment, "staging")
+	assert.Equal(t, 10*time.Second, newConfig(opts...).MetricInterval)
+	assert.Equal(t, 30*time.Second, newConfig(append(opts, WithEnvironment(EnvironmentProd))...).MetricInterval,
+		"the environment option overrides the variable")
+
+	t.Setenv(envEnvironment, "qa")
+	assert.Equal(t, time.Minute, newConfig(opts...).MetricInterval, "no interval for the environment")
+}
@@ -0,0 +1,217 @@
+package kgsotel
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// gelfChunkSize is the maximum size of a GELF UDP datagram.
+	gelfChunkSize = 8192
+	// gelfMaxChunks is the maximum number of chunks of a GELF message.
+	gelfMaxChunks = 128
+	// gelfChunkHeaderSize is the size of the header of a chunk: magic bytes,
+	// message ID, sequence number and count.
+	gelfChunkHeaderSize = 12
+)
+
+// gelfFieldNames maps the trace correlation fields of the log helpers to
+// their GELF names.
+var gelfFieldNames = map[string]string{
+	"traceID": "trace_id",
+	"spanID":  "span_id",
+}
+
+// gelfInvalidChars matches the characters not allowed in a GELF field name.
+var gelfInvalidChars = regexp.MustCompile(`[^\w.\-]`)
+
+// WithGELF returns an Option to also send the logs to a Graylog server at
+// addr in GELF 1.1, over "udp" or "tcp". The fields of the logs become GELF
+// additional fields, the trace and span IDs as _trace_id and _span_id. The
+// UDP messages larger than a datagram are chunked. The connection is opened
+// by the first log, an unreachable server drops the logs without failing the
+// initialization.
+func WithGELF(network, addr string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LogCores = append(cfg.LogCores, func(serviceName string, level zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+			w, err := newGELFWriter(network, addr)
+			if err != nil {
+				return nil, nil, err
+			}
+			return newGELFCore(serviceName, level, w), w.Close, nil
+		})
+	})
+}
+
+// gelfCore is a zap core writing the entries as GELF messages.
+type gelfCore struct {
+	zapcore.LevelEnabler
+	host    string
+	service string
+	fields  []zapcore.Field
+	w       *gelfWriter
+}
+
+func newGELFCore(serviceName string, level zapcore.LevelEnabler, w *gelfWriter) *gelfCore {
+	host, _ := os.Hostname()
+	return &gelfCore{LevelEnabler: level, host: host, service: serviceName, w: w}
+}
+
+func (c *gelfCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *gelfCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *gelfCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	data, err := json.Marshal(c.message(ent, fields))
+	if err != nil {
+		return fmt.Errorf("encode gelf message: %w", err)
+	}
+	return c.w.write(data)
+}
+
+func (c *gelfCore) Sync() error {
+	return nil
+}
+
+// message returns the GELF message of the entry.
+func (c *gelfCore) message(ent zapcore.Entry, fields []zapcore.Field) map[string]any {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	msg := make(map[string]any, len(enc.Fields)+8)
+	for k, v := range enc.Fields {
+		if name, ok := gelfFieldNames[k]; ok {
+			k = name
+		}
+		k = gelfInvalidChars.ReplaceAllString(k, "_")
+		if k == "id" { // _id is reserved
+			k = "id_"
+		}
+		msg["_"+k] = v
+	}
+	msg["version"] = "1.1"
+	msg["host"] = c.host
+	msg["short_message"] = ent.Message
+	msg["timestamp"] = float64(ent.Time.UnixNano()) / float64(time.Second)
+	msg["level"] = syslogSeverity(ent.Level)
+	msg["_service"] = c.service
+	if ent.Caller.Defined {
+		msg["_caller"] = ent.Caller.TrimmedPath()
+	}
+	if ent.Stack != "" {
+		msg["full_message"] = ent.Stack
+	}
+	return msg
+}
+
+// gelfWriter sends the GELF messages over UDP, in chunks if needed, or over
+// TCP, delimited by a null byte. The connection is opened by the first
+// message, and the TCP connection is reopened after an error.
+type gelfWriter struct {
+	network string
+	addr    string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	dialer sinkDialer
+	closed bool
+}
+
+func newGELFWriter(network, addr string) (*gelfWriter, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("gelf: unsupported network %q", network)
+	}
+	return &gelfWriter{network: network, addr: addr}, nil
+}
+
+func (w *gelfWriter) write(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errors.New("gelf: writer closed")
+	}
+	if w.conn == nil {
+		conn, err := w.dialer.dial(func() (net.Conn, error) {
+			return net.DialTimeout(w.network, w.addr, sinkDialTimeout)
+		})
+		if err != nil {
+			return fmt.Errorf("gelf: %w", err)
+		}
+		w.conn = conn
+	}
+	if w.network == "udp" {
+		return w.writeUDP(data)
+	}
+	if err := sinkWrite(w.conn, append(data, 0)); err != nil {
+		w.conn.Close()
+		w.conn = nil
+		return fmt.Errorf("gelf: %w", err)
+	}
+	return nil
+}
+
+// writeUDP sends the message in one datagram, or in chunks if it is too large.
+func (w *gelfWriter) writeUDP(data []byte) error {
+	if len(data) <= gelfChunkSize {
+		return sinkWrite(w.conn, data)
+	}
+	payload := gelfChunkSize - gelfChunkHeaderSize
+	count := int(math.Ceil(float64(len(data)) / float64(payload)))
+	if count > gelfMaxChunks {
+		return fmt.Errorf("gelf: message of %d bytes is too large", len(data))
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	var chunk bytes.Buffer
+	for i := 0; i < count; i++ {
+		chunk.Reset()
+		chunk.Write([]byte{0x1e, 0x0f})
+		chunk.Write(id)
+		chunk.Write([]byte{byte(i), byte(count)})
+		chunk.Write(data[i*payload : min((i+1)*payload, len(data))])
+		if err := sinkWrite(w.conn, chunk.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (w *gelfWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,123 @@
+package kgsotel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newGELFLogger(t *testing.T, network, addr string) *zap.Logger {
+	w, err := newGELFWriter(network, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { w.Close() })
+	return zap.New(newGELFCore("svc", zapcore.DebugLevel, w))
+}
+
+func TestGELFUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	logger := newGELFLogger(t, "udp", conn.LocalAddr().String())
+	logger.With(zap.String("traceID", "0af7651916cd43dd8448eb211c80319c")).
+		Warn("payment declined", zap.Int("amount", 7), zap.String("id", "x"), zap.String("user name", "bob"))
+
+	buf := make([]byte, gelfChunkSize)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	var msg map[string]any
+	require.NoError(t, json.Unmarshal(buf[:n], &msg))
+	assert.Equal(t, "1.1", msg["version"])
+	assert.Equal(t, "payment declined", msg["short_message"])
+	assert.Equal(t, float64(4), msg["level"])
+	assert.Equal(t, "svc", msg["_service"])
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", msg["_trace_id"])
+	assert.Equal(t, float64(7), msg["_amount"])
+	assert.Equal(t, "x", msg["_id_"])
+	assert.Equal(t, "bob", msg["_user_name"])
+	assert.NotEmpty(t, msg["timestamp"])
+}
+
+func TestGELFUDPChunks(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	logger := newGELFLogger(t, "udp", conn.LocalAddr().String())
+	long := strings.Repeat("x", 3*gelfChunkSize)
+	logger.Info("large", zap.String("payload", long))
+
+	var chunks [][]byte
+	buf := make([]byte, gelfChunkSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		chunk := bytes.Clone(buf[:n])
+		require.Equal(t, []byte{0x1e, 0x0f}, chunk[:2])
+		chunks = append(chunks, chunk)
+		if len(chunks) == int(chunk[11]) {
+			break
+		}
+	}
+	var data []byte
+	for i, c := range chunks {
+		assert.Equal(t, chunks[0][2:10], c[2:10], "message ID")
+		assert.Equal(t, byte(i), c[10])
+		data = append(data, c[gelfChunkHeaderSize:]...)
+	}
+	var msg map[string]any
+	require.NoError(t, json.Unmarshal(data, &msg))
+	assert.Equal(t, long, msg["_payload"])
+}
+
+func TestGELFTCP(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	logger := newGELFLogger(t, "tcp", lis.Addr().String())
+	logger.Error("first")
+	logger.Info("second")
+
+	conn, err := lis.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"first", "second"} {
+		data, err := r.ReadBytes(0)
+		require.NoError(t, err)
+		var msg map[string]any
+		require.NoError(t, json.Unmarshal(data[:len(data)-1], &msg))
+		assert.Equal(t, want, msg["short_message"])
+	}
+}
+
+func TestGELFUnsupportedNetwork(t *testing.T) {
+	_, err := newGELFWriter("unix", "/tmp/gelf.sock")
+	assert.Error(t, err)
+}
+
+func TestGELFUnreachable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	w, err := newGELFWriter("tcp", addr)
+	require.NoError(t, err, "the connection is opened by the first message")
+	defer w.Close()
+	assert.Error(t, w.write([]byte(`{}`)))
+	assert.ErrorIs(t, w.write([]byte(`{}`)), errSinkUnreachable, "the dial is not retried at once")
+}
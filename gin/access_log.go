@@ -0,0 +1,82 @@
+package otelgin
+
+import (
+	"kgs/otel/internal/defaults"
+	"kgs/otel/internal/killswitch"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// AccessLogMessage is the message of the access logs.
+const AccessLogMessage = "http request"
+
+// AccessLogMiddleware returns a middleware writing one structured log per
+// request to the global logger, with the method, the route, the status, the
+// duration, the sizes, the client IP and the trace ID. The server errors are
+// logged at the error level, the other requests at the info level. It only
+// applies the WithFilter and WithGinFilter options, and must be mounted after
+// TracingMiddleware to log the trace IDs.
+func AccessLogMiddleware(opts ...Option) gin.HandlerFunc {
+	if killswitch.Disabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	cfg := config{}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if paths := defaults.GetMiddleware().GinExcludedPaths; len(paths) > 0 {
+		cfg.Filters = append(cfg.Filters, excludePaths(paths))
+	}
+
+	return func(c *gin.Context) {
+		for _, f := range cfg.Filters {
+			if !f(c.Request) {
+				c.Next()
+				return
+			}
+		}
+		for _, f := range cfg.GinFilters {
+			if !f(c) {
+				c.Next()
+				return
+			}
+		}
+
+		start := time.Now()
+		c.Next()
+		elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("http.request.method", c.Request.Method),
+			zap.String("http.route", c.FullPath()),
+			zap.String("url.path", c.Request.URL.Path),
+			zap.Int("http.response.status_code", status),
+			zap.Float64("http.server.request.duration_ms", elapsed),
+			zap.String("client.address", c.ClientIP()),
+			zap.String("user_agent.original", c.Request.UserAgent()),
+		}
+		if c.Request.ContentLength >= 0 {
+			fields = append(fields, zap.Int64("http.request.body.size", c.Request.ContentLength))
+		}
+		if size := c.Writer.Size(); size >= 0 {
+			fields = append(fields, zap.Int("http.response.body.size", size))
+		}
+		if sc := oteltrace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			fields = append(fields, zap.String("traceID", sc.TraceID().String()), zap.String("spanID", sc.SpanID().String()))
+		}
+
+		if status >= http.StatusInternalServerError {
+			zap.L().Error(AccessLogMessage, fields...)
+			return
+		}
+		zap.L().Info(AccessLogMessage, fields...)
+	}
+}
@@ -0,0 +1,58 @@
+package otelgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider)))
+	r.Use(AccessLogMiddleware(WithFilter(func(r *http.Request) bool { return r.URL.Path != "/healthz" })))
+	r.POST("/users/:id", func(c *gin.Context) { c.String(http.StatusCreated, "created") })
+	r.GET("/fail", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPost, "/users/42", strings.NewReader("name=ada"))
+	req.RemoteAddr = "10.0.0.7:1234"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, AccessLogMessage, entries[0].Message)
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "POST", fields["http.request.method"])
+	assert.Equal(t, "/users/:id", fields["http.route"])
+	assert.Equal(t, "/users/42", fields["url.path"])
+	assert.Equal(t, int64(201), fields["http.response.status_code"])
+	assert.Equal(t, int64(8), fields["http.request.body.size"])
+	assert.Equal(t, int64(7), fields["http.response.body.size"])
+	assert.Equal(t, "10.0.0.7", fields["client.address"])
+	assert.Contains(t, fields, "http.server.request.duration_ms")
+
+	span, ok := rec.Span("/users/:id")
+	require.True(t, ok)
+	assert.Equal(t, span.SpanContext().TraceID().String(), fields["traceID"])
+
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	assert.Equal(t, int64(503), entries[1].ContextMap()["http.response.status_code"])
+}
@@ -0,0 +1,84 @@
+package otelgin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RequestBodyKey is the captured request body.
+const RequestBodyKey = attribute.Key("http.request.body")
+
+// WithCapturedHeaders returns an Option to add the request and response
+// headers to the spans, as http.request.header.<name> and
+// http.response.header.<name> attributes. Only capture the headers without
+// secrets, e.g. not Authorization or Cookie.
+func WithCapturedHeaders(request, response []string) Option {
+	return optionFunc(func(c *config) {
+		c.RequestHeaders = headerKeys("http.request.header.", request)
+		c.ResponseHeaders = headerKeys("http.response.header.", response)
+	})
+}
+
+// WithRequestBodyCapture returns an Option to add the first maxBytes of the
+// request bodies to the spans, as the http.request.body attribute.
+func WithRequestBodyCapture(maxBytes int) Option {
+	return optionFunc(func(c *config) {
+		c.RequestBodyMax = maxBytes
+	})
+}
+
+// capturedHeader is a captured header and the key of its attribute.
+type capturedHeader struct {
+	name string
+	key  attribute.Key
+}
+
+// headerKeys returns the attribute keys of the headers, prefixed.
+func headerKeys(prefix string, names []string) []capturedHeader {
+	headers := make([]capturedHeader, 0, len(names))
+	for _, name := range names {
+		headers = append(headers, capturedHeader{
+			name: http.CanonicalHeaderKey(name),
+			key:  attribute.Key(prefix + strings.ToLower(name)),
+		})
+	}
+	return headers
+}
+
+// headerAttrs returns the attributes of the captured headers present in h.
+func headerAttrs(h http.Header, headers []capturedHeader) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, c := range headers {
+		if values := h.Values(c.name); len(values) > 0 {
+			attrs = append(attrs, c.key.StringSlice(values))
+		}
+	}
+	return attrs
+}
+
+// captureBody returns the attribute of the first max bytes of the body of
+// r, which is left to be read by the handlers.
+func captureBody(r *http.Request, max int) (attribute.KeyValue, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return attribute.KeyValue{}, false
+	}
+	head, err := io.ReadAll(io.LimitReader(r.Body, int64(max)))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(head), r.Body), Closer: r.Body}
+	if err != nil || len(head) == 0 {
+		return attribute.KeyValue{}, false
+	}
+	for len(head) > 0 && !utf8.Valid(head) {
+		head = head[:len(head)-1]
+	}
+	return RequestBodyKey.String(string(head)), true
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,110 @@
+package otelgin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// CarrierFunc returns a carrier of the trace context of a request other than
+// its headers, for the browsers and webhooks which cannot set traceparent.
+type CarrierFunc func(*http.Request) propagation.TextMapCarrier
+
+// WithCarriers returns an Option to extract the trace context from the
+// carriers, in order, when the headers of a request have none.
+//
+//	otelgin.WithCarriers(otelgin.QueryCarrier, otelgin.CookieCarrier)
+func WithCarriers(carriers ...CarrierFunc) Option {
+	return optionFunc(func(c *config) {
+		c.Carriers = append(c.Carriers, carriers...)
+	})
+}
+
+// WithRouteCarriers returns an Option to replace the carriers of
+// WithCarriers for the requests to route, e.g. "/webhooks/:source".
+func WithRouteCarriers(route string, carriers ...CarrierFunc) Option {
+	return optionFunc(func(c *config) {
+		if c.RouteCarriers == nil {
+			c.RouteCarriers = make(map[string][]CarrierFunc)
+		}
+		c.RouteCarriers[route] = carriers
+	})
+}
+
+// QueryCarrier reads the trace context from the query parameters named like
+// the headers, e.g. ?traceparent=00-...-01.
+func QueryCarrier(r *http.Request) propagation.TextMapCarrier {
+	return queryCarrier(r.URL.Query())
+}
+
+// CookieCarrier reads the trace context from the cookies named like the
+// headers.
+func CookieCarrier(r *http.Request) propagation.TextMapCarrier {
+	return cookieCarrier{r}
+}
+
+// queryCarrier is a read-only carrier of query parameters.
+type queryCarrier url.Values
+
+func (c queryCarrier) Get(key string) string {
+	return url.Values(c).Get(key)
+}
+
+func (queryCarrier) Set(string, string) {}
+
+func (c queryCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// cookieCarrier is a read-only carrier of cookies.
+type cookieCarrier struct {
+	r *http.Request
+}
+
+func (c cookieCarrier) Get(key string) string {
+	cookie, err := c.r.Cookie(key)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func (cookieCarrier) Set(string, string) {}
+
+func (c cookieCarrier) Keys() []string {
+	var keys []string
+	for _, cookie := range c.r.Cookies() {
+		if !slices.Contains(keys, cookie.Name) {
+			keys = append(keys, cookie.Name)
+		}
+	}
+	return keys
+}
+
+// extract returns ctx with the trace context of the request headers, or of
+// the first carrier of the route having one.
+func (c *config) extract(ctx context.Context, r *http.Request, route string) context.Context {
+	extracted := c.Propagators.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	if oteltrace.SpanContextFromContext(extracted).IsValid() {
+		return extracted
+	}
+
+	carriers, ok := c.RouteCarriers[route]
+	if !ok {
+		carriers = c.Carriers
+	}
+	for _, carrier := range carriers {
+		if alt := c.Propagators.Extract(ctx, carrier(r)); oteltrace.SpanContextFromContext(alt).IsValid() {
+			return alt
+		}
+	}
+	return extracted
+}
@@ -0,0 +1,48 @@
+package otelgin
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PreflightMode is how the middleware handles the CORS preflight requests.
+type PreflightMode int
+
+const (
+	// PreflightTrace traces the preflight requests like the other ones.
+	PreflightTrace PreflightMode = iota
+	// PreflightExclude neither traces nor measures the preflight requests.
+	PreflightExclude
+	// PreflightCollapse traces the preflight requests in spans named
+	// "CORS preflight", measured without their route, so they do not add a
+	// series per route.
+	PreflightCollapse
+)
+
+// CORSPreflightKey marks the spans of the collapsed preflight requests.
+const CORSPreflightKey = attribute.Key("http.cors.preflight")
+
+// preflightSpanName is the name of the collapsed preflight spans.
+const preflightSpanName = "CORS preflight"
+
+// WithCORSPreflight returns an Option to exclude or collapse the CORS
+// preflight requests, the OPTIONS requests with the Origin and
+// Access-Control-Request-Method headers sent by the browsers.
+func WithCORSPreflight(mode PreflightMode) Option {
+	return optionFunc(func(c *config) {
+		c.CORSPreflight = mode
+		if mode == PreflightExclude {
+			c.Filters = append(c.Filters, func(r *http.Request) bool {
+				return !isPreflight(r)
+			})
+		}
+	})
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
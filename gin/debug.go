@@ -0,0 +1,50 @@
+package otelgin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	kgsotel "kgs/otel"
+)
+
+// WithDebugHeader returns an Option to flag the requests with the header set
+// to 1 or true for debugging (see kgsotel.ContextWithDebug): their logs are
+// written down to the debug level and their spans are sampled. authorize
+// restricts the flag to the authenticated callers, e.g. by checking a token;
+// if nil, the header is always honored. The flag propagated in the baggage
+// by an upstream service is honored under the same conditions; it is removed
+// from the baggage of the other requests, and of all the requests without
+// WithDebugHeader.
+func WithDebugHeader(header string, authorize func(*http.Request) bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.DebugHeader = header
+		cfg.DebugAuthorize = authorize
+	})
+}
+
+// debugContext flags ctx for debugging when the request asks for it, and
+// removes the flag propagated by the callers not allowed to set it.
+func (cfg *config) debugContext(ctx context.Context, r *http.Request) context.Context {
+	requested := cfg.DebugHeader != "" && debugRequested(r, cfg.DebugHeader)
+	if !requested && !kgsotel.DebugEnabled(ctx) {
+		return ctx
+	}
+	if cfg.DebugHeader == "" || (cfg.DebugAuthorize != nil && !cfg.DebugAuthorize(r)) {
+		return kgsotel.ContextWithoutDebug(ctx)
+	}
+	if requested {
+		return kgsotel.ContextWithDebug(ctx)
+	}
+	return ctx
+}
+
+// debugRequested reports whether the request asks to be debugged.
+func debugRequested(r *http.Request, header string) bool {
+	switch strings.ToLower(r.Header.Get(header)) {
+	case "1", "true":
+		return true
+	default:
+		return false
+	}
+}
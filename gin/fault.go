@@ -0,0 +1,71 @@
+package otelgin
+
+import (
+	"kgs/otel/internal/fault"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FaultOption configures FaultInjectionMiddleware.
+type FaultOption func(*faultConfig)
+
+type faultConfig struct {
+	authorize func(*http.Request) bool
+}
+
+// AuthorizeFaults returns a FaultOption to honor the fault.delay and
+// fault.error baggage members of the requests for which authorize returns
+// true, e.g. checking a token or the source address. Without it, the faults
+// are only requested by the headers.
+func AuthorizeFaults(authorize func(*http.Request) bool) FaultOption {
+	return func(cfg *faultConfig) {
+		cfg.authorize = authorize
+	}
+}
+
+// FaultInjectionMiddleware returns a middleware injecting the delays and
+// errors requested by the X-Fault-Delay (e.g. 200ms) and X-Fault-Error
+// (an HTTP status code, e.g. 503) headers, or by the fault.delay and
+// fault.error baggage members with AuthorizeFaults. The fault members are
+// removed from the baggage seen by the handlers, so they are not propagated.
+// The affected spans are tagged with fault.injected. When enabled is false,
+// it does nothing, so it can stay mounted in production and be turned on for
+// chaos experiments. Mount it after TracingMiddleware to see the baggage and
+// tag the spans.
+func FaultInjectionMiddleware(enabled bool, opts ...FaultOption) gin.HandlerFunc {
+	if !enabled {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	var cfg faultConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		trusted := cfg.authorize != nil && cfg.authorize(c.Request)
+		req, ok := fault.FromContext(ctx, c.Request.Header.Get, trusted)
+		if stripped := fault.WithoutFault(ctx); stripped != ctx {
+			c.Request = c.Request.WithContext(stripped)
+		}
+		if !ok {
+			c.Next()
+			return
+		}
+		req.Tag(ctx)
+
+		if err := req.Wait(ctx); err != nil {
+			c.Abort()
+			return
+		}
+		if code, err := strconv.Atoi(req.Error); err == nil && code >= http.StatusBadRequest && code <= 599 {
+			c.AbortWithStatusJSON(code, gin.H{"error": "injected fault"})
+			return
+		}
+		c.Next()
+	}
+}
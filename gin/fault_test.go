@@ -0,0 +1,66 @@
+package otelgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestFaultInjectionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(enabled bool, header string) int {
+		r := gin.New()
+		r.Use(FaultInjectionMiddleware(enabled))
+		r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Fault-Error", header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(true, "503"))
+	assert.Equal(t, http.StatusOK, serve(true, "200"))
+	assert.Equal(t, http.StatusOK, serve(true, ""))
+	assert.Equal(t, http.StatusOK, serve(false, "503"))
+}
+
+func TestFaultInjectionMiddlewareBaggage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	member, err := baggage.NewMember("fault.error", "503")
+	require.NoError(t, err)
+	b, err := baggage.New(member)
+	require.NoError(t, err)
+
+	serve := func(opts ...FaultOption) (int, baggage.Baggage) {
+		var seen baggage.Baggage
+		r := gin.New()
+		r.Use(FaultInjectionMiddleware(true, opts...))
+		r.GET("/", func(c *gin.Context) {
+			seen = baggage.FromContext(c.Request.Context())
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(baggage.ContextWithBaggage(req.Context(), b))
+		req.Header.Set("X-Chaos-Token", "secret")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code, seen
+	}
+
+	// The baggage faults are ignored, and not propagated, unless authorized.
+	code, seen := serve()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, seen.Member("fault.error").Key())
+	code, _ = serve(AuthorizeFaults(func(*http.Request) bool { return false }))
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = serve(AuthorizeFaults(func(r *http.Request) bool { return r.Header.Get("X-Chaos-Token") == "secret" }))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
@@ -0,0 +1,98 @@
+package otelgin
+
+import (
+	"kgs/otel/internal/semconvutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// routeKey identifies the metric attribute set of a request. The host,
+// scheme and protocol of the request are part of the set.
+type routeKey struct {
+	method string
+	route  string
+	status int
+	host   string
+	tls    bool
+	proto  string
+}
+
+// maxRouteSets bounds the cached sets, as the host of the requests is
+// chosen by the clients. The sets past it are built for every request.
+const maxRouteSets = 1024
+
+// routeAttributeSets caches the metric attribute sets by route, so the
+// low-overhead mode does not build them for every request.
+type routeAttributeSets struct {
+	sets    sync.Map
+	size    atomic.Int64
+	semconv semconvutil.Version
+}
+
+// attrsPool holds the slices the attribute sets are built from.
+var attrsPool = sync.Pool{
+	New: func() any {
+		attrs := make([]attribute.KeyValue, 0, 8)
+		return &attrs
+	},
+}
+
+// get returns the metric attribute set of the request to the route.
+func (r *routeAttributeSets) get(server string, req *http.Request, route string, status int) attribute.Set {
+	key := routeKey{
+		method: knownMethod(req.Method),
+		route:  route,
+		status: status,
+		host:   req.Host,
+		tls:    req.TLS != nil,
+		proto:  req.Proto,
+	}
+	if set, ok := r.sets.Load(key); ok {
+		return set.(attribute.Set)
+	}
+
+	buf := attrsPool.Get().(*[]attribute.KeyValue)
+	attrs := semconvutil.AppendHTTPServerRequestMetrics((*buf)[:0], server, req)
+	if route != "" {
+		attrs = append(attrs, semconv.HTTPRoute(route))
+	}
+	if status > 0 {
+		attrs = append(attrs, semconv.HTTPStatusCode(status))
+	}
+	set := attribute.NewSet(r.semconv.Convert(attrs)...)
+	clear(attrs)
+	*buf = attrs[:0]
+	attrsPool.Put(buf)
+
+	if r.size.Load() < maxRouteSets {
+		if _, loaded := r.sets.LoadOrStore(key, set); !loaded {
+			r.size.Add(1)
+		}
+	}
+	return set
+}
+
+// knownMethod returns the method, or _OTHER for the non-standard ones which
+// would make the cache grow without bound.
+func knownMethod(method string) string {
+	switch method {
+	case http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace:
+		return method
+	default:
+		return "_OTHER"
+	}
+}
+
+// contentLength returns the size of the request announced by its headers,
+// without reading the body.
+func contentLength(req *http.Request) int {
+	if req.ContentLength > 0 {
+		return int(req.ContentLength)
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package otelgin
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"kgs/otel/internal/semconvutil"
+
+	"github.com/stretchr/testify/assert"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+func TestRouteAttributeSets(t *testing.T) {
+	sets := &routeAttributeSets{semconv: semconvutil.V1_20}
+
+	req := httptest.NewRequest(http.MethodGet, "http://shop.example:8080/users/1", nil)
+	set := sets.get("", req, "/users/:id", http.StatusOK)
+	host, _ := set.Value(semconv.NetHostNameKey)
+	assert.Equal(t, "shop.example", host.AsString())
+	route, _ := set.Value(semconv.HTTPRouteKey)
+	assert.Equal(t, "/users/:id", route.AsString())
+
+	// The host and scheme of the next requests are not the ones of the first.
+	req = httptest.NewRequest(http.MethodGet, "http://admin.example:9090/users/2", nil)
+	req.TLS = &tls.ConnectionState{}
+	set = sets.get("", req, "/users/:id", http.StatusOK)
+	host, _ = set.Value(semconv.NetHostNameKey)
+	assert.Equal(t, "admin.example", host.AsString())
+	port, _ := set.Value(semconv.NetHostPortKey)
+	assert.Equal(t, int64(9090), port.AsInt64())
+	scheme, _ := set.Value(semconv.HTTPSchemeKey)
+	assert.Equal(t, "https", scheme.AsString())
+	assert.Equal(t, set, sets.get("", req, "/users/:id", http.StatusOK))
+}
+
+func TestRouteAttributeSetsBounded(t *testing.T) {
+	sets := &routeAttributeSets{semconv: semconvutil.V1_20}
+	for i := range maxRouteSets + 10 {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Host = "host" + strconv.Itoa(i)
+		set := sets.get("", req, "/users", http.StatusOK)
+		host, _ := set.Value(semconv.NetHostNameKey)
+		assert.Equal(t, req.Host, host.AsString())
+	}
+	assert.Equal(t, int64(maxRouteSets), sets.size.Load())
+}
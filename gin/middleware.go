@@ -9,8 +9,17 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	kgsotel "kgs/otel"
+	"kgs/otel/internal/defaults"
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/lowoverhead"
+	"kgs/otel/internal/overhead"
 	"kgs/otel/internal/semconvutil"
+	"kgs/otel/internal/slow"
+	"kgs/otel/internal/traceresponse"
+	"maps"
 	"net/http"
+	"slices"
 	"time"
 
 	otelmetric "go.opentelemetry.io/otel/metric"
@@ -19,7 +28,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/propagation"
 	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
 	oteltrace "go.opentelemetry.io/otel/trace"
 )
@@ -34,11 +42,43 @@ const (
 // The service parameter should describe the name of the (virtual)
 // server handling the request.
 func TracingMiddleware(serviceName string, opts ...Option) gin.HandlerFunc {
+	if killswitch.Disabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+	return NewMiddleware(serviceName, opts...).Handler()
+}
+
+// Middleware holds the tracer and the instruments of the middleware, shared
+// by the handlers of the router groups.
+type Middleware struct {
+	serviceName string
+	config      config
+	tracer      oteltrace.Tracer
+	meter       otelmetric.Meter
+
+	lowOverhead bool
+	routeSets   *routeAttributeSets
+	overheadRec *overhead.Recorder
+	semconv     semconvutil.Version
+}
+
+// NewMiddleware creates the tracer and the instruments of the middleware
+// once, for the handlers of several router groups with different options:
+//
+//	m := otelgin.NewMiddleware("shop")
+//	admin := r.Group("/admin", m.Handler(otelgin.WithCapturedHeaders([]string{"X-Admin-User"}, nil)))
+//	public := r.Group("/public", m.Handler())
+func NewMiddleware(serviceName string, opts ...Option) *Middleware {
 	var err error
 	cfg := config{}
 	for _, opt := range opts {
 		opt.apply(&cfg)
 	}
+	if paths := defaults.GetMiddleware().GinExcludedPaths; len(paths) > 0 {
+		cfg.Filters = append(cfg.Filters, excludePaths(paths))
+	}
 	if cfg.TracerProvider == nil {
 		cfg.TracerProvider = otel.GetTracerProvider()
 	}
@@ -49,14 +89,33 @@ func TracingMiddleware(serviceName string, opts ...Option) gin.HandlerFunc {
 		cfg.Propagators = otel.GetTextMapPropagator()
 	}
 
+	// The attributes are converted to the selected semantic conventions.
+	sc := cfg.Semconv.Resolve()
+	m := &Middleware{
+		serviceName: serviceName,
+		// In the low-overhead mode, the metric attribute sets are cached by route.
+		lowOverhead: lowoverhead.Enabled(),
+		routeSets:   &routeAttributeSets{semconv: sc},
+		// In the overhead measurement mode, the time and allocations outside of
+		// the handlers are reported.
+		overheadRec: overhead.NewRecorder(cfg.MeterProvider, "otelgin"),
+		semconv:     sc,
+	}
+
 	// Set the tracer and meter for the service.
-	tracer := otel.Tracer(serviceName)
-	meter := otel.Meter(serviceName)
+	m.tracer = cfg.TracerProvider.Tracer(serviceName, oteltrace.WithSchemaURL(sc.SchemaURL()))
+	meter := cfg.MeterProvider.Meter(serviceName, otelmetric.WithSchemaURL(sc.SchemaURL()))
+	m.meter = meter
 
 	// Measure the request duration of the incoming requests.
-	cfg.reqDuration, err = meter.Float64Histogram("http."+role+".request.duration",
+	durationOpts := []otelmetric.Float64HistogramOption{
 		otelmetric.WithDescription("Measures the duration of inbound RPC."),
-		otelmetric.WithUnit("ms"))
+		otelmetric.WithUnit(sc.DurationUnit()),
+	}
+	if buckets := sc.DurationBuckets(cfg.DurationBuckets); len(buckets) > 0 {
+		durationOpts = append(durationOpts, otelmetric.WithExplicitBucketBoundaries(buckets...))
+	}
+	cfg.reqDuration, err = meter.Float64Histogram("http."+role+".request.duration", durationOpts...)
 	if err != nil {
 		otel.Handle(err)
 		if cfg.reqDuration == nil {
@@ -97,6 +156,52 @@ func TracingMiddleware(serviceName string, opts ...Option) gin.HandlerFunc {
 		}
 	}
 
+	// Count the requests rejected by the guards.
+	cfg.rejected, err = meter.Int64Counter("http."+role+".request.rejected",
+		otelmetric.WithDescription("Measures the number of requests rejected before reaching the handlers."),
+		otelmetric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rejected == nil {
+			cfg.rejected = noop.Int64Counter{}
+		}
+	}
+
+	m.config = cfg
+	return m
+}
+
+// Handler returns the middleware handler with the options of a router group
+// added to the ones of the middleware. The providers, propagators, buckets
+// and semantic conventions version of the middleware are kept, as the
+// instruments are shared; a group selecting another version with WithSemconv
+// is reported to otel.Handle.
+func (m *Middleware) Handler(opts ...Option) gin.HandlerFunc {
+	if killswitch.Disabled() {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	cfg := m.config
+	cfg.Filters = slices.Clip(cfg.Filters)
+	cfg.GinFilters = slices.Clip(cfg.GinFilters)
+	cfg.SlowThresholds = maps.Clone(cfg.SlowThresholds)
+	cfg.Carriers = slices.Clip(cfg.Carriers)
+	cfg.RouteCarriers = maps.Clone(cfg.RouteCarriers)
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	cfg.TracerProvider, cfg.MeterProvider, cfg.Propagators = m.config.TracerProvider, m.config.MeterProvider, m.config.Propagators
+	if cfg.Semconv != m.config.Semconv && cfg.Semconv.Resolve() != m.semconv {
+		otel.Handle(fmt.Errorf("otelgin: the router group semantic conventions %q are ignored, the middleware ones are kept", cfg.Semconv))
+		cfg.Semconv = m.config.Semconv
+	}
+
+	serviceName, tracer, meter := m.serviceName, m.tracer, m.meter
+	lowOverhead, routeSets, overheadRec := m.lowOverhead, m.routeSets, m.overheadRec
+	sc := m.semconv
+
 	return func(c *gin.Context) {
 		var (
 			metricAttrs []attribute.KeyValue
@@ -111,6 +216,18 @@ func TracingMiddleware(serviceName string, opts ...Option) gin.HandlerFunc {
 				return
 			}
 		}
+		start := time.Now()
+		var (
+			cost overhead.Cost
+			seg  overhead.Segment
+		)
+		if overheadRec != nil {
+			seg = overhead.Begin()
+			defer func() {
+				cost.End(seg)
+				overheadRec.Record(c.Request.Context(), &cost)
+			}()
+		}
 		c.Set(tracerKey, tracer)
 		c.Set(meterKey, meter)
 		savedCtx := c.Request.Context()
@@ -119,26 +236,38 @@ func TracingMiddleware(serviceName string, opts ...Option) gin.HandlerFunc {
 		}()
 
 		// Extract the context from the incoming request. If the context is not empty,
-		ctx := cfg.Propagators.Extract(savedCtx, propagation.HeaderCarrier(c.Request.Header))
+		ctx := cfg.extract(savedCtx, c.Request, c.FullPath())
+		// The debug flag is set before the span starts, so it is sampled.
+		ctx = cfg.debugContext(ctx, c.Request)
 
 		// Set the trace attributes for the request.
 		httpTraceAttrs := semconvutil.HTTPServerRequest(serviceName, c.Request)
+		if cfg.QueryParams != nil {
+			httpTraceAttrs = queryAttrs(httpTraceAttrs, c.Request.URL, cfg.QueryParams)
+		}
+		httpTraceAttrs = sc.Convert(httpTraceAttrs)
 		opts := []oteltrace.SpanStartOption{
 			oteltrace.WithAttributes(httpTraceAttrs...),
 			oteltrace.WithSpanKind(oteltrace.SpanKindServer),
 		}
 
 		// Set the span name for the request.
-		metricAttrs = semconvutil.HTTPServerRequestMetrics(serviceName, c.Request)
-		var spanName string
+		if !lowOverhead {
+			metricAttrs = sc.Convert(semconvutil.HTTPServerRequestMetrics(serviceName, c.Request))
+		}
+		var route, spanName string
 		if cfg.SpanNameFormatter == nil {
 			spanName = c.FullPath()
 		} else {
 			spanName = cfg.SpanNameFormatter(c.Request)
 		}
-		if spanName == "" {
+		if cfg.CORSPreflight == PreflightCollapse && isPreflight(c.Request) {
+			spanName = preflightSpanName
+			opts = append(opts, oteltrace.WithAttributes(CORSPreflightKey.Bool(true)))
+		} else if spanName == "" {
 			spanName = fmt.Sprintf("HTTP %s route not found", c.Request.Method)
 		} else {
+			route = spanName
 			rAttr = semconv.HTTPRoute(spanName)
 			opts = append(opts, oteltrace.WithAttributes(rAttr))
 			metricAttrs = append(metricAttrs, rAttr)
@@ -146,20 +275,113 @@ func TracingMiddleware(serviceName string, opts ...Option) gin.HandlerFunc {
 
 		// Start the span for the request.
 		ctx, span := tracer.Start(ctx, spanName, opts...)
+		span = sc.Span(span)
 		defer span.End()
+		ctx, unlabel := kgsotel.LabelGoroutine(ctx, span, spanName)
+		defer unlabel()
+
+		// The handler time of the slow requests is measured by TimeHandler.
+		var (
+			slowThreshold = cfg.slowThreshold(route)
+			timer         *slow.Timer
+		)
+		if slowThreshold > 0 {
+			ctx, timer = slow.ContextWithTimer(ctx)
+		}
 
 		// Pass the span through the request context
 		c.Request = c.Request.WithContext(ctx)
+		if len(cfg.RequestHeaders) > 0 {
+			span.SetAttributes(headerAttrs(c.Request.Header, cfg.RequestHeaders)...)
+		}
+		if cfg.MaxRequestSize > 0 {
+			if reason := guardRequestSize(c, cfg.MaxRequestSize); reason != "" {
+				attrs := []attribute.KeyValue{RejectReasonKey.String(reason)}
+				if route != "" {
+					attrs = append(attrs, rAttr)
+				}
+				cfg.rejected.Add(ctx, 1, otelmetric.WithAttributes(attrs...))
+			}
+		}
+		if cfg.RequestBodyMax > 0 && !c.IsAborted() {
+			if attr, ok := captureBody(c.Request, cfg.RequestBodyMax); ok {
+				span.SetAttributes(attr)
+			}
+		}
+		if cfg.TraceResponse {
+			traceresponse.Set(c.Writer.Header(), span.SpanContext())
+		}
 
 		// Calculate the size of the request.
-		reqSize := calcReqSize(c)
+		var (
+			reqSize   int
+			multipart *countingBody
+		)
+		// The body of a rejected request is not read.
+		if lowOverhead || c.IsAborted() {
+			reqSize = contentLength(c.Request)
+		} else if isMultipart(c.Request) && c.Request.Body != nil {
+			// The uploads are counted as the handlers read them instead of
+			// being buffered.
+			multipart = &countingBody{ReadCloser: c.Request.Body}
+			c.Request.Body = multipart
+			reqSize = calcHeaderSize(c.Request)
+		} else {
+			reqSize = calcReqSize(c)
+		}
 		before := time.Now()
 
+		// The streams record their duration at the first byte.
+		var sse *sseWriter
+		if cfg.SSE {
+			sse = newSSEWriter(c.Writer, func(w *sseWriter) {
+				elapsedTime := sc.Duration(w.ttfb)
+				status := w.Status()
+				if lowOverhead {
+					cfg.reqDuration.Record(ctx, elapsedTime, otelmetric.WithAttributeSet(routeSets.get(serviceName, c.Request, route, status)))
+					return
+				}
+				attrs := append(metricAttrs[:len(metricAttrs):len(metricAttrs)], sc.KeyValue(semconv.HTTPStatusCode(status)))
+				cfg.reqDuration.Record(ctx, elapsedTime, otelmetric.WithAttributes(attrs...))
+			})
+			c.Writer = sse
+		}
+		streaming := func() bool { return sse != nil && sse.streaming }
+
+		// The WebSocket connections are served until they are closed.
+		var upgrade *upgradeWriter
+		if isWebSocketUpgrade(c.Request) {
+			upgrade = &upgradeWriter{ResponseWriter: c.Writer}
+			c.Writer = upgrade
+		}
+
 		// Serve the request to the next middleware
+		if overheadRec != nil {
+			cost.End(seg)
+		}
+		nextStart := time.Now()
 		c.Next()
+		if overheadRec != nil {
+			seg = overhead.Begin()
+		}
+		// The streams and the WebSocket connections are long by design.
+		if slowThreshold > 0 && !streaming() && (upgrade == nil || !upgrade.upgraded()) {
+			handler, ok := timer.Handler()
+			if !ok {
+				handler = time.Since(nextStart)
+			}
+			slow.Annotate(span, slowThreshold, time.Since(start), handler)
+		}
 
-		// Use floating point division here for higher precision (instead of Millisecond method).
-		elapsedTime := float64(time.Since(before)) / float64(time.Millisecond)
+		elapsedTime := sc.Duration(time.Since(before))
+		if upgrade != nil && upgrade.upgraded() {
+			elapsedTime = sc.Duration(upgrade.hijackedAt.Sub(before))
+			span.SetAttributes(UpgradeKey.String("websocket"))
+		}
+		if multipart != nil {
+			reqSize += int(multipart.n.Load())
+			span.SetAttributes(multipartAttrs(c.Request)...)
+		}
 		respSize := c.Writer.Size()
 		// If nothing written in the response yet, a value of -1 may be returned.
 		if respSize < 0 {
@@ -168,28 +390,82 @@ func TracingMiddleware(serviceName string, opts ...Option) gin.HandlerFunc {
 
 		// Set the span Status by http status code.
 		status := c.Writer.Status()
-		span.SetStatus(semconvutil.HTTPServerStatus(status))
+		if cfg.StatusMapper != nil {
+			span.SetStatus(cfg.StatusMapper(route, status))
+		} else {
+			span.SetStatus(semconvutil.HTTPServerStatus(status))
+		}
+		if streaming() {
+			span.SetAttributes(sse.attributes()...)
+		}
+		if len(cfg.ResponseHeaders) > 0 {
+			span.SetAttributes(headerAttrs(c.Writer.Header(), cfg.ResponseHeaders)...)
+		}
+
+		if lowOverhead {
+			if status > 0 {
+				span.SetAttributes(semconv.HTTPStatusCode(status))
+			}
+			if len(c.Errors) > 0 {
+				span.SetAttributes(attribute.String("gin.errors", c.Errors.String()))
+				span.SetAttributes(codedErrorAttrs(c.Errors)...)
+			}
+			opt := otelmetric.WithAttributeSet(routeSets.get(serviceName, c.Request, route, status))
+			cfg.reqSize.Add(ctx, int64(reqSize), opt)
+			cfg.respSize.Add(ctx, int64(respSize), opt)
+			if !streaming() {
+				cfg.reqDuration.Record(ctx, elapsedTime, opt)
+			}
+			cfg.activeReqs.Add(ctx, 1, opt)
+			return
+		}
 
 		// Set the attributes for the span and metrics.
 		cfg.reqSize.Add(ctx, int64(reqSize), otelmetric.WithAttributes(metricAttrs...))
 		cfg.respSize.Add(ctx, int64(respSize), otelmetric.WithAttributes(metricAttrs...))
 
 		if status > 0 {
-			statusAttr := semconv.HTTPStatusCode(status)
+			statusAttr := sc.KeyValue(semconv.HTTPStatusCode(status))
 			span.SetAttributes(statusAttr)
 			metricAttrs = append(metricAttrs, statusAttr)
 		}
 		if len(c.Errors) > 0 {
 			errAttr := attribute.String("gin.errors", c.Errors.String())
 			span.SetAttributes(errAttr)
+			span.SetAttributes(codedErrorAttrs(c.Errors)...)
 			metricAttrs = append(metricAttrs, errAttr)
 		}
 
-		cfg.reqDuration.Record(ctx, elapsedTime, otelmetric.WithAttributes(metricAttrs...))
+		if !streaming() {
+			cfg.reqDuration.Record(ctx, elapsedTime, otelmetric.WithAttributes(metricAttrs...))
+		}
 		cfg.activeReqs.Add(ctx, 1, otelmetric.WithAttributes(metricAttrs...))
 	}
 }
 
+// codedErrorAttrs returns the codes and the fields of the kgsotel.CodedError
+// of the handlers. They are only set on the span, as they may have a high
+// cardinality.
+func codedErrorAttrs(errs []*gin.Error) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, e := range errs {
+		attrs = append(attrs, kgsotel.ErrorAttributes(e.Err)...)
+	}
+	return attrs
+}
+
+// excludePaths returns a Filter rejecting the requests to the given paths.
+func excludePaths(paths []string) Filter {
+	excluded := make(map[string]struct{}, len(paths))
+	for _, p := range paths {
+		excluded[p] = struct{}{}
+	}
+	return func(r *http.Request) bool {
+		_, ok := excluded[r.URL.Path]
+		return !ok
+	}
+}
+
 // calcReqSize returns the total size of the request.
 // It will calculate the header size by iterate all the header KVs
 // and add with body size.
@@ -204,15 +480,6 @@ func calcReqSize(c *gin.Context) int {
 	// Restore the request body for further processing
 	c.Request.Body = io.NopCloser(bytes.NewReader(body))
 
-	// Calculate the size of headers
-	headerSize := 0
-	for name, values := range c.Request.Header {
-		headerSize += len(name) + 2 // Colon and space
-		for _, value := range values {
-			headerSize += len(value)
-		}
-	}
-
 	// Calculate the total size of the request (headers + body)
-	return headerSize + len(body)
+	return calcHeaderSize(c.Request) + len(body)
 }
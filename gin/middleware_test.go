@@ -0,0 +1,555 @@
+package otelgin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	kgsotel "kgs/otel"
+	"kgs/otel/internal/overhead"
+	"kgs/otel/oteltest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+func TestOverheadMeasurement(t *testing.T) {
+	overhead.SetEnabled(true)
+	defer overhead.SetEnabled(false)
+
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider)))
+	r.GET("/slow", func(c *gin.Context) {
+		time.Sleep(50 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	component := []attribute.KeyValue{overhead.ComponentKey.String("otelgin")}
+	oteltest.AssertHistogramCount(t, rec, "kgsotel.overhead.duration", component, 1)
+	oteltest.AssertHistogramCount(t, rec, "kgsotel.overhead.allocations", component, 1)
+	oteltest.AssertHistogramCount(t, rec, "kgsotel.overhead.allocated_bytes", component, 1)
+
+	// The time spent in the handler is not part of the overhead.
+	m, ok := rec.Metric(context.Background(), "kgsotel.overhead.duration")
+	require.True(t, ok)
+	dps := m.Data.(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, dps, 1)
+	assert.Less(t, dps[0].Sum, 50.0)
+}
+
+func TestWithTraceResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var rec *oteltest.Recorder
+	serve := func(opts ...Option) *httptest.ResponseRecorder {
+		rec = oteltest.NewRecorder()
+		r := gin.New()
+		r.Use(TracingMiddleware("svc", append(opts, WithTracerProvider(rec.TracerProvider))...))
+		r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		return w
+	}
+
+	assert.Empty(t, serve().Header().Get("traceresponse"), "off by default")
+
+	w := serve(WithTraceResponse(true))
+	span, ok := rec.Span("/ping")
+	require.True(t, ok)
+	sc := span.SpanContext()
+	assert.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", w.Header().Get("traceresponse"))
+}
+
+func TestWithSemconv(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider), WithSemconv(kgsotel.SemconvV1_26)))
+	r.GET("/ping", func(c *gin.Context) {
+		time.Sleep(10 * time.Millisecond)
+		c.String(http.StatusOK, "pong")
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping?v=1", nil))
+
+	span, ok := rec.Span("/ping")
+	require.True(t, ok)
+	attrs := attribute.NewSet(span.Attributes()...)
+	method, _ := attrs.Value("http.request.method")
+	assert.Equal(t, "GET", method.AsString())
+	status, _ := attrs.Value("http.response.status_code")
+	assert.Equal(t, int64(http.StatusOK), status.AsInt64())
+	assert.False(t, attrs.HasValue("http.method"))
+	assert.False(t, attrs.HasValue("http.status_code"))
+
+	// The stable conventions measure the durations in seconds.
+	m, ok := rec.Metric(context.Background(), "http.server.request.duration")
+	require.True(t, ok)
+	assert.Equal(t, "s", m.Unit)
+	dps := m.Data.(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, dps, 1)
+	assert.GreaterOrEqual(t, dps[0].Sum, 0.01)
+	assert.Less(t, dps[0].Sum, 1.0)
+	assert.Equal(t, 0.005, dps[0].Bounds[0])
+}
+
+func TestWithQueryParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(opts ...Option) attribute.Set {
+		rec := oteltest.NewRecorder()
+		r := gin.New()
+		r.Use(TracingMiddleware("svc", append(opts, WithTracerProvider(rec.TracerProvider))...))
+		r.GET("/search", func(c *gin.Context) { c.Status(http.StatusOK) })
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?q=shoes&page=2&page=3&token=s3cr3t&debug", nil))
+		span, ok := rec.Span("/search")
+		require.True(t, ok)
+		return attribute.NewSet(span.Attributes()...)
+	}
+
+	attrs := serve()
+	target, _ := attrs.Value(semconv.HTTPTargetKey)
+	assert.Equal(t, "/search", target.AsString(), "no query by default")
+
+	attrs = serve(WithQueryParams("q", "page"))
+	target, _ = attrs.Value(semconv.HTTPTargetKey)
+	assert.Equal(t, "/search?q=shoes&page=2&page=3&token=[REDACTED]&debug", target.AsString())
+	q, _ := attrs.Value("url.query.q")
+	assert.Equal(t, []string{"shoes"}, q.AsStringSlice())
+	page, _ := attrs.Value("url.query.page")
+	assert.Equal(t, []string{"2", "3"}, page.AsStringSlice())
+	assert.False(t, attrs.HasValue("url.query.token"))
+
+	attrs = serve(WithQueryParams())
+	target, _ = attrs.Value(semconv.HTTPTargetKey)
+	assert.Equal(t, "/search?q=[REDACTED]&page=[REDACTED]&page=[REDACTED]&token=[REDACTED]&debug", target.AsString())
+}
+
+func TestWithSSE(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider), WithSSE(true)))
+	r.GET("/events", func(c *gin.Context) {
+		for i := range 3 {
+			c.SSEvent("tick", i)
+			c.Writer.Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong\n\n") })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.Equal(t, 3, strings.Count(w.Body.String(), "event:tick"))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	span, ok := rec.Span("/events")
+	require.True(t, ok)
+	attrs := attribute.NewSet(span.Attributes()...)
+	streaming, _ := attrs.Value(StreamingKey)
+	events, _ := attrs.Value(SSEEventsKey)
+	assert.True(t, streaming.AsBool())
+	assert.Equal(t, int64(3), events.AsInt64())
+
+	span, ok = rec.Span("/ping")
+	require.True(t, ok)
+	pingAttrs := attribute.NewSet(span.Attributes()...)
+	assert.False(t, pingAttrs.HasValue(StreamingKey))
+
+	// The duration of the stream is its time to first byte.
+	m, ok := rec.Metric(context.Background(), "http.server.request.duration")
+	require.True(t, ok)
+	for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+		assert.Equal(t, uint64(1), dp.Count)
+		if route, _ := dp.Attributes.Value("http.route"); route.AsString() == "/events" {
+			assert.Less(t, dp.Sum, 20.0)
+		}
+	}
+}
+
+func TestMiddlewareRouterGroups(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	m := NewMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))
+
+	r := gin.New()
+	admin := r.Group("/admin", m.Handler(
+		WithCapturedHeaders([]string{"X-Admin-User"}, []string{"X-Request-Id"}),
+		WithRequestBodyCapture(8),
+	))
+	admin.POST("/users", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Header("X-Request-Id", "r-1")
+		c.String(http.StatusOK, string(body))
+	})
+	public := r.Group("/public", m.Handler())
+	public.POST("/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/admin/users", "/public/users"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name":"ada"}`))
+		req.Header.Set("X-Admin-User", "root")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if path == "/admin/users" {
+			assert.Equal(t, `{"name":"ada"}`, w.Body.String(), "the handler reads the whole body")
+		}
+	}
+
+	span, ok := rec.Span("/admin/users")
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), attribute.StringSlice("http.request.header.x-admin-user", []string{"root"}))
+	assert.Contains(t, span.Attributes(), attribute.StringSlice("http.response.header.x-request-id", []string{"r-1"}))
+	assert.Contains(t, span.Attributes(), RequestBodyKey.String(`{"name":`))
+
+	span, ok = rec.Span("/public/users")
+	require.True(t, ok)
+	for _, kv := range span.Attributes() {
+		assert.NotContains(t, string(kv.Key), "header")
+		assert.NotEqual(t, RequestBodyKey, kv.Key)
+	}
+
+	// Both groups record to the same instruments.
+	for _, route := range []string{"/admin/users", "/public/users"} {
+		oteltest.AssertHistogramCount(t, rec, "http.server.request.duration", []attribute.KeyValue{attribute.String("http.route", route)}, 1)
+	}
+}
+
+func TestMiddlewareRouterGroupSemconv(t *testing.T) {
+	var handled []error
+	handler := otel.GetErrorHandler()
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { handled = append(handled, err) }))
+	t.Cleanup(func() { otel.SetErrorHandler(handler) })
+
+	rec := oteltest.NewRecorder()
+	m := NewMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))
+	m.Handler(WithSemconv(kgsotel.SemconvV1_20))
+	assert.Empty(t, handled)
+
+	// The version of a group cannot differ from the middleware one.
+	m.Handler(WithSemconv(kgsotel.SemconvV1_26))
+	require.Len(t, handled, 1)
+	assert.Contains(t, handled[0].Error(), "semantic conventions")
+}
+
+func TestWithMaxRequestSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider), WithMaxRequestSize(8)))
+	var handled []string
+	r.POST("/upload", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		require.NoError(t, err)
+		handled = append(handled, string(body))
+		c.Status(http.StatusOK)
+	})
+
+	post := func(body io.Reader) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", body))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, post(strings.NewReader("12345678")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(strings.NewReader("123456789")))
+	// A reader of unknown length is sent chunked.
+	assert.Equal(t, http.StatusOK, post(io.MultiReader(strings.NewReader("1234"))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(io.MultiReader(strings.NewReader("123456789"))))
+	assert.Equal(t, []string{"12345678", "1234"}, handled)
+
+	route := attribute.String("http.route", "/upload")
+	oteltest.AssertSumValue(t, rec, "http.server.request.rejected", []attribute.KeyValue{route, RejectReasonKey.String(RejectContentLength)}, int64(1))
+	oteltest.AssertSumValue(t, rec, "http.server.request.rejected", []attribute.KeyValue{route, RejectReasonKey.String(RejectBodyTooLarge)}, int64(1))
+}
+
+func TestWithCORSPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(mode PreflightMode) *oteltest.Recorder {
+		rec := oteltest.NewRecorder()
+		r := gin.New()
+		r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider), WithCORSPreflight(mode)))
+		for _, path := range []string{"/users", "/orders"} {
+			r.OPTIONS(path, func(c *gin.Context) { c.Status(http.StatusNoContent) })
+			req := httptest.NewRequest(http.MethodOptions, path, nil)
+			req.Header.Set("Origin", "https://shop.example")
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		// Not a preflight, without the CORS headers.
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodOptions, "/users", nil))
+		return rec
+	}
+
+	rec := serve(PreflightExclude)
+	require.Len(t, rec.Spans(), 1)
+	assert.Equal(t, "/users", rec.Spans()[0].Name())
+
+	rec = serve(PreflightCollapse)
+	require.Len(t, rec.Spans(), 3)
+	span, ok := rec.Span(preflightSpanName)
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), CORSPreflightKey.Bool(true))
+	m, ok := rec.Metric(context.Background(), "http.server.request.duration")
+	require.True(t, ok)
+	assert.Len(t, m.Data.(metricdata.Histogram[float64]).DataPoints, 2, "the preflights share a series")
+}
+
+func TestWithStatusMapper(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithStatusMapper(func(route string, status int) (codes.Code, string) {
+		switch {
+		case status == http.StatusTooManyRequests:
+			return codes.Error, "rate limited"
+		case status == http.StatusInternalServerError && route == "/lookup/:id":
+			return codes.Unset, ""
+		}
+		return DefaultStatus(route, status)
+	})))
+	r.GET("/limited", func(c *gin.Context) { c.Status(http.StatusTooManyRequests) })
+	r.GET("/lookup/:id", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+	r.GET("/broken", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+	for _, path := range []string{"/limited", "/lookup/1", "/broken"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	want := map[string]codes.Code{"/limited": codes.Error, "/lookup/:id": codes.Unset, "/broken": codes.Error}
+	for name, code := range want {
+		span, ok := rec.Span(name)
+		require.True(t, ok, name)
+		assert.Equal(t, code, span.Status().Code, name)
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider)))
+	r.POST("/upload", func(c *gin.Context) {
+		_, err := c.MultipartForm()
+		require.NoError(t, err)
+		c.Status(http.StatusOK)
+	})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("album", "holidays"))
+	for name, content := range map[string]string{"a.jpg": "aaaa", "b.jpg": "bbbbbb"} {
+		fw, err := mw.CreateFormFile("photos", name)
+		require.NoError(t, err)
+		io.WriteString(fw, content)
+	}
+	require.NoError(t, mw.Close())
+	size := body.Len()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	headerSize := calcHeaderSize(req)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	span, ok := rec.Span("/upload")
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), MultipartFilesKey.Int64(2))
+	assert.Contains(t, span.Attributes(), MultipartFileBytesKey.Int64(10))
+	assert.Contains(t, span.Attributes(), MultipartFieldsKey.Int64(1))
+	oteltest.AssertSumValue(t, rec, "http.server.request.body.size", []attribute.KeyValue{attribute.String("http.route", "/upload")}, int64(headerSize+size))
+}
+
+func TestWithDebugHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authorize := func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer admin" }
+	r.Use(TracingMiddleware("svc", WithTracerProvider(oteltest.NewRecorder().TracerProvider),
+		WithPropagators(propagation.Baggage{}), WithDebugHeader("X-Debug", authorize)))
+	var debugged bool
+	r.GET("/ping", func(c *gin.Context) { debugged = kgsotel.DebugEnabled(c.Request.Context()) })
+
+	serve := func(headers ...string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return debugged
+	}
+	assert.False(t, serve())
+	assert.False(t, serve("X-Debug", "1"), "not authorized")
+	assert.True(t, serve("X-Debug", "1", "Authorization", "Bearer admin"))
+	assert.False(t, serve("X-Debug", "0", "Authorization", "Bearer admin"))
+	assert.False(t, serve("Baggage", "kgs-debug=1"), "propagated by an unauthorized caller")
+	assert.True(t, serve("Baggage", "kgs-debug=1", "Authorization", "Bearer admin"))
+}
+
+func TestDebugBaggageWithoutDebugHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(oteltest.NewRecorder().TracerProvider), WithPropagators(propagation.Baggage{})))
+	var debugged bool
+	var member string
+	r.GET("/ping", func(c *gin.Context) {
+		debugged = kgsotel.DebugEnabled(c.Request.Context())
+		member = baggage.FromContext(c.Request.Context()).Member("tenant").Value()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Baggage", "kgs-debug=1,tenant=acme")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	assert.False(t, debugged)
+	assert.Equal(t, "acme", member, "the other members are kept")
+}
+
+func TestCodedErrorAttributes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider)))
+	r.GET("/orders/:id", func(c *gin.Context) {
+		_ = c.Error(kgsotel.NewError("ORDER_NOT_FOUND", "order missing", kgsotel.NewFiled("order.id", "7")))
+		c.Status(http.StatusNotFound)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders/7", nil))
+
+	span, ok := rec.Span("/orders/:id")
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), attribute.String(kgsotel.ErrorCodeKey, "ORDER_NOT_FOUND"))
+	assert.Contains(t, span.Attributes(), attribute.String("order.id", "7"))
+}
+
+func TestWebSocketUpgrade(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider)))
+	r.GET("/ws", func(c *gin.Context) {
+		conn, rw, err := c.Writer.Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+		// The connection is served until the client closes it.
+		_, _ = rw.ReadByte()
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	require.NoError(t, err)
+	_, err = io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, conn.Close())
+
+	require.Eventually(t, func() bool {
+		_, ok := rec.Span("/ws")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+	span, _ := rec.Span("/ws")
+	assert.Contains(t, span.Attributes(), semconv.HTTPStatusCode(http.StatusSwitchingProtocols))
+	assert.Contains(t, span.Attributes(), UpgradeKey.String("websocket"))
+	assert.Equal(t, codes.Unset, span.Status().Code)
+
+	// The request duration is the handshake, not the connection.
+	m, ok := rec.Metric(context.Background(), "http.server.request.duration")
+	require.True(t, ok)
+	dps := m.Data.(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, dps, 1)
+	assert.Less(t, dps[0].Sum, 50.0)
+}
+
+func TestWithSlowThreshold(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc",
+		WithTracerProvider(rec.TracerProvider),
+		WithSlowThreshold(20*time.Millisecond),
+		WithRouteSlowThreshold("/export", time.Second),
+	))
+	r.Use(func(c *gin.Context) {
+		time.Sleep(15 * time.Millisecond)
+		c.Next()
+	})
+	handler := func(c *gin.Context) {
+		time.Sleep(15 * time.Millisecond)
+		c.Status(http.StatusOK)
+	}
+	r.GET("/report", TimeHandler(handler))
+	r.GET("/export", handler)
+	for _, path := range []string{"/report", "/export"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	span, ok := rec.Span("/report")
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), SlowKey.Bool(true))
+	require.Len(t, span.Events(), 1)
+	event := span.Events()[0]
+	assert.Equal(t, "slow request", event.Name)
+	breakdown := map[attribute.Key]float64{}
+	for _, kv := range event.Attributes {
+		breakdown[kv.Key] = kv.Value.AsFloat64()
+	}
+	assert.Equal(t, 20.0, breakdown["kgs.slow.threshold_ms"])
+	assert.GreaterOrEqual(t, breakdown["kgs.slow.duration_ms"], 30.0)
+	assert.GreaterOrEqual(t, breakdown["kgs.slow.handler_ms"], 15.0)
+	assert.GreaterOrEqual(t, breakdown["kgs.slow.middleware_ms"], 15.0)
+
+	// The threshold of the route is not exceeded.
+	span, ok = rec.Span("/export")
+	require.True(t, ok)
+	assert.NotContains(t, span.Attributes(), SlowKey.Bool(true))
+	assert.Empty(t, span.Events())
+}
+
+func TestWithCarriers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := oteltest.NewRecorder()
+	r := gin.New()
+	r.Use(TracingMiddleware("svc",
+		WithTracerProvider(rec.TracerProvider),
+		WithPropagators(propagation.TraceContext{}),
+		WithCarriers(QueryCarrier),
+		WithRouteCarriers("/webhook", CookieCarrier),
+	))
+	r.GET("/page", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/webhook", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parent  = "00-" + traceID + "-00f067aa0ba902b7-01"
+	)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/page?traceparent="+parent, nil))
+	req := httptest.NewRequest(http.MethodGet, "/webhook?traceparent="+parent, nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest(http.MethodGet, "/webhook", nil)
+	req.AddCookie(&http.Cookie{Name: "traceparent", Value: parent})
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := rec.Spans()
+	require.Len(t, spans, 3)
+	assert.Equal(t, traceID, spans[0].SpanContext().TraceID().String())
+	// The query carrier is replaced by the cookie one on the webhook route.
+	assert.NotEqual(t, traceID, spans[1].SpanContext().TraceID().String())
+	assert.Equal(t, traceID, spans[2].SpanContext().TraceID().String())
+}
@@ -0,0 +1,76 @@
+package otelgin
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Attributes of the multipart uploads.
+const (
+	// MultipartFilesKey is the number of files of a multipart request.
+	MultipartFilesKey = attribute.Key("http.request.multipart.files")
+	// MultipartFileBytesKey is the total size of the files in bytes.
+	MultipartFileBytesKey = attribute.Key("http.request.multipart.file_bytes")
+	// MultipartFieldsKey is the number of the other form fields.
+	MultipartFieldsKey = attribute.Key("http.request.multipart.fields")
+)
+
+// isMultipart reports whether r is a multipart/form-data request, whose body
+// is not buffered to measure it.
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// countingBody counts the bytes of the body read by the handlers.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// multipartAttrs returns the attributes of the parts of r parsed by the
+// handlers, from their headers.
+func multipartAttrs(r *http.Request) []attribute.KeyValue {
+	form := r.MultipartForm
+	if form == nil {
+		return nil
+	}
+	var files, size int64
+	for _, headers := range form.File {
+		for _, h := range headers {
+			files++
+			size += h.Size
+		}
+	}
+	var fields int64
+	for _, values := range form.Value {
+		fields += int64(len(values))
+	}
+	return []attribute.KeyValue{
+		MultipartFilesKey.Int64(files),
+		MultipartFileBytesKey.Int64(size),
+		MultipartFieldsKey.Int64(fields),
+	}
+}
+
+// calcHeaderSize returns the size of the request headers.
+func calcHeaderSize(r *http.Request) int {
+	headerSize := 0
+	for name, values := range r.Header {
+		headerSize += len(name) + 2 // Colon and space
+		for _, value := range values {
+			headerSize += len(value)
+		}
+	}
+	return headerSize
+}
@@ -6,9 +6,14 @@
 package otelgin
 
 import (
+	kgsotel "kgs/otel"
+	"kgs/otel/internal/semconvutil"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	otelmetric "go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	oteltrace "go.opentelemetry.io/otel/trace"
@@ -21,11 +26,29 @@ type config struct {
 	Filters           []Filter
 	GinFilters        []GinFilter
 	SpanNameFormatter SpanNameFormatter
+	DurationBuckets   []float64
+	TraceResponse     bool
+	SSE               bool
+	RequestHeaders    []capturedHeader
+	ResponseHeaders   []capturedHeader
+	RequestBodyMax    int
+	MaxRequestSize    int64
+	CORSPreflight     PreflightMode
+	StatusMapper      StatusMapper
+	DebugHeader       string
+	DebugAuthorize    func(*http.Request) bool
+	SlowThreshold     time.Duration
+	SlowThresholds    map[string]time.Duration
+	Carriers          []CarrierFunc
+	RouteCarriers     map[string][]CarrierFunc
+	Semconv           kgsotel.SemconvVersion
+	QueryParams       map[string]attribute.Key
 
 	reqDuration otelmetric.Float64Histogram
 	reqSize     otelmetric.Int64UpDownCounter
 	respSize    otelmetric.Int64UpDownCounter
 	activeReqs  otelmetric.Int64UpDownCounter
+	rejected    otelmetric.Int64Counter
 }
 
 // Adding new Filter parameter (*gin.Context)
@@ -39,6 +62,10 @@ type Filter func(*http.Request) bool
 // SpanNameFormatter is used to set span name by http.request.
 type SpanNameFormatter func(r *http.Request) string
 
+// StatusMapper returns the span status of a response with the HTTP status
+// code to the route, which is empty if no route matched.
+type StatusMapper func(route string, status int) (codes.Code, string)
+
 // Option specifies instrumentation configuration options.
 type Option interface {
 	apply(*config)
@@ -81,6 +108,18 @@ func WithMeterProvider(provider otelmetric.MeterProvider) Option {
 	})
 }
 
+// WithTelemetry specifies a telemetry pipeline whose tracer provider, meter
+// provider and propagator are used instead of the global ones.
+func WithTelemetry(t *kgsotel.Telemetry) Option {
+	return optionFunc(func(cfg *config) {
+		if t != nil {
+			cfg.TracerProvider = t.TracerProvider()
+			cfg.MeterProvider = t.MeterProvider()
+			cfg.Propagators = t.Propagator()
+		}
+	})
+}
+
 // WithFilter adds a filter to the list of filters used by the handler.
 // If any filter indicates to exclude a request then the request will not be
 // traced. All filters must allow a request to be traced for a Span to be created.
@@ -107,3 +146,50 @@ func WithGinFilter(f ...GinFilter) Option {
 		c.GinFilters = append(c.GinFilters, f...)
 	})
 }
+
+// WithDurationBuckets specifies explicit bucket boundaries (in milliseconds,
+// or in seconds with the v1.26.0 conventions) for the request duration
+// histogram. If none are specified, the SDK default boundaries are used, or
+// the boundaries advised by the v1.26.0 conventions.
+func WithDurationBuckets(bounds ...float64) Option {
+	return optionFunc(func(c *config) {
+		c.DurationBuckets = bounds
+	})
+}
+
+// WithTraceResponse specifies whether the traceresponse header of the W3C
+// Trace Context Level 2 is set on the responses, so the callers that did not
+// start the trace, e.g. browsers, can find the server-side trace. It is off
+// by default, as it reveals the trace IDs to the callers.
+func WithTraceResponse(enabled bool) Option {
+	return optionFunc(func(c *config) {
+		c.TraceResponse = enabled
+	})
+}
+
+// WithStatusMapper returns an Option to set the span status of the responses
+// with f instead of the semantic conventions, which only treat the 5xx as
+// errors, e.g. to treat 429 as an error or 404 on a lookup route as success.
+// DefaultStatus can be called for the statuses f does not override.
+func WithStatusMapper(f StatusMapper) Option {
+	return optionFunc(func(c *config) {
+		c.StatusMapper = f
+	})
+}
+
+// WithSemconv returns an Option to select the version of the semantic
+// conventions of the middleware instead of the one of kgsotel.WithSemconv.
+// Like the providers, it cannot be changed by the options of a router group.
+func WithSemconv(version kgsotel.SemconvVersion) Option {
+	return optionFunc(func(c *config) {
+		if version.Valid() {
+			c.Semconv = version
+		}
+	})
+}
+
+// DefaultStatus returns the span status of a server response by the
+// semantic conventions.
+func DefaultStatus(_ string, status int) (codes.Code, string) {
+	return semconvutil.HTTPServerStatus(status)
+}
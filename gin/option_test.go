@@ -0,0 +1,40 @@
+package otelgin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestWithDurationBuckets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	r := gin.New()
+	r.Use(TracingMiddleware("svc", WithMeterProvider(mp), WithDurationBuckets(5, 50, 500)))
+	r.GET("/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "http.server.request.duration" {
+				continue
+			}
+			dps := m.Data.(metricdata.Histogram[float64]).DataPoints
+			require.Len(t, dps, 1)
+			assert.Equal(t, []float64{5, 50, 500}, dps[0].Bounds)
+			assert.Len(t, dps[0].BucketCounts, 4)
+			return
+		}
+	}
+	t.Fatal("metric http.server.request.duration not found")
+}
@@ -0,0 +1,43 @@
+// Package otelgintest mounts the otelgin middleware on a gin engine
+// recording its telemetry in memory, to test the filters, span name
+// formatters and attributes of a service.
+package otelgintest
+
+import (
+	otelgin "kgs/otel/gin"
+	"kgs/otel/oteltest"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Harness is a gin engine instrumented by the otelgin middleware.
+type Harness struct {
+	*oteltest.Recorder
+
+	// Engine serves the requests, register the routes under test on it.
+	Engine *gin.Engine
+}
+
+// New returns a Harness whose middleware is created with the options.
+func New(serviceName string, opts ...otelgin.Option) *Harness {
+	gin.SetMode(gin.TestMode)
+
+	recorder := oteltest.NewRecorder()
+	opts = append([]otelgin.Option{
+		otelgin.WithTracerProvider(recorder.TracerProvider),
+		otelgin.WithMeterProvider(recorder.MeterProvider),
+	}, opts...)
+
+	engine := gin.New()
+	engine.Use(otelgin.TracingMiddleware(serviceName, opts...))
+	return &Harness{Recorder: recorder, Engine: engine}
+}
+
+// Do serves the request and returns the recorded response.
+func (h *Harness) Do(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.Engine.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,46 @@
+package otelgintest
+
+import (
+	"context"
+	otelgin "kgs/otel/gin"
+	"kgs/otel/oteltest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+func TestHarness(t *testing.T) {
+	h := New("svc", otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/healthz"
+	}))
+	h.Engine.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	h.Engine.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	assert.Equal(t, http.StatusOK, h.Do(httptest.NewRequest(http.MethodGet, "/users/1", nil)).Code)
+	assert.Equal(t, http.StatusOK, h.Do(httptest.NewRequest(http.MethodGet, "/healthz", nil)).Code)
+
+	require.Len(t, h.Spans(), 1)
+	span, ok := h.Span("/users/:id")
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), semconv.HTTPRoute("/users/:id"))
+
+	_, ok = h.Metric(context.Background(), "http.server.request.duration")
+	assert.True(t, ok)
+}
+
+func TestGoldenSpans(t *testing.T) {
+	h := New("svc")
+	h.Engine.GET("/users/:id", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	h.Engine.POST("/users", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+
+	h.Do(httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	h.Do(httptest.NewRequest(http.MethodPost, "/users", nil))
+	h.Do(httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	oteltest.AssertGoldenSpans(t, "testdata/spans.golden.json", h.Spans())
+}
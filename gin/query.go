@@ -0,0 +1,69 @@
+package otelgin
+
+import (
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// queryRedacted replaces the values of the query parameters not captured.
+const queryRedacted = "[REDACTED]"
+
+// WithQueryParams returns an Option to add the query parameters named to the
+// spans, as url.query.<name> attributes, e.g. url.query.page for ?page=2.
+// The http.target attribute then includes the query with the values of the
+// other parameters redacted, so it never exposes more than the allowed
+// parameters, e.g. an access token:
+//
+//	/search?q=shoes&page=2&token=[REDACTED]
+//
+// Without names, the query is recorded with all its values redacted.
+func WithQueryParams(names ...string) Option {
+	return optionFunc(func(c *config) {
+		c.QueryParams = make(map[string]attribute.Key, len(names))
+		for _, name := range names {
+			c.QueryParams[name] = attribute.Key("url.query." + name)
+		}
+	})
+}
+
+// queryAttrs returns attrs with the redacted query of u added to its
+// http.target, and the attributes of the allowed parameters.
+func queryAttrs(attrs []attribute.KeyValue, u *url.URL, allowed map[string]attribute.Key) []attribute.KeyValue {
+	if u.RawQuery == "" {
+		return attrs
+	}
+	for i, kv := range attrs {
+		if kv.Key == semconv.HTTPTargetKey {
+			attrs[i] = semconv.HTTPTarget(kv.Value.AsString() + "?" + redactQuery(u.RawQuery, allowed))
+		}
+	}
+	values := u.Query()
+	for name, key := range allowed {
+		if v, ok := values[name]; ok {
+			attrs = append(attrs, key.StringSlice(v))
+		}
+	}
+	return attrs
+}
+
+// redactQuery returns the raw query with the values of the parameters not
+// allowed redacted, keeping their order.
+func redactQuery(query string, allowed map[string]attribute.Key) string {
+	var b strings.Builder
+	for i, param := range strings.Split(query, "&") {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		key, _, hasValue := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(key)
+		if _, ok := allowed[name]; (ok && err == nil) || !hasValue {
+			b.WriteString(param)
+			continue
+		}
+		b.WriteString(key + "=" + queryRedacted)
+	}
+	return b.String()
+}
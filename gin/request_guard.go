@@ -0,0 +1,70 @@
+package otelgin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RejectReasonKey is the reason a request was rejected.
+const RejectReasonKey = attribute.Key("http.request.reject_reason")
+
+// The reasons of the rejected requests.
+const (
+	// RejectContentLength is a request declaring a body over the limit.
+	RejectContentLength = "content_length"
+	// RejectBodyTooLarge is a request of unknown length whose body is over
+	// the limit.
+	RejectBodyTooLarge = "body_too_large"
+)
+
+// WithMaxRequestSize returns an Option to reject the requests whose body is
+// over maxBytes with 413 Request Entity Too Large. The rejected requests
+// are counted by the http.server.request.rejected metric with the
+// http.request.reject_reason attribute, so abuse and misconfigured clients
+// are visible. The bodies of unknown length are buffered up to the limit.
+func WithMaxRequestSize(maxBytes int64) Option {
+	return optionFunc(func(c *config) {
+		c.MaxRequestSize = maxBytes
+	})
+}
+
+// guardRequestSize rejects the request if its body is over max, and returns
+// the reason, or "" if it is accepted.
+func guardRequestSize(c *gin.Context, max int64) string {
+	r := c.Request
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+	if r.ContentLength > max {
+		c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		return RejectContentLength
+	}
+	if r.ContentLength >= 0 {
+		// The server does not read past the declared length.
+		return ""
+	}
+
+	head, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	if int64(len(head)) > max {
+		c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		return RejectBodyTooLarge
+	}
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(head), errReader{err}), Closer: r.Body}
+	return ""
+}
+
+// errReader returns the error of reading the body, or io.EOF.
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,53 @@
+package otelgin
+
+import (
+	"time"
+
+	"kgs/otel/internal/slow"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SlowKey marks the spans of the requests exceeding their slow threshold.
+const SlowKey = slow.Key
+
+// WithSlowThreshold returns an Option to mark the spans of the requests
+// taking longer than d with kgs.slow=true and a "slow request" event
+// breaking their duration down into handler and middleware time.
+func WithSlowThreshold(d time.Duration) Option {
+	return optionFunc(func(c *config) {
+		c.SlowThreshold = d
+	})
+}
+
+// WithRouteSlowThreshold returns an Option to override the slow threshold of
+// the requests to route, e.g. "/reports/:id". A zero d disables the
+// annotation for the route.
+func WithRouteSlowThreshold(route string, d time.Duration) Option {
+	return optionFunc(func(c *config) {
+		if c.SlowThresholds == nil {
+			c.SlowThresholds = make(map[string]time.Duration)
+		}
+		c.SlowThresholds[route] = d
+	})
+}
+
+// TimeHandler wraps the final handler of a route so the breakdown of its
+// slow requests separates the handler from the middlewares registered after
+// the tracing middleware. Without it, the handler time includes them.
+//
+//	r.GET("/reports/:id", otelgin.TimeHandler(getReport))
+func TimeHandler(h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer slow.StartHandler(c.Request.Context())()
+		h(c)
+	}
+}
+
+// slowThreshold returns the slow threshold of the requests to route.
+func (c *config) slowThreshold(route string) time.Duration {
+	if d, ok := c.SlowThresholds[route]; ok {
+		return d
+	}
+	return c.SlowThreshold
+}
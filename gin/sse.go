@@ -0,0 +1,97 @@
+package otelgin
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// StreamingKey marks the spans of the Server-Sent Events streams.
+	StreamingKey = attribute.Key("http.response.streaming")
+	// TimeToFirstByteKey is the time to the first byte of a stream in
+	// milliseconds.
+	TimeToFirstByteKey = attribute.Key("http.response.time_to_first_byte_ms")
+	// SSEEventsKey is the number of events sent on a stream.
+	SSEEventsKey = attribute.Key("sse.events_sent")
+)
+
+// WithSSE returns an Option to handle the Server-Sent Events endpoints,
+// whose responses have the text/event-stream content type. Their spans are
+// marked as streaming, with the time to first byte and the number of events
+// sent, and the request duration histogram measures their time to first
+// byte, as a stream may last for hours. It counts the bytes written by all
+// the handlers, so it is off by default.
+func WithSSE(enabled bool) Option {
+	return optionFunc(func(c *config) {
+		c.SSE = enabled
+	})
+}
+
+// sseWriter counts the events written to a Server-Sent Events stream.
+type sseWriter struct {
+	gin.ResponseWriter
+
+	start     time.Time
+	firstByte func(w *sseWriter)
+
+	wrote     bool
+	streaming bool
+	ttfb      time.Duration
+	events    int64
+	last      byte
+}
+
+func newSSEWriter(w gin.ResponseWriter, firstByte func(w *sseWriter)) *sseWriter {
+	return &sseWriter{ResponseWriter: w, start: time.Now(), firstByte: firstByte}
+}
+
+// first detects the streams and calls firstByte at the first write.
+func (w *sseWriter) first() {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+	w.streaming = strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	if w.streaming {
+		w.ttfb = time.Since(w.start)
+		w.firstByte(w)
+	}
+}
+
+// count counts the events of the stream, each ended by a blank line.
+func (w *sseWriter) count(s string) {
+	if !w.streaming || len(s) == 0 {
+		return
+	}
+	if w.last == '\n' && s[0] == '\n' {
+		w.events++
+	}
+	w.events += int64(strings.Count(s, "\n\n"))
+	w.last = s[len(s)-1]
+}
+
+func (w *sseWriter) Write(b []byte) (int, error) {
+	w.first()
+	n, err := w.ResponseWriter.Write(b)
+	w.count(string(b[:n]))
+	return n, err
+}
+
+func (w *sseWriter) WriteString(s string) (int, error) {
+	w.first()
+	n, err := w.ResponseWriter.WriteString(s)
+	w.count(s[:n])
+	return n, err
+}
+
+// attributes returns the span attributes of the stream.
+func (w *sseWriter) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		StreamingKey.Bool(true),
+		TimeToFirstByteKey.Float64(float64(w.ttfb) / float64(time.Millisecond)),
+		SSEEventsKey.Int64(w.events),
+	}
+}
@@ -0,0 +1,50 @@
+package otelgin
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// UpgradeKey is the protocol a request was upgraded to, e.g. websocket.
+const UpgradeKey = attribute.Key("http.upgrade")
+
+// isWebSocketUpgrade reports whether the request asks to upgrade to the
+// WebSocket protocol.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
+
+// upgradeWriter detects the connections hijacked by the WebSocket upgrades,
+// e.g. by gorilla/websocket, whose 101 status is not written through the
+// writer. The request duration histogram measures their handshake, as the
+// handler serves the connection until it is closed.
+type upgradeWriter struct {
+	gin.ResponseWriter
+	hijackedAt time.Time
+}
+
+func (w *upgradeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.Hijack()
+	if err == nil {
+		w.hijackedAt = time.Now()
+	}
+	return conn, rw, err
+}
+
+func (w *upgradeWriter) Status() int {
+	if w.upgraded() {
+		return http.StatusSwitchingProtocols
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *upgradeWriter) upgraded() bool {
+	return !w.hijackedAt.IsZero()
+}
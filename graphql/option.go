@@ -0,0 +1,60 @@
+package otelgraphql
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// config is a group of options for the tracer.
+type config struct {
+	TracerProvider trace.TracerProvider
+	SpanAttributes []attribute.KeyValue
+	TrivialFields  bool
+	Document       bool
+}
+
+// Option applies an option value for a config.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithTracerProvider returns an Option to use the tracer provider.
+// If none is specified, the global provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.TracerProvider = provider
+		}
+	})
+}
+
+// WithSpanAttributes returns an Option to add attributes to the query spans.
+func WithSpanAttributes(attrs ...attribute.KeyValue) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SpanAttributes = attrs
+	})
+}
+
+// WithTrivialFields returns an Option to trace the trivial fields too, the
+// fields resolved from a struct field or a method without context. They
+// are not traced by default, as they are many and take no time.
+func WithTrivialFields(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.TrivialFields = enabled
+	})
+}
+
+// WithDocument returns an Option to add the query document to the query
+// spans in graphql.document. The documents may hold literal arguments, so
+// it is off by default.
+func WithDocument(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.Document = enabled
+	})
+}
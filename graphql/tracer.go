@@ -0,0 +1,187 @@
+// Package otelgraphql traces the queries and the field resolutions of the
+// graph-gophers/graphql-go servers, with their errors.
+//
+// The module does not depend on graphql-go, whose tracer interface uses its
+// own types, so the Tracer takes the standard types and a few lines bind it
+// to the interface of the graphql-go version of the service:
+//
+//	type tracer struct{ *otelgraphql.Tracer }
+//
+//	func (t tracer) TraceQuery(ctx context.Context, query, operationName string, variables map[string]interface{}, _ map[string]*introspection.Type) (context.Context, gqltrace.QueryFinishFunc) {
+//		ctx, finish := t.Tracer.TraceQuery(ctx, query, operationName, variables)
+//		return ctx, func(errs []*gqlerrors.QueryError) { finish(otelgraphql.Errors(errs)) }
+//	}
+//
+//	func (t tracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, args map[string]interface{}) (context.Context, gqltrace.FieldFinishFunc) {
+//		ctx, finish := t.Tracer.TraceField(ctx, label, typeName, fieldName, trivial, args)
+//		return ctx, func(err *gqlerrors.QueryError) { finish(otelgraphql.Error(err)) }
+//	}
+//
+//	schema := graphql.MustParseSchema(sdl, resolver, graphql.Tracer(tracer{otelgraphql.NewTracer()}))
+package otelgraphql
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ScopeName is the instrumentation scope name.
+	ScopeName = "kgs/otel/graphql"
+
+	// FieldNameKey is the name of the resolved field.
+	FieldNameKey = attribute.Key("graphql.field.name")
+	// FieldTypeKey is the name of the type of the resolved field.
+	FieldTypeKey = attribute.Key("graphql.field.parent_type")
+	// FieldAliasKey is the alias of the resolved field, if any.
+	FieldAliasKey = attribute.Key("graphql.field.alias")
+	// ErrorCountKey is the number of errors of a query.
+	ErrorCountKey = attribute.Key("graphql.errors.count")
+)
+
+// Tracer traces the queries and the fields of a graphql-go schema.
+type Tracer struct {
+	cfg    *config
+	tracer trace.Tracer
+}
+
+// NewTracer returns a Tracer with the options.
+func NewTracer(opts ...Option) *Tracer {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	return &Tracer{cfg: cfg, tracer: cfg.TracerProvider.Tracer(ScopeName)}
+}
+
+// TraceQuery starts the span of a query, named after its operation type and
+// name, e.g. "query GetUser". The returned function ends it with the errors
+// of the query, which are recorded as exception events. The variables are
+// not recorded.
+func (t *Tracer) TraceQuery(ctx context.Context, query, operationName string, _ map[string]interface{}) (context.Context, func([]error)) {
+	opType := operationType(query, operationName)
+	name := opType
+	attrs := make([]attribute.KeyValue, 0, len(t.cfg.SpanAttributes)+3)
+	attrs = append(attrs, semconv.GraphqlOperationTypeKey.String(opType))
+	if operationName != "" {
+		name += " " + operationName
+		attrs = append(attrs, semconv.GraphqlOperationName(operationName))
+	}
+	if t.cfg.Document {
+		attrs = append(attrs, semconv.GraphqlDocument(query))
+	}
+	attrs = append(attrs, t.cfg.SpanAttributes...)
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+	return ctx, func(errs []error) {
+		if len(errs) > 0 {
+			for _, err := range errs {
+				span.RecordError(err)
+			}
+			span.SetAttributes(ErrorCountKey.Int(len(errs)))
+			span.SetStatus(codes.Error, errs[0].Error())
+		}
+		span.End()
+	}
+}
+
+// TraceField starts the span of a field resolution, named after its type
+// and name, e.g. "User.friends". The trivial fields are not traced unless
+// WithTrivialFields is set. The returned function ends it with the error of
+// the resolver, attributed to the field. The arguments are not recorded.
+func (t *Tracer) TraceField(ctx context.Context, label, typeName, fieldName string, trivial bool, _ map[string]interface{}) (context.Context, func(error)) {
+	if trivial && !t.cfg.TrivialFields {
+		return ctx, func(error) {}
+	}
+	attrs := []attribute.KeyValue{FieldNameKey.String(fieldName), FieldTypeKey.String(typeName)}
+	if label != "" && label != fieldName {
+		attrs = append(attrs, FieldAliasKey.String(label))
+	}
+	ctx, span := t.tracer.Start(ctx, typeName+"."+fieldName, trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// Errors converts the errors of a query, e.g. []*errors.QueryError, to a
+// slice of errors, without the nil ones.
+func Errors[E error](errs []E) []error {
+	var converted []error
+	for _, err := range errs {
+		if e := Error(err); e != nil {
+			converted = append(converted, e)
+		}
+	}
+	return converted
+}
+
+// Error converts the error of a field, e.g. an *errors.QueryError, to an
+// error, nil if it is a nil pointer.
+func Error[E error](err E) error {
+	if v := reflect.ValueOf(err); !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return nil
+	}
+	return err
+}
+
+// operationType returns the type of the operation of the query: query,
+// mutation or subscription.
+func operationType(query, operationName string) string {
+	doc := query
+	for {
+		doc = strings.TrimLeft(doc, " \t\r\n,")
+		if strings.HasPrefix(doc, "#") {
+			if i := strings.IndexByte(doc, '\n'); i >= 0 {
+				doc = doc[i:]
+				continue
+			}
+			return "query"
+		}
+		break
+	}
+	// With several operations, the type is the one of the operation named.
+	if operationName != "" {
+		for _, typ := range []string{"query", "mutation", "subscription"} {
+			if isOperation(doc, typ+" "+operationName) {
+				return typ
+			}
+		}
+	}
+	for _, typ := range []string{"mutation", "subscription"} {
+		if strings.HasPrefix(doc, typ) {
+			return typ
+		}
+	}
+	return "query"
+}
+
+// isOperation reports whether the document defines the operation, e.g.
+// "mutation CreateUser".
+func isOperation(doc, operation string) bool {
+	for i := 0; ; {
+		j := strings.Index(doc[i:], operation)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(operation)
+		before := strings.TrimRight(doc[:start], " \t\r\n,")
+		if (before == "" || strings.HasSuffix(before, "}")) &&
+			(end == len(doc) || strings.IndexByte(" \t\r\n({@", doc[end]) >= 0) {
+			return true
+		}
+		i = end
+	}
+}
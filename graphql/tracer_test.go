@@ -0,0 +1,74 @@
+package otelgraphql
+
+import (
+	"context"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// queryError stands for *errors.QueryError of graphql-go.
+type queryError struct{ msg string }
+
+func (e *queryError) Error() string { return e.msg }
+
+func TestTracer(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	tracer := NewTracer(WithTracerProvider(rec.TracerProvider))
+
+	ctx, finishQuery := tracer.TraceQuery(context.Background(), "query GetUser { user(id: 1) { name friends { name } } }", "GetUser", nil)
+	_, finishName := tracer.TraceField(ctx, "name", "User", "name", true, nil)
+	finishName(Error[*queryError](nil))
+	_, finishFriends := tracer.TraceField(ctx, "friends", "User", "friends", false, nil)
+	failure := &queryError{"friends service down"}
+	finishFriends(Error(failure))
+	finishQuery(Errors([]*queryError{failure}))
+
+	spans := rec.Spans()
+	require.Len(t, spans, 2, "the trivial fields are not traced")
+	field, query := spans[0], spans[1]
+	assert.Equal(t, "User.friends", field.Name())
+	assert.Equal(t, query.SpanContext().SpanID(), field.Parent().SpanID())
+	assert.Contains(t, field.Attributes(), FieldNameKey.String("friends"))
+	assert.Equal(t, codes.Error, field.Status().Code)
+
+	assert.Equal(t, "query GetUser", query.Name())
+	assert.Contains(t, query.Attributes(), semconv.GraphqlOperationName("GetUser"))
+	assert.Contains(t, query.Attributes(), ErrorCountKey.Int(1))
+	assert.Equal(t, "friends service down", query.Status().Description)
+	for _, attr := range query.Attributes() {
+		assert.NotEqual(t, semconv.GraphqlDocumentKey, attr.Key, "the document is off by default")
+	}
+}
+
+func TestTracerSuccess(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	tracer := NewTracer(WithTracerProvider(rec.TracerProvider), WithDocument(true))
+
+	_, finish := tracer.TraceQuery(context.Background(), "{ me { name } }", "", nil)
+	finish(Errors([]*queryError{}))
+
+	span, ok := rec.Span("query")
+	require.True(t, ok)
+	assert.Equal(t, codes.Unset, span.Status().Code)
+	assert.Contains(t, span.Attributes(), semconv.GraphqlDocument("{ me { name } }"))
+}
+
+func TestOperationType(t *testing.T) {
+	tests := []struct {
+		query, name, want string
+	}{
+		{"{ me { name } }", "", "query"},
+		{"# create\nmutation CreateUser($name: String!) { createUser(name: $name) { id } }", "CreateUser", "mutation"},
+		{"subscription { events }", "", "subscription"},
+		{"query CreateUsers { a } mutation CreateUser { b }", "CreateUser", "mutation"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, operationType(tt.query, tt.name), tt.query)
+	}
+}
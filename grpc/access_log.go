@@ -0,0 +1,56 @@
+package otelgrpc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// AccessLogMessage is the message of the access logs.
+const AccessLogMessage = "grpc call"
+
+// WithAccessLog returns an Option to write one structured log per RPC to the
+// global logger when it ends, with the method, the status, the duration, the
+// message counts, the peer and the trace ID of the RPC span. The RPCs failing
+// with a server error (Unknown, DeadlineExceeded, Unimplemented, Internal,
+// Unavailable or DataLoss) are logged at the error level, the others at the
+// info level.
+func WithAccessLog(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.AccessLog = enabled
+	})
+}
+
+// logAccess writes the access log of the ended RPC.
+func (m *middleware) logAccess(ctx context.Context, gctx *gRPCContext, rs *stats.End, code grpcCodes.Code) {
+	elapsed := float64(rs.EndTime.Sub(rs.BeginTime)) / float64(time.Millisecond)
+	fields := []zap.Field{
+		zap.String("rpc.method", gctx.fullMethod),
+		zap.String("rpc.role", m.role.String()),
+		zap.Int("rpc.grpc.status_code", int(code)),
+		zap.String("rpc.grpc.status", code.String()),
+		zap.Float64("rpc.duration_ms", elapsed),
+		zap.Int64("rpc.messages_received", atomic.LoadInt64(&gctx.messagesReceived)),
+		zap.Int64("rpc.messages_sent", atomic.LoadInt64(&gctx.messagesSent)),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("net.peer.address", p.Addr.String()))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields, zap.String("traceID", sc.TraceID().String()), zap.String("spanID", sc.SpanID().String()))
+	}
+
+	if c, _ := serverStatus(status.New(code, "")); c == codes.Error {
+		zap.L().Error(AccessLogMessage, append(fields, zap.Error(rs.Error))...)
+		return
+	}
+	zap.L().Info(AccessLogMessage, fields...)
+}
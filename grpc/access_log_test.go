@@ -0,0 +1,68 @@
+package otelgrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+func TestWithAccessLog(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	rec := oteltest.NewRecorder()
+	h := TracingMiddleware(RoleServer, WithTracerProvider(rec.TracerProvider), WithAccessLog(true))
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 5000}
+	begin := time.Now()
+	for _, err := range []error{nil, status.Error(grpcCodes.Unavailable, "backend down")} {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+		ctx = h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+		h.HandleRPC(ctx, &stats.InPayload{Length: 10})
+		h.HandleRPC(ctx, &stats.OutPayload{Length: 20})
+		h.HandleRPC(ctx, &stats.OutPayload{Length: 20})
+		h.HandleRPC(ctx, &stats.End{BeginTime: begin, EndTime: begin.Add(15 * time.Millisecond), Error: err})
+	}
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, AccessLogMessage, entries[0].Message)
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "/shop.Orders/Get", fields["rpc.method"])
+	assert.Equal(t, "server", fields["rpc.role"])
+	assert.Equal(t, "OK", fields["rpc.grpc.status"])
+	assert.Equal(t, 15.0, fields["rpc.duration_ms"])
+	assert.Equal(t, int64(1), fields["rpc.messages_received"])
+	assert.Equal(t, int64(2), fields["rpc.messages_sent"])
+	assert.Equal(t, "10.0.0.7:5000", fields["net.peer.address"])
+
+	spans := rec.Spans()
+	require.Len(t, spans, 2)
+	assert.Equal(t, spans[0].SpanContext().TraceID().String(), fields["traceID"])
+
+	assert.Equal(t, zapcore.ErrorLevel, entries[1].Level)
+	assert.Equal(t, int64(grpcCodes.Unavailable), entries[1].ContextMap()["rpc.grpc.status_code"])
+}
+
+func TestAccessLogDisabled(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	h := TracingMiddleware(RoleClient)
+	ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+	h.HandleRPC(ctx, &stats.End{})
+	assert.Zero(t, logs.Len())
+}
@@ -0,0 +1,45 @@
+package otelgrpc
+
+import (
+	"net"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// GRPCLBPolicyKey is the load-balancing policy of a client RPC.
+const GRPCLBPolicyKey = attribute.Key("rpc.grpc.lb_policy")
+
+// WithLoadBalancingPolicy returns an Option to record the name of the
+// load-balancing policy, e.g. round_robin, on the client spans. The policy
+// is set by the service config of the connection, which gRPC does not expose
+// to the stats handlers.
+func WithLoadBalancingPolicy(name string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LBPolicy = name
+	})
+}
+
+// pickedAddrAttrs returns the attributes of the address of the subchannel
+// picked by the balancer for a client RPC.
+func pickedAddrAttrs(addr net.Addr) []attribute.KeyValue {
+	return sockAttrs(addr, semconv.NetSockPeerAddrKey, semconv.NetSockPeerPortKey)
+}
+
+// sockAttrs returns the attributes of the address and the port of addr.
+func sockAttrs(addr net.Addr, addrKey, portKey attribute.Key) []attribute.KeyValue {
+	if addr == nil {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		// Unix sockets have no port.
+		return []attribute.KeyValue{addrKey.String(addr.String())}
+	}
+	attrs := []attribute.KeyValue{addrKey.String(host)}
+	if p, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, portKey.Int(p))
+	}
+	return attrs
+}
@@ -0,0 +1,116 @@
+package otelgrpc
+
+import (
+	"context"
+	"time"
+
+	kgsotel "kgs/otel"
+	"kgs/otel/internal/killswitch"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+const (
+	// GRPCTargetKey is the target of a client connection.
+	GRPCTargetKey = attribute.Key("rpc.grpc.target")
+	// GRPCConnectivityStateKey is the state a client connection entered.
+	GRPCConnectivityStateKey = attribute.Key("rpc.grpc.connectivity_state")
+)
+
+// clientConnMetrics are the instruments of a watched client connection.
+type clientConnMetrics struct {
+	transitions metric.Int64Counter
+	timeToReady metric.Float64Histogram
+}
+
+// WatchClientConn records the state transitions of conn until ctx is done or
+// conn is closed, to diagnose flapping upstreams:
+//
+//   - rpc.client.connection.state_transitions counts the states entered,
+//     with the rpc.grpc.connectivity_state attribute,
+//   - rpc.client.connection.time_to_ready measures the time from connecting
+//     to ready, including the failed attempts in between.
+//
+// The transitions are logged too, with a warning for TRANSIENT_FAILURE. The
+// options set the meter provider and the metric attributes; the watch runs
+// in its own goroutine.
+func WatchClientConn(ctx context.Context, conn *grpc.ClientConn, opts ...Option) {
+	if killswitch.Disabled() {
+		return
+	}
+	cfg := newConfig(RoleClient, opts...)
+
+	var (
+		m   clientConnMetrics
+		err error
+	)
+	m.transitions, err = cfg.meter.Int64Counter("rpc.client.connection.state_transitions",
+		metric.WithDescription("Measures the number of connectivity states entered by the client connections."),
+		metric.WithUnit("{transition}"))
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	m.timeToReady, err = cfg.meter.Float64Histogram("rpc.client.connection.time_to_ready",
+		metric.WithDescription("Measures the time for the client connections to become ready."),
+		metric.WithUnit("ms"))
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+
+	attrs := append([]attribute.KeyValue{GRPCTargetKey.String(conn.Target())}, cfg.MetricAttributes...)
+	// The initial state is read before returning, so the transitions caused
+	// by the caller right after, e.g. by conn.Connect, are not missed.
+	go watchClientConn(ctx, conn, conn.GetState(), &m, attrs)
+}
+
+// watchClientConn records the transitions of conn from state until it is
+// shut down.
+func watchClientConn(ctx context.Context, conn *grpc.ClientConn, state connectivity.State, m *clientConnMetrics, attrs []attribute.KeyValue) {
+	var connecting time.Time
+	if state == connectivity.Connecting {
+		connecting = time.Now()
+	}
+	for state != connectivity.Shutdown {
+		if !conn.WaitForStateChange(ctx, state) {
+			return
+		}
+		prev := state
+		state = conn.GetState()
+
+		stateAttrs := append(attrs[:len(attrs):len(attrs)], GRPCConnectivityStateKey.String(state.String()))
+		m.transitions.Add(ctx, 1, metric.WithAttributes(stateAttrs...))
+
+		switch state {
+		case connectivity.Connecting:
+			// The time to ready runs on across the retries after a failure.
+			if connecting.IsZero() {
+				connecting = time.Now()
+			}
+		case connectivity.Ready:
+			if !connecting.IsZero() {
+				elapsed := float64(time.Since(connecting)) / float64(time.Millisecond)
+				m.timeToReady.Record(ctx, elapsed, metric.WithAttributes(attrs...))
+				connecting = time.Time{}
+			}
+		case connectivity.Idle, connectivity.Shutdown:
+			connecting = time.Time{}
+		}
+
+		fields := []kgsotel.Field{
+			kgsotel.NewFiled("target", conn.Target()),
+			kgsotel.NewFiled("from", prev.String()),
+			kgsotel.NewFiled("to", state.String()),
+		}
+		if state == connectivity.TransientFailure {
+			kgsotel.Warn(ctx, "grpc client connection failed", fields...)
+		} else {
+			kgsotel.Info(ctx, "grpc client connection state changed", fields...)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package otelgrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestWatchClientConn(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	WatchClientConn(ctx, conn, WithMeterProvider(mp), WithMetricAttributes(attribute.String("upstream", "orders")))
+
+	conn.Connect()
+	target := []attribute.KeyValue{GRPCTargetKey.String(conn.Target()), attribute.String("upstream", "orders")}
+	ready := append(target, GRPCConnectivityStateKey.String(connectivity.Ready.String()))
+	assert.Eventually(t, func() bool {
+		return oteltest.AssertSumValue(noopT{}, reader, "rpc.client.connection.state_transitions", ready, int64(1))
+	}, 5*time.Second, 10*time.Millisecond)
+	oteltest.AssertSumValue(t, reader, "rpc.client.connection.state_transitions",
+		append(target, GRPCConnectivityStateKey.String(connectivity.Connecting.String())), int64(1))
+	oteltest.AssertHistogramCount(t, reader, "rpc.client.connection.time_to_ready", target, 1)
+}
+
+// noopT discards the failures of the assertions polled by Eventually.
+type noopT struct{}
+
+func (noopT) Errorf(string, ...any) {}
+func (noopT) Helper()               {}
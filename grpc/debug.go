@@ -0,0 +1,55 @@
+package otelgrpc
+
+import (
+	"context"
+	"strings"
+
+	kgsotel "kgs/otel"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// WithDebugMetadata returns an Option to flag the incoming RPCs with the
+// metadata key set to 1 or true for debugging (see kgsotel.ContextWithDebug):
+// their logs are written down to the debug level and their spans are
+// sampled. authorize restricts the flag to the authenticated callers; if
+// nil, the metadata is always honored. It only applies to the servers. The
+// flag propagated in the baggage is honored under the same conditions; it is
+// removed from the baggage of the other RPCs, and of all the RPCs without
+// WithDebugMetadata.
+func WithDebugMetadata(key string, authorize func(ctx context.Context, md metadata.MD) bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.DebugMetadataKey = strings.ToLower(key)
+		cfg.DebugAuthorize = authorize
+	})
+}
+
+// debugContext flags the context of an incoming RPC for debugging when the
+// RPC asks for it, and removes the flag propagated by the callers not
+// allowed to set it.
+func (cfg *config) debugContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requested := cfg.DebugMetadataKey != "" && debugRequested(md, cfg.DebugMetadataKey)
+	if !requested && !kgsotel.DebugEnabled(ctx) {
+		return ctx
+	}
+	if cfg.DebugMetadataKey == "" || (cfg.DebugAuthorize != nil && !cfg.DebugAuthorize(ctx, md)) {
+		return kgsotel.ContextWithoutDebug(ctx)
+	}
+	if requested {
+		return kgsotel.ContextWithDebug(ctx)
+	}
+	return ctx
+}
+
+// debugRequested reports whether the metadata of the incoming RPC asks to be
+// debugged.
+func debugRequested(md metadata.MD, key string) bool {
+	for _, v := range md.Get(key) {
+		switch strings.ToLower(v) {
+		case "1", "true":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package otelgrpc
+
+import (
+	"context"
+	"kgs/otel/internal/fault"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// FaultOption configures the fault injection interceptors.
+type FaultOption func(*faultConfig)
+
+type faultConfig struct {
+	authorize func(ctx context.Context) bool
+}
+
+// AuthorizeFaults returns a FaultOption to honor the fault.delay and
+// fault.error baggage members of the RPCs for which authorize returns true,
+// e.g. checking the peer or its credentials. Without it, the faults are only
+// requested by the metadata.
+func AuthorizeFaults(authorize func(ctx context.Context) bool) FaultOption {
+	return func(cfg *faultConfig) {
+		cfg.authorize = authorize
+	}
+}
+
+// FaultUnaryServerInterceptor returns an interceptor injecting the delays and
+// errors requested by the x-fault-delay (e.g. 200ms) and x-fault-error (a
+// gRPC code, e.g. 14 or UNAVAILABLE) metadata, or by the fault.delay and
+// fault.error baggage members with AuthorizeFaults. The fault members are
+// removed from the baggage seen by the handlers, so they are not propagated.
+// The affected spans are tagged with fault.injected. When enabled is false,
+// it does nothing, so it can stay installed in production and be turned on
+// for chaos experiments.
+func FaultUnaryServerInterceptor(enabled bool, opts ...FaultOption) grpc.UnaryServerInterceptor {
+	cfg := newFaultConfig(opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if enabled {
+			if err := injectFault(ctx, cfg); err != nil {
+				return nil, err
+			}
+			ctx = fault.WithoutFault(ctx)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// FaultStreamServerInterceptor is the streaming version of FaultUnaryServerInterceptor.
+func FaultStreamServerInterceptor(enabled bool, opts ...FaultOption) grpc.StreamServerInterceptor {
+	cfg := newFaultConfig(opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if enabled {
+			ctx := ss.Context()
+			if err := injectFault(ctx, cfg); err != nil {
+				return err
+			}
+			if stripped := fault.WithoutFault(ctx); stripped != ctx {
+				ss = &faultServerStream{ServerStream: ss, ctx: stripped}
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+func newFaultConfig(opts []FaultOption) faultConfig {
+	var cfg faultConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// faultServerStream is a server stream without the fault baggage members.
+type faultServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *faultServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// injectFault waits for the requested delay and returns the requested error.
+func injectFault(ctx context.Context, cfg faultConfig) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	trusted := cfg.authorize != nil && cfg.authorize(ctx)
+	req, ok := fault.FromContext(ctx, func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}, trusted)
+	if !ok {
+		return nil
+	}
+	req.Tag(ctx)
+
+	if err := req.Wait(ctx); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if code, ok := parseCode(req.Error); ok && code != codes.OK {
+		return status.Error(code, "injected fault")
+	}
+	return nil
+}
+
+// parseCode parses a gRPC code from its number or its name.
+func parseCode(s string) (codes.Code, bool) {
+	if s == "" {
+		return codes.OK, false
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return codes.Code(n), n >= 0 && n <= int(codes.Unauthenticated)
+	}
+	var code codes.Code
+	if err := code.UnmarshalJSON([]byte(`"` + strings.ToUpper(s) + `"`)); err != nil {
+		return codes.OK, false
+	}
+	return code, true
+}
@@ -0,0 +1,74 @@
+package otelgrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseCode(t *testing.T) {
+	for in, want := range map[string]codes.Code{"14": codes.Unavailable, "unavailable": codes.Unavailable, "NOT_FOUND": codes.NotFound} {
+		code, ok := parseCode(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, code, in)
+	}
+	for _, in := range []string{"", "99", "nope"} {
+		_, ok := parseCode(in)
+		assert.False(t, ok, in)
+	}
+}
+
+func TestInjectFault(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-fault-error", "UNAVAILABLE"))
+	assert.Equal(t, codes.Unavailable, status.Code(injectFault(ctx, faultConfig{})))
+
+	member, err := baggage.NewMember("fault.error", "UNAVAILABLE")
+	require.NoError(t, err)
+	b, err := baggage.New(member)
+	require.NoError(t, err)
+	ctx = baggage.ContextWithBaggage(context.Background(), b)
+	// The baggage faults are ignored unless authorized.
+	assert.NoError(t, injectFault(ctx, faultConfig{}))
+	denied := newFaultConfig([]FaultOption{AuthorizeFaults(func(context.Context) bool { return false })})
+	assert.NoError(t, injectFault(ctx, denied))
+	allowed := newFaultConfig([]FaultOption{AuthorizeFaults(func(context.Context) bool { return true })})
+	assert.Equal(t, codes.Unavailable, status.Code(injectFault(ctx, allowed)))
+
+	delay, err := baggage.NewMember("fault.delay", "20ms")
+	require.NoError(t, err)
+	b, err = baggage.New(delay)
+	require.NoError(t, err)
+	start := time.Now()
+	assert.NoError(t, injectFault(baggage.ContextWithBaggage(context.Background(), b), allowed))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	assert.NoError(t, injectFault(context.Background(), allowed))
+}
+
+func TestFaultInterceptorStripsBaggage(t *testing.T) {
+	member, err := baggage.NewMember("fault.delay", "1ms")
+	require.NoError(t, err)
+	tenant, err := baggage.NewMember("tenant", "acme")
+	require.NoError(t, err)
+	b, err := baggage.New(member, tenant)
+	require.NoError(t, err)
+
+	var seen baggage.Baggage
+	interceptor := FaultUnaryServerInterceptor(true)
+	_, err = interceptor(baggage.ContextWithBaggage(context.Background(), b), nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, _ any) (any, error) {
+			seen = baggage.FromContext(ctx)
+			return nil, nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, "acme", seen.Member("tenant").Value())
+	assert.Empty(t, seen.Member("fault.delay").Key())
+}
@@ -0,0 +1,46 @@
+package otelgrpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// HandlerErrorUnaryServerInterceptor returns an interceptor keeping the error
+// returned by the handler of the unary RPCs before gRPC converts it to a
+// status, which loses its type: the server spans get the code and the fields
+// of its kgsotel.CodedError, and the ValidationErrorMapper sees it. It must
+// be the last interceptor of the chain.
+func HandlerErrorUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		keepHandlerError(ctx, err)
+		return resp, err
+	}
+}
+
+// HandlerErrorStreamServerInterceptor is the
+// HandlerErrorUnaryServerInterceptor of the streaming RPCs.
+func HandlerErrorStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		keepHandlerError(ss.Context(), err)
+		return err
+	}
+}
+
+// keepHandlerError keeps err on the RPC of ctx.
+func keepHandlerError(ctx context.Context, err error) {
+	if gctx, _ := ctx.Value(gRPCContextKey{}).(*gRPCContext); gctx != nil && err != nil {
+		gctx.handlerErr = err
+	}
+}
+
+// handlerError returns the error of the RPC, the one kept by the interceptors
+// or else err.
+func handlerError(gctx *gRPCContext, err error) error {
+	if gctx != nil && gctx.handlerErr != nil {
+		return gctx.handlerErr
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package otelgrpc
+
+import (
+	"kgs/otel/internal"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	grpcCodes "google.golang.org/grpc/codes"
+)
+
+// maxCachedMethods bounds the methods cached by the low-overhead mode, as
+// a server also sees the RPCs to the methods it does not implement.
+const maxCachedMethods = 1024
+
+// methodAttributes are the span name and attributes of a method.
+type methodAttributes struct {
+	name        string
+	spanAttrs   []attribute.KeyValue
+	metricAttrs []attribute.KeyValue
+	metricSet   attribute.Set
+	// statusSets are the metric attribute sets with each status code.
+	statusSets [grpcCodes.Unauthenticated + 1]attribute.Set
+}
+
+// statusSet returns the metric attribute set with the status code.
+func (a *methodAttributes) statusSet(code grpcCodes.Code) attribute.Set {
+	if int(code) < len(a.statusSets) {
+		return a.statusSets[code]
+	}
+	return attribute.NewSet(append(slices.Clip(a.metricAttrs), semconv.RPCGRPCStatusCodeKey.Int(int(code)))...)
+}
+
+// methodCache caches the attributes by full method name, so the low-overhead
+// mode does not parse the method and build the attribute sets for every RPC.
+type methodCache struct {
+	methods sync.Map
+	size    atomic.Int64
+}
+
+// get returns the attributes of the method, or nil if the cache is full.
+func (c *methodCache) get(fullMethod string, cfg *config) *methodAttributes {
+	if a, ok := c.methods.Load(fullMethod); ok {
+		return a.(*methodAttributes)
+	}
+	if c.size.Load() >= maxCachedMethods {
+		return nil
+	}
+
+	name, attrs := internal.ParseFullMethod(fullMethod)
+	attrs = append(attrs, semconv.RPCSystemGRPC)
+	a := &methodAttributes{
+		name:        name,
+		spanAttrs:   slices.Clip(append(slices.Clip(attrs), cfg.SpanAttributes...)),
+		metricAttrs: slices.Clip(append(slices.Clip(attrs), cfg.MetricAttributes...)),
+	}
+	if mc := cfg.Methods[fullMethod]; mc != nil {
+		a.spanAttrs = slices.Clip(append(a.spanAttrs, mc.SpanAttributes...))
+		a.metricAttrs = slices.Clip(append(a.metricAttrs, mc.MetricAttributes...))
+	}
+	a.metricSet = attribute.NewSet(a.metricAttrs...)
+	for code := range a.statusSets {
+		a.statusSets[code] = attribute.NewSet(append(a.metricAttrs, semconv.RPCGRPCStatusCodeKey.Int(code))...)
+	}
+
+	if actual, loaded := c.methods.LoadOrStore(fullMethod, a); loaded {
+		return actual.(*methodAttributes)
+	}
+	c.size.Add(1)
+	return a
+}
@@ -7,6 +7,7 @@ package otelgrpc
 
 import (
 	"context"
+	"strings"
 
 	"google.golang.org/grpc/metadata"
 
@@ -15,12 +16,21 @@ import (
 
 type metadataSupplier struct {
 	metadata *metadata.MD
+	// keys maps the propagation keys to custom metadata keys.
+	keys map[string]string
 }
 
 // assert that metadataSupplier implements the TextMapCarrier interface.
 var _ propagation.TextMapCarrier = &metadataSupplier{}
 
 func (s *metadataSupplier) Get(key string) string {
+	// The standard key is read when the custom one is missing, so the
+	// callers propagating either are traced.
+	if custom, ok := s.keys[strings.ToLower(key)]; ok {
+		if values := s.metadata.Get(custom); len(values) > 0 {
+			return values[0]
+		}
+	}
 	values := s.metadata.Get(key)
 	if len(values) == 0 {
 		return ""
@@ -29,29 +39,39 @@ func (s *metadataSupplier) Get(key string) string {
 }
 
 func (s *metadataSupplier) Set(key string, value string) {
+	if custom, ok := s.keys[strings.ToLower(key)]; ok {
+		key = custom
+	}
 	s.metadata.Set(key, value)
 }
 
 func (s *metadataSupplier) Keys() []string {
 	out := make([]string, 0, len(*s.metadata))
 	for key := range *s.metadata {
+		for std, custom := range s.keys {
+			if key == custom {
+				key = std
+				break
+			}
+		}
 		out = append(out, key)
 	}
 	return out
 }
 
-func inject(ctx context.Context, propagators propagation.TextMapPropagator) context.Context {
+func inject(ctx context.Context, propagators propagation.TextMapPropagator, keys map[string]string) context.Context {
 	md, ok := metadata.FromOutgoingContext(ctx)
 	if !ok {
 		md = metadata.MD{}
 	}
 	propagators.Inject(ctx, &metadataSupplier{
 		metadata: &md,
+		keys:     keys,
 	})
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
-func extract(ctx context.Context, propagators propagation.TextMapPropagator) context.Context {
+func extract(ctx context.Context, propagators propagation.TextMapPropagator, keys map[string]string) context.Context {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		md = metadata.MD{}
@@ -59,5 +79,6 @@ func extract(ctx context.Context, propagators propagation.TextMapPropagator) con
 
 	return propagators.Extract(ctx, &metadataSupplier{
 		metadata: &md,
+		keys:     keys,
 	})
 }
@@ -0,0 +1,130 @@
+package otelgrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxPayloadSize bounds the payloads captured on the spans.
+const maxPayloadSize = 4 << 10
+
+// RPCMessagePayloadKey is the payload of a captured message.
+const RPCMessagePayloadKey = attribute.Key("rpc.message.payload")
+
+// MethodConfig overrides the configuration of the RPCs to a method, so one
+// stats handler can serve heterogeneous services.
+type MethodConfig struct {
+	// SampleRatio, if set, is the ratio of the RPCs traced, from 0 to 1. The
+	// other RPCs are not recorded and propagate a not sampled context. It can
+	// only lower the sampling of the tracer provider.
+	SampleRatio *float64
+	// SpanAttributes are added to the span attributes of the handler.
+	SpanAttributes []attribute.KeyValue
+	// MetricAttributes are added to the metric attributes of the handler.
+	MetricAttributes []attribute.KeyValue
+	// CapturePayloads adds the messages to the span as events, in JSON and
+	// truncated to 4KiB. Use it for the methods without personal data.
+	CapturePayloads bool
+	// DisableMetrics suppresses the metrics of the RPCs, which are still
+	// traced.
+	DisableMetrics bool
+	// SlowThreshold, if not zero, overrides the threshold of
+	// WithSlowThreshold. A negative value disables the annotation.
+	SlowThreshold time.Duration
+}
+
+// SampleRatio returns a pointer to ratio, for MethodConfig.SampleRatio.
+func SampleRatio(ratio float64) *float64 {
+	return &ratio
+}
+
+// WithMethodConfig returns an Option to override the configuration of the
+// RPCs to the full method name, e.g. "/shop.Orders/Get". It is consulted when
+// the RPCs are tagged.
+func WithMethodConfig(fullMethod string, mc MethodConfig) Option {
+	return optionFunc(func(cfg *config) {
+		if cfg.Methods == nil {
+			cfg.Methods = make(map[string]*MethodConfig)
+		}
+		cfg.Methods[fullMethod] = &mc
+	})
+}
+
+// sampled reports whether the RPC in ctx is traced by the sample ratio of
+// mc. The decision follows the trace ID of the parent when there is one, so
+// the services sampling a method by the same ratio keep the same traces.
+func (mc *MethodConfig) sampled(ctx context.Context) bool {
+	if mc == nil || mc.SampleRatio == nil {
+		return true
+	}
+	ratio := *mc.SampleRatio
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	bound := uint64(ratio * (1 << 63))
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		tid := sc.TraceID()
+		return binary.BigEndian.Uint64(tid[8:16])>>1 < bound
+	}
+	return rand.Uint64()>>1 < bound
+}
+
+// notSampled returns ctx whose parent is not sampled, so the decision is
+// propagated. Without a parent, it gets one with a fresh trace ID, or the
+// downstream services would start new sampled traces.
+func notSampled(ctx context.Context) context.Context {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		var tid trace.TraceID
+		var sid trace.SpanID
+		binary.BigEndian.PutUint64(tid[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(tid[8:], rand.Uint64())
+		binary.BigEndian.PutUint64(sid[:], rand.Uint64()|1)
+		sc = trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: sid})
+	}
+	return trace.ContextWithSpanContext(ctx, sc.WithTraceFlags(sc.TraceFlags().WithSampled(false)))
+}
+
+// payloadEvent adds the message event with the payload to the span.
+func payloadEvent(span trace.Span, typ attribute.KeyValue, payload any, size int) {
+	span.AddEvent("message", trace.WithAttributes(
+		typ,
+		semconv.MessageUncompressedSize(size),
+		RPCMessagePayloadKey.String(formatPayload(payload)),
+	))
+}
+
+// formatPayload returns the message in JSON, truncated to maxPayloadSize.
+func formatPayload(payload any) string {
+	var s string
+	if m, ok := payload.(proto.Message); ok {
+		b, err := protojson.Marshal(m)
+		if err != nil {
+			return fmt.Sprintf("<%v>", err)
+		}
+		s = string(b)
+	} else {
+		s = fmt.Sprint(payload)
+	}
+	if len(s) <= maxPayloadSize {
+		return s
+	}
+	s = s[:maxPayloadSize]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
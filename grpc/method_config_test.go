@@ -0,0 +1,88 @@
+package otelgrpc
+
+import (
+	"context"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestWithMethodConfig(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	h := TracingMiddleware(RoleServer,
+		WithTracerProvider(rec.TracerProvider),
+		WithMeterProvider(rec.MeterProvider),
+		WithMethodConfig("/shop.Orders/Get", MethodConfig{
+			SpanAttributes:   []attribute.KeyValue{attribute.String("tier", "gold")},
+			MetricAttributes: []attribute.KeyValue{attribute.String("team", "orders")},
+			CapturePayloads:  true,
+		}),
+		WithMethodConfig("/grpc.health.v1.Health/Check", MethodConfig{DisableMetrics: true}),
+		WithMethodConfig("/shop.Orders/List", MethodConfig{SampleRatio: SampleRatio(0)}),
+	)
+
+	call := func(method string) {
+		ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: method})
+		h.HandleRPC(ctx, &stats.InPayload{Payload: wrapperspb.String("order-1"), Length: 9})
+		h.HandleRPC(ctx, &stats.End{})
+	}
+	call("/shop.Orders/Get")
+	call("/grpc.health.v1.Health/Check")
+	call("/shop.Orders/List")
+
+	span, ok := rec.Span("shop.Orders/Get")
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), attribute.String("tier", "gold"))
+	require.Len(t, span.Events(), 1)
+	assert.Contains(t, span.Events()[0].Attributes, RPCMessagePayloadKey.String(`"order-1"`))
+	assert.Contains(t, span.Events()[0].Attributes, semconv.MessageTypeReceived)
+	oteltest.AssertHistogramCount(t, rec, "rpc.server.duration", []attribute.KeyValue{attribute.String("team", "orders")}, 1)
+
+	// The metrics are suppressed, the span is not.
+	span, ok = rec.Span("grpc.health.v1.Health/Check")
+	require.True(t, ok)
+	assert.Empty(t, span.Events())
+	m, ok := rec.Metric(context.Background(), "rpc.server.duration")
+	require.True(t, ok)
+	for _, dp := range m.Data.(metricdata.Histogram[float64]).DataPoints {
+		service, _ := dp.Attributes.Value(semconv.RPCServiceKey)
+		assert.NotEqual(t, "grpc.health.v1.Health", service.AsString())
+	}
+
+	// The RPCs not sampled are not traced but measured.
+	_, ok = rec.Span("shop.Orders/List")
+	assert.False(t, ok)
+	oteltest.AssertHistogramCount(t, rec, "rpc.server.duration", []attribute.KeyValue{semconv.RPCMethod("List")}, 1)
+}
+
+func TestMethodConfigSampled(t *testing.T) {
+	parent := func(tid byte) context.Context {
+		return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{15: tid, 8: tid},
+			SpanID:     trace.SpanID{1},
+			TraceFlags: trace.FlagsSampled,
+		}))
+	}
+	mc := &MethodConfig{SampleRatio: SampleRatio(0.5)}
+	assert.True(t, mc.sampled(parent(0x10)))
+	assert.False(t, mc.sampled(parent(0xf0)))
+	assert.True(t, (*MethodConfig)(nil).sampled(parent(0xf0)))
+
+	ctx := notSampled(parent(0xf0))
+	assert.False(t, trace.SpanContextFromContext(ctx).IsSampled())
+
+	// Without a parent, the decision is propagated by a new trace.
+	sc := trace.SpanContextFromContext(notSampled(context.Background()))
+	assert.True(t, sc.IsValid())
+	assert.False(t, sc.IsSampled())
+	assert.NotEqual(t, sc.TraceID(), trace.SpanContextFromContext(notSampled(context.Background())).TraceID())
+}
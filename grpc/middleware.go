@@ -7,8 +7,14 @@ package otelgrpc
 
 import (
 	"context"
+	kgsotel "kgs/otel"
 	"kgs/otel/internal"
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/lowoverhead"
+	"kgs/otel/internal/overhead"
 	"kgs/otel/internal/semconvutil"
+	"kgs/otel/internal/slow"
+	"slices"
 	"sync/atomic"
 	"time"
 
@@ -18,6 +24,7 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
 	"go.opentelemetry.io/otel/trace"
 	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/stats"
 	"google.golang.org/grpc/status"
@@ -31,34 +38,123 @@ type gRPCContext struct {
 	messagesSent     int64
 	metricAttrs      []attribute.KeyValue
 	record           bool
+	streaming        bool
+	// method holds the cached attributes in the low-overhead mode.
+	method *methodAttributes
+	// cost accumulates the time spent in the handler in the overhead
+	// measurement mode.
+	cost overhead.Cost
+	// capturePayloads and noMetrics are set by the method configuration.
+	capturePayloads bool
+	noMetrics       bool
+	// fullMethod is the method of the RPC, kept for the access log.
+	fullMethod string
+	// unlabel restores the pprof labels of the server goroutine.
+	unlabel func()
+	// slowThreshold and timer annotate the slow unary RPCs of the server.
+	slowThreshold time.Duration
+	timer         *slow.Timer
+	// handlerErr is the error returned by the handler, kept by the
+	// HandlerError interceptors before its conversion to a status.
+	handlerErr error
+}
+
+// connContextKey is a 0 size type to use as key for connection values.
+type connContextKey struct{}
+
+type connContext struct {
+	metricAttrs []attribute.KeyValue
+	// spanAttrs are the addresses of the connection, added to the spans of
+	// its RPCs.
+	spanAttrs []attribute.KeyValue
 }
 
 type middleware struct {
 	config *config
 	role   Role
+
+	lowOverhead bool
+	methods     methodCache
+	overhead    *overhead.Recorder
 }
 
 func TracingMiddleware(role Role, opts ...Option) stats.Handler {
+	if killswitch.Disabled() {
+		return noopHandler{}
+	}
+
+	cfg := newConfig(role, opts...)
 	m := &middleware{
-		config: newConfig(role, opts...),
-		role:   role,
+		config:      cfg,
+		role:        role,
+		lowOverhead: lowoverhead.Enabled(),
+		overhead:    overhead.NewRecorder(cfg.MeterProvider, "otelgrpc"),
 	}
 
 	return m
 }
 
+// noopHandler is the stats handler used when the telemetry is disabled.
+type noopHandler struct{}
+
+func (noopHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context   { return ctx }
+func (noopHandler) HandleRPC(context.Context, stats.RPCStats)                         {}
+func (noopHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context { return ctx }
+func (noopHandler) HandleConn(context.Context, stats.ConnStats)                       {}
+
 // TagConn can attach some information to the given context.
 func (m *middleware) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
-	return ctx
+	var attrs []attribute.KeyValue
+	if info.RemoteAddr != nil {
+		attrs = append(attrs, semconvutil.NetTransport(info.RemoteAddr.Network()))
+	}
+	hostAttrs := sockAttrs(info.LocalAddr, semconv.NetSockHostAddrKey, semconv.NetSockHostPortKey)
+	// The local address of a server is its listening address, with a low
+	// cardinality; the one of a client has an ephemeral port, like the remote
+	// address of a server, so they are kept off the metrics.
+	if m.role.isServer() {
+		attrs = append(attrs, hostAttrs...)
+	}
+
+	sc := m.config.Semconv
+	cctx := connContext{
+		metricAttrs: append(sc.Convert(attrs), m.config.MetricAttributes...),
+		spanAttrs:   sc.Convert(append(sockAttrs(info.RemoteAddr, semconv.NetSockPeerAddrKey, semconv.NetSockPeerPortKey), hostAttrs...)),
+	}
+	return context.WithValue(ctx, connContextKey{}, &cctx)
 }
 
 // HandleConn processes the Conn stats.
-func (m *middleware) HandleConn(ctx context.Context, info stats.ConnStats) {
+func (m *middleware) HandleConn(ctx context.Context, cs stats.ConnStats) {
+	var metricAttrs []attribute.KeyValue
+	if cctx, _ := ctx.Value(connContextKey{}).(*connContext); cctx != nil {
+		metricAttrs = cctx.metricAttrs
+	}
+	addOpts := []metric.AddOption{metric.WithAttributeSet(attribute.NewSet(metricAttrs...))}
+
+	switch cs.(type) {
+	case *stats.ConnBegin:
+		m.config.rpcConnOpened.Add(ctx, 1, addOpts...)
+		m.config.rpcActiveConns.Add(ctx, 1, addOpts...)
+	case *stats.ConnEnd:
+		m.config.rpcConnClosed.Add(ctx, 1, addOpts...)
+		m.config.rpcActiveConns.Add(ctx, -1, addOpts...)
+	default:
+		return
+	}
 }
 
 // TagRPC can attach some information to the given context.
 func (m *middleware) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
-	ctx = extract(ctx, m.config.Propagators)
+	var seg overhead.Segment
+	if m.overhead != nil {
+		seg = overhead.Begin()
+	}
+	ctx = extract(ctx, m.config.Propagators, m.config.MetadataKeys)
+	// The debug flag is set before the span starts, so it is sampled.
+	if m.role.isServer() {
+		ctx = m.config.debugContext(ctx)
+	}
 
 	var spanKind trace.SpanKind
 	if m.role.isServer() {
@@ -67,58 +163,142 @@ func (m *middleware) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context
 		spanKind = trace.SpanKindClient
 	}
 
-	name, attrs := internal.ParseFullMethod(info.FullMethodName)
-	attrs = append(attrs, semconv.RPCSystemGRPC)
-	ctx, _ = m.config.tracer.Start(
-		trace.ContextWithRemoteSpanContext(ctx, trace.SpanContextFromContext(ctx)),
-		name,
-		trace.WithSpanKind(spanKind),
-		trace.WithAttributes(append(attrs, m.config.SpanAttributes...)...),
+	var gctx gRPCContext
+	mc := m.config.Methods[info.FullMethodName]
+	var mdAttrs []attribute.KeyValue
+	if m.role.isServer() && m.config.MetadataExtractor != nil {
+		md, _ := metadata.FromIncomingContext(ctx)
+		mdAttrs = m.config.MetadataExtractor(md)
+	}
+	// The cached attributes do not vary with the metadata.
+	if m.lowOverhead && len(mdAttrs) == 0 {
+		gctx.method = m.methods.get(info.FullMethodName, m.config)
+	}
+	var (
+		name      string
+		spanAttrs []attribute.KeyValue
 	)
-
-	gctx := gRPCContext{
-		metricAttrs: append(attrs, m.config.MetricAttributes...),
-		record:      true,
+	if gctx.method != nil {
+		name, spanAttrs = gctx.method.name, gctx.method.spanAttrs
+		gctx.metricAttrs = gctx.method.metricAttrs
+	} else {
+		var attrs []attribute.KeyValue
+		name, attrs = internal.ParseFullMethod(info.FullMethodName)
+		attrs = append(attrs, semconv.RPCSystemGRPC)
+		spanAttrs = append(slices.Clip(attrs), m.config.SpanAttributes...)
+		gctx.metricAttrs = append(slices.Clip(attrs), m.config.MetricAttributes...)
+		if mc != nil {
+			spanAttrs = append(spanAttrs, mc.SpanAttributes...)
+			gctx.metricAttrs = append(gctx.metricAttrs, mc.MetricAttributes...)
+		}
+		spanAttrs = append(spanAttrs, mdAttrs...)
+		gctx.metricAttrs = append(gctx.metricAttrs, mdAttrs...)
+	}
+	if cctx, _ := ctx.Value(connContextKey{}).(*connContext); cctx != nil {
+		spanAttrs = append(slices.Clip(spanAttrs), cctx.spanAttrs...)
+	}
+	if mc.sampled(ctx) {
+		ctx, _ = m.config.tracer.Start(
+			trace.ContextWithRemoteSpanContext(ctx, trace.SpanContextFromContext(ctx)),
+			name,
+			trace.WithSpanKind(spanKind),
+			trace.WithAttributes(spanAttrs...),
+		)
+	} else {
+		ctx = notSampled(ctx)
 	}
+	if mc != nil {
+		gctx.capturePayloads = mc.CapturePayloads
+		gctx.noMetrics = mc.DisableMetrics
+	}
+	if !m.role.isServer() && m.config.LBPolicy != "" {
+		trace.SpanFromContext(ctx).SetAttributes(GRPCLBPolicyKey.String(m.config.LBPolicy))
+	}
+	if m.config.AccessLog {
+		gctx.fullMethod = info.FullMethodName
+	}
+	gctx.record = true
 	if m.config.Filter != nil {
 		gctx.record = m.config.Filter(info)
 	}
+	if m.overhead != nil {
+		defer gctx.cost.End(seg)
+	}
 
 	// If role is server then return context with gRPCContextKey.
 	if m.role.isServer() {
+		// The handler runs on the goroutine of TagRPC and the End stats.
+		ctx, gctx.unlabel = kgsotel.LabelGoroutine(ctx, trace.SpanFromContext(ctx), name)
+		if gctx.slowThreshold = m.config.slowThreshold(mc); gctx.slowThreshold > 0 {
+			ctx, gctx.timer = slow.ContextWithTimer(ctx)
+		}
 		return context.WithValue(ctx, gRPCContextKey{}, &gctx)
 	}
 
 	// If role is client then inject the current context
-	return inject(context.WithValue(ctx, gRPCContextKey{}, &gctx), m.config.Propagators)
+	return inject(context.WithValue(ctx, gRPCContextKey{}, &gctx), m.config.Propagators, m.config.MetadataKeys)
 }
 
 // HandleRPC processes the RPC stats.
 func (m *middleware) HandleRPC(ctx context.Context, rs stats.RPCStats) {
-	span := trace.SpanFromContext(ctx)
+	span := m.config.Semconv.Span(trace.SpanFromContext(ctx))
 	var metricAttrs []attribute.KeyValue
 	// var messageId int64
 
 	gctx, _ := ctx.Value(gRPCContextKey{}).(*gRPCContext)
 	if gctx != nil {
+		if _, ok := rs.(*stats.End); ok && gctx.unlabel != nil {
+			defer gctx.unlabel()
+		}
 		if !gctx.record {
 			return
 		}
-		metricAttrs = make([]attribute.KeyValue, 0, len(gctx.metricAttrs)+1)
-		metricAttrs = append(metricAttrs, gctx.metricAttrs...)
+		if m.overhead != nil {
+			seg := overhead.Begin()
+			defer func() {
+				gctx.cost.End(seg)
+				if _, ok := rs.(*stats.End); ok {
+					m.overhead.Record(ctx, &gctx.cost)
+				}
+			}()
+		}
+		if gctx.method == nil {
+			metricAttrs = make([]attribute.KeyValue, 0, len(gctx.metricAttrs)+1)
+			metricAttrs = append(metricAttrs, gctx.metricAttrs...)
+		}
 	}
 
+	// The metrics of the method may be suppressed by its configuration.
+	recordMetrics := gctx != nil && !gctx.noMetrics
+
 	switch rs := rs.(type) {
 	case *stats.Begin:
+		if gctx != nil && (rs.IsClientStream || rs.IsServerStream) {
+			gctx.streaming = true
+			if recordMetrics {
+				m.config.rpcActiveStreams.Add(ctx, 1, metric.WithAttributeSet(metricSet(gctx, metricAttrs)))
+			}
+		}
 	case *stats.InPayload:
 		if gctx != nil {
-			m.config.rpcRequestSize.Record(ctx, int64(rs.Length), metric.WithAttributeSet(attribute.NewSet(metricAttrs...)))
+			atomic.AddInt64(&gctx.messagesReceived, 1)
+		}
+		if gctx != nil && gctx.capturePayloads {
+			payloadEvent(span, semconv.MessageTypeReceived, rs.Payload, rs.Length)
+		}
+		if recordMetrics {
+			m.config.rpcRequestSize.Record(ctx, int64(rs.Length), metric.WithAttributeSet(metricSet(gctx, metricAttrs)))
 		}
 
 	case *stats.OutPayload:
 		if gctx != nil {
-			// messageId = atomic.AddInt64(&gctx.messagesSent, 1)
-			m.config.rpcResponseSize.Record(ctx, int64(rs.Length), metric.WithAttributeSet(attribute.NewSet(metricAttrs...)))
+			atomic.AddInt64(&gctx.messagesSent, 1)
+		}
+		if gctx != nil && gctx.capturePayloads {
+			payloadEvent(span, semconv.MessageTypeSent, rs.Payload, rs.Length)
+		}
+		if recordMetrics {
+			m.config.rpcResponseSize.Record(ctx, int64(rs.Length), metric.WithAttributeSet(metricSet(gctx, metricAttrs)))
 		}
 
 	case *stats.OutTrailer:
@@ -126,34 +306,74 @@ func (m *middleware) HandleRPC(ctx context.Context, rs stats.RPCStats) {
 		if p, ok := peer.FromContext(ctx); ok {
 			span.SetAttributes(semconvutil.NetTransport(p.Addr.Network()))
 		}
+		// On the client, the headers are sent on the subchannel picked by
+		// the balancer.
+		if rs.Client {
+			span.SetAttributes(pickedAddrAttrs(rs.RemoteAddr)...)
+		}
+	case *stats.PickerUpdated:
+		span.AddEvent("Delayed LB pick complete")
 	case *stats.End:
-		var rpcStatusAttr attribute.KeyValue
-
+		code := grpcCodes.OK
+		var violations []Violation
 		if rs.Error != nil {
+			// The error of a server handler keeps its type only if an
+			// interceptor kept it before its conversion to a status.
+			span.SetAttributes(kgsotel.ErrorAttributes(handlerError(gctx, rs.Error))...)
 			s, _ := status.FromError(rs.Error)
 			if m.role.isServer() {
-				statusCode, msg := serverStatus(s)
-				span.SetStatus(statusCode, msg)
+				// The validation failures are client mistakes whatever
+				// their code.
+				if violations = m.config.violations(gctx, rs.Error); len(violations) > 0 {
+					annotateViolations(span, violations)
+				} else {
+					statusCode, msg := serverStatus(s)
+					span.SetStatus(statusCode, msg)
+				}
 			} else {
 				span.SetStatus(codes.Error, s.Message())
 			}
-			rpcStatusAttr = semconv.RPCGRPCStatusCodeKey.Int(int(s.Code()))
-		} else {
-			rpcStatusAttr = semconv.RPCGRPCStatusCodeKey.Int(int(grpcCodes.OK))
+			code = s.Code()
 		}
+		rpcStatusAttr := semconv.RPCGRPCStatusCodeKey.Int(int(code))
 		span.SetAttributes(rpcStatusAttr)
+		if gctx != nil && gctx.slowThreshold > 0 && !gctx.streaming {
+			handler, ok := gctx.timer.Handler()
+			if !ok {
+				handler = -1
+			}
+			slow.Annotate(span, gctx.slowThreshold, rs.EndTime.Sub(rs.BeginTime), handler)
+		}
 		span.End()
+		if gctx != nil && m.config.AccessLog {
+			m.logAccess(ctx, gctx, rs, code)
+		}
+		if gctx != nil && gctx.noMetrics {
+			return
+		}
 
-		metricAttrs = append(metricAttrs, rpcStatusAttr)
+		var recordSet attribute.Set
+		if gctx != nil && gctx.method != nil {
+			recordSet = gctx.method.statusSet(code)
+		} else {
+			metricAttrs = append(metricAttrs, rpcStatusAttr)
+			recordSet = attribute.NewSet(metricAttrs...)
+		}
 		// Allocate vararg slice once.
-		recordOpts := []metric.RecordOption{metric.WithAttributeSet(attribute.NewSet(metricAttrs...))}
+		recordOpts := []metric.RecordOption{metric.WithAttributeSet(recordSet)}
 
 		// Use floating point division here for higher precision (instead of Millisecond method).
 		// Measure right before calling Record() to capture as much elapsed time as possible.
 		elapsedTime := float64(rs.EndTime.Sub(rs.BeginTime)) / float64(time.Millisecond)
 
 		m.config.rpcDuration.Record(ctx, elapsedTime, recordOpts...)
+		if len(violations) > 0 {
+			m.config.rpcValidation.Add(ctx, 1, metric.WithAttributeSet(recordSet))
+		}
 		if gctx != nil {
+			if gctx.streaming {
+				m.config.rpcActiveStreams.Add(ctx, -1, metric.WithAttributeSet(metricSet(gctx, gctx.metricAttrs)))
+			}
 			m.config.rpcRequestsPerRPC.Record(ctx, atomic.LoadInt64(&gctx.messagesReceived), recordOpts...)
 			m.config.rpcResponsesPerRPC.Record(ctx, atomic.LoadInt64(&gctx.messagesSent), recordOpts...)
 		}
@@ -163,6 +383,15 @@ func (m *middleware) HandleRPC(ctx context.Context, rs stats.RPCStats) {
 
 }
 
+// metricSet returns the metric attribute set of the RPC, cached in the
+// low-overhead mode.
+func metricSet(gctx *gRPCContext, attrs []attribute.KeyValue) attribute.Set {
+	if gctx != nil && gctx.method != nil {
+		return gctx.method.metricSet
+	}
+	return attribute.NewSet(attrs...)
+}
+
 // serverStatus returns a span status code and message for a given gRPC
 // status code. It maps specific gRPC status codes to a corresponding span
 // status code and message. This function is intended for use on the server
@@ -0,0 +1,347 @@
+package otelgrpc
+
+import (
+	"context"
+	kgsotel "kgs/otel"
+	"kgs/otel/internal/defaults"
+	"kgs/otel/internal/lowoverhead"
+	"kgs/otel/internal/overhead"
+	"kgs/otel/oteltest"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestActiveStreams(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	h := TracingMiddleware(RoleServer, WithMeterProvider(mp))
+
+	begin := func(method string, stream bool) context.Context {
+		ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: method})
+		h.HandleRPC(ctx, &stats.Begin{IsClientStream: stream, IsServerStream: stream})
+		return ctx
+	}
+	watch := begin("/shop.Orders/Watch", true)
+	begin("/shop.Orders/Watch", true)
+	unary := begin("/shop.Orders/Get", false)
+
+	method := func(name string) []attribute.KeyValue { return []attribute.KeyValue{semconv.RPCMethod(name)} }
+	oteltest.AssertSumValue(t, reader, "rpc.server.active_streams", method("Watch"), int64(2))
+
+	// The closed streams leave the gauge, the unary RPCs never enter it.
+	h.HandleRPC(watch, &stats.End{})
+	h.HandleRPC(unary, &stats.End{})
+	oteltest.AssertSumValue(t, reader, "rpc.server.active_streams", method("Watch"), int64(1))
+	oteltest.AssertSumValue(t, reader, "rpc.server.active_streams", nil, int64(1))
+}
+
+func TestHandleConnMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	h := TracingMiddleware(RoleServer, WithMeterProvider(mp))
+
+	addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1834}
+	ctx := h.TagConn(context.Background(), &stats.ConnTagInfo{RemoteAddr: addr, LocalAddr: addr})
+
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	h.HandleConn(ctx, &stats.ConnEnd{})
+
+	oteltest.AssertSumValue(t, reader, "rpc.server.connections.opened", nil, int64(2))
+	oteltest.AssertSumValue(t, reader, "rpc.server.connections.closed", nil, int64(1))
+	oteltest.AssertSumValue(t, reader, "rpc.server.active_connections", nil, int64(1))
+}
+
+func TestWithTelemetry(t *testing.T) {
+	ctx := context.Background()
+	tel, err := kgsotel.New(ctx, kgsotel.Config{
+		ServiceName: "svc",
+		Options:     []kgsotel.Option{kgsotel.WithStdoutExporters(true)},
+	})
+	require.NoError(t, err)
+	defer tel.Shutdown(ctx)
+
+	cfg := newConfig(RoleServer, WithTelemetry(tel))
+	assert.Same(t, tel.TracerProvider(), cfg.TracerProvider)
+	assert.Same(t, tel.MeterProvider(), cfg.MeterProvider)
+	assert.Equal(t, tel.Propagator(), cfg.Propagators)
+}
+
+func TestLowOverheadMetrics(t *testing.T) {
+	lowoverhead.SetEnabled(true)
+	defer lowoverhead.SetEnabled(false)
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	h := TracingMiddleware(RoleServer, WithMeterProvider(mp), WithMetricAttributes(attribute.String("team", "shop")))
+
+	for _, err := range []error{nil, nil, status.Error(grpcCodes.NotFound, "missing")} {
+		ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+		h.HandleRPC(ctx, &stats.InPayload{Length: 10})
+		h.HandleRPC(ctx, &stats.End{Error: err})
+	}
+
+	team := attribute.String("team", "shop")
+	oteltest.AssertHistogramCount(t, reader, "rpc.server.duration",
+		[]attribute.KeyValue{team, semconv.RPCGRPCStatusCodeKey.Int(int(grpcCodes.OK))}, 2)
+	oteltest.AssertHistogramCount(t, reader, "rpc.server.duration",
+		[]attribute.KeyValue{team, semconv.RPCGRPCStatusCodeKey.Int(int(grpcCodes.NotFound))}, 1)
+}
+
+func TestOverheadMeasurement(t *testing.T) {
+	overhead.SetEnabled(true)
+	defer overhead.SetEnabled(false)
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	h := TracingMiddleware(RoleServer, WithMeterProvider(mp))
+
+	ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+	h.HandleRPC(ctx, &stats.InPayload{Length: 10})
+	h.HandleRPC(ctx, &stats.End{})
+
+	component := []attribute.KeyValue{overhead.ComponentKey.String("otelgrpc")}
+	oteltest.AssertHistogramCount(t, reader, "kgsotel.overhead.duration", component, 1)
+	oteltest.AssertHistogramCount(t, reader, "kgsotel.overhead.allocations", component, 1)
+}
+
+func TestClientPickAttributes(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	h := TracingMiddleware(RoleClient, WithTracerProvider(rec.TracerProvider), WithLoadBalancingPolicy("round_robin"))
+
+	ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+	h.HandleRPC(ctx, &stats.PickerUpdated{})
+	h.HandleRPC(ctx, &stats.OutHeader{Client: true, RemoteAddr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 8443}})
+	h.HandleRPC(ctx, &stats.End{})
+
+	span, ok := rec.Span("shop.Orders/Get")
+	require.True(t, ok)
+	assert.Contains(t, span.Attributes(), GRPCLBPolicyKey.String("round_robin"))
+	assert.Contains(t, span.Attributes(), semconv.NetSockPeerAddr("10.0.0.7"))
+	assert.Contains(t, span.Attributes(), semconv.NetSockPeerPort(8443))
+	require.Len(t, span.Events(), 1)
+	assert.Equal(t, "Delayed LB pick complete", span.Events()[0].Name)
+}
+
+func TestTagConnAttributes(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	h := TracingMiddleware(RoleServer, WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))
+
+	local := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 8443}
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 51234}
+	ctx := h.TagConn(context.Background(), &stats.ConnTagInfo{RemoteAddr: remote, LocalAddr: local})
+	h.HandleConn(ctx, &stats.ConnBegin{})
+
+	rpcCtx := h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+	h.HandleRPC(rpcCtx, &stats.End{})
+
+	span, ok := rec.Span("shop.Orders/Get")
+	require.True(t, ok)
+	for _, attr := range []attribute.KeyValue{
+		semconv.NetSockPeerAddr("10.0.0.9"),
+		semconv.NetSockPeerPort(51234),
+		semconv.NetSockHostAddr("10.0.0.1"),
+		semconv.NetSockHostPort(8443),
+	} {
+		assert.Contains(t, span.Attributes(), attr)
+	}
+
+	// The connection metrics get the local address only.
+	oteltest.AssertSumValue(t, rec, "rpc.server.active_connections",
+		[]attribute.KeyValue{semconv.NetSockHostAddr("10.0.0.1"), semconv.NetSockHostPort(8443)}, int64(1))
+}
+
+func TestTagConnClientAttributes(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	h := TracingMiddleware(RoleClient, WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))
+
+	local := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 51234}
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 8443}
+	ctx := h.TagConn(context.Background(), &stats.ConnTagInfo{RemoteAddr: remote, LocalAddr: local})
+	h.HandleConn(ctx, &stats.ConnBegin{})
+
+	// The ephemeral local port of a client is kept off the metrics.
+	m, ok := rec.Metric(context.Background(), "rpc.client.active_connections")
+	require.True(t, ok)
+	sum, ok := m.Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, sum.DataPoints, 1)
+	assert.False(t, sum.DataPoints[0].Attributes.HasValue(semconv.NetSockHostPortKey))
+	assert.False(t, sum.DataPoints[0].Attributes.HasValue(semconv.NetSockHostAddrKey))
+}
+
+func TestWithMetadataAttributeExtractor(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	h := TracingMiddleware(RoleServer, WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider),
+		WithMetadataAttributeExtractor(func(md metadata.MD) []attribute.KeyValue {
+			if v := md.Get("x-client-version"); len(v) > 0 {
+				return []attribute.KeyValue{attribute.String("client.version", v[0])}
+			}
+			return nil
+		}))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-client-version", "2.4.1"))
+	ctx = h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+	h.HandleRPC(ctx, &stats.End{})
+
+	span, ok := rec.Span("shop.Orders/Get")
+	require.True(t, ok)
+	version := attribute.String("client.version", "2.4.1")
+	assert.Contains(t, span.Attributes(), version)
+	oteltest.AssertHistogramCount(t, rec, "rpc.server.duration", []attribute.KeyValue{version}, 1)
+}
+
+func TestWithDebugMetadata(t *testing.T) {
+	h := TracingMiddleware(RoleServer, WithPropagators(propagation.Baggage{}), WithDebugMetadata("X-Debug", func(_ context.Context, md metadata.MD) bool {
+		return len(md.Get("authorization")) > 0
+	}))
+	tag := func(kv ...string) context.Context {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+		return h.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+	}
+	assert.False(t, kgsotel.DebugEnabled(tag()))
+	assert.False(t, kgsotel.DebugEnabled(tag("x-debug", "true")))
+	assert.True(t, kgsotel.DebugEnabled(tag("x-debug", "true", "authorization", "Bearer admin")))
+	assert.False(t, kgsotel.DebugEnabled(tag("baggage", "kgs-debug=1")), "propagated by an unauthorized caller")
+	assert.True(t, kgsotel.DebugEnabled(tag("baggage", "kgs-debug=1", "authorization", "Bearer admin")))
+
+	h = TracingMiddleware(RoleServer, WithPropagators(propagation.Baggage{}))
+	assert.False(t, kgsotel.DebugEnabled(tag("baggage", "kgs-debug=1")), "without WithDebugMetadata")
+}
+
+// codedErrorHealth is a health service failing with a CodedError.
+type codedErrorHealth struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (codedErrorHealth) Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	return nil, kgsotel.NewError("ORDER_NOT_FOUND", "order missing", kgsotel.Field{Key: "order.id", Value: "42"})
+}
+
+func TestCodedErrorAttributes(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.StatsHandler(TracingMiddleware(RoleServer, WithTracerProvider(rec.TracerProvider))),
+		grpc.ChainUnaryInterceptor(HandlerErrorUnaryServerInterceptor()),
+	)
+	healthpb.RegisterHealthServer(srv, codedErrorHealth{})
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.Error(t, err)
+
+	var span sdktrace.ReadOnlySpan
+	require.Eventually(t, func() bool {
+		var ok bool
+		span, ok = rec.Span("grpc.health.v1.Health/Check")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, span.Attributes(), attribute.String(kgsotel.ErrorCodeKey, "ORDER_NOT_FOUND"))
+	assert.Contains(t, span.Attributes(), attribute.String("order.id", "42"))
+}
+
+func TestWithSlowThreshold(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	h := TracingMiddleware(RoleServer,
+		WithTracerProvider(rec.TracerProvider),
+		WithSlowThreshold(50*time.Millisecond),
+		WithMethodConfig("/shop.Orders/Export", MethodConfig{SlowThreshold: -1}),
+	)
+	timing := HandlerTimingUnaryServerInterceptor()
+	begin := time.Now()
+	for _, method := range []string{"/shop.Orders/Get", "/shop.Orders/Export"} {
+		ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: method})
+		_, err := timing(ctx, nil, nil, func(context.Context, any) (any, error) { return nil, nil })
+		require.NoError(t, err)
+		h.HandleRPC(ctx, &stats.End{BeginTime: begin, EndTime: begin.Add(80 * time.Millisecond)})
+	}
+
+	spans := rec.Spans()
+	require.Len(t, spans, 2)
+	assert.Contains(t, spans[0].Attributes(), SlowKey.Bool(true))
+	require.Len(t, spans[0].Events(), 1)
+	event := spans[0].Events()[0]
+	assert.Equal(t, "slow request", event.Name)
+	assert.Contains(t, event.Attributes, attribute.Float64("kgs.slow.duration_ms", 80))
+	var keys []attribute.Key
+	for _, kv := range event.Attributes {
+		keys = append(keys, kv.Key)
+	}
+	assert.Contains(t, keys, attribute.Key("kgs.slow.handler_ms"))
+	assert.Contains(t, keys, attribute.Key("kgs.slow.middleware_ms"))
+
+	// The annotation is disabled for the method.
+	assert.NotContains(t, spans[1].Attributes(), SlowKey.Bool(true))
+	assert.Empty(t, spans[1].Events())
+}
+
+func TestWithMetadataKeys(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parent  = "00-" + traceID + "-00f067aa0ba902b7-01"
+	)
+	rec := oteltest.NewRecorder()
+	keys := map[string]string{"traceparent": "X-Partner-Trace"}
+	server := TracingMiddleware(RoleServer, WithTracerProvider(rec.TracerProvider),
+		WithPropagators(propagation.TraceContext{}), WithMetadataKeys(keys))
+	client := TracingMiddleware(RoleClient, WithTracerProvider(rec.TracerProvider),
+		WithPropagators(propagation.TraceContext{}), WithMetadataKeys(keys))
+
+	info := &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-partner-trace", parent))
+	ctx = server.TagRPC(ctx, info)
+	assert.Equal(t, traceID, trace.SpanContextFromContext(ctx).TraceID().String())
+
+	// The standard key is still read.
+	std := server.TagRPC(metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", parent)), info)
+	assert.Equal(t, traceID, trace.SpanContextFromContext(std).TraceID().String())
+
+	out := client.TagRPC(ctx, info)
+	md, _ := metadata.FromOutgoingContext(out)
+	require.Len(t, md.Get("x-partner-trace"), 1)
+	assert.Contains(t, md.Get("x-partner-trace")[0], traceID)
+	assert.Empty(t, md.Get("traceparent"))
+}
+
+func TestExcludedMethodsWithFilter(t *testing.T) {
+	defaults.SetMiddleware(defaults.Middleware{GRPCExcludedMethods: []string{"/grpc.health.v1.Health/Check"}})
+	defer defaults.SetMiddleware(defaults.Middleware{})
+
+	cfg := newConfig(RoleServer, WithFilter(func(info *stats.RPCTagInfo) bool {
+		return info.FullMethodName != "/shop.Orders/Ping"
+	}))
+	for method, want := range map[string]bool{
+		"/grpc.health.v1.Health/Check": false,
+		"/shop.Orders/Ping":            false,
+		"/shop.Orders/Get":             true,
+	} {
+		assert.Equal(t, want, cfg.Filter(&stats.RPCTagInfo{FullMethodName: method}), method)
+	}
+}
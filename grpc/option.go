@@ -6,13 +6,19 @@
 package otelgrpc
 
 import (
+	"context"
+	kgsotel "kgs/otel"
+	"kgs/otel/internal/defaults"
+	"strings"
+	"time"
+
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/propagation"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/stats"
 )
 
@@ -33,6 +39,20 @@ type config struct {
 	SpanStartOptions  []trace.SpanStartOption
 	SpanAttributes    []attribute.KeyValue
 	MetricAttributes  []attribute.KeyValue
+	DurationBuckets   []float64
+	SizeBuckets       []float64
+	LBPolicy          string
+	Methods           map[string]*MethodConfig
+	MetadataExtractor MetadataAttributeExtractor
+	AccessLog         bool
+	DebugMetadataKey  string
+	DebugAuthorize    func(context.Context, metadata.MD) bool
+	SlowThreshold     time.Duration
+	MetadataKeys      map[string]string
+	Semconv           kgsotel.SemconvVersion
+	// ValidationErrorMapper decomposes the validation errors of the
+	// handlers.
+	ValidationErrorMapper ValidationErrorMapper
 
 	tracer trace.Tracer
 	meter  metric.Meter
@@ -42,6 +62,11 @@ type config struct {
 	rpcResponseSize    metric.Int64Histogram
 	rpcRequestsPerRPC  metric.Int64Histogram
 	rpcResponsesPerRPC metric.Int64Histogram
+	rpcConnOpened      metric.Int64Counter
+	rpcConnClosed      metric.Int64Counter
+	rpcActiveConns     metric.Int64UpDownCounter
+	rpcActiveStreams   metric.Int64UpDownCounter
+	rpcValidation      metric.Int64Counter
 }
 
 // Filter is a predicate used to determine whether a given request in
@@ -56,6 +81,10 @@ type Filter func(*stats.RPCTagInfo) bool
 // Deprecated: Use stats handlers instead.
 type InterceptorFilter func(*InterceptorInfo) bool
 
+// MetadataAttributeExtractor returns the attributes of an RPC from its
+// incoming metadata.
+type MetadataAttributeExtractor func(md metadata.MD) []attribute.KeyValue
+
 // Option applies an option value for a config.
 type Option interface {
 	apply(*config)
@@ -67,6 +96,19 @@ func (o optionFunc) apply(c *config) {
 	o(c)
 }
 
+// WithTelemetry returns an Option to use the tracer provider, the meter
+// provider and the propagator of the telemetry pipeline instead of the
+// global ones.
+func WithTelemetry(t *kgsotel.Telemetry) Option {
+	return optionFunc(func(cfg *config) {
+		if t != nil {
+			cfg.TracerProvider = t.TracerProvider()
+			cfg.MeterProvider = t.MeterProvider()
+			cfg.Propagators = t.Propagator()
+		}
+	})
+}
+
 // WithFilter returns an Option to use the request filter.
 func WithFilter(f Filter) Option {
 	return optionFunc(func(cfg *config) {
@@ -133,12 +175,91 @@ func WithMetricAttributes(attrs ...attribute.KeyValue) Option {
 	})
 }
 
+// WithDurationBuckets returns an Option to use explicit bucket boundaries
+// (in milliseconds) for the RPC duration histogram. If none are specified,
+// the SDK default boundaries are used.
+func WithDurationBuckets(bounds ...float64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.DurationBuckets = bounds
+	})
+}
+
+// WithSizeBuckets returns an Option to use explicit bucket boundaries
+// (in bytes) for the RPC request and response size histograms. If none are
+// specified, the SDK default boundaries are used.
+func WithSizeBuckets(bounds ...float64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SizeBuckets = bounds
+	})
+}
+
+// WithMetadataAttributeExtractor returns an Option to add the attributes
+// returned by f from the incoming metadata, e.g. the client version or a
+// shard key, to the server spans and metrics. Keep the cardinality of the
+// values low, as they are metric attributes.
+func WithMetadataAttributeExtractor(f MetadataAttributeExtractor) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.MetadataExtractor = f
+	})
+}
+
+// WithMetadataKeys returns an Option to propagate the context in custom
+// metadata keys, for the partner services not using the standard ones.
+// keys maps the keys of the propagators, e.g. "traceparent", to the metadata
+// keys, e.g. "x-partner-trace". The servers read the custom keys, falling
+// back to the standard ones, and the clients write the custom keys instead
+// of the standard ones.
+func WithMetadataKeys(keys map[string]string) Option {
+	return optionFunc(func(cfg *config) {
+		if cfg.MetadataKeys == nil {
+			cfg.MetadataKeys = make(map[string]string, len(keys))
+		}
+		for std, custom := range keys {
+			cfg.MetadataKeys[strings.ToLower(std)] = strings.ToLower(custom)
+		}
+	})
+}
+
+// WithSemconv returns an Option to select the version of the semantic
+// conventions of the middleware instead of the one of kgsotel.WithSemconv.
+func WithSemconv(version kgsotel.SemconvVersion) Option {
+	return optionFunc(func(cfg *config) {
+		if version.Valid() {
+			cfg.Semconv = version
+		}
+	})
+}
+
+// excludeMethods returns a Filter rejecting the RPCs to the given full method names.
+func excludeMethods(methods []string) Filter {
+	excluded := make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		excluded[m] = struct{}{}
+	}
+	return func(info *stats.RPCTagInfo) bool {
+		_, ok := excluded[info.FullMethodName]
+		return !ok
+	}
+}
+
 // newConfig creates a new config with the given role and options.
 func newConfig(role Role, opts ...Option) *config {
 	cfg := &config{}
 	for _, opt := range opts {
 		opt.apply(cfg)
 	}
+	// The methods excluded by the config file are excluded on top of the
+	// filter of the options.
+	if methods := defaults.GetMiddleware().GRPCExcludedMethods; len(methods) > 0 {
+		excluded := excludeMethods(methods)
+		if filter := cfg.Filter; filter != nil {
+			cfg.Filter = func(info *stats.RPCTagInfo) bool {
+				return excluded(info) && filter(info)
+			}
+		} else {
+			cfg.Filter = excluded
+		}
+	}
 	if cfg.TracerProvider == nil {
 		cfg.TracerProvider = otel.GetTracerProvider()
 	}
@@ -149,20 +270,28 @@ func newConfig(role Role, opts ...Option) *config {
 		cfg.Propagators = otel.GetTextMapPropagator()
 	}
 
+	// The attributes are converted to the selected semantic conventions.
+	cfg.Semconv = cfg.Semconv.Resolve()
+
 	// Set the tracer and meter for the service.
-	cfg.tracer = cfg.TracerProvider.Tracer(ScopeName)
+	cfg.tracer = cfg.TracerProvider.Tracer(ScopeName, trace.WithSchemaURL(cfg.Semconv.SchemaURL()))
 
 	cfg.meter = cfg.MeterProvider.Meter(
 		ScopeName,
-		metric.WithSchemaURL(semconv.SchemaURL),
+		metric.WithSchemaURL(cfg.Semconv.SchemaURL()),
 	)
 
 	var err error
 
 	// Measure the duration of the incoming RPCs.
-	cfg.rpcDuration, err = cfg.meter.Float64Histogram("rpc."+role.String()+".duration",
+	durationOpts := []metric.Float64HistogramOption{
 		metric.WithDescription("Measures the duration of inbound RPC."),
-		metric.WithUnit("ms"))
+		metric.WithUnit("ms"),
+	}
+	if len(cfg.DurationBuckets) > 0 {
+		durationOpts = append(durationOpts, metric.WithExplicitBucketBoundaries(cfg.DurationBuckets...))
+	}
+	cfg.rpcDuration, err = cfg.meter.Float64Histogram("rpc."+role.String()+".duration", durationOpts...)
 	if err != nil {
 		otel.Handle(err)
 		if cfg.rpcDuration == nil {
@@ -171,9 +300,15 @@ func newConfig(role Role, opts ...Option) *config {
 	}
 
 	// Measure the size of the request and response bodies.
+	var sizeOpts []metric.Int64HistogramOption
+	if len(cfg.SizeBuckets) > 0 {
+		sizeOpts = append(sizeOpts, metric.WithExplicitBucketBoundaries(cfg.SizeBuckets...))
+	}
 	cfg.rpcRequestSize, err = cfg.meter.Int64Histogram("rpc."+role.String()+".request.size",
-		metric.WithDescription("Measures size of RPC request messages (uncompressed)."),
-		metric.WithUnit("By"))
+		append([]metric.Int64HistogramOption{
+			metric.WithDescription("Measures size of RPC request messages (uncompressed)."),
+			metric.WithUnit("By"),
+		}, sizeOpts...)...)
 	if err != nil {
 		otel.Handle(err)
 		if cfg.rpcRequestSize == nil {
@@ -183,8 +318,10 @@ func newConfig(role Role, opts ...Option) *config {
 
 	// Measure the size of the request and response bodies.
 	cfg.rpcResponseSize, err = cfg.meter.Int64Histogram("rpc."+role.String()+".response.size",
-		metric.WithDescription("Measures size of RPC response messages (uncompressed)."),
-		metric.WithUnit("By"))
+		append([]metric.Int64HistogramOption{
+			metric.WithDescription("Measures size of RPC response messages (uncompressed)."),
+			metric.WithUnit("By"),
+		}, sizeOpts...)...)
 	if err != nil {
 		otel.Handle(err)
 		if cfg.rpcResponseSize == nil {
@@ -214,5 +351,60 @@ func newConfig(role Role, opts ...Option) *config {
 		}
 	}
 
+	// Count the connections opened.
+	cfg.rpcConnOpened, err = cfg.meter.Int64Counter("rpc."+role.String()+".connections.opened",
+		metric.WithDescription("Measures the number of connections opened."),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rpcConnOpened == nil {
+			cfg.rpcConnOpened = noop.Int64Counter{}
+		}
+	}
+
+	// Count the connections closed.
+	cfg.rpcConnClosed, err = cfg.meter.Int64Counter("rpc."+role.String()+".connections.closed",
+		metric.WithDescription("Measures the number of connections closed."),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rpcConnClosed == nil {
+			cfg.rpcConnClosed = noop.Int64Counter{}
+		}
+	}
+
+	// Count the RPCs rejected by the validation of their request.
+	cfg.rpcValidation, err = cfg.meter.Int64Counter("rpc."+role.String()+".validation_failures",
+		metric.WithDescription("Measures the number of RPCs failing the validation of their request."),
+		metric.WithUnit("{call}"))
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rpcValidation == nil {
+			cfg.rpcValidation = noop.Int64Counter{}
+		}
+	}
+
+	// Measure the number of currently open connections.
+	cfg.rpcActiveConns, err = cfg.meter.Int64UpDownCounter("rpc."+role.String()+".active_connections",
+		metric.WithDescription("Measures the number of currently open connections."),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rpcActiveConns == nil {
+			cfg.rpcActiveConns = noop.Int64UpDownCounter{}
+		}
+	}
+
+	// Measure the number of currently open streams.
+	cfg.rpcActiveStreams, err = cfg.meter.Int64UpDownCounter("rpc."+role.String()+".active_streams",
+		metric.WithDescription("Measures the number of currently open streaming RPCs."),
+		metric.WithUnit("{stream}"))
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rpcActiveStreams == nil {
+			cfg.rpcActiveStreams = noop.Int64UpDownCounter{}
+		}
+	}
+
 	return cfg
 }
@@ -0,0 +1,54 @@
+package otelgrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/stats"
+)
+
+// histogramPoint returns the only data point of the named histogram.
+func histogramPoint[N int64 | float64](t *testing.T, reader sdkmetric.Reader, name string) metricdata.HistogramDataPoint[N] {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			hist, ok := m.Data.(metricdata.Histogram[N])
+			require.True(t, ok, "%s is a %T", name, m.Data)
+			require.Len(t, hist.DataPoints, 1, name)
+			return hist.DataPoints[0]
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return metricdata.HistogramDataPoint[N]{}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	h := TracingMiddleware(RoleServer, WithMeterProvider(mp), WithDurationBuckets(1, 10, 100), WithSizeBuckets(64, 1024))
+
+	ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Get"})
+	h.HandleRPC(ctx, &stats.InPayload{Length: 10})
+	h.HandleRPC(ctx, &stats.OutPayload{Length: 2048})
+	h.HandleRPC(ctx, &stats.End{})
+
+	assert.Equal(t, []float64{1, 10, 100}, histogramPoint[float64](t, reader, "rpc.server.duration").Bounds)
+	for name, counts := range map[string][]uint64{
+		"rpc.server.request.size":  {1, 0, 0},
+		"rpc.server.response.size": {0, 0, 1},
+	} {
+		dp := histogramPoint[int64](t, reader, name)
+		assert.Equal(t, []float64{64, 1024}, dp.Bounds, name)
+		assert.Equal(t, counts, dp.BucketCounts, name)
+	}
+}
@@ -0,0 +1,100 @@
+// Package otelgrpctest drives the otelgrpc stats handler with fake RPCs and
+// records its telemetry in memory, to test the filters and attributes of a
+// service without starting a server.
+package otelgrpctest
+
+import (
+	"context"
+	otelgrpc "kgs/otel/grpc"
+	"kgs/otel/oteltest"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+)
+
+// Harness is an otelgrpc stats handler recording its telemetry.
+type Harness struct {
+	*oteltest.Recorder
+
+	Handler stats.Handler
+	role    otelgrpc.Role
+}
+
+// RPC describes a fake RPC.
+type RPC struct {
+	// FullMethod is the full method name, e.g. /package.Service/Method.
+	FullMethod string
+	// Metadata is the incoming metadata of a server RPC, or the outgoing
+	// metadata of a client RPC.
+	Metadata metadata.MD
+	// RequestSizes and ResponseSizes are the sizes of the messages sent and
+	// received by the RPC. A unary RPC has a single message in each direction.
+	RequestSizes  []int
+	ResponseSizes []int
+	// Streaming marks the RPC as a streaming one.
+	Streaming bool
+	// Duration is the duration of the RPC.
+	Duration time.Duration
+	// Err is the error returned by the RPC.
+	Err error
+}
+
+// New returns a Harness whose handler is created with the role and options.
+func New(role otelgrpc.Role, opts ...otelgrpc.Option) *Harness {
+	recorder := oteltest.NewRecorder()
+	opts = append([]otelgrpc.Option{
+		otelgrpc.WithTracerProvider(recorder.TracerProvider),
+		otelgrpc.WithMeterProvider(recorder.MeterProvider),
+	}, opts...)
+
+	return &Harness{
+		Recorder: recorder,
+		Handler:  otelgrpc.TracingMiddleware(role, opts...),
+		role:     role,
+	}
+}
+
+// Call feeds the stats of the RPC to the handler.
+func (h *Harness) Call(ctx context.Context, rpc RPC) {
+	client := h.role == otelgrpc.RoleClient
+	if rpc.Metadata != nil {
+		if client {
+			ctx = metadata.NewOutgoingContext(ctx, rpc.Metadata)
+		} else {
+			ctx = metadata.NewIncomingContext(ctx, rpc.Metadata)
+		}
+	}
+
+	ctx = h.Handler.TagRPC(ctx, &stats.RPCTagInfo{FullMethodName: rpc.FullMethod})
+	begin := time.Now()
+	h.Handler.HandleRPC(ctx, &stats.Begin{
+		Client:         client,
+		BeginTime:      begin,
+		IsClientStream: rpc.Streaming,
+		IsServerStream: rpc.Streaming,
+	})
+
+	// The requests are received by a server and sent by a client.
+	for _, n := range rpc.RequestSizes {
+		if client {
+			h.Handler.HandleRPC(ctx, &stats.OutPayload{Client: client, Length: n, WireLength: n})
+		} else {
+			h.Handler.HandleRPC(ctx, &stats.InPayload{Client: client, Length: n, WireLength: n})
+		}
+	}
+	for _, n := range rpc.ResponseSizes {
+		if client {
+			h.Handler.HandleRPC(ctx, &stats.InPayload{Client: client, Length: n, WireLength: n})
+		} else {
+			h.Handler.HandleRPC(ctx, &stats.OutPayload{Client: client, Length: n, WireLength: n})
+		}
+	}
+
+	h.Handler.HandleRPC(ctx, &stats.End{
+		Client:    client,
+		BeginTime: begin,
+		EndTime:   begin.Add(rpc.Duration),
+		Error:     rpc.Err,
+	})
+}
@@ -0,0 +1,50 @@
+package otelgrpctest
+
+import (
+	"context"
+	otelgrpc "kgs/otel/grpc"
+	"kgs/otel/oteltest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHarness(t *testing.T) {
+	ctx := context.Background()
+	h := New(otelgrpc.RoleServer)
+
+	h.Call(ctx, RPC{
+		FullMethod:    "/shop.Orders/Get",
+		RequestSizes:  []int{12},
+		ResponseSizes: []int{40},
+		Duration:      5 * time.Millisecond,
+	})
+	h.Call(ctx, RPC{
+		FullMethod: "/shop.Orders/Create",
+		Err:        status.Error(grpcCodes.Internal, "boom"),
+	})
+
+	require.Len(t, h.Spans(), 2)
+	span, ok := h.Span("shop.Orders/Create")
+	require.True(t, ok)
+	assert.Equal(t, codes.Error, span.Status().Code)
+
+	oteltest.AssertHistogramCount(t, h, "rpc.server.duration",
+		[]attribute.KeyValue{semconv.RPCMethod("Get")}, 1)
+}
+
+func TestGoldenSpans(t *testing.T) {
+	ctx := context.Background()
+	h := New(otelgrpc.RoleServer)
+	h.Call(ctx, RPC{FullMethod: "/shop.Orders/Get"})
+	h.Call(ctx, RPC{FullMethod: "/shop.Orders/Create", Err: status.Error(grpcCodes.Internal, "boom")})
+
+	oteltest.AssertGoldenSpans(t, "testdata/spans.golden.json", h.Spans())
+}
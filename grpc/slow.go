@@ -0,0 +1,42 @@
+package otelgrpc
+
+import (
+	"context"
+	"time"
+
+	"kgs/otel/internal/slow"
+
+	"google.golang.org/grpc"
+)
+
+// SlowKey marks the spans of the RPCs exceeding their slow threshold.
+const SlowKey = slow.Key
+
+// WithSlowThreshold returns an Option to mark the server spans of the unary
+// RPCs taking longer than d with kgs.slow=true and a "slow request" event
+// with their duration. MethodConfig.SlowThreshold overrides it per method.
+// The streaming RPCs are long by design and never marked.
+func WithSlowThreshold(d time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SlowThreshold = d
+	})
+}
+
+// HandlerTimingUnaryServerInterceptor returns an interceptor timing the
+// handler of the RPCs, so the event of the slow RPCs breaks their duration
+// down into handler and middleware time. It must be the last interceptor of
+// the chain for the other interceptors to count as middleware.
+func HandlerTimingUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		defer slow.StartHandler(ctx)()
+		return handler(ctx, req)
+	}
+}
+
+// slowThreshold returns the slow threshold of the RPCs configured by mc.
+func (cfg *config) slowThreshold(mc *MethodConfig) time.Duration {
+	if mc != nil && mc.SlowThreshold != 0 {
+		return max(mc.SlowThreshold, 0)
+	}
+	return cfg.SlowThreshold
+}
@@ -0,0 +1,159 @@
+package otelgrpc
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// The attributes of the RPCs failing the validation of their request.
+const (
+	// ValidationFailedKey marks the spans of the RPCs rejected by the
+	// validation, client mistakes rather than server faults.
+	ValidationFailedKey = attribute.Key("kgs.validation.failed")
+	// ValidationViolationsKey is the number of violations of the request.
+	ValidationViolationsKey = attribute.Key("kgs.validation.violations")
+
+	ValidationFieldKey      = attribute.Key("kgs.validation.field")
+	ValidationConstraintKey = attribute.Key("kgs.validation.constraint")
+	ValidationMessageKey    = attribute.Key("kgs.validation.message")
+)
+
+// maxViolationEvents bounds the events of the violations of a request.
+const maxViolationEvents = 10
+
+// Violation is a constraint of a request field failed by the request.
+type Violation struct {
+	// Field is the path of the field, e.g. "user.email".
+	Field string
+	// Constraint is the failed constraint, e.g. "required" or
+	// "string.email".
+	Constraint string
+	// Message describes the violation.
+	Message string
+}
+
+// ValidationErrorMapper returns the violations of the validation error err,
+// or nil if err is not a validation error.
+type ValidationErrorMapper func(err error) []Violation
+
+// WithValidationErrors returns an Option to decompose the validation errors
+// returned by the handlers into their violations. The server spans of the
+// rejected RPCs get kgs.validation.failed=true and a "validation violation"
+// event with the field and constraint of each of the first 10 violations,
+// keep an Unset status as client mistakes, and are counted by the
+// rpc.server.validation_failures metric.
+//
+// mapper recognizes the errors of a validation library; it defaults to
+// DefaultValidationErrorMapper, which maps the BadRequest details of the
+// statuses. The errors of protovalidate are mapped by:
+//
+//	func(err error) []otelgrpc.Violation {
+//		var verr *protovalidate.ValidationError
+//		if !errors.As(err, &verr) {
+//			return otelgrpc.DefaultValidationErrorMapper(err)
+//		}
+//		var violations []otelgrpc.Violation
+//		for _, v := range verr.ToProto().GetViolations() {
+//			violations = append(violations, otelgrpc.Violation{
+//				Field:      protovalidate.FieldPathString(v.GetField()),
+//				Constraint: v.GetConstraintId(),
+//				Message:    v.GetMessage(),
+//			})
+//		}
+//		return violations
+//	}
+//
+// and the validator.ValidationErrors of github.com/go-playground/validator by:
+//
+//	func(err error) []otelgrpc.Violation {
+//		var verrs validator.ValidationErrors
+//		if !errors.As(err, &verrs) {
+//			return otelgrpc.DefaultValidationErrorMapper(err)
+//		}
+//		violations := make([]otelgrpc.Violation, 0, len(verrs))
+//		for _, fe := range verrs {
+//			violations = append(violations, otelgrpc.Violation{
+//				Field:      fe.Namespace(),
+//				Constraint: fe.Tag(),
+//				Message:    fe.Error(),
+//			})
+//		}
+//		return violations
+//	}
+//
+// The errors converted to a status lose their type: register
+// HandlerErrorUnaryServerInterceptor or HandlerErrorStreamServerInterceptor
+// last for mapper to see the errors returned by the handlers.
+func WithValidationErrors(mapper ValidationErrorMapper) Option {
+	return optionFunc(func(cfg *config) {
+		if mapper == nil {
+			mapper = DefaultValidationErrorMapper
+		}
+		cfg.ValidationErrorMapper = mapper
+	})
+}
+
+// DefaultValidationErrorMapper maps the statuses with a BadRequest detail,
+// e.g. InvalidArgument errors built by the handlers.
+func DefaultValidationErrorMapper(err error) []Violation {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	var violations []Violation
+	for _, detail := range s.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, fv := range br.GetFieldViolations() {
+				violations = append(violations, Violation{
+					Field:   fv.GetField(),
+					Message: fv.GetDescription(),
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// ValidationUnaryServerInterceptor is HandlerErrorUnaryServerInterceptor,
+// keeping the error returned by the handler for the ValidationErrorMapper.
+func ValidationUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return HandlerErrorUnaryServerInterceptor()
+}
+
+// ValidationStreamServerInterceptor is HandlerErrorStreamServerInterceptor.
+func ValidationStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return HandlerErrorStreamServerInterceptor()
+}
+
+// violations returns the violations of the error of the RPC, the one kept
+// by the interceptors or else err.
+func (cfg *config) violations(gctx *gRPCContext, err error) []Violation {
+	if cfg.ValidationErrorMapper == nil || err == nil {
+		return nil
+	}
+	return cfg.ValidationErrorMapper(handlerError(gctx, err))
+}
+
+// annotateViolations adds the violations to the span of the RPC.
+func annotateViolations(span trace.Span, violations []Violation) {
+	span.SetAttributes(
+		ValidationFailedKey.Bool(true),
+		ValidationViolationsKey.Int(len(violations)),
+	)
+	for i, v := range violations {
+		if i == maxViolationEvents {
+			break
+		}
+		attrs := []attribute.KeyValue{ValidationFieldKey.String(v.Field)}
+		if v.Constraint != "" {
+			attrs = append(attrs, ValidationConstraintKey.String(v.Constraint))
+		}
+		if v.Message != "" {
+			attrs = append(attrs, ValidationMessageKey.String(v.Message))
+		}
+		span.AddEvent("validation violation", trace.WithAttributes(attrs...))
+	}
+}
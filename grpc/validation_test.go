@@ -0,0 +1,118 @@
+package otelgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kgs/otel/oteltest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// fieldErrors are the errors of a validation library, e.g.
+// validator.ValidationErrors of go-playground/validator.
+type fieldErrors []fieldError
+
+type fieldError struct{ namespace, tag string }
+
+func (errs fieldErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		msgs = append(msgs, fe.namespace+" failed on "+fe.tag)
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// mapFieldErrors maps the fieldErrors, and the other errors with the default
+// mapper.
+func mapFieldErrors(err error) []Violation {
+	var errs fieldErrors
+	if !errors.As(err, &errs) {
+		return DefaultValidationErrorMapper(err)
+	}
+	violations := make([]Violation, 0, len(errs))
+	for _, fe := range errs {
+		violations = append(violations, Violation{Field: fe.namespace, Constraint: fe.tag})
+	}
+	return violations
+}
+
+func TestWithValidationErrors(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	h := TracingMiddleware(RoleServer,
+		WithTracerProvider(rec.TracerProvider),
+		WithMeterProvider(rec.MeterProvider),
+		WithValidationErrors(mapFieldErrors),
+	)
+	intercept := ValidationUnaryServerInterceptor()
+
+	// The handler returns the error of the validation library, converted
+	// to an Unknown status by gRPC.
+	verr := fmt.Errorf("create order: %w", fieldErrors{{"order.ID", "required"}, {"order.Email", "email"}})
+	ctx := h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Create"})
+	_, err := intercept(ctx, nil, nil, func(context.Context, any) (any, error) { return nil, verr })
+	require.Error(t, err)
+	h.HandleRPC(ctx, &stats.End{Error: status.Error(grpcCodes.Unknown, err.Error())})
+
+	// The handler returns an InvalidArgument status with a BadRequest.
+	st, err := status.New(grpcCodes.InvalidArgument, "invalid order").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "items", Description: "must not be empty"}},
+	})
+	require.NoError(t, err)
+	ctx = h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Update"})
+	h.HandleRPC(ctx, &stats.End{Error: st.Err()})
+
+	// A server fault is not a validation failure.
+	ctx = h.TagRPC(context.Background(), &stats.RPCTagInfo{FullMethodName: "/shop.Orders/Delete"})
+	_, err = intercept(ctx, nil, nil, func(context.Context, any) (any, error) { return nil, errors.New("db down") })
+	h.HandleRPC(ctx, &stats.End{Error: status.Error(grpcCodes.Unknown, err.Error())})
+
+	span, ok := rec.Span("shop.Orders/Create")
+	require.True(t, ok)
+	assert.Equal(t, codes.Unset, span.Status().Code, "a client mistake")
+	assert.Contains(t, span.Attributes(), ValidationFailedKey.Bool(true))
+	assert.Contains(t, span.Attributes(), ValidationViolationsKey.Int(2))
+	require.Len(t, span.Events(), 2)
+	assert.Equal(t, "validation violation", span.Events()[0].Name)
+	assert.Contains(t, span.Events()[0].Attributes, ValidationFieldKey.String("order.ID"))
+	assert.Contains(t, span.Events()[0].Attributes, ValidationConstraintKey.String("required"))
+	assert.Contains(t, span.Events()[1].Attributes, ValidationConstraintKey.String("email"))
+
+	span, ok = rec.Span("shop.Orders/Update")
+	require.True(t, ok)
+	require.Len(t, span.Events(), 1)
+	assert.Contains(t, span.Events()[0].Attributes, ValidationFieldKey.String("items"))
+	assert.Contains(t, span.Events()[0].Attributes, ValidationMessageKey.String("must not be empty"))
+
+	span, ok = rec.Span("shop.Orders/Delete")
+	require.True(t, ok)
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.NotContains(t, span.Attributes(), ValidationFailedKey.Bool(true))
+
+	for _, method := range []string{"Create", "Update"} {
+		oteltest.AssertSumValue(t, rec, "rpc.server.validation_failures",
+			[]attribute.KeyValue{semconv.RPCMethod(method)}, int64(1))
+	}
+}
+
+func TestDefaultValidationErrorMapper(t *testing.T) {
+	assert.Nil(t, DefaultValidationErrorMapper(errors.New("db down")))
+	assert.Nil(t, DefaultValidationErrorMapper(status.Error(grpcCodes.InvalidArgument, "invalid order")))
+
+	st, err := status.New(grpcCodes.InvalidArgument, "invalid order").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "items", Description: "must not be empty"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Violation{{Field: "items", Message: "must not be empty"}}, DefaultValidationErrorMapper(st.Err()))
+}
@@ -0,0 +1,145 @@
+package kgsotel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// HealthStatus is the health of the connection to the collector.
+type HealthStatus struct {
+	// Healthy reports whether the connection is usable.
+	Healthy bool `json:"healthy"`
+	// State is the connectivity state of the connection, e.g. READY.
+	State string `json:"state"`
+	// Since is the time of the last state change.
+	Since time.Time `json:"since"`
+	// Failures is the number of times the connection went into TRANSIENT_FAILURE.
+	Failures int64 `json:"failures"`
+	// Recoveries is the number of times the connection became READY after a failure.
+	Recoveries int64 `json:"recoveries"`
+}
+
+// connHealth tracks the connectivity state changes of the collector connection.
+type connHealth struct {
+	mu         sync.Mutex
+	state      connectivity.State
+	since      time.Time
+	failures   int64
+	recoveries int64
+	failed     bool
+}
+
+// update records the new state and returns whether it is a failure or a recovery.
+func (h *connHealth) update(state connectivity.State) (failure, recovery bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.state = state
+	h.since = time.Now()
+	switch state {
+	case connectivity.TransientFailure:
+		h.failures++
+		h.failed = true
+		return true, false
+	case connectivity.Ready:
+		if h.failed {
+			h.recoveries++
+			h.failed = false
+			return false, true
+		}
+	}
+	return false, false
+}
+
+func (h *connHealth) snapshot() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return HealthStatus{
+		// An idle or connecting connection is healthy until it fails.
+		Healthy:    h.state != connectivity.TransientFailure && h.state != connectivity.Shutdown,
+		State:      h.state.String(),
+		Since:      h.since,
+		Failures:   h.failures,
+		Recoveries: h.recoveries,
+	}
+}
+
+// CollectorHealth returns the health of the connection to the collector of
+// the telemetry created by InitTelemetry.
+func CollectorHealth() HealthStatus {
+	return diag.health.snapshot()
+}
+
+// CollectorHealth returns the health of the connection to the collector.
+func (t *Telemetry) CollectorHealth() HealthStatus {
+	return t.status.health.snapshot()
+}
+
+// probeConn connects to the collector and waits until the connection is
+// ready, or returns an error once the timeout expires.
+func probeConn(ctx context.Context, conn *grpc.ClientConn, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("probe collector %s: still %s after %s", conn.Target(), state, timeout)
+		}
+	}
+}
+
+// watchConn records the state changes of the connection and counts the
+// failures and recoveries until ctx is done or the connection is closed.
+func watchConn(ctx context.Context, conn *grpc.ClientConn, health *connHealth, mp metric.MeterProvider) {
+	meter := mp.Meter(scopeName)
+
+	var err error
+	failures, err := meter.Int64Counter("kgsotel.collector.connection.failures",
+		metric.WithDescription("Measures the number of times the collector connection failed."),
+		metric.WithUnit("{failure}"))
+	if err != nil {
+		otel.Handle(err)
+		if failures == nil {
+			failures = noop.Int64Counter{}
+		}
+	}
+
+	recoveries, err := meter.Int64Counter("kgsotel.collector.connection.recoveries",
+		metric.WithDescription("Measures the number of times the collector connection recovered from a failure."),
+		metric.WithUnit("{recovery}"))
+	if err != nil {
+		otel.Handle(err)
+		if recoveries == nil {
+			recoveries = noop.Int64Counter{}
+		}
+	}
+
+	state := conn.GetState()
+	health.update(state)
+	for conn.WaitForStateChange(ctx, state) {
+		state = conn.GetState()
+		failure, recovery := health.update(state)
+		if failure {
+			failures.Add(ctx, 1)
+		}
+		if recovery {
+			recoveries.Add(ctx, 1)
+		}
+		if state == connectivity.Shutdown {
+			return
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestConnHealth(t *testing.T) {
+	h := &connHealth{}
+
+	h.update(connectivity.Connecting)
+	assert.True(t, h.snapshot().Healthy)
+
+	failure, _ := h.update(connectivity.TransientFailure)
+	assert.True(t, failure)
+	assert.False(t, h.snapshot().Healthy)
+
+	_, recovery := h.update(connectivity.Ready)
+	assert.True(t, recovery)
+
+	status := h.snapshot()
+	assert.True(t, status.Healthy)
+	assert.Equal(t, "READY", status.State)
+	assert.Equal(t, int64(1), status.Failures)
+	assert.Equal(t, int64(1), status.Recoveries)
+}
+
+func TestStartupProbeUnreachable(t *testing.T) {
+	_, err := New(context.Background(), Config{
+		ServiceName: "svc",
+		Endpoint:    "127.0.0.1:1",
+		Options:     []Option{WithStartupProbe(200 * time.Millisecond)},
+	})
+	assert.ErrorContains(t, err, "probe collector")
+}
@@ -0,0 +1,53 @@
+package otelhttp
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// BaggageFilter reports whether a baggage member is sent to the server.
+type BaggageFilter func(m baggage.Member) bool
+
+// WithBaggageFilter returns an Option to only send the baggage members
+// accepted by f, e.g. to keep the internal debug flags from crossing a
+// trust boundary. All the members are sent by default.
+func WithBaggageFilter(f BaggageFilter) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.BaggageFilter = f
+	})
+}
+
+// defaultPropagator returns the global propagator completed with the trace
+// context and the baggage if it does not propagate them, so both reach the
+// servers even before the telemetry is initialized.
+func defaultPropagator(global propagation.TextMapPropagator) propagation.TextMapPropagator {
+	fields := global.Fields()
+	propagators := []propagation.TextMapPropagator{global}
+	if !slices.Contains(fields, "traceparent") {
+		propagators = append(propagators, propagation.TraceContext{})
+	}
+	if !slices.Contains(fields, "baggage") {
+		propagators = append(propagators, propagation.Baggage{})
+	}
+	if len(propagators) == 1 {
+		return global
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
+
+// filterBaggage returns ctx with the baggage members accepted by f.
+func filterBaggage(ctx context.Context, f BaggageFilter) context.Context {
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return ctx
+	}
+	for _, m := range bag.Members() {
+		if !f(m) {
+			bag = bag.DeleteMember(m.Key())
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
@@ -0,0 +1,51 @@
+package otelhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestTransportBaggage(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	tenant, err := baggage.NewMember("tenant", "acme")
+	require.NoError(t, err)
+	debug, err := baggage.NewMember("debug", "1")
+	require.NoError(t, err)
+	bag, err := baggage.New(tenant, debug)
+	require.NoError(t, err)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+
+	get := func(opts ...Option) {
+		rec := oteltest.NewRecorder()
+		client := &http.Client{Transport: NewTransport(nil, append(opts, WithTracerProvider(rec.TracerProvider))...)}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// The trace context and the baggage are sent by default.
+	get()
+	assert.NotEmpty(t, header.Get("traceparent"))
+	members := strings.Split(header.Get("baggage"), ",")
+	assert.ElementsMatch(t, []string{"tenant=acme", "debug=1"}, members)
+
+	get(WithBaggageFilter(func(m baggage.Member) bool { return m.Key() != "debug" }))
+	assert.Equal(t, "tenant=acme", header.Get("baggage"))
+	assert.Equal(t, 2, baggage.FromContext(ctx).Len(), "the context is not modified")
+}
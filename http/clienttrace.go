@@ -0,0 +1,124 @@
+package otelhttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes of the connection phase events.
+const (
+	// PhaseDurationKey is the duration of a connection phase in milliseconds.
+	PhaseDurationKey = attribute.Key("http.phase.duration_ms")
+	// PhaseErrorKey is the error a connection phase failed with.
+	PhaseErrorKey = attribute.Key("http.phase.error")
+	// ConnReusedKey tells whether the request reused an idle connection.
+	ConnReusedKey = attribute.Key("http.conn.reused")
+)
+
+// WithClientTrace returns an Option to add the connection phases of the
+// requests, from net/http/httptrace, to their spans as events: the DNS
+// lookup, the TCP connect, the TLS handshake and the time to first byte,
+// each with its duration.
+func WithClientTrace(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ClientTrace = enabled
+	})
+}
+
+// clientTrace adds the connection phase events to a span. The callbacks of
+// the dials to several addresses may run concurrently.
+type clientTrace struct {
+	span  trace.Span
+	start time.Time
+
+	mu       sync.Mutex
+	dns      time.Time
+	connects map[string]time.Time
+	tls      time.Time
+}
+
+// withClientTrace returns ctx with a client trace adding the connection
+// phases of the request to span.
+func withClientTrace(ctx context.Context, span trace.Span) context.Context {
+	ct := &clientTrace{span: span, start: time.Now(), connects: make(map[string]time.Time)}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn:              ct.gotConn,
+		DNSStart:             ct.dnsStart,
+		DNSDone:              ct.dnsDone,
+		ConnectStart:         ct.connectStart,
+		ConnectDone:          ct.connectDone,
+		TLSHandshakeStart:    ct.tlsStart,
+		TLSHandshakeDone:     ct.tlsDone,
+		WroteRequest:         ct.wroteRequest,
+		GotFirstResponseByte: ct.gotFirstResponseByte,
+	})
+}
+
+// event adds the event of a phase which started at start.
+func (ct *clientTrace) event(name string, start time.Time, err error, attrs ...attribute.KeyValue) {
+	attrs = append(attrs, PhaseDurationKey.Float64(float64(time.Since(start))/float64(time.Millisecond)))
+	if err != nil {
+		attrs = append(attrs, PhaseErrorKey.String(err.Error()))
+	}
+	ct.span.AddEvent(name, trace.WithAttributes(attrs...))
+}
+
+func (ct *clientTrace) gotConn(info httptrace.GotConnInfo) {
+	ct.span.AddEvent("http.got_conn", trace.WithAttributes(ConnReusedKey.Bool(info.Reused)))
+}
+
+func (ct *clientTrace) dnsStart(httptrace.DNSStartInfo) {
+	ct.mu.Lock()
+	ct.dns = time.Now()
+	ct.mu.Unlock()
+}
+
+func (ct *clientTrace) dnsDone(info httptrace.DNSDoneInfo) {
+	ct.mu.Lock()
+	start := ct.dns
+	ct.mu.Unlock()
+	ct.event("http.dns", start, info.Err)
+}
+
+func (ct *clientTrace) connectStart(_, addr string) {
+	ct.mu.Lock()
+	ct.connects[addr] = time.Now()
+	ct.mu.Unlock()
+}
+
+func (ct *clientTrace) connectDone(network, addr string, err error) {
+	ct.mu.Lock()
+	start := ct.connects[addr]
+	delete(ct.connects, addr)
+	ct.mu.Unlock()
+	ct.event("http.connect", start, err, attribute.String("net.sock.peer.addr", addr))
+}
+
+func (ct *clientTrace) tlsStart() {
+	ct.mu.Lock()
+	ct.tls = time.Now()
+	ct.mu.Unlock()
+}
+
+func (ct *clientTrace) tlsDone(_ tls.ConnectionState, err error) {
+	ct.mu.Lock()
+	start := ct.tls
+	ct.mu.Unlock()
+	ct.event("http.tls", start, err)
+}
+
+func (ct *clientTrace) wroteRequest(info httptrace.WroteRequestInfo) {
+	ct.event("http.wrote_request", ct.start, info.Err)
+}
+
+// gotFirstResponseByte adds the time to first byte, from the start of the
+// request.
+func (ct *clientTrace) gotFirstResponseByte() {
+	ct.event("http.first_byte", ct.start, nil)
+}
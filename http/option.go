@@ -0,0 +1,128 @@
+package otelhttp
+
+import (
+	kgsotel "kgs/otel"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// config is a group of options for the client transport.
+type config struct {
+	TracerProvider    trace.TracerProvider
+	MeterProvider     metric.MeterProvider
+	Propagators       propagation.TextMapPropagator
+	SpanNameFormatter SpanNameFormatter
+	MetricAttributes  []attribute.KeyValue
+	DurationBuckets   []float64
+	SizeBuckets       []float64
+	ClientTrace       bool
+	BaggageFilter     BaggageFilter
+	Semconv           kgsotel.SemconvVersion
+}
+
+// SpanNameFormatter is used to set the span name of an outgoing request.
+type SpanNameFormatter func(r *http.Request) string
+
+// Option applies an option value for a config.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithTelemetry returns an Option to use the tracer provider, the meter
+// provider and the propagator of the telemetry pipeline instead of the
+// global ones.
+func WithTelemetry(t *kgsotel.Telemetry) Option {
+	return optionFunc(func(cfg *config) {
+		if t != nil {
+			cfg.TracerProvider = t.TracerProvider()
+			cfg.MeterProvider = t.MeterProvider()
+			cfg.Propagators = t.Propagator()
+		}
+	})
+}
+
+// WithTracerProvider returns an Option to use the tracer provider.
+// If none is specified, the global provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.TracerProvider = provider
+		}
+	})
+}
+
+// WithMeterProvider returns an Option to use the meter provider.
+// If none is specified, the global provider is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.MeterProvider = provider
+		}
+	})
+}
+
+// WithPropagators returns an Option to use the propagators to inject the
+// trace context into the outgoing requests. If none are specified, the
+// global ones are used, with the W3C trace context and baggage.
+func WithPropagators(propagators propagation.TextMapPropagator) Option {
+	return optionFunc(func(cfg *config) {
+		if propagators != nil {
+			cfg.Propagators = propagators
+		}
+	})
+}
+
+// WithSpanNameFormatter returns an Option to name the spans with f instead
+// of "HTTP <method>".
+func WithSpanNameFormatter(f SpanNameFormatter) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SpanNameFormatter = f
+	})
+}
+
+// WithMetricAttributes returns an Option to add attributes to every
+// recorded datapoint.
+func WithMetricAttributes(attrs ...attribute.KeyValue) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.MetricAttributes = attrs
+	})
+}
+
+// WithDurationBuckets returns an Option to use explicit bucket boundaries
+// (in milliseconds, or in seconds with the v1.26.0 conventions) for the
+// request duration histogram. If none are specified, the SDK default
+// boundaries are used, or the boundaries advised by the v1.26.0 conventions.
+func WithDurationBuckets(bounds ...float64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.DurationBuckets = bounds
+	})
+}
+
+// WithSizeBuckets returns an Option to use explicit bucket boundaries (in
+// bytes) for the request and response size histograms. If none are
+// specified, the SDK default boundaries are used.
+func WithSizeBuckets(bounds ...float64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SizeBuckets = bounds
+	})
+}
+
+// WithSemconv returns an Option to select the version of the semantic
+// conventions of the transport instead of the one of kgsotel.WithSemconv.
+func WithSemconv(version kgsotel.SemconvVersion) Option {
+	return optionFunc(func(c *config) {
+		if version.Valid() {
+			c.Semconv = version
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package otelhttp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/semconvutil"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// RetryAttemptKey is the number of an attempt, starting at 1.
+	RetryAttemptKey = attribute.Key("http.retry.attempt")
+	// RetryBackoffKey is the time waited before an attempt in milliseconds.
+	RetryBackoffKey = attribute.Key("http.retry.backoff_ms")
+	// RetryAttemptsKey is the number of attempts of a logical request.
+	RetryAttemptsKey = attribute.Key("http.retry.attempts")
+)
+
+// maxRetryBackoff bounds the default backoff.
+const maxRetryBackoff = 30 * time.Second
+
+// RetryPolicy decides when and how often the requests are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, 3 if zero.
+	MaxAttempts int
+	// Backoff returns the time to wait before the attempt, starting at 2.
+	// The backoff doubles from 100ms up to 30s if nil.
+	Backoff func(attempt int) time.Duration
+	// ShouldRetry reports whether the result of an attempt is retried. The
+	// transport errors, 429 and 5xx responses are retried if nil.
+	ShouldRetry func(resp *http.Response, err error) bool
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 3
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(attempt)
+	}
+	// The shift is bounded, as a larger one overflows the duration.
+	shift := min(max(attempt-2, 0), 10)
+	return min(100*time.Millisecond<<shift, maxRetryBackoff)
+}
+
+func (p RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if p.ShouldRetry != nil {
+		return p.ShouldRetry(resp, err)
+	}
+	return err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// attemptKey is the context key of the attempt of a request.
+type attemptKey struct{}
+
+type attempt struct {
+	number  int
+	backoff time.Duration
+}
+
+// ContextWithAttempt returns ctx marking the request as the attempt number n,
+// starting at 1, sent after waiting backoff. The Transport adds them to the
+// span of the request, so the retry libraries can annotate their attempts,
+// e.g. from a request hook.
+func ContextWithAttempt(ctx context.Context, n int, backoff time.Duration) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt{number: n, backoff: backoff})
+}
+
+// attemptAttrs returns the attributes of the attempt of ctx, if any.
+func attemptAttrs(ctx context.Context) []attribute.KeyValue {
+	a, ok := ctx.Value(attemptKey{}).(attempt)
+	if !ok {
+		return nil
+	}
+	return []attribute.KeyValue{
+		RetryAttemptKey.Int(a.number),
+		semconv.HTTPResendCount(a.number - 1),
+		RetryBackoffKey.Float64(float64(a.backoff) / float64(time.Millisecond)),
+	}
+}
+
+// RetryTransport is an http.RoundTripper retrying the requests. Each request
+// is traced by a logical span, parent of the client spans of its attempts,
+// so the retries do not look like one long call.
+type RetryTransport struct {
+	transport *Transport
+	policy    RetryPolicy
+}
+
+// NewRetryTransport returns a RetryTransport sending the attempts through a
+// Transport wrapping base, created with the options. The requests with a
+// body are only retried if it can be read again, see http.Request.GetBody.
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy, opts ...Option) *RetryTransport {
+	return &RetryTransport{transport: NewTransport(base, opts...), policy: policy}
+}
+
+// RoundTrip sends the attempts of the request until one is not retried or
+// the attempts are exhausted, and returns the result of the last one.
+func (t *RetryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+	if !killswitch.Disabled() {
+		spanName := "HTTP " + r.Method
+		if t.transport.config.SpanNameFormatter != nil {
+			spanName = t.transport.config.SpanNameFormatter(r)
+		}
+		var span trace.Span
+		ctx, span = t.transport.tracer.Start(ctx, spanName,
+			trace.WithAttributes(t.transport.config.Semconv.Convert(semconvutil.HTTPClientRequest(r))...))
+		defer span.End()
+	}
+	span := t.transport.config.Semconv.Span(trace.SpanFromContext(ctx))
+
+	var (
+		resp    *http.Response
+		err     error
+		backoff time.Duration
+	)
+	n := 1
+	for ; ; n++ {
+		req := r.WithContext(ContextWithAttempt(ctx, n, backoff))
+		if n > 1 && r.Body != nil && r.Body != http.NoBody {
+			body, bodyErr := r.GetBody()
+			if bodyErr != nil {
+				return nil, fmt.Errorf("retry: get body: %w", bodyErr)
+			}
+			req.Body = body
+		}
+		resp, err = t.transport.RoundTrip(req)
+		if n >= t.policy.maxAttempts() || !t.policy.shouldRetry(resp, err) {
+			break
+		}
+		if n == 1 && r.Body != nil && r.Body != http.NoBody && r.GetBody == nil {
+			break
+		}
+
+		backoff = t.policy.backoff(n + 1)
+		if resp != nil {
+			// Drain the body so the connection is reused.
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			span.SetAttributes(RetryAttemptsKey.Int(n))
+			span.SetStatus(codes.Error, ctx.Err().Error())
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	span.SetAttributes(RetryAttemptsKey.Int(n))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+	span.SetStatus(semconvutil.HTTPClientStatus(resp.StatusCode))
+	return resp, nil
+}
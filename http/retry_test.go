@@ -0,0 +1,106 @@
+package otelhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRetryTransport(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "order", string(body))
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	rec := oteltest.NewRecorder()
+	client := &http.Client{Transport: NewRetryTransport(nil, RetryPolicy{
+		Backoff: func(attempt int) time.Duration { return time.Duration(attempt) * time.Millisecond },
+	}, WithTracerProvider(rec.TracerProvider))}
+
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("order"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var (
+		logical  trace.SpanContext
+		attempts []attribute.Set
+	)
+	for _, span := range rec.Spans() {
+		if span.SpanKind() == trace.SpanKindInternal {
+			logical = span.SpanContext()
+			assert.Contains(t, span.Attributes(), RetryAttemptsKey.Int(3))
+			assert.Contains(t, span.Attributes(), semconv.HTTPStatusCode(http.StatusOK))
+			continue
+		}
+		attempts = append(attempts, attribute.NewSet(span.Attributes()...))
+	}
+	require.True(t, logical.IsValid())
+	require.Len(t, attempts, 3)
+	for _, span := range rec.Spans() {
+		if span.SpanKind() == trace.SpanKindClient {
+			assert.Equal(t, logical.SpanID(), span.Parent().SpanID())
+		}
+	}
+	for i, set := range attempts {
+		n, _ := set.Value(RetryAttemptKey)
+		backoff, _ := set.Value(RetryBackoffKey)
+		assert.Equal(t, int64(i+1), n.AsInt64())
+		if i == 0 {
+			assert.Zero(t, backoff.AsFloat64())
+		} else {
+			assert.Equal(t, float64(i+1), backoff.AsFloat64())
+		}
+	}
+}
+
+func TestRetryTransportExhausted(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	rec := oteltest.NewRecorder()
+	client := &http.Client{Transport: NewRetryTransport(nil, RetryPolicy{
+		MaxAttempts: 2,
+		Backoff:     func(int) time.Duration { return 0 },
+	}, WithTracerProvider(rec.TracerProvider))}
+
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	var p RetryPolicy
+	assert.Equal(t, 100*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 200*time.Millisecond, p.backoff(3))
+	assert.Equal(t, 25600*time.Millisecond, p.backoff(10))
+	// The large attempts neither overflow nor exceed the cap.
+	for _, attempt := range []int{11, 40, 100, 1 << 20} {
+		assert.Equal(t, maxRetryBackoff, p.backoff(attempt), attempt)
+	}
+}
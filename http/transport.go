@@ -0,0 +1,247 @@
+// Package otelhttp instruments the outgoing HTTP requests with client spans
+// and the RED metrics mirroring the ones of the server middlewares.
+package otelhttp
+
+import (
+	"io"
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/semconvutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name.
+const ScopeName = "kgs/otel/http"
+
+// Transport is an http.RoundTripper tracing and measuring the requests sent
+// by its base round tripper.
+type Transport struct {
+	base   http.RoundTripper
+	config *config
+
+	tracer      trace.Tracer
+	reqDuration metric.Float64Histogram
+	reqSize     metric.Int64Histogram
+	respSize    metric.Int64Histogram
+	activeReqs  metric.Int64UpDownCounter
+}
+
+// NewTransport returns a Transport wrapping base, or http.DefaultTransport
+// if base is nil:
+//
+//	client := &http.Client{Transport: otelhttp.NewTransport(nil)}
+func NewTransport(base http.RoundTripper, opts ...Option) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	cfg := &config{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otel.GetMeterProvider()
+	}
+	if cfg.Propagators == nil {
+		cfg.Propagators = defaultPropagator(otel.GetTextMapPropagator())
+	}
+
+	// The attributes are converted to the selected semantic conventions.
+	cfg.Semconv = cfg.Semconv.Resolve()
+
+	t := &Transport{
+		base:   base,
+		config: cfg,
+		tracer: cfg.TracerProvider.Tracer(ScopeName, trace.WithSchemaURL(cfg.Semconv.SchemaURL())),
+	}
+	meter := cfg.MeterProvider.Meter(ScopeName, metric.WithSchemaURL(cfg.Semconv.SchemaURL()))
+
+	var err error
+
+	// Measure the duration of the outgoing requests, until the response
+	// headers are received.
+	durationOpts := []metric.Float64HistogramOption{
+		metric.WithDescription("Measures the duration of outbound HTTP requests."),
+		metric.WithUnit(cfg.Semconv.DurationUnit()),
+	}
+	if buckets := cfg.Semconv.DurationBuckets(cfg.DurationBuckets); len(buckets) > 0 {
+		durationOpts = append(durationOpts, metric.WithExplicitBucketBoundaries(buckets...))
+	}
+	t.reqDuration, err = meter.Float64Histogram("http.client.request.duration", durationOpts...)
+	if err != nil {
+		otel.Handle(err)
+		if t.reqDuration == nil {
+			t.reqDuration = noop.Float64Histogram{}
+		}
+	}
+
+	// Measure the size of the request and response bodies.
+	var sizeOpts []metric.Int64HistogramOption
+	if len(cfg.SizeBuckets) > 0 {
+		sizeOpts = append(sizeOpts, metric.WithExplicitBucketBoundaries(cfg.SizeBuckets...))
+	}
+	t.reqSize, err = meter.Int64Histogram("http.client.request.body.size",
+		append([]metric.Int64HistogramOption{
+			metric.WithDescription("Measures the size of outbound HTTP request bodies."),
+			metric.WithUnit("By"),
+		}, sizeOpts...)...)
+	if err != nil {
+		otel.Handle(err)
+		if t.reqSize == nil {
+			t.reqSize = noop.Int64Histogram{}
+		}
+	}
+	t.respSize, err = meter.Int64Histogram("http.client.response.body.size",
+		append([]metric.Int64HistogramOption{
+			metric.WithDescription("Measures the size of inbound HTTP response bodies."),
+			metric.WithUnit("By"),
+		}, sizeOpts...)...)
+	if err != nil {
+		otel.Handle(err)
+		if t.respSize == nil {
+			t.respSize = noop.Int64Histogram{}
+		}
+	}
+
+	// Measure the number of requests in flight.
+	t.activeReqs, err = meter.Int64UpDownCounter("http.client.active_requests",
+		metric.WithDescription("Measures the number of outbound HTTP requests in flight."),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+		if t.activeReqs == nil {
+			t.activeReqs = noop.Int64UpDownCounter{}
+		}
+	}
+
+	return t
+}
+
+// RoundTrip sends the request in a client span, with the trace context
+// injected into its headers.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if killswitch.Disabled() {
+		return t.base.RoundTrip(r)
+	}
+
+	spanName := "HTTP " + r.Method
+	if t.config.SpanNameFormatter != nil {
+		spanName = t.config.SpanNameFormatter(r)
+	}
+	sc := t.config.Semconv
+	ctx, span := t.tracer.Start(r.Context(), spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(sc.Convert(semconvutil.HTTPClientRequest(r))...),
+		trace.WithAttributes(sc.Convert(attemptAttrs(r.Context()))...),
+	)
+	span = sc.Span(span)
+
+	if t.config.ClientTrace {
+		ctx = withClientTrace(ctx, span)
+	}
+
+	// The request must not be modified, inject into a copy.
+	r = r.Clone(ctx)
+	injectCtx := ctx
+	if t.config.BaggageFilter != nil {
+		injectCtx = filterBaggage(ctx, t.config.BaggageFilter)
+	}
+	t.config.Propagators.Inject(injectCtx, propagation.HeaderCarrier(r.Header))
+
+	metricAttrs := append(sc.Convert(semconvutil.HTTPClientRequestMetrics(r)), t.config.MetricAttributes...)
+	active := metric.WithAttributeSet(attribute.NewSet(metricAttrs...))
+	t.activeReqs.Add(ctx, 1, active)
+
+	var reqBody *countingBody
+	if r.Body != nil && r.Body != http.NoBody {
+		reqBody = &countingBody{ReadCloser: r.Body}
+		r.Body = reqBody
+	}
+
+	before := time.Now()
+	resp, err := t.base.RoundTrip(r)
+	elapsedTime := sc.Duration(time.Since(before))
+	t.activeReqs.Add(ctx, -1, active)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		t.reqDuration.Record(ctx, elapsedTime, metric.WithAttributes(metricAttrs...))
+		return resp, err
+	}
+
+	span.SetAttributes(semconvutil.HTTPClientResponse(resp)...)
+	span.SetStatus(semconvutil.HTTPClientStatus(resp.StatusCode))
+	metricAttrs = append(metricAttrs, sc.KeyValue(semconv.HTTPStatusCode(resp.StatusCode)))
+	opt := metric.WithAttributeSet(attribute.NewSet(metricAttrs...))
+	t.reqDuration.Record(ctx, elapsedTime, opt)
+	if reqBody != nil {
+		t.reqSize.Record(ctx, reqBody.n.Load(), opt)
+	} else {
+		t.reqSize.Record(ctx, 0, opt)
+	}
+
+	// The span ends and the response size is recorded once the body is
+	// read or closed. The body of an upgraded connection, e.g. a WebSocket,
+	// is an io.ReadWriteCloser left as is, so the span ends now.
+	if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+		t.respSize.Record(ctx, 0, opt)
+		span.End()
+		return resp, nil
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, done: func(n int64) {
+		t.respSize.Record(ctx, n, opt)
+		span.End()
+	}}
+	return resp, nil
+}
+
+// countingBody counts the bytes read from a body, and calls done once at
+// the end of the body or when it is closed.
+type countingBody struct {
+	io.ReadCloser
+	n    atomic.Int64
+	done func(n int64)
+	once sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *countingBody) finish() {
+	if b.done != nil {
+		b.once.Do(func() { b.done(b.n.Load()) })
+	}
+}
+
+// NewClient returns an http.Client whose transport is a Transport wrapping
+// http.DefaultTransport.
+func NewClient(opts ...Option) *http.Client {
+	return &http.Client{Transport: NewTransport(nil, opts...)}
+}
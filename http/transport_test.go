@@ -0,0 +1,160 @@
+package otelhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTransport(t *testing.T) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "created")
+	}))
+	defer srv.Close()
+
+	rec := oteltest.NewRecorder()
+	client := &http.Client{Transport: NewTransport(nil,
+		WithTracerProvider(rec.TracerProvider),
+		WithMeterProvider(rec.MeterProvider),
+		WithPropagators(propagation.TraceContext{}),
+		WithMetricAttributes(attribute.String("upstream", "orders")),
+	)}
+
+	resp, err := client.Post(srv.URL+"/orders", "text/plain", strings.NewReader("order"))
+	require.NoError(t, err)
+	assert.Empty(t, rec.Spans(), "the span ends with the body")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "created", string(body))
+
+	span, ok := rec.Span("HTTP POST")
+	require.True(t, ok)
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+	assert.Contains(t, span.Attributes(), semconv.HTTPStatusCode(http.StatusCreated))
+	assert.Equal(t, "00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01", traceparent)
+
+	attrs := []attribute.KeyValue{
+		semconv.HTTPMethod(http.MethodPost),
+		semconv.HTTPStatusCode(http.StatusCreated),
+		attribute.String("upstream", "orders"),
+	}
+	oteltest.AssertHistogramCount(t, rec, "http.client.request.duration", attrs, 1)
+	oteltest.AssertSumValue(t, rec, "http.client.active_requests", []attribute.KeyValue{semconv.HTTPMethod(http.MethodPost)}, int64(0))
+
+	sizes := map[string]int64{}
+	for _, name := range []string{"http.client.request.body.size", "http.client.response.body.size"} {
+		m, ok := rec.Metric(context.Background(), name)
+		require.True(t, ok, name)
+		dps := m.Data.(metricdata.Histogram[int64]).DataPoints
+		require.Len(t, dps, 1)
+		sizes[name] = dps[0].Sum
+	}
+	assert.Equal(t, map[string]int64{
+		"http.client.request.body.size":  5,
+		"http.client.response.body.size": 7,
+	}, sizes)
+}
+
+func TestTransportUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		rw.Flush()
+		// Echo the bytes of the upgraded connection.
+		line, _ := rw.ReadString('\n')
+		rw.WriteString(line)
+		rw.Flush()
+	}))
+	defer srv.Close()
+
+	rec := oteltest.NewRecorder()
+	client := &http.Client{Transport: NewTransport(nil, WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))}
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/echo", nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	// The span ends with the upgrade, and the body stays writable.
+	_, ok := rec.Span("HTTP GET")
+	assert.True(t, ok)
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	require.True(t, ok, "the upgraded body is writable")
+	_, err = io.WriteString(conn, "ping\n")
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping\n", string(buf))
+}
+
+func TestTransportError(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	client := &http.Client{Transport: NewTransport(nil, WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))}
+
+	_, err := client.Get("http://127.0.0.1:1/unreachable")
+	require.Error(t, err)
+
+	span, ok := rec.Span("HTTP GET")
+	require.True(t, ok)
+	assert.Equal(t, codes.Error, span.Status().Code)
+	oteltest.AssertHistogramCount(t, rec, "http.client.request.duration", []attribute.KeyValue{semconv.HTTPMethod(http.MethodGet)}, 1)
+}
+
+func TestWithClientTrace(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	get := func(opts ...Option) []string {
+		rec := oteltest.NewRecorder()
+		// A new transport does not reuse the connection of the previous request.
+		transport := NewTransport(srv.Client().Transport.(*http.Transport).Clone(), append(opts, WithTracerProvider(rec.TracerProvider))...)
+		resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		span, ok := rec.Span("HTTP GET")
+		require.True(t, ok)
+		var names []string
+		for _, e := range span.Events() {
+			names = append(names, e.Name)
+		}
+		return names
+	}
+
+	assert.Empty(t, get(), "off by default")
+
+	events := get(WithClientTrace(true))
+	for _, name := range []string{"http.connect", "http.tls", "http.got_conn", "http.wrote_request", "http.first_byte"} {
+		assert.Contains(t, events, name)
+	}
+}
@@ -0,0 +1,60 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithDeterministicIDs returns an Option to generate the trace and span IDs
+// from the seed, so the same sequence of spans always gets the same IDs.
+// It is meant for snapshot tests and documentation examples, the IDs must be
+// random in production.
+func WithDeterministicIDs(seed int64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.IDGenerator = NewDeterministicIDGenerator(seed)
+	})
+}
+
+// NewDeterministicIDGenerator returns an ID generator producing a
+// predictable sequence of IDs from the seed.
+func NewDeterministicIDGenerator(seed int64) sdktrace.IDGenerator {
+	return &deterministicIDGenerator{rand: rand.New(rand.NewSource(seed))}
+}
+
+type deterministicIDGenerator struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewIDs returns the next trace and span IDs of the sequence.
+func (g *deterministicIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var tid trace.TraceID
+	for !tid.IsValid() {
+		binary.BigEndian.PutUint64(tid[:8], g.rand.Uint64())
+		binary.BigEndian.PutUint64(tid[8:], g.rand.Uint64())
+	}
+	return tid, g.newSpanID()
+}
+
+// NewSpanID returns the next span ID of the sequence.
+func (g *deterministicIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.newSpanID()
+}
+
+func (g *deterministicIDGenerator) newSpanID() trace.SpanID {
+	var sid trace.SpanID
+	for !sid.IsValid() {
+		binary.BigEndian.PutUint64(sid[:], g.rand.Uint64())
+	}
+	return sid
+}
@@ -0,0 +1,39 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeterministicIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	a, b := NewDeterministicIDGenerator(42), NewDeterministicIDGenerator(42)
+
+	tidA, sidA := a.NewIDs(ctx)
+	tidB, sidB := b.NewIDs(ctx)
+	assert.Equal(t, tidA, tidB)
+	assert.Equal(t, sidA, sidB)
+	assert.Equal(t, a.NewSpanID(ctx, tidA), b.NewSpanID(ctx, tidB))
+
+	tidC, _ := NewDeterministicIDGenerator(7).NewIDs(ctx)
+	assert.NotEqual(t, tidA, tidC)
+}
+
+func TestWithDeterministicIDs(t *testing.T) {
+	ctx := context.Background()
+	traceID := func() string {
+		tel, err := New(ctx, Config{
+			ServiceName: "svc",
+			Options:     []Option{WithStdoutExporters(true), WithDisabledSignals(SignalMetrics), WithDeterministicIDs(1)},
+		})
+		require.NoError(t, err)
+		defer tel.Shutdown(ctx)
+
+		_, span := tel.TracerProvider().Tracer("test").Start(ctx, "op")
+		return span.SpanContext().TraceID().String()
+	}
+	assert.Equal(t, traceID(), traceID())
+}
@@ -0,0 +1,33 @@
+// Package defaults holds the middleware defaults loaded from the kgsotel
+// configuration file. It lets the root package hand settings to the gin and
+// gRPC middlewares without them importing each other.
+package defaults
+
+import "sync"
+
+// Middleware holds the defaults applied by the middlewares when they are created.
+type Middleware struct {
+	// GinExcludedPaths are the request paths the gin middleware does not trace.
+	GinExcludedPaths []string
+	// GRPCExcludedMethods are the full method names the gRPC middleware does not trace.
+	GRPCExcludedMethods []string
+}
+
+var (
+	mu         sync.RWMutex
+	middleware Middleware
+)
+
+// SetMiddleware replaces the middleware defaults.
+func SetMiddleware(m Middleware) {
+	mu.Lock()
+	defer mu.Unlock()
+	middleware = m
+}
+
+// GetMiddleware returns the current middleware defaults.
+func GetMiddleware() Middleware {
+	mu.RLock()
+	defer mu.RUnlock()
+	return middleware
+}
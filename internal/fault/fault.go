@@ -0,0 +1,91 @@
+// Package fault parses the fault injection requests shared by the gin and
+// gRPC fault injection middlewares.
+package fault
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DelayKey requests a delay, e.g. 200ms. It is read from the header
+	// X-Fault-Delay (x-fault-delay metadata) or the baggage member fault.delay.
+	DelayKey = "fault.delay"
+	// ErrorKey requests an error, e.g. 503 or UNAVAILABLE. It is read from the
+	// header X-Fault-Error (x-fault-error metadata) or the baggage member fault.error.
+	ErrorKey = "fault.error"
+
+	DelayHeader = "X-Fault-Delay"
+	ErrorHeader = "X-Fault-Error"
+
+	// MaxDelay bounds the injected delays.
+	MaxDelay = 30 * time.Second
+)
+
+// Request is a fault requested by a caller.
+type Request struct {
+	Delay time.Duration
+	// Error is the raw error requested, parsed by each middleware.
+	Error string
+}
+
+// FromContext returns the fault requested by the headers, read with get,
+// or else by the baggage of the context if trustBaggage is true. The baggage
+// crosses the services, so its faults are only honored for the authorized
+// callers.
+func FromContext(ctx context.Context, get func(key string) string, trustBaggage bool) (Request, bool) {
+	delay, errValue := get(DelayHeader), get(ErrorHeader)
+	if delay == "" && errValue == "" && trustBaggage {
+		b := baggage.FromContext(ctx)
+		delay, errValue = b.Member(DelayKey).Value(), b.Member(ErrorKey).Value()
+	}
+
+	var req Request
+	if d, err := time.ParseDuration(delay); err == nil && d > 0 {
+		req.Delay = min(d, MaxDelay)
+	}
+	req.Error = errValue
+	return req, req.Delay > 0 || req.Error != ""
+}
+
+// WithoutFault returns the context without the fault members in its baggage,
+// so the faults are not propagated to the downstream services.
+func WithoutFault(ctx context.Context) context.Context {
+	b := baggage.FromContext(ctx)
+	if b.Member(DelayKey).Key() == "" && b.Member(ErrorKey).Key() == "" {
+		return ctx
+	}
+	b = b.DeleteMember(DelayKey).DeleteMember(ErrorKey)
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// Wait waits for the delay of the request, or until the context is done.
+func (r Request) Wait(ctx context.Context) error {
+	if r.Delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(r.Delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Tag marks the span of the context as affected by the fault.
+func (r Request) Tag(ctx context.Context) {
+	attrs := []attribute.KeyValue{attribute.Bool("fault.injected", true)}
+	if r.Delay > 0 {
+		attrs = append(attrs, attribute.Int64("fault.delay_ms", r.Delay.Milliseconds()))
+	}
+	if r.Error != "" {
+		attrs = append(attrs, attribute.String(ErrorKey, r.Error))
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+}
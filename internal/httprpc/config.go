@@ -0,0 +1,145 @@
+// Package httprpc instruments the RPC frameworks running over HTTP, such as
+// connect-go and Twirp, with the spans and rpc.* metrics of otelgrpc. The
+// framework packages provide the Protocol reading the procedure and the
+// status of the RPCs.
+package httprpc
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	grpcCodes "google.golang.org/grpc/codes"
+)
+
+// StatusCodeKey is the numeric gRPC status code of the RPCs, the codes of
+// the frameworks being mapped to the ones of gRPC.
+const StatusCodeKey = attribute.Key("rpc.grpc.status_code")
+
+// Protocol reads the RPCs of a framework from the HTTP requests and
+// responses.
+type Protocol interface {
+	// System returns the rpc.system of the request.
+	System(r *http.Request) string
+	// Procedure returns the full method of the request, e.g.
+	// /acme.user.v1.UserService/GetUser.
+	Procedure(r *http.Request) string
+	// KeepErrorBody reports whether the status of the response of the
+	// system is read from its body.
+	KeepErrorBody(system string, status int) bool
+	// Status returns the code and the message of the RPC from the HTTP
+	// status, the headers and trailers and the start of the body kept.
+	Status(system string, status int, header http.Header, body []byte) (grpcCodes.Code, string)
+}
+
+// MaxErrorBody bounds the error body kept to read the status of an RPC.
+const MaxErrorBody = 4 << 10
+
+// Config is a group of options for the instrumentation, aliased by the
+// config of the framework packages.
+type Config struct {
+	TracerProvider   trace.TracerProvider
+	MeterProvider    metric.MeterProvider
+	Propagators      propagation.TextMapPropagator
+	SpanAttributes   []attribute.KeyValue
+	MetricAttributes []attribute.KeyValue
+	DurationBuckets  []float64
+	SizeBuckets      []float64
+
+	tracer trace.Tracer
+
+	rpcDuration     metric.Float64Histogram
+	rpcRequestSize  metric.Int64Histogram
+	rpcResponseSize metric.Int64Histogram
+}
+
+// Init sets the default providers and creates the tracer and the
+// instruments of the role, server or client, named like the ones of
+// otelgrpc.
+func (cfg *Config) Init(scopeName, role string) {
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otel.GetMeterProvider()
+	}
+	if cfg.Propagators == nil {
+		cfg.Propagators = otel.GetTextMapPropagator()
+	}
+	cfg.tracer = cfg.TracerProvider.Tracer(scopeName)
+	meter := cfg.MeterProvider.Meter(scopeName)
+
+	var err error
+
+	// Measure the duration of the RPCs.
+	durationOpts := []metric.Float64HistogramOption{
+		metric.WithDescription("Measures the duration of " + direction(role) + " RPC."),
+		metric.WithUnit("ms"),
+	}
+	if len(cfg.DurationBuckets) > 0 {
+		durationOpts = append(durationOpts, metric.WithExplicitBucketBoundaries(cfg.DurationBuckets...))
+	}
+	cfg.rpcDuration, err = meter.Float64Histogram("rpc."+role+".duration", durationOpts...)
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rpcDuration == nil {
+			cfg.rpcDuration = noop.Float64Histogram{}
+		}
+	}
+
+	// Measure the size of the request and response bodies.
+	var sizeOpts []metric.Int64HistogramOption
+	if len(cfg.SizeBuckets) > 0 {
+		sizeOpts = append(sizeOpts, metric.WithExplicitBucketBoundaries(cfg.SizeBuckets...))
+	}
+	cfg.rpcRequestSize, err = meter.Int64Histogram("rpc."+role+".request.size",
+		append([]metric.Int64HistogramOption{
+			metric.WithDescription("Measures size of RPC request messages (uncompressed)."),
+			metric.WithUnit("By"),
+		}, sizeOpts...)...)
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rpcRequestSize == nil {
+			cfg.rpcRequestSize = noop.Int64Histogram{}
+		}
+	}
+	cfg.rpcResponseSize, err = meter.Int64Histogram("rpc."+role+".response.size",
+		append([]metric.Int64HistogramOption{
+			metric.WithDescription("Measures size of RPC response messages (uncompressed)."),
+			metric.WithUnit("By"),
+		}, sizeOpts...)...)
+	if err != nil {
+		otel.Handle(err)
+		if cfg.rpcResponseSize == nil {
+			cfg.rpcResponseSize = noop.Int64Histogram{}
+		}
+	}
+}
+
+func direction(role string) string {
+	if role == "server" {
+		return "inbound"
+	}
+	return "outbound"
+}
+
+// ServerStatus returns the span status of a server RPC, an error only for
+// the codes of server errors, like otelgrpc.
+func ServerStatus(code grpcCodes.Code, msg string) (codes.Code, string) {
+	switch code {
+	case grpcCodes.Unknown,
+		grpcCodes.DeadlineExceeded,
+		grpcCodes.Unimplemented,
+		grpcCodes.Internal,
+		grpcCodes.Unavailable,
+		grpcCodes.DataLoss:
+		return codes.Error, msg
+	default:
+		return codes.Unset, ""
+	}
+}
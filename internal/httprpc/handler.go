@@ -0,0 +1,135 @@
+package httprpc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"kgs/otel/internal"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rpcAttributes returns the name of the span and the attributes of the RPC
+// of the request.
+func rpcAttributes(p Protocol, r *http.Request) (string, string, []attribute.KeyValue) {
+	system := p.System(r)
+	name, attrs := internal.ParseFullMethod(p.Procedure(r))
+	return system, name, append(attrs, semconv.RPCSystemKey.String(system))
+}
+
+// Handler wraps the handler of a service so each RPC it serves is a server
+// span, measured by the rpc.server.* metrics. The config must be
+// initialized for the server role.
+func Handler(h http.Handler, p Protocol, cfg *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		system, name, attrs := rpcAttributes(p, r)
+		ctx := cfg.Propagators.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := cfg.tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(attrs...),
+			trace.WithAttributes(cfg.SpanAttributes...),
+		)
+		defer span.End()
+
+		body := &countingBody{ReadCloser: r.Body}
+		r = r.WithContext(ctx)
+		if r.Body != nil {
+			r.Body = body
+		}
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK, keep: func(status int) bool {
+			return p.KeepErrorBody(system, status)
+		}}
+		before := time.Now()
+		h.ServeHTTP(rw, r)
+		elapsedTime := float64(time.Since(before)) / float64(time.Millisecond)
+
+		code, msg := p.Status(system, rw.status, w.Header(), rw.errBody.Bytes())
+		statusAttr := StatusCodeKey.Int(int(code))
+		span.SetAttributes(statusAttr)
+		span.SetStatus(ServerStatus(code, msg))
+
+		metricAttrs := make([]attribute.KeyValue, 0, len(attrs)+len(cfg.MetricAttributes)+1)
+		metricAttrs = append(append(append(metricAttrs, attrs...), cfg.MetricAttributes...), statusAttr)
+		opt := metric.WithAttributeSet(attribute.NewSet(metricAttrs...))
+		cfg.rpcDuration.Record(ctx, elapsedTime, opt)
+		cfg.rpcRequestSize.Record(ctx, body.n.Load(), opt)
+		cfg.rpcResponseSize.Record(ctx, rw.n, opt)
+	})
+}
+
+// responseWriter records the status and the size of the response, and the
+// error body kept by the protocol.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	n       int64
+	keep    func(status int) bool
+	errBody bytes.Buffer
+	wrote   bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wrote {
+		w.status, w.wrote = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wrote = true
+	if w.errBody.Len() < MaxErrorBody && w.keep(w.status) {
+		w.errBody.Write(p[:min(len(p), MaxErrorBody-w.errBody.Len())])
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Flush flushes the streamed responses.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the writer for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// countingBody counts the bytes read from a body, and calls done once at
+// the end of the body or when it is closed.
+type countingBody struct {
+	io.ReadCloser
+	n    atomic.Int64
+	done func()
+	once atomic.Bool
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *countingBody) finish() {
+	if b.done != nil && b.once.CompareAndSwap(false, true) {
+		b.done()
+	}
+}
@@ -0,0 +1,101 @@
+package httprpc
+
+import (
+	kgsotel "kgs/otel"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option applies an option value for a Config. The framework packages alias
+// it and forward the options, so they are written once.
+type Option func(*Config)
+
+// WithTelemetry returns an Option to use the tracer provider, the meter
+// provider and the propagator of the telemetry pipeline instead of the
+// global ones.
+func WithTelemetry(t *kgsotel.Telemetry) Option {
+	return func(cfg *Config) {
+		if t != nil {
+			cfg.TracerProvider = t.TracerProvider()
+			cfg.MeterProvider = t.MeterProvider()
+			cfg.Propagators = t.Propagator()
+		}
+	}
+}
+
+// WithTracerProvider returns an Option to use the tracer provider.
+// If none is specified, the global provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(cfg *Config) {
+		if provider != nil {
+			cfg.TracerProvider = provider
+		}
+	}
+}
+
+// WithMeterProvider returns an Option to use the meter provider.
+// If none is specified, the global provider is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(cfg *Config) {
+		if provider != nil {
+			cfg.MeterProvider = provider
+		}
+	}
+}
+
+// WithPropagators returns an Option to use the propagators.
+// If none are specified, the global ones are used.
+func WithPropagators(propagators propagation.TextMapPropagator) Option {
+	return func(cfg *Config) {
+		if propagators != nil {
+			cfg.Propagators = propagators
+		}
+	}
+}
+
+// WithSpanAttributes returns an Option to add attributes to the spans.
+func WithSpanAttributes(attrs ...attribute.KeyValue) Option {
+	return func(cfg *Config) {
+		cfg.SpanAttributes = attrs
+	}
+}
+
+// WithMetricAttributes returns an Option to add attributes to every
+// recorded datapoint.
+func WithMetricAttributes(attrs ...attribute.KeyValue) Option {
+	return func(cfg *Config) {
+		cfg.MetricAttributes = attrs
+	}
+}
+
+// WithDurationBuckets returns an Option to use explicit bucket boundaries
+// (in milliseconds) for the RPC duration histogram. If none are specified,
+// the SDK default boundaries are used.
+func WithDurationBuckets(bounds ...float64) Option {
+	return func(cfg *Config) {
+		cfg.DurationBuckets = bounds
+	}
+}
+
+// WithSizeBuckets returns an Option to use explicit bucket boundaries (in
+// bytes) for the request and response size histograms. If none are
+// specified, the SDK default boundaries are used.
+func WithSizeBuckets(bounds ...float64) Option {
+	return func(cfg *Config) {
+		cfg.SizeBuckets = bounds
+	}
+}
+
+// NewConfig returns the config of the options, with the instruments of the
+// role, server or client, named like the ones of otelgrpc.
+func NewConfig(scopeName, role string, opts []Option) *Config {
+	cfg := &Config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cfg.Init(scopeName, role)
+	return cfg
+}
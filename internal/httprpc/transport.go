@@ -0,0 +1,133 @@
+package httprpc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"kgs/otel/internal/killswitch"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	grpcCodes "google.golang.org/grpc/codes"
+)
+
+// Transport is an http.RoundTripper making each RPC of a client a client
+// span, measured by the rpc.client.* metrics.
+type Transport struct {
+	base     http.RoundTripper
+	protocol Protocol
+	config   *Config
+}
+
+// NewTransport returns a Transport wrapping base, or http.DefaultTransport
+// if base is nil. The config must be initialized for the client role.
+func NewTransport(base http.RoundTripper, p Protocol, cfg *Config) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base, protocol: p, config: cfg}
+}
+
+// RoundTrip sends the RPC in a client span, with the trace context injected
+// into its headers. The span ends at the end of the response body, once the
+// trailers are received.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if killswitch.Disabled() {
+		return t.base.RoundTrip(r)
+	}
+	cfg := t.config
+	system, name, attrs := rpcAttributes(t.protocol, r)
+	ctx, span := cfg.tracer.Start(r.Context(), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(cfg.SpanAttributes...),
+	)
+
+	// The request must not be modified, inject into a copy.
+	r = r.Clone(ctx)
+	cfg.Propagators.Inject(ctx, propagation.HeaderCarrier(r.Header))
+	reqBody := &countingBody{ReadCloser: r.Body}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = reqBody
+	}
+
+	before := time.Now()
+	record := func(code grpcCodes.Code, msg string, respSize int64) {
+		elapsedTime := float64(time.Since(before)) / float64(time.Millisecond)
+		statusAttr := StatusCodeKey.Int(int(code))
+		span.SetAttributes(statusAttr)
+		if code != grpcCodes.OK {
+			span.SetStatus(codes.Error, msg)
+		}
+		span.End()
+
+		metricAttrs := make([]attribute.KeyValue, 0, len(attrs)+len(cfg.MetricAttributes)+1)
+		metricAttrs = append(append(append(metricAttrs, attrs...), cfg.MetricAttributes...), statusAttr)
+		opt := metric.WithAttributeSet(attribute.NewSet(metricAttrs...))
+		cfg.rpcDuration.Record(ctx, elapsedTime, opt)
+		cfg.rpcRequestSize.Record(ctx, reqBody.n.Load(), opt)
+		cfg.rpcResponseSize.Record(ctx, respSize, opt)
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		code := grpcCodes.Unavailable
+		if ctx.Err() != nil {
+			code = grpcCodes.Canceled
+		}
+		record(code, err.Error(), 0)
+		return resp, err
+	}
+
+	if resp.Body == nil || resp.Body == http.NoBody {
+		code, msg := t.protocol.Status(system, resp.StatusCode, mergeTrailer(resp), nil)
+		record(code, msg, 0)
+		return resp, nil
+	}
+	body := &errorBody{countingBody: countingBody{ReadCloser: resp.Body}}
+	body.keep = t.protocol.KeepErrorBody(system, resp.StatusCode)
+	body.done = func() {
+		code, msg := t.protocol.Status(system, resp.StatusCode, mergeTrailer(resp), body.buf.Bytes())
+		record(code, msg, body.n.Load())
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// mergeTrailer returns the headers of the response with its trailers, read
+// at the end of the body.
+func mergeTrailer(resp *http.Response) http.Header {
+	if len(resp.Trailer) == 0 {
+		return resp.Header
+	}
+	h := resp.Header.Clone()
+	for k, v := range resp.Trailer {
+		h[k] = v
+	}
+	return h
+}
+
+// errorBody keeps the start of the error body of the response.
+type errorBody struct {
+	countingBody
+	keep bool
+	buf  bytes.Buffer
+}
+
+func (b *errorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	if b.keep && b.buf.Len() < MaxErrorBody {
+		b.buf.Write(p[:min(n, MaxErrorBody-b.buf.Len())])
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
@@ -0,0 +1,36 @@
+// Package killswitch holds the global switch turning the whole kgsotel
+// package into no-op pass-throughs. It is initialized from the
+// KGSOTEL_DISABLED environment variable and shared by the middlewares.
+package killswitch
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// EnvDisabled is the environment variable disabling all the telemetry.
+const EnvDisabled = "KGSOTEL_DISABLED"
+
+var disabled atomic.Bool
+
+func init() {
+	disabled.Store(DisabledByEnv())
+}
+
+// DisabledByEnv reports whether the environment disables the telemetry,
+// regardless of SetDisabled.
+func DisabledByEnv() bool {
+	v, _ := strconv.ParseBool(os.Getenv(EnvDisabled))
+	return v
+}
+
+// Disabled reports whether the telemetry is disabled.
+func Disabled() bool {
+	return disabled.Load()
+}
+
+// SetDisabled turns the telemetry off or back on.
+func SetDisabled(v bool) {
+	disabled.Store(v)
+}
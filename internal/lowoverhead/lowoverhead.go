@@ -0,0 +1,37 @@
+// Package lowoverhead holds the global switch of the low-overhead mode, in
+// which the middlewares and log helpers trade some detail for speed. It is
+// initialized from the KGSOTEL_LOW_OVERHEAD environment variable and shared
+// by the middlewares.
+package lowoverhead
+
+import (
+	"os"
+	"strconv"
+	"sync/atomic"
+)
+
+// EnvLowOverhead is the environment variable enabling the low-overhead mode.
+const EnvLowOverhead = "KGSOTEL_LOW_OVERHEAD"
+
+var enabled atomic.Bool
+
+func init() {
+	enabled.Store(EnabledByEnv())
+}
+
+// EnabledByEnv reports whether the environment enables the low-overhead
+// mode, regardless of SetEnabled.
+func EnabledByEnv() bool {
+	v, _ := strconv.ParseBool(os.Getenv(EnvLowOverhead))
+	return v
+}
+
+// Enabled reports whether the low-overhead mode is enabled.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// SetEnabled turns the low-overhead mode on or off.
+func SetEnabled(v bool) {
+	enabled.Store(v)
+}
@@ -0,0 +1,165 @@
+// Package otlpconv converts SDK log records to their OTLP protobuf form,
+// so they can be persisted and re-sent without the OTLP log exporter.
+package otlpconv
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logpb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// ResourceLogs converts the records into ResourceLogs grouped by
+// instrumentation scope. The records must share the same resource, which is
+// the case for the records exported by a single LoggerProvider.
+func ResourceLogs(records []sdklog.Record) []*logpb.ResourceLogs {
+	if len(records) == 0 {
+		return nil
+	}
+
+	res := records[0].Resource()
+	rl := &logpb.ResourceLogs{
+		Resource:  &resourcepb.Resource{Attributes: Attributes(res.Attributes())},
+		SchemaUrl: res.SchemaURL(),
+	}
+
+	scopes := make(map[instrumentation.Scope]*logpb.ScopeLogs)
+	for i := range records {
+		r := &records[i]
+		scope := r.InstrumentationScope()
+		sl, ok := scopes[scope]
+		if !ok {
+			sl = &logpb.ScopeLogs{
+				Scope: &commonpb.InstrumentationScope{
+					Name:    scope.Name,
+					Version: scope.Version,
+				},
+				SchemaUrl: scope.SchemaURL,
+			}
+			scopes[scope] = sl
+			rl.ScopeLogs = append(rl.ScopeLogs, sl)
+		}
+		sl.LogRecords = append(sl.LogRecords, LogRecord(r))
+	}
+
+	return []*logpb.ResourceLogs{rl}
+}
+
+// LogRecord converts a single record.
+func LogRecord(r *sdklog.Record) *logpb.LogRecord {
+	lr := &logpb.LogRecord{
+		TimeUnixNano:           uint64(r.Timestamp().UnixNano()),
+		ObservedTimeUnixNano:   uint64(r.ObservedTimestamp().UnixNano()),
+		SeverityNumber:         logpb.SeverityNumber(r.Severity()),
+		SeverityText:           r.SeverityText(),
+		Body:                   LogValue(r.Body()),
+		DroppedAttributesCount: uint32(r.DroppedAttributes()),
+		Flags:                  uint32(r.TraceFlags()),
+	}
+	if r.Timestamp().IsZero() {
+		lr.TimeUnixNano = 0
+	}
+	if r.ObservedTimestamp().IsZero() {
+		lr.ObservedTimeUnixNano = 0
+	}
+	if tid := r.TraceID(); tid.IsValid() {
+		lr.TraceId = tid[:]
+	}
+	if sid := r.SpanID(); sid.IsValid() {
+		lr.SpanId = sid[:]
+	}
+
+	lr.Attributes = make([]*commonpb.KeyValue, 0, r.AttributesLen())
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		lr.Attributes = append(lr.Attributes, &commonpb.KeyValue{Key: kv.Key, Value: LogValue(kv.Value)})
+		return true
+	})
+
+	return lr
+}
+
+// LogValue converts a log value.
+func LogValue(v log.Value) *commonpb.AnyValue {
+	switch v.Kind() {
+	case log.KindBool:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case log.KindInt64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case log.KindFloat64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case log.KindString:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	case log.KindBytes:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v.AsBytes()}}
+	case log.KindSlice:
+		values := make([]*commonpb.AnyValue, 0, len(v.AsSlice()))
+		for _, e := range v.AsSlice() {
+			values = append(values, LogValue(e))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case log.KindMap:
+		kvs := make([]*commonpb.KeyValue, 0, len(v.AsMap()))
+		for _, kv := range v.AsMap() {
+			kvs = append(kvs, &commonpb.KeyValue{Key: kv.Key, Value: LogValue(kv.Value)})
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: kvs}}}
+	default:
+		return nil
+	}
+}
+
+// Attributes converts attribute key-values.
+func Attributes(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: AttributeValue(kv.Value)})
+	}
+	return out
+}
+
+// AttributeValue converts an attribute value.
+func AttributeValue(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.STRING:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	case attribute.BOOLSLICE:
+		var values []*commonpb.AnyValue
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: b}})
+		}
+		return arrayValue(values)
+	case attribute.INT64SLICE:
+		var values []*commonpb.AnyValue
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: i}})
+		}
+		return arrayValue(values)
+	case attribute.FLOAT64SLICE:
+		var values []*commonpb.AnyValue
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: f}})
+		}
+		return arrayValue(values)
+	case attribute.STRINGSLICE:
+		var values []*commonpb.AnyValue
+		for _, s := range v.AsStringSlice() {
+			values = append(values, &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}})
+		}
+		return arrayValue(values)
+	default:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "INVALID"}}
+	}
+}
+
+func arrayValue(values []*commonpb.AnyValue) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
@@ -0,0 +1,152 @@
+// Package overhead measures the cost of the instrumentation itself: the time
+// spent and the heap allocations made by the middlewares for each request,
+// reported as kgsotel.overhead.* metrics. The measurement is switched on by
+// the KGSOTEL_MEASURE_OVERHEAD environment variable and shared by the
+// middlewares.
+package overhead
+
+import (
+	"context"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelmetric "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// EnvMeasureOverhead is the environment variable enabling the measurement.
+const EnvMeasureOverhead = "KGSOTEL_MEASURE_OVERHEAD"
+
+// ComponentKey is the attribute naming the measured middleware.
+const ComponentKey = attribute.Key("kgsotel.component")
+
+const (
+	allocObjects = "/gc/heap/allocs:objects"
+	allocBytes   = "/gc/heap/allocs:bytes"
+)
+
+var enabled atomic.Bool
+
+func init() {
+	enabled.Store(EnabledByEnv())
+}
+
+// EnabledByEnv reports whether the environment enables the measurement,
+// regardless of SetEnabled.
+func EnabledByEnv() bool {
+	v, _ := strconv.ParseBool(os.Getenv(EnvMeasureOverhead))
+	return v
+}
+
+// Enabled reports whether the overhead is measured.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// SetEnabled turns the measurement on or off.
+func SetEnabled(v bool) {
+	enabled.Store(v)
+}
+
+// samplePool reuses the runtime/metrics samples, so reading them does not
+// allocate in the measured code.
+var samplePool = sync.Pool{New: func() any {
+	return &[2]metrics.Sample{{Name: allocObjects}, {Name: allocBytes}}
+}}
+
+// readAllocs returns the number and the size of the heap allocations of the
+// process so far.
+func readAllocs() (objects, bytes uint64) {
+	s := samplePool.Get().(*[2]metrics.Sample)
+	metrics.Read(s[:])
+	objects, bytes = s[0].Value.Uint64(), s[1].Value.Uint64()
+	samplePool.Put(s)
+	return objects, bytes
+}
+
+// Segment is a stretch of instrumentation code being measured.
+type Segment struct {
+	start   time.Time
+	objects uint64
+	bytes   uint64
+}
+
+// Begin starts measuring a segment.
+func Begin() Segment {
+	objects, bytes := readAllocs()
+	return Segment{start: time.Now(), objects: objects, bytes: bytes}
+}
+
+// Cost accumulates the segments of a request. It is safe for concurrent use,
+// e.g. by the send and receive sides of a stream.
+type Cost struct {
+	nanos   atomic.Int64
+	objects atomic.Int64
+	bytes   atomic.Int64
+}
+
+// End adds the time spent and the allocations made since s began. The
+// allocations are counted for the whole process, so they are approximate
+// when other goroutines allocate at the same time.
+func (c *Cost) End(s Segment) {
+	elapsed := time.Since(s.start)
+	objects, bytes := readAllocs()
+	c.nanos.Add(int64(elapsed))
+	c.objects.Add(int64(objects - s.objects))
+	c.bytes.Add(int64(bytes - s.bytes))
+}
+
+// Recorder records the cost of the requests of a middleware.
+type Recorder struct {
+	duration otelmetric.Float64Histogram
+	objects  otelmetric.Int64Histogram
+	bytes    otelmetric.Int64Histogram
+	opt      otelmetric.RecordOption
+}
+
+// NewRecorder returns a Recorder of the cost of the component, or nil if the
+// measurement is disabled.
+func NewRecorder(mp otelmetric.MeterProvider, component string) *Recorder {
+	if !Enabled() {
+		return nil
+	}
+	meter := mp.Meter("kgs/otel/overhead")
+	r := &Recorder{opt: otelmetric.WithAttributeSet(attribute.NewSet(ComponentKey.String(component)))}
+
+	var err error
+	r.duration, err = meter.Float64Histogram("kgsotel.overhead.duration",
+		otelmetric.WithDescription("Measures the time spent in the instrumentation per request."),
+		otelmetric.WithUnit("ms"))
+	if err != nil {
+		otel.Handle(err)
+		r.duration = noop.Float64Histogram{}
+	}
+	r.objects, err = meter.Int64Histogram("kgsotel.overhead.allocations",
+		otelmetric.WithDescription("Measures the heap allocations made by the instrumentation per request."),
+		otelmetric.WithUnit("{allocation}"))
+	if err != nil {
+		otel.Handle(err)
+		r.objects = noop.Int64Histogram{}
+	}
+	r.bytes, err = meter.Int64Histogram("kgsotel.overhead.allocated_bytes",
+		otelmetric.WithDescription("Measures the heap bytes allocated by the instrumentation per request."),
+		otelmetric.WithUnit("By"))
+	if err != nil {
+		otel.Handle(err)
+		r.bytes = noop.Int64Histogram{}
+	}
+	return r
+}
+
+// Record records the cost of a request.
+func (r *Recorder) Record(ctx context.Context, c *Cost) {
+	r.duration.Record(ctx, float64(c.nanos.Load())/float64(time.Millisecond), r.opt)
+	r.objects.Record(ctx, c.objects.Load(), r.opt)
+	r.bytes.Record(ctx, c.bytes.Load(), r.opt)
+}
@@ -103,6 +103,12 @@ func HTTPServerRequestMetrics(server string, req *http.Request) []attribute.KeyV
 	return hc.ServerRequestMetrics(server, req)
 }
 
+// AppendHTTPServerRequestMetrics appends the attributes of
+// HTTPServerRequestMetrics to dst, for the callers reusing their slices.
+func AppendHTTPServerRequestMetrics(dst []attribute.KeyValue, server string, req *http.Request) []attribute.KeyValue {
+	return hc.AppendServerRequestMetrics(dst, server, req)
+}
+
 // HTTPServerStatus returns a span status code and message for an HTTP status code
 // value returned by a server. Status codes in the 400-499 range are not
 // returned as errors.
@@ -451,7 +457,13 @@ func (c *httpConv) ServerRequestMetrics(server string, req *http.Request) []attr
 	net.protocol.version    string
 	*/
 
-	n := 3 // Method, scheme, and host name.
+	// Method, scheme, host name and port, protocol name and version.
+	return c.AppendServerRequestMetrics(make([]attribute.KeyValue, 0, 6), server, req)
+}
+
+// AppendServerRequestMetrics appends the attributes of ServerRequestMetrics
+// to attrs.
+func (c *httpConv) AppendServerRequestMetrics(attrs []attribute.KeyValue, server string, req *http.Request) []attribute.KeyValue {
 	var host string
 	var p int
 	if server == "" {
@@ -464,18 +476,7 @@ func (c *httpConv) ServerRequestMetrics(server string, req *http.Request) []attr
 		}
 	}
 	hostPort := requiredHTTPPort(req.TLS != nil, p)
-	if hostPort > 0 {
-		n++
-	}
 	protoName, protoVersion := netProtocol(req.Proto)
-	if protoName != "" {
-		n++
-	}
-	if protoVersion != "" {
-		n++
-	}
-
-	attrs := make([]attribute.KeyValue, 0, n)
 
 	attrs = append(attrs, c.methodMetric(req.Method))
 	attrs = append(attrs, c.scheme(req.TLS != nil))
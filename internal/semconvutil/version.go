@@ -0,0 +1,198 @@
+package semconvutil
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Version is a version of the semantic conventions emitted by the
+// middlewares. The attributes are built by the v1.20.0 conventions of this
+// package and converted to the selected version.
+type Version string
+
+const (
+	// V1_20 is the version of the conventions of this package.
+	V1_20 Version = "1.20.0"
+	// V1_26 has the stable HTTP conventions, e.g. http.request.method and
+	// url.scheme instead of http.method and http.scheme.
+	V1_26 Version = "1.26.0"
+)
+
+var defaultVersion atomic.Value
+
+// SetDefault sets the version of the middlewares not selecting one.
+func SetDefault(v Version) {
+	defaultVersion.Store(v)
+}
+
+// Default returns the version of the middlewares not selecting one.
+func Default() Version {
+	if v, ok := defaultVersion.Load().(Version); ok && v != "" {
+		return v
+	}
+	return V1_20
+}
+
+// Resolve returns v, or the default version if v is empty.
+func (v Version) Resolve() Version {
+	if v == "" {
+		return Default()
+	}
+	return v
+}
+
+// Valid reports whether the version is supported.
+func (v Version) Valid() bool {
+	return v == V1_20 || v == V1_26
+}
+
+// SchemaURL returns the schema URL of the version.
+func (v Version) SchemaURL() string {
+	if v == V1_26 {
+		return semconv126.SchemaURL
+	}
+	return semconv.SchemaURL
+}
+
+// durationBuckets126 are the bucket boundaries advised by the v1.26.0
+// conventions for the HTTP durations in seconds.
+var durationBuckets126 = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// DurationUnit returns the unit of the HTTP duration histograms: s with the
+// v1.26.0 conventions, ms before.
+func (v Version) DurationUnit() string {
+	if v == V1_26 {
+		return "s"
+	}
+	return "ms"
+}
+
+// Duration returns d in the unit of the HTTP duration histograms.
+func (v Version) Duration(d time.Duration) float64 {
+	if v == V1_26 {
+		return d.Seconds()
+	}
+	// Use floating point division here for higher precision (instead of Millisecond method).
+	return float64(d) / float64(time.Millisecond)
+}
+
+// DurationBuckets returns the bucket boundaries of the HTTP duration
+// histograms: bounds if set, else the boundaries advised by the v1.26.0
+// conventions, or nil for the SDK defaults before.
+func (v Version) DurationBuckets(bounds []float64) []float64 {
+	if len(bounds) > 0 || v != V1_26 {
+		return bounds
+	}
+	return durationBuckets126
+}
+
+// renames126 are the keys renamed from v1.20.0 to v1.26.0 without a change
+// of their values.
+var renames126 = map[attribute.Key]attribute.Key{
+	semconv.HTTPMethodKey:                semconv126.HTTPRequestMethodKey,
+	semconv.HTTPStatusCodeKey:            semconv126.HTTPResponseStatusCodeKey,
+	semconv.HTTPSchemeKey:                semconv126.URLSchemeKey,
+	semconv.HTTPURLKey:                   semconv126.URLFullKey,
+	semconv.HTTPClientIPKey:              semconv126.ClientAddressKey,
+	semconv.HTTPRequestContentLengthKey:  semconv126.HTTPRequestBodySizeKey,
+	semconv.HTTPResponseContentLengthKey: semconv126.HTTPResponseBodySizeKey,
+	semconv.HTTPResendCountKey:           semconv126.HTTPRequestResendCountKey,
+	semconv.NetHostNameKey:               semconv126.ServerAddressKey,
+	semconv.NetHostPortKey:               semconv126.ServerPortKey,
+	semconv.NetPeerNameKey:               semconv126.ServerAddressKey,
+	semconv.NetPeerPortKey:               semconv126.ServerPortKey,
+	semconv.NetSockPeerAddrKey:           semconv126.NetworkPeerAddressKey,
+	semconv.NetSockPeerPortKey:           semconv126.NetworkPeerPortKey,
+	semconv.NetSockHostAddrKey:           semconv126.NetworkLocalAddressKey,
+	semconv.NetSockHostPortKey:           semconv126.NetworkLocalPortKey,
+	semconv.NetProtocolNameKey:           semconv126.NetworkProtocolNameKey,
+	semconv.NetProtocolVersionKey:        semconv126.NetworkProtocolVersionKey,
+}
+
+// Convert returns the attributes of the v1.20.0 conventions in attrs
+// converted to v, and the other attributes unchanged. attrs is returned
+// as is for V1_20.
+func (v Version) Convert(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if v != V1_26 || len(attrs) == 0 {
+		return attrs
+	}
+	out := make([]attribute.KeyValue, 0, len(attrs)+1)
+	for _, kv := range attrs {
+		out = appendConverted126(out, kv)
+	}
+	return out
+}
+
+// KeyValue returns kv converted to v. The attributes with no equivalent in v
+// are returned unchanged.
+func (v Version) KeyValue(kv attribute.KeyValue) attribute.KeyValue {
+	if v != V1_26 {
+		return kv
+	}
+	if converted := appendConverted126(nil, kv); len(converted) > 0 {
+		return converted[0]
+	}
+	return kv
+}
+
+// appendConverted126 appends the v1.26.0 equivalent of kv to out.
+func appendConverted126(out []attribute.KeyValue, kv attribute.KeyValue) []attribute.KeyValue {
+	if key, ok := renames126[kv.Key]; ok {
+		return append(out, attribute.KeyValue{Key: key, Value: kv.Value})
+	}
+	switch kv.Key {
+	case semconv.HTTPTargetKey:
+		path, query, found := strings.Cut(kv.Value.AsString(), "?")
+		out = append(out, semconv126.URLPath(path))
+		if found {
+			out = append(out, semconv126.URLQuery(query))
+		}
+		return out
+	case semconv.NetTransportKey:
+		switch kv.Value.AsString() {
+		case "ip_tcp":
+			return append(out, semconv126.NetworkTransportTCP)
+		case "ip_udp":
+			return append(out, semconv126.NetworkTransportUDP)
+		case "pipe":
+			return append(out, semconv126.NetworkTransportPipe)
+		case "unix":
+			return append(out, semconv126.NetworkTransportUnix)
+		}
+		// The in-process and other transports have no equivalent.
+		return out
+	case semconv.NetSockFamilyKey:
+		switch kv.Value.AsString() {
+		case "inet":
+			return append(out, semconv126.NetworkTypeIpv4)
+		case "inet6":
+			return append(out, semconv126.NetworkTypeIpv6)
+		}
+		return out
+	}
+	return append(out, kv)
+}
+
+// Span returns span converting the attributes set on it to v.
+func (v Version) Span(span trace.Span) trace.Span {
+	if v != V1_26 {
+		return span
+	}
+	return convertingSpan{Span: span, version: v}
+}
+
+// convertingSpan converts the attributes set on the span.
+type convertingSpan struct {
+	trace.Span
+	version Version
+}
+
+func (s convertingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.Span.SetAttributes(s.version.Convert(kv)...)
+}
@@ -0,0 +1,59 @@
+package semconvutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestVersionConvert(t *testing.T) {
+	attrs := []attribute.KeyValue{
+		attribute.String("http.method", "GET"),
+		attribute.String("http.target", "/users?id=1"),
+		attribute.String("net.transport", "ip_tcp"),
+		attribute.String("net.sock.family", "inet6"),
+		attribute.String("app.tenant", "acme"),
+	}
+	assert.Equal(t, attrs, V1_20.Convert(attrs))
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("http.request.method", "GET"),
+		attribute.String("url.path", "/users"),
+		attribute.String("url.query", "id=1"),
+		attribute.String("network.transport", "tcp"),
+		attribute.String("network.type", "ipv6"),
+		attribute.String("app.tenant", "acme"),
+	}, V1_26.Convert(attrs))
+
+	assert.Equal(t, attribute.Int("http.response.status_code", 200), V1_26.KeyValue(attribute.Int("http.status_code", 200)))
+	assert.Equal(t, attribute.Int("http.status_code", 200), V1_20.KeyValue(attribute.Int("http.status_code", 200)))
+}
+
+func TestVersionDefault(t *testing.T) {
+	t.Cleanup(func() { SetDefault("") })
+
+	assert.Equal(t, V1_20, Version("").Resolve())
+	SetDefault(V1_26)
+	assert.Equal(t, V1_26, Version("").Resolve())
+	assert.Equal(t, V1_20, V1_20.Resolve(), "an explicit version overrides the default")
+	assert.NotEqual(t, V1_20.SchemaURL(), V1_26.SchemaURL())
+	assert.False(t, Version("1.4.0").Valid())
+
+	span := noop.Span{}
+	assert.Equal(t, span, V1_20.Span(span))
+	assert.NotEqual(t, span, V1_26.Span(span))
+}
+
+func TestVersionDuration(t *testing.T) {
+	assert.Equal(t, "ms", V1_20.DurationUnit())
+	assert.Equal(t, "s", V1_26.DurationUnit())
+	assert.Equal(t, 1500.0, V1_20.Duration(1500*time.Millisecond))
+	assert.Equal(t, 1.5, V1_26.Duration(1500*time.Millisecond))
+
+	assert.Nil(t, V1_20.DurationBuckets(nil), "the SDK defaults")
+	assert.Equal(t, 10.0, V1_26.DurationBuckets(nil)[len(V1_26.DurationBuckets(nil))-1])
+	assert.Equal(t, []float64{1, 2}, V1_26.DurationBuckets([]float64{1, 2}))
+}
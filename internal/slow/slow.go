@@ -0,0 +1,89 @@
+// Package slow annotates the server spans of the requests exceeding a
+// duration threshold, for the gin and gRPC middlewares.
+package slow
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Key marks the spans of the slow requests.
+	Key = attribute.Key("kgs.slow")
+	// EventName is the name of the span event with the breakdown of a slow
+	// request.
+	EventName = "slow request"
+
+	ThresholdKey  = attribute.Key("kgs.slow.threshold_ms")
+	DurationKey   = attribute.Key("kgs.slow.duration_ms")
+	HandlerKey    = attribute.Key("kgs.slow.handler_ms")
+	MiddlewareKey = attribute.Key("kgs.slow.middleware_ms")
+)
+
+// timerKey is the context key of the Timer of a request.
+type timerKey struct{}
+
+// Timer accumulates the time spent in the handler of a request.
+type Timer struct {
+	handler atomic.Int64
+	timed   atomic.Bool
+}
+
+// ContextWithTimer returns ctx with a new Timer for the request.
+func ContextWithTimer(ctx context.Context) (context.Context, *Timer) {
+	t := &Timer{}
+	return context.WithValue(ctx, timerKey{}, t), t
+}
+
+// StartHandler starts timing the handler of the request in ctx and returns
+// the function stopping it. It does nothing if the request has no Timer.
+func StartHandler(ctx context.Context) func() {
+	t, _ := ctx.Value(timerKey{}).(*Timer)
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		t.handler.Add(int64(time.Since(start)))
+		t.timed.Store(true)
+	}
+}
+
+// Handler returns the time spent in the handler, and whether it was timed.
+func (t *Timer) Handler() (time.Duration, bool) {
+	if t == nil || !t.timed.Load() {
+		return 0, false
+	}
+	return time.Duration(t.handler.Load()), true
+}
+
+// Annotate marks span as slow and adds the breakdown event if total exceeds
+// the threshold. handler is the time spent in the handler, negative if
+// unknown; the rest of total is attributed to the middlewares.
+func Annotate(span trace.Span, threshold, total, handler time.Duration) bool {
+	if threshold <= 0 || total <= threshold {
+		return false
+	}
+	attrs := []attribute.KeyValue{
+		ThresholdKey.Float64(ms(threshold)),
+		DurationKey.Float64(ms(total)),
+	}
+	if handler >= 0 {
+		attrs = append(attrs,
+			HandlerKey.Float64(ms(handler)),
+			MiddlewareKey.Float64(ms(max(total-handler, 0))),
+		)
+	}
+	span.SetAttributes(Key.Bool(true))
+	span.AddEvent(EventName, trace.WithAttributes(attrs...))
+	return true
+}
+
+// ms returns d in milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,30 @@
+// Package traceresponse formats the traceresponse header of the W3C Trace
+// Context Level 2, which tells the callers the trace of the server-side span,
+// for the HTTP server middlewares.
+package traceresponse
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header is the name of the traceresponse header.
+const Header = "traceresponse"
+
+// Format returns the traceresponse value of sc, version 00 like traceparent,
+// or "" if sc is not valid.
+func Format(sc trace.SpanContext) string {
+	if !sc.IsValid() {
+		return ""
+	}
+	return "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+}
+
+// Set sets the traceresponse header of sc in h. It must be called before the
+// response headers are written.
+func Set(h http.Header, sc trace.SpanContext) {
+	if v := Format(sc); v != "" {
+		h.Set(Header, v)
+	}
+}
@@ -0,0 +1,125 @@
+package kgsotel
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+const (
+	// defaultPodInfoDir is where the downward API volume is usually mounted.
+	defaultPodInfoDir = "/etc/podinfo"
+	// serviceAccountNamespaceFile holds the namespace of the pod.
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	// cgroupFile lists the cgroups of the process, which contain the container ID.
+	cgroupFile = "/proc/self/cgroup"
+)
+
+// containerIDPattern matches the 64 hex characters ID of a container in a cgroup path.
+var containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// WithKubernetesDetector returns an Option to add the k8s.* attributes of
+// the pod to the resource. They are read from the environment variables set
+// with the downward API (K8S_POD_NAME, K8S_POD_UID, K8S_NAMESPACE_NAME,
+// K8S_NODE_NAME), then from the files of the downward API volume mounted at
+// podInfoDir (name, uid, namespace), /etc/podinfo if empty. The container ID
+// is read from the cgroups of the process.
+func WithKubernetesDetector(podInfoDir string) Option {
+	return optionFunc(func(cfg *config) {
+		if podInfoDir == "" {
+			podInfoDir = defaultPodInfoDir
+		}
+		cfg.PodInfoDir = podInfoDir
+	})
+}
+
+// k8sDetector detects the k8s.* resource attributes of the pod.
+type k8sDetector struct {
+	podInfoDir    string
+	namespaceFile string
+	cgroupFile    string
+}
+
+func newK8sDetector(podInfoDir string) k8sDetector {
+	return k8sDetector{
+		podInfoDir:    podInfoDir,
+		namespaceFile: serviceAccountNamespaceFile,
+		cgroupFile:    cgroupFile,
+	}
+}
+
+// Detect returns the resource of the pod. It is empty outside of Kubernetes.
+func (d k8sDetector) Detect(context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	add := func(attr func(string) attribute.KeyValue, value string) {
+		if value != "" {
+			attrs = append(attrs, attr(value))
+		}
+	}
+
+	add(semconv.K8SPodName, firstNonEmpty(
+		os.Getenv("K8S_POD_NAME"), os.Getenv("POD_NAME"), d.readPodInfo("name")))
+	add(semconv.K8SPodUID, firstNonEmpty(
+		os.Getenv("K8S_POD_UID"), os.Getenv("POD_UID"), d.readPodInfo("uid")))
+	add(semconv.K8SNamespaceName, firstNonEmpty(
+		os.Getenv("K8S_NAMESPACE_NAME"), os.Getenv("POD_NAMESPACE"), d.readPodInfo("namespace"), readTrimmed(d.namespaceFile)))
+	add(semconv.K8SNodeName, firstNonEmpty(
+		os.Getenv("K8S_NODE_NAME"), os.Getenv("NODE_NAME")))
+	if len(attrs) == 0 {
+		// Not running in a pod, the container ID would be meaningless.
+		return resource.Empty(), nil
+	}
+	add(semconv.ContainerID, d.containerID())
+
+	return resource.NewSchemaless(attrs...), nil
+}
+
+func (d k8sDetector) readPodInfo(name string) string {
+	if d.podInfoDir == "" {
+		return ""
+	}
+	return readTrimmed(filepath.Join(d.podInfoDir, name))
+}
+
+// containerID returns the ID of the container found in the cgroups of the process.
+func (d k8sDetector) containerID() string {
+	f, err := os.Open(d.cgroupFile)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := containerIDPattern.FindString(scanner.Text()); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// readTrimmed returns the content of the file without surrounding spaces,
+// or an empty string if it cannot be read.
+func readTrimmed(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
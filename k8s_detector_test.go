@@ -0,0 +1,52 @@
+package kgsotel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+func TestK8sDetector(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "name"), []byte("api-7d9f\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "namespace"), []byte("shop\n"), 0o644))
+	cgroup := filepath.Join(dir, "cgroup")
+	id := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	require.NoError(t, os.WriteFile(cgroup, []byte("0::/kubepods/besteffort/pod1/"+id+"\n"), 0o644))
+	t.Setenv("K8S_NODE_NAME", "node-1")
+
+	d := k8sDetector{podInfoDir: dir, cgroupFile: cgroup}
+	res, err := d.Detect(context.Background())
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		semconv.K8SPodName("api-7d9f"),
+		semconv.K8SNamespaceName("shop"),
+		semconv.K8SNodeName("node-1"),
+		semconv.ContainerID(id),
+	}, res.Attributes())
+}
+
+func TestK8sDetectorOutsideKubernetes(t *testing.T) {
+	d := k8sDetector{podInfoDir: t.TempDir()}
+	res, err := d.Detect(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, res.Attributes())
+}
+
+func TestNewResourceWithKubernetesDetector(t *testing.T) {
+	t.Setenv("K8S_POD_NAME", "api-7d9f")
+
+	res, err := newResource(context.Background(), "svc", newConfig(WithKubernetesDetector(t.TempDir())))
+	require.NoError(t, err)
+
+	v, ok := res.Set().Value(semconv.K8SPodNameKey)
+	assert.True(t, ok)
+	assert.Equal(t, "api-7d9f", v.AsString())
+}
@@ -0,0 +1,211 @@
+package kgsotel
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogRepeatedKey is the field of the summary logs counting the identical
+// logs suppressed by the deduplication.
+const LogRepeatedKey = "log.repeated"
+
+// minDedupSweepInterval bounds the frequency of the sweeps of the ended
+// windows.
+const minDedupSweepInterval = 10 * time.Millisecond
+
+// maxDedupKeys bounds the number of distinct logs tracked by the
+// deduplication; the logs beyond it are written.
+const maxDedupKeys = 10000
+
+// WithLogDeduplication returns an Option to suppress the logs identical to a
+// log written less than window ago: same level, message and error
+// fingerprint (see WithErrorFingerprint). The first log is written, and when
+// the window ends, a summary log "<message> (repeated N times)" with the
+// log.repeated field counts the suppressed ones. It protects the pipeline
+// from the tight error loops. A zero window disables it.
+func WithLogDeduplication(window time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LogDedupWindow = window
+	})
+}
+
+// dedupKey identifies the identical logs.
+type dedupKey struct {
+	level       zapcore.Level
+	message     string
+	fingerprint string
+}
+
+// dedupEntry tracks the window of a log.
+type dedupEntry struct {
+	start      time.Time
+	suppressed int
+	entry      zapcore.Entry
+	core       zapcore.Core
+	fields     []zapcore.Field
+}
+
+// logDedup holds the windows of the logs written by the cores.
+type logDedup struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[dedupKey]*dedupEntry
+	lastSweep time.Time
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newLogDedup returns a deduplication sweeping the ended windows every
+// window/2, but at most every minDedupSweepInterval, so the summaries are
+// written even if the logs do not occur again, until it is closed.
+func newLogDedup(window time.Duration) *logDedup {
+	d := &logDedup{
+		window:  window,
+		now:     time.Now,
+		entries: map[dedupKey]*dedupEntry{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go d.run(max(window/2, minDedupSweepInterval))
+	return d
+}
+
+func (d *logDedup) run(interval time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := d.now()
+			d.mu.Lock()
+			ended := d.sweep(now)
+			d.mu.Unlock()
+			writeSummaries(ended)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// close stops the sweeps. The pending summaries are written by flush.
+func (d *logDedup) close() {
+	d.stopOnce.Do(func() { close(d.stop) })
+	<-d.done
+}
+
+// allow reports whether the log is written, and writes the summaries of the
+// ended windows.
+func (d *logDedup) allow(core zapcore.Core, ent zapcore.Entry, fields []zapcore.Field) bool {
+	key := dedupKey{level: ent.Level, message: ent.Message}
+	for _, f := range fields {
+		if f.Key == ErrorFingerprintKey {
+			key.fingerprint = f.String
+			if key.fingerprint == "" {
+				key.fingerprint = fmt.Sprint(f.Interface)
+			}
+		}
+	}
+
+	now := d.now()
+	d.mu.Lock()
+	var ended []*dedupEntry
+	if now.Sub(d.lastSweep) >= d.window/2 {
+		// Between the sweeps, the window of a log is checked at its next
+		// occurrence.
+		ended = d.sweep(now)
+	}
+	e, ok := d.entries[key]
+	allowed := !ok || now.Sub(e.start) >= d.window
+	if !allowed {
+		e.suppressed++
+	} else {
+		if ok && e.suppressed > 0 {
+			ended = append(ended, e)
+		}
+		if ok || len(d.entries) < maxDedupKeys {
+			d.entries[key] = &dedupEntry{start: now, entry: ent, core: core, fields: slices.Clone(fields)}
+		}
+	}
+	d.mu.Unlock()
+
+	writeSummaries(ended)
+	return allowed
+}
+
+// sweep removes the ended windows and returns the ones which suppressed logs.
+func (d *logDedup) sweep(now time.Time) []*dedupEntry {
+	d.lastSweep = now
+	var ended []*dedupEntry
+	for key, e := range d.entries {
+		if now.Sub(e.start) >= d.window {
+			delete(d.entries, key)
+			if e.suppressed > 0 {
+				ended = append(ended, e)
+			}
+		}
+	}
+	return ended
+}
+
+// flush ends all the windows and writes their summaries.
+func (d *logDedup) flush() {
+	d.mu.Lock()
+	var ended []*dedupEntry
+	for key, e := range d.entries {
+		delete(d.entries, key)
+		if e.suppressed > 0 {
+			ended = append(ended, e)
+		}
+	}
+	d.mu.Unlock()
+	writeSummaries(ended)
+}
+
+// writeSummaries writes the summary logs of the windows.
+func writeSummaries(ended []*dedupEntry) {
+	for _, e := range ended {
+		ent := e.entry
+		ent.Message = fmt.Sprintf("%s (repeated %d times)", ent.Message, e.suppressed)
+		if ce := e.core.Check(ent, nil); ce != nil {
+			ce.Write(append(e.fields[:len(e.fields):len(e.fields)], zap.Int(LogRepeatedKey, e.suppressed))...)
+		}
+	}
+}
+
+// dedupCore suppresses the identical logs written to its core.
+type dedupCore struct {
+	zapcore.Core
+	dedup *logDedup
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{Core: c.Core.With(fields), dedup: c.dedup}
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	return ce.AddCore(ent, c)
+}
+
+// Write writes the log to the enabled cores unless it is suppressed.
+func (c *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.dedup.allow(c.Core, ent, fields) {
+		return nil
+	}
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+	return nil
+}
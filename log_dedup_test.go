@@ -0,0 +1,89 @@
+package kgsotel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogDeduplication(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	dedup := newLogDedup(time.Hour)
+	defer dedup.close()
+	now := time.Unix(1700000000, 0)
+	dedup.now = func() time.Time { return now }
+	logger := zap.New(&dedupCore{Core: core, dedup: dedup})
+
+	for i := 0; i < 5; i++ {
+		logger.Error("db down", zap.String(ErrorFingerprintKey, "a"))
+	}
+	logger.Error("db down", zap.String(ErrorFingerprintKey, "b"))
+	logger.Warn("db down")
+	logger.Debug("ignored")
+	require.Len(t, logs.All(), 3)
+
+	// The next occurrence after the window writes the summary first.
+	now = now.Add(time.Hour)
+	logger.Error("db down", zap.String(ErrorFingerprintKey, "a"))
+	entries := logs.TakeAll()
+	require.Len(t, entries, 5)
+	assert.Equal(t, "db down (repeated 4 times)", entries[3].Message)
+	assert.Equal(t, int64(4), entries[3].ContextMap()[LogRepeatedKey])
+	assert.Equal(t, "a", entries[3].ContextMap()[ErrorFingerprintKey])
+	assert.Equal(t, "db down", entries[4].Message)
+
+	logger.Error("db down", zap.String(ErrorFingerprintKey, "a"))
+	assert.Zero(t, logs.Len())
+	dedup.flush()
+	entries = logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "db down (repeated 1 times)", entries[0].Message)
+}
+
+func TestLogDeduplicationWith(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	dedup := newLogDedup(time.Minute)
+	defer dedup.close()
+	logger := zap.New(&dedupCore{Core: core, dedup: dedup})
+	logger.With(zap.String("user", "ada")).Info("retrying")
+	logger.With(zap.String("user", "bob")).Info("retrying")
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestLogDeduplicationSweep(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	dedup := newLogDedup(50 * time.Millisecond)
+	defer dedup.close()
+	logger := zap.New(&dedupCore{Core: core, dedup: dedup})
+
+	for i := 0; i < 3; i++ {
+		logger.Error("db down")
+	}
+	// The summary is written when the window ends, without a next occurrence.
+	require.Eventually(t, func() bool { return logs.Len() == 2 }, time.Second, 10*time.Millisecond)
+	entries := logs.TakeAll()
+	assert.Equal(t, "db down (repeated 2 times)", entries[1].Message)
+
+	dedup.close()
+	logger.Error("db down")
+	logger.Error("db down")
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, logs.Len())
+}
+
+func TestLogDeduplicationTinyWindow(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	// The sweeps of a window shorter than 2ns do not panic.
+	dedup := newLogDedup(time.Nanosecond)
+	defer dedup.close()
+	logger := zap.New(&dedupCore{Core: core, dedup: dedup})
+	logger.Info("retrying")
+	time.Sleep(2 * minDedupSweepInterval)
+	logger.Info("retrying")
+	assert.Equal(t, 2, logs.Len())
+}
@@ -0,0 +1,51 @@
+package kgsotel
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// The network log sinks (GELF, syslog) dial their connection on the first
+// write rather than at startup, so an unreachable server does not fail the
+// initialization, and their dials and writes time out, so it does not block
+// the logging either.
+const (
+	sinkDialTimeout  = 2 * time.Second
+	sinkWriteTimeout = 2 * time.Second
+	// sinkRedialDelay is the delay before dialing again after a failure,
+	// the logs written meanwhile are dropped.
+	sinkRedialDelay = 5 * time.Second
+)
+
+// errSinkUnreachable is returned while a failed dial waits to be retried.
+var errSinkUnreachable = errors.New("server unreachable")
+
+// sinkDialer dials the connection of a log sink, at most once per
+// sinkRedialDelay after a failure. It is guarded by the mutex of its writer.
+type sinkDialer struct {
+	retryAt time.Time
+}
+
+// dial opens the connection with connect, or fails fast while the last
+// failure is recent.
+func (d *sinkDialer) dial(connect func() (net.Conn, error)) (net.Conn, error) {
+	if time.Now().Before(d.retryAt) {
+		return nil, errSinkUnreachable
+	}
+	conn, err := connect()
+	if err != nil {
+		d.retryAt = time.Now().Add(sinkRedialDelay)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// sinkWrite writes b to conn within sinkWriteTimeout.
+func sinkWrite(conn net.Conn, b []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(sinkWriteTimeout)); err != nil {
+		return err
+	}
+	_, err := conn.Write(b)
+	return err
+}
@@ -0,0 +1,93 @@
+package kgsotel
+
+import (
+	"context"
+	"slices"
+
+	"kgs/otel/internal/killswitch"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldsKey is the context key of the fields accumulated for the loggers.
+type fieldsKey struct{}
+
+// ContextWithFields returns ctx with the fields added to the ones of the
+// loggers retrieved from it, e.g. the user of a request set by a
+// middleware for the logs of the handlers.
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	prev, _ := ctx.Value(fieldsKey{}).([]Field)
+	return context.WithValue(ctx, fieldsKey{}, append(slices.Clip(prev), fields...))
+}
+
+// Logger is a trace-aware logger bound to a context and fields. Its logs are
+// written like the ones of Info, Warn and Error, with the trace and span IDs
+// of the context.
+type Logger struct {
+	ctx    context.Context
+	fields []Field
+}
+
+// FromContext returns the Logger of ctx, with the fields accumulated by
+// ContextWithFields:
+//
+//	kgsotel.FromContext(ctx).With(kgsotel.NewFiled("order", id)).Info("order shipped")
+func FromContext(ctx context.Context) *Logger {
+	fields, _ := ctx.Value(fieldsKey{}).([]Field)
+	return &Logger{ctx: ctx, fields: fields}
+}
+
+// With returns a Logger adding the fields to the ones of l.
+func (l *Logger) With(fields ...Field) *Logger {
+	return &Logger{ctx: l.ctx, fields: append(slices.Clip(l.fields), fields...)}
+}
+
+// Context returns the context of l with its fields, for the loggers of the
+// functions it is passed to.
+func (l *Logger) Context() context.Context {
+	return context.WithValue(l.ctx, fieldsKey{}, l.fields)
+}
+
+func (l *Logger) Info(message string, fields ...Field) {
+	if killswitch.Disabled() {
+		zap.L().Info(message, plainZapFields(l.withFields(fields))...)
+		return
+	}
+	span, zapFields := setSpanAttrsAndZapFields(l.ctx, l.withFields(fields)...)
+	addSpanEvent(span, zapcore.InfoLevel, message)
+	loggerFor(l.ctx).Info(message, zapFields...)
+}
+
+func (l *Logger) Warn(message string, fields ...Field) {
+	if killswitch.Disabled() {
+		zap.L().Warn(message, plainZapFields(l.withFields(fields))...)
+		return
+	}
+	span, zapFields := setSpanAttrsAndZapFields(l.ctx, l.withFields(fields)...)
+	addSpanEvent(span, zapcore.WarnLevel, message)
+	span.SetStatus(codes.Error, message)
+	loggerFor(l.ctx).Warn(message, zapFields...)
+}
+
+func (l *Logger) Error(message string, fields ...Field) {
+	if killswitch.Disabled() {
+		zap.L().Error(message, plainZapFields(withErrorFields(l.withFields(fields)))...)
+		return
+	}
+	fields = withFingerprint(message, withErrorFields(l.withFields(fields)), 2)
+	span, zapFields := setSpanAttrsAndZapFields(l.ctx, fields...)
+	addSpanEvent(span, zapcore.ErrorLevel, message)
+	span.SetStatus(codes.Error, message)
+	loggerFor(l.ctx).Error(message, zapFields...)
+	reportError(l.ctx, span, message, fields)
+}
+
+// withFields returns the fields of l followed by fields.
+func (l *Logger) withFields(fields []Field) []Field {
+	if len(l.fields) == 0 {
+		return fields
+	}
+	return append(slices.Clip(l.fields), fields...)
+}
@@ -6,23 +6,47 @@ import (
 	"time"
 
 	"go.opentelemetry.io/contrib/bridges/otelzap"
-	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/log"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-func initLogger(serviceName string) *zap.Logger {
-	// Create a new logger
-	core := zapcore.NewTee(
-		zapcore.NewCore(zapcore.NewConsoleEncoder(getConsoleConfig()), zapcore.AddSync(os.Stdout), zapcore.DebugLevel),
-		otelzap.NewCore(serviceName, otelzap.WithLoggerProvider(global.GetLoggerProvider())),
-	)
-	logger := zap.New(core)
+// logLevel is the level of the global logger, it can be changed at runtime.
+var logLevel = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
+// logCoreFactory creates an extra core the logs are written to, and the
+// function releasing it.
+type logCoreFactory func(serviceName string, level zapcore.LevelEnabler) (zapcore.Core, func() error, error)
 
-	// Replace the global logger
-	zap.ReplaceGlobals(logger)
+// loggerConfig customizes the console logger and the options of the logger.
+type loggerConfig struct {
+	encoderConfig *zapcore.EncoderConfig
+	options       []zap.Option
+	dedup         *logDedup
+}
 
-	return logger
+// initLogger returns the logger writing to the console, the logger provider
+// and the extra cores. The cores write all the levels, the level of the
+// logger is applied on top of them, so the debugged contexts can skip it.
+// The level core wraps the cores of the options too, e.g. zap.WrapCore, so
+// it stays the outermost one.
+func initLogger(serviceName string, provider log.LoggerProvider, level zap.AtomicLevel, lc loggerConfig, extra ...zapcore.Core) *zap.Logger {
+	// Create a new logger
+	otelCore := otelzap.NewCore(serviceName, otelzap.WithLoggerProvider(provider))
+	encoderConfig := getConsoleConfig()
+	if lc.encoderConfig != nil {
+		encoderConfig = *lc.encoderConfig
+	}
+	core := zapcore.NewTee(append([]zapcore.Core{
+		zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.AddSync(os.Stdout), zapcore.DebugLevel),
+		otelCore,
+	}, extra...)...)
+	if lc.dedup != nil {
+		core = &dedupCore{Core: core, dedup: lc.dedup}
+	}
+	return zap.New(core, lc.options...).WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &levelCore{Core: c, level: level}
+	}))
 }
 
 func getConsoleConfig() zapcore.EncoderConfig {
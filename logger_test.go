@@ -0,0 +1,44 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	recorder := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(context.Background(), "op")
+
+	ctx = ContextWithFields(ctx, NewFiled("user", "ada"))
+	logger := FromContext(ctx).With(NewFiled("order", 42))
+	logger.Info("order shipped", NewFiled("carrier", "ups"))
+	FromContext(logger.Context()).Warn("late")
+	FromContext(ctx).Error("failed")
+	span.End()
+
+	entries := logs.All()
+	require.Len(t, entries, 3)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "ada", fields["user"])
+	assert.Equal(t, int64(42), fields["order"])
+	assert.Equal(t, "ups", fields["carrier"])
+	assert.Equal(t, span.SpanContext().TraceID().String(), fields["traceID"])
+	assert.Contains(t, fields["caller"], "logger_test.go")
+
+	assert.Equal(t, int64(42), entries[1].ContextMap()["order"], "the fields are passed on by Context")
+	assert.NotContains(t, entries[2].ContextMap(), "order", "With does not change the context")
+	assert.Equal(t, "ada", entries[2].ContextMap()["user"])
+
+	require.Len(t, recorder.Ended(), 1)
+	assert.Len(t, recorder.Ended()[0].Events(), 3)
+}
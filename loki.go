@@ -0,0 +1,87 @@
+package kgsotel
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// The attributes hinting the Loki exporter of the collector which
+// attributes to index as labels.
+const (
+	lokiAttributeLabelsHint = "loki.attribute.labels"
+	lokiResourceLabelsHint  = "loki.resource.labels"
+)
+
+// lokiLevelLabel is the label holding the severity of the log.
+const lokiLevelLabel = "level"
+
+// lokiResourceLabels are the resource attributes indexed as labels.
+var lokiResourceLabels = []string{string(semconv.ServiceNameKey), string(semconv.DeploymentEnvironmentKey)}
+
+// lokiMessageKey is the key of the original body when the other attributes
+// are moved to the body.
+const lokiMessageKey = "message"
+
+// WithLokiLabels returns an Option to shape the log records for Loki: the
+// service name, the deployment environment, the level and the given
+// attributes are indexed as labels, and every other attribute is moved into
+// the body, next to the message. Keep the labels few and of low cardinality,
+// e.g. no user or request IDs.
+func WithLokiLabels(attributes ...string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LokiLabels = append([]string{lokiLevelLabel}, attributes...)
+	})
+}
+
+// lokiProcessor rewrites the log records before they are batched, keeping
+// the label attributes and moving the others into the body.
+type lokiProcessor struct {
+	passthroughProcessor
+	labels     map[string]bool
+	labelsHint string
+}
+
+var _ sdklog.Processor = (*lokiProcessor)(nil)
+
+func newLokiProcessor(labels []string) *lokiProcessor {
+	p := &lokiProcessor{labels: make(map[string]bool, len(labels))}
+	for _, l := range labels {
+		p.labels[l] = true
+	}
+	p.labelsHint = strings.Join(labels, ",")
+	return p
+}
+
+// OnEmit moves the attributes which are not labels into the body.
+func (p *lokiProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	labels := make([]log.KeyValue, 0, len(p.labels)+3)
+	body := make([]log.KeyValue, 0, r.AttributesLen()+1)
+	body = append(body, log.KeyValue{Key: lokiMessageKey, Value: r.Body()})
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		if p.labels[kv.Key] {
+			labels = append(labels, kv)
+		} else {
+			body = append(body, kv)
+		}
+		return true
+	})
+
+	level := r.SeverityText()
+	if level == "" {
+		level = r.Severity().String()
+	}
+	labels = append(labels,
+		log.String(lokiLevelLabel, strings.ToLower(level)),
+		log.String(lokiAttributeLabelsHint, p.labelsHint),
+		log.String(lokiResourceLabelsHint, strings.Join(lokiResourceLabels, ",")),
+	)
+	r.SetAttributes(labels...)
+	if len(body) > 1 {
+		r.SetBody(log.MapValue(body...))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// recordingProcessor keeps the emitted log records.
+type recordingProcessor struct {
+	records []sdklog.Record
+}
+
+func (p *recordingProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	p.records = append(p.records, r.Clone())
+	return nil
+}
+
+func (p *recordingProcessor) Shutdown(context.Context) error   { return nil }
+func (p *recordingProcessor) ForceFlush(context.Context) error { return nil }
+
+// emitLoki emits the record through a Loki processor with the labels.
+func emitLoki(t *testing.T, record log.Record, labels ...string) sdklog.Record {
+	rec := &recordingProcessor{}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(newLokiProcessor(newConfig(WithLokiLabels(labels...)).LokiLabels)),
+		sdklog.WithProcessor(rec),
+	)
+	lp.Logger("test").Emit(context.Background(), record)
+	require.Len(t, rec.records, 1)
+	return rec.records[0]
+}
+
+func TestLokiProcessor(t *testing.T) {
+	var record log.Record
+	record.SetBody(log.StringValue("order paid"))
+	record.SetSeverity(log.SeverityWarn)
+	record.SetSeverityText("WARN")
+	record.AddAttributes(log.String("team", "shop"), log.String("order.id", "42"), log.Int("amount", 7))
+	r := emitLoki(t, record, "team")
+
+	attrs := map[string]string{}
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value.String()
+		return true
+	})
+	assert.Equal(t, map[string]string{
+		"team":                  "shop",
+		"level":                 "warn",
+		"loki.attribute.labels": "level,team",
+		"loki.resource.labels":  "service.name,deployment.environment",
+	}, attrs)
+
+	body := map[string]log.Value{}
+	for _, kv := range r.Body().AsMap() {
+		body[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "order paid", body["message"].AsString())
+	assert.Equal(t, "42", body["order.id"].AsString())
+	assert.Equal(t, int64(7), body["amount"].AsInt64())
+	assert.NotContains(t, body, "team")
+}
+
+func TestLokiProcessorKeepsBody(t *testing.T) {
+	var record log.Record
+	record.SetBody(log.StringValue("started"))
+	record.SetSeverity(log.SeverityInfo)
+	r := emitLoki(t, record)
+	assert.Equal(t, "info", lokiLevel(r))
+	assert.Equal(t, "started", r.Body().AsString())
+}
+
+func lokiLevel(r sdklog.Record) string {
+	var level string
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		if kv.Key == "level" {
+			level = kv.Value.AsString()
+		}
+		return true
+	})
+	return level
+}
@@ -0,0 +1,118 @@
+package otelmessaging
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// MapCarrier is a propagation.TextMapCarrier over string headers.
+type MapCarrier = propagation.MapCarrier
+
+// JSONCarrier is a propagation.TextMapCarrier over the headers of a message
+// encoded in JSON, decoded as an object. The values which are not strings
+// are formatted when read.
+type JSONCarrier map[string]any
+
+// Get returns the value associated with the passed key.
+func (c JSONCarrier) Get(key string) string {
+	switch v := c[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Set stores the key-value pair.
+func (c JSONCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys lists the keys stored in this carrier.
+func (c JSONCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// BytesCarrier is a propagation.TextMapCarrier over binary headers, e.g. a
+// protobuf map<string, bytes> field.
+type BytesCarrier map[string][]byte
+
+// Get returns the value associated with the passed key.
+func (c BytesCarrier) Get(key string) string {
+	return string(c[key])
+}
+
+// Set stores the key-value pair.
+func (c BytesCarrier) Set(key, value string) {
+	c[key] = []byte(value)
+}
+
+// Keys lists the keys stored in this carrier.
+func (c BytesCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Header is a header entry of a message, e.g. a protobuf message with key
+// and value fields, whose generated getters implement it.
+type Header interface {
+	GetKey() string
+	GetValue() string
+}
+
+// ListCarrier is a propagation.TextMapCarrier over a list of header entries,
+// e.g. a protobuf repeated Header field.
+type ListCarrier[H Header] struct {
+	headers   *[]H
+	newHeader func(key, value string) H
+}
+
+// NewListCarrier returns a carrier over the headers, creating the entries
+// with newHeader. The entries set replace the entries with the same key.
+//
+//	otelmessaging.NewListCarrier(&msg.Headers, func(k, v string) *pb.Header {
+//		return &pb.Header{Key: k, Value: v}
+//	})
+func NewListCarrier[H Header](headers *[]H, newHeader func(key, value string) H) ListCarrier[H] {
+	return ListCarrier[H]{headers: headers, newHeader: newHeader}
+}
+
+// Get returns the value of the first entry with the key.
+func (c ListCarrier[H]) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.GetKey() == key {
+			return h.GetValue()
+		}
+	}
+	return ""
+}
+
+// Set replaces the entries with the key by an entry with the value.
+func (c ListCarrier[H]) Set(key, value string) {
+	headers := (*c.headers)[:0]
+	for _, h := range *c.headers {
+		if h.GetKey() != key {
+			headers = append(headers, h)
+		}
+	}
+	*c.headers = append(headers, c.newHeader(key, value))
+}
+
+// Keys lists the keys of the entries.
+func (c ListCarrier[H]) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.GetKey())
+	}
+	return keys
+}
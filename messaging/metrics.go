@@ -0,0 +1,99 @@
+package otelmessaging
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const (
+	// ScopeName is the instrumentation scope name.
+	ScopeName = "kgs/otel/messaging"
+
+	// SystemKey is the messaging system, e.g. kafka, nats or rabbitmq.
+	SystemKey = attribute.Key("messaging.system")
+	// DestinationKey is the topic, subject or queue name.
+	DestinationKey = attribute.Key("messaging.destination.name")
+	// ConsumerGroupKey is the consumer group (or durable/queue group) name.
+	ConsumerGroupKey = attribute.Key("messaging.consumer.group.name")
+	// PartitionKey is the partition of the destination, when applicable.
+	PartitionKey = attribute.Key("messaging.destination.partition.id")
+)
+
+// Metrics records standardized consumer lag and queue depth metrics so
+// dashboards work the same way across Kafka, NATS and RabbitMQ services.
+type Metrics struct {
+	config *config
+
+	consumerLag metric.Int64Gauge
+	queueDepth  metric.Int64Gauge
+}
+
+// NewMetrics creates the messaging instruments with the given options.
+func NewMetrics(opts ...Option) *Metrics {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otel.GetMeterProvider()
+	}
+
+	meter := cfg.MeterProvider.Meter(ScopeName)
+	m := &Metrics{config: cfg}
+
+	var err error
+
+	// Measure how far the consumer is behind the head of the destination.
+	m.consumerLag, err = meter.Int64Gauge("messaging.consumer.lag",
+		metric.WithDescription("Measures the number of messages the consumer is behind the latest message."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		otel.Handle(err)
+		if m.consumerLag == nil {
+			m.consumerLag = noop.Int64Gauge{}
+		}
+	}
+
+	// Measure the number of messages waiting in the destination.
+	m.queueDepth, err = meter.Int64Gauge("messaging.queue.depth",
+		metric.WithDescription("Measures the number of messages waiting to be consumed."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		otel.Handle(err)
+		if m.queueDepth == nil {
+			m.queueDepth = noop.Int64Gauge{}
+		}
+	}
+
+	return m
+}
+
+// RecordConsumerLag records the lag of a consumer group on a destination.
+// Extra attributes (e.g. PartitionKey) may be passed to refine the datapoint.
+func (m *Metrics) RecordConsumerLag(ctx context.Context, system, destination, group string, lag int64, attrs ...attribute.KeyValue) {
+	// The slice of the caller is not written to.
+	attrs = append(slices.Clip(attrs),
+		SystemKey.String(system),
+		DestinationKey.String(destination),
+		ConsumerGroupKey.String(group),
+	)
+	m.consumerLag.Record(ctx, lag, m.attributeSet(attrs))
+}
+
+// RecordQueueDepth records the number of messages waiting on a destination.
+func (m *Metrics) RecordQueueDepth(ctx context.Context, system, destination string, depth int64, attrs ...attribute.KeyValue) {
+	attrs = append(slices.Clip(attrs),
+		SystemKey.String(system),
+		DestinationKey.String(destination),
+	)
+	m.queueDepth.Record(ctx, depth, m.attributeSet(attrs))
+}
+
+func (m *Metrics) attributeSet(attrs []attribute.KeyValue) metric.RecordOption {
+	return metric.WithAttributeSet(attribute.NewSet(append(attrs, m.config.MetricAttributes...)...))
+}
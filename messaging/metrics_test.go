@@ -0,0 +1,76 @@
+package otelmessaging
+
+import (
+	"context"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// gaugeValues returns the values of the named gauge by attribute set.
+func gaugeValues(t *testing.T, reader sdkmetric.Reader, name string) map[attribute.Distinct]int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	values := map[attribute.Distinct]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+				values[dp.Attributes.Equivalent()] = dp.Value
+			}
+		}
+	}
+	return values
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	m := NewMetrics(WithMeterProvider(mp), WithMetricAttributes(attribute.String("team", "billing")))
+
+	m.RecordConsumerLag(ctx, "kafka", "orders", "invoicer", 10, PartitionKey.String("0"))
+	m.RecordConsumerLag(ctx, "kafka", "orders", "invoicer", 3, PartitionKey.String("1"))
+	m.RecordConsumerLag(ctx, "kafka", "orders", "invoicer", 7, PartitionKey.String("0"))
+	m.RecordQueueDepth(ctx, "rabbitmq", "emails", 42)
+
+	// The last lag of each partition is kept.
+	distinct := func(attrs ...attribute.KeyValue) attribute.Distinct {
+		set := attribute.NewSet(attrs...)
+		return set.Equivalent()
+	}
+	lag := func(partition string) attribute.Distinct {
+		return distinct(
+			SystemKey.String("kafka"),
+			DestinationKey.String("orders"),
+			ConsumerGroupKey.String("invoicer"),
+			PartitionKey.String(partition),
+			attribute.String("team", "billing"),
+		)
+	}
+	assert.Equal(t, map[attribute.Distinct]int64{lag("0"): 7, lag("1"): 3}, gaugeValues(t, reader, "messaging.consumer.lag"))
+	depth := distinct(SystemKey.String("rabbitmq"), DestinationKey.String("emails"), attribute.String("team", "billing"))
+	assert.Equal(t, map[attribute.Distinct]int64{depth: 42}, gaugeValues(t, reader, "messaging.queue.depth"))
+}
+
+func TestMetricsKeepCallerAttributes(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	m := NewMetrics(WithMeterProvider(rec.MeterProvider))
+
+	// The spare capacity of the attributes of the caller is not written to.
+	backing := make([]attribute.KeyValue, 1, 4)
+	backing[0] = PartitionKey.String("0")
+	m.RecordConsumerLag(context.Background(), "kafka", "orders", "invoicer", 1, backing...)
+	m.RecordQueueDepth(context.Background(), "kafka", "orders", 1, backing...)
+	assert.Equal(t, []attribute.KeyValue{{}, {}, {}}, backing[1:4])
+}
@@ -0,0 +1,94 @@
+package otelmessaging
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// config is a group of options for the messaging metrics and wrappers.
+type config struct {
+	MeterProvider    metric.MeterProvider
+	MetricAttributes []attribute.KeyValue
+
+	TracerProvider trace.TracerProvider
+	Propagators    propagation.TextMapPropagator
+	System         string
+	Destination    string
+	SpanAttributes []attribute.KeyValue
+}
+
+// Option applies an option value for a config.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithMeterProvider returns an Option to use the meter provider.
+// If none is specified, the global provider is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.MeterProvider = provider
+		}
+	})
+}
+
+// WithMetricAttributes returns an Option to add attributes to every
+// recorded datapoint.
+func WithMetricAttributes(attrs ...attribute.KeyValue) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.MetricAttributes = attrs
+	})
+}
+
+// WithTracerProvider returns an Option to use the tracer provider for the
+// producer and consumer spans. If none is specified, the global provider is
+// used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.TracerProvider = provider
+		}
+	})
+}
+
+// WithPropagators returns an Option to use the propagators for the message
+// headers. If none is specified, the global propagators are used.
+func WithPropagators(propagators propagation.TextMapPropagator) Option {
+	return optionFunc(func(cfg *config) {
+		if propagators != nil {
+			cfg.Propagators = propagators
+		}
+	})
+}
+
+// WithSystem returns an Option to set the messaging system of the spans,
+// e.g. the name of an internal queue.
+func WithSystem(system string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.System = system
+	})
+}
+
+// WithDestination returns an Option to set the destination of the spans,
+// e.g. the queue name. It is also the first part of their names.
+func WithDestination(destination string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.Destination = destination
+	})
+}
+
+// WithSpanAttributes returns an Option to add attributes to the producer and
+// consumer spans.
+func WithSpanAttributes(attrs ...attribute.KeyValue) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SpanAttributes = attrs
+	})
+}
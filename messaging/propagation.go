@@ -0,0 +1,100 @@
+package otelmessaging
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Carrier returns the carrier over the headers of a message.
+type Carrier[M any] func(msg M) propagation.TextMapCarrier
+
+// WrapProducer wraps the function sending messages to a queue so it sends
+// each message in a producer span, with the trace context injected in its
+// headers, for the queues which are neither Kafka nor NATS.
+//
+//	send := otelmessaging.WrapProducer(queue.Send, func(m *Message) propagation.TextMapCarrier {
+//		return otelmessaging.MapCarrier(m.Headers)
+//	}, otelmessaging.WithSystem("jobs"), otelmessaging.WithDestination("emails"))
+func WrapProducer[M any](send func(context.Context, M) error, carrier Carrier[M], opts ...Option) func(context.Context, M) error {
+	cfg := newTraceConfig(opts)
+	tracer := cfg.TracerProvider.Tracer(ScopeName)
+	attrs := cfg.spanAttributes(semconv.MessagingOperationPublish)
+	return func(ctx context.Context, msg M) error {
+		ctx, span := tracer.Start(ctx, cfg.spanName("publish"),
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+		cfg.Propagators.Inject(ctx, carrier(msg))
+		err := send(ctx, msg)
+		recordError(span, err)
+		return err
+	}
+}
+
+// WrapConsumer wraps the function handling the messages of a queue so it
+// handles each message in a consumer span, continuing the trace context
+// extracted from its headers.
+func WrapConsumer[M any](handle func(context.Context, M) error, carrier Carrier[M], opts ...Option) func(context.Context, M) error {
+	cfg := newTraceConfig(opts)
+	tracer := cfg.TracerProvider.Tracer(ScopeName)
+	attrs := cfg.spanAttributes(semconv.MessagingOperationProcess)
+	return func(ctx context.Context, msg M) error {
+		ctx = cfg.Propagators.Extract(ctx, carrier(msg))
+		ctx, span := tracer.Start(ctx, cfg.spanName("process"),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+		err := handle(ctx, msg)
+		recordError(span, err)
+		return err
+	}
+}
+
+func newTraceConfig(opts []Option) *config {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.Propagators == nil {
+		cfg.Propagators = otel.GetTextMapPropagator()
+	}
+	return cfg
+}
+
+// spanName returns the name of the spans of the operation, prefixed by the
+// destination.
+func (cfg *config) spanName(operation string) string {
+	if cfg.Destination == "" {
+		return operation
+	}
+	return cfg.Destination + " " + operation
+}
+
+func (cfg *config) spanAttributes(operation attribute.KeyValue) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{operation}
+	if cfg.System != "" {
+		attrs = append(attrs, SystemKey.String(cfg.System))
+	}
+	if cfg.Destination != "" {
+		attrs = append(attrs, DestinationKey.String(cfg.Destination))
+	}
+	return append(attrs, cfg.SpanAttributes...)
+}
+
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,84 @@
+package otelmessaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type header struct{ key, value string }
+
+func (h *header) GetKey() string   { return h.key }
+func (h *header) GetValue() string { return h.value }
+
+type message struct {
+	Headers []*header
+}
+
+func TestWrapProducerConsumer(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	opts := []Option{
+		WithTracerProvider(rec.TracerProvider),
+		WithPropagators(propagation.TraceContext{}),
+		WithSystem("jobs"),
+		WithDestination("emails"),
+	}
+	carrier := func(m *message) propagation.TextMapCarrier {
+		return NewListCarrier(&m.Headers, func(k, v string) *header { return &header{k, v} })
+	}
+
+	queue := make(chan *message, 1)
+	send := WrapProducer(func(_ context.Context, m *message) error {
+		queue <- m
+		return nil
+	}, carrier, opts...)
+	failure := errors.New("bounced")
+	var handled trace.SpanContext
+	handle := WrapConsumer(func(ctx context.Context, _ *message) error {
+		handled = trace.SpanContextFromContext(ctx)
+		return failure
+	}, carrier, opts...)
+
+	require.NoError(t, send(context.Background(), &message{Headers: []*header{{"traceparent", "stale"}}}))
+	m := <-queue
+	assert.Len(t, m.Headers, 1)
+	assert.ErrorIs(t, handle(context.Background(), m), failure)
+
+	producer, ok := rec.Span("emails publish")
+	require.True(t, ok)
+	consumer, ok := rec.Span("emails process")
+	require.True(t, ok)
+	assert.Equal(t, trace.SpanKindProducer, producer.SpanKind())
+	assert.Equal(t, trace.SpanKindConsumer, consumer.SpanKind())
+	assert.Equal(t, producer.SpanContext().SpanID(), consumer.Parent().SpanID())
+	assert.Equal(t, consumer.SpanContext(), handled)
+	assert.Contains(t, consumer.Attributes(), SystemKey.String("jobs"))
+	assert.Equal(t, codes.Error, consumer.Status().Code)
+}
+
+func TestJSONCarrier(t *testing.T) {
+	var headers JSONCarrier
+	require.NoError(t, json.Unmarshal([]byte(`{"retries": 2, "tenant": "acme"}`), &headers))
+	assert.Equal(t, "2", headers.Get("retries"))
+	assert.Equal(t, "acme", headers.Get("tenant"))
+	assert.Empty(t, headers.Get("missing"))
+
+	headers.Set("traceparent", "00-1-2-01")
+	assert.ElementsMatch(t, []string{"retries", "tenant", "traceparent"}, headers.Keys())
+}
+
+func TestBytesCarrier(t *testing.T) {
+	headers := BytesCarrier{}
+	headers.Set("traceparent", "00-1-2-01")
+	assert.Equal(t, []byte("00-1-2-01"), headers["traceparent"])
+	assert.Equal(t, "00-1-2-01", headers.Get("traceparent"))
+}
@@ -0,0 +1,30 @@
+package kgsotel
+
+import (
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OpenTracingInstaller installs the OpenTracing to OpenTelemetry bridge: it
+// creates the bridge tracer from the tracer provider of the telemetry, sets
+// it as the OpenTracing global tracer, and returns the tracer provider to
+// register as the OpenTelemetry global one, so the spans of both APIs join
+// the same traces.
+type OpenTracingInstaller func(tp trace.TracerProvider, propagator propagation.TextMapPropagator) trace.TracerProvider
+
+// WithOpenTracingBridge returns an Option to install the OpenTracing bridge
+// when the telemetry is initialized by InitTelemetry. The installer keeps
+// the opentracing-go dependency in the service, e.g. with the bridge of
+// go.opentelemetry.io/otel/bridge/opentracing:
+//
+//	kgsotel.WithOpenTracingBridge(func(tp trace.TracerProvider, p propagation.TextMapPropagator) trace.TracerProvider {
+//		bridge, wrapper := otbridge.NewTracerPair(tp.Tracer("opentracing"))
+//		bridge.SetTextMapPropagator(p)
+//		opentracing.SetGlobalTracer(bridge)
+//		return wrapper
+//	})
+func WithOpenTracingBridge(install OpenTracingInstaller) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.OpenTracingInstaller = install
+	})
+}
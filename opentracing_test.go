@@ -0,0 +1,63 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"kgs/otel/internal/semconvutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// restoreGlobals restores the global providers, propagator and logger at the
+// end of the test.
+func restoreGlobals(t *testing.T) {
+	tp, mp, lp := otel.GetTracerProvider(), otel.GetMeterProvider(), global.GetLoggerProvider()
+	propagator, logger := otel.GetTextMapPropagator(), zap.L()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tp)
+		otel.SetMeterProvider(mp)
+		global.SetLoggerProvider(lp)
+		otel.SetTextMapPropagator(propagator)
+		zap.ReplaceGlobals(logger)
+		setErrorReporter(nil)
+		setErrorFingerprint(false)
+		setSpanEventLevel(zapcore.InfoLevel)
+		setDebugLogger(nil)
+		setPprofLabels(false)
+		setSectionSpans(false)
+		semconvutil.SetDefault("")
+	})
+}
+
+type bridgeProvider struct {
+	trace.TracerProvider
+}
+
+func TestWithOpenTracingBridge(t *testing.T) {
+	restoreGlobals(t)
+
+	var installedWith trace.TracerProvider
+	var propagator propagation.TextMapPropagator
+	install := func(tp trace.TracerProvider, p propagation.TextMapPropagator) trace.TracerProvider {
+		installedWith, propagator = tp, p
+		return bridgeProvider{tp}
+	}
+
+	ctx := context.Background()
+	shutdown, err := InitTelemetry(ctx, "svc", "", WithStdoutExporters(true), WithOpenTracingBridge(install))
+	require.NoError(t, err)
+	defer shutdown(ctx)
+
+	tel := Global()
+	assert.Same(t, tel.SDKTracerProvider(), installedWith)
+	assert.Equal(t, tel.Propagator(), propagator)
+	assert.Equal(t, bridgeProvider{tel.SDKTracerProvider()}, otel.GetTracerProvider())
+}
@@ -0,0 +1,423 @@
+package kgsotel
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/lowoverhead"
+	"kgs/otel/internal/overhead"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+)
+
+// config is a group of options for the telemetry initialization.
+type config struct {
+	TemporalitySelector    sdkmetric.TemporalitySelector
+	AggregationSelector    sdkmetric.AggregationSelector
+	MetricInterval         time.Duration
+	MetricIntervals        map[Environment]time.Duration
+	Sampler                sdktrace.Sampler
+	Headers                map[string]string
+	LogLevel               zapcore.Level
+	SpanEventLevel         zapcore.Level
+	DisabledSignals        map[Signal]bool
+	Environment            Environment
+	StdoutExporters        bool
+	StartupProbeTimeout    time.Duration
+	BreakerThreshold       int
+	BreakerCooldown        time.Duration
+	ReadinessFailureWindow time.Duration
+	ReadinessSaturation    float64
+	DiskBufferDir          string
+	DiskBufferMaxBytes     int64
+	ShutdownTimeout        time.Duration
+	Disabled               bool
+	LowOverhead            bool
+	MeasureOverhead        bool
+
+	ServiceVersion        string
+	DeploymentEnvironment string
+	ServiceInstanceID     string
+	PodInfoDir            string
+
+	TracerProviderOptions []sdktrace.TracerProviderOption
+	MeterProviderOptions  []sdkmetric.Option
+	LoggerProviderOptions []sdklog.LoggerProviderOption
+
+	TraceTreeWriter io.Writer
+	IDGenerator     sdktrace.IDGenerator
+	CaptureDir      string
+	CaptureMatcher  SpanMatcher
+	SpanLeakMaxAge  time.Duration
+	SLATargets      map[string]time.Duration
+
+	Dialer      Dialer
+	DialOptions []grpc.DialOption
+	ProxyURL    string
+	TLS         *tlsFiles
+	TokenSource TokenSource
+
+	DatadogPropagation DatadogMode
+	XRayPropagation    bool
+	ErrorReporter      ErrorReporter
+	ErrorFingerprint   bool
+	PprofLabels        bool
+	SectionSpans       bool
+	Semconv            SemconvVersion
+	PIIScrubbing       bool
+	PIIPatterns        []*regexp.Regexp
+
+	OpenTracingInstaller OpenTracingInstaller
+
+	LokiLabels       []string
+	LogCores         []logCoreFactory
+	ZapEncoderConfig *zapcore.EncoderConfig
+	ZapOptions       []zap.Option
+	LogDedupWindow   time.Duration
+	SeverityMapping  map[zapcore.Level]log.Severity
+	LogBodyFields    []string
+	AsyncLogBuffer   int
+}
+
+// Signal is a telemetry signal exported by the package.
+type Signal string
+
+const (
+	SignalTraces  Signal = "traces"
+	SignalMetrics Signal = "metrics"
+	SignalLogs    Signal = "logs"
+)
+
+// defaultShutdownTimeout bounds the export of the remaining telemetry on shutdown.
+const defaultShutdownTimeout = 10 * time.Second
+
+// envEnvironment is the environment variable naming the environment
+// profile (e.g. dev, staging, prod) the service is running in.
+const envEnvironment = "KGSOTEL_ENV"
+
+// Option applies an option value for a config.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithTemporalitySelector returns an Option to choose the temporality used
+// by the metric exporter for each instrument kind.
+// If none is specified, cumulative temporality is used for all instruments.
+func WithTemporalitySelector(selector sdkmetric.TemporalitySelector) Option {
+	return optionFunc(func(cfg *config) {
+		if selector != nil {
+			cfg.TemporalitySelector = selector
+		}
+	})
+}
+
+// WithDeltaTemporality returns an Option to export counters and histograms
+// with delta temporality, as expected by backends like Dynatrace or
+// Statsd-style pipelines. UpDownCounters stay cumulative.
+func WithDeltaTemporality() Option {
+	return WithTemporalitySelector(deltaTemporalitySelector)
+}
+
+// WithAggregationSelector returns an Option to choose the aggregation used
+// by the metric exporter for each instrument kind.
+// If none is specified, the SDK default aggregations are used.
+func WithAggregationSelector(selector sdkmetric.AggregationSelector) Option {
+	return optionFunc(func(cfg *config) {
+		if selector != nil {
+			cfg.AggregationSelector = selector
+		}
+	})
+}
+
+// WithMetricInterval returns an Option to set the interval at which metrics
+// are collected and exported. If none is specified, the SDK default of 60s is used.
+func WithMetricInterval(interval time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		if interval > 0 {
+			cfg.MetricInterval = interval
+		}
+	})
+}
+
+// WithEnvironmentMetricInterval returns an Option to set the metric export
+// interval used when the service runs in the env environment (see
+// WithEnvironment). It takes precedence over WithMetricInterval for that environment.
+func WithEnvironmentMetricInterval(env Environment, interval time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		if interval <= 0 {
+			return
+		}
+		if cfg.MetricIntervals == nil {
+			cfg.MetricIntervals = make(map[Environment]time.Duration)
+		}
+		cfg.MetricIntervals[env] = interval
+	})
+}
+
+// WithSampler returns an Option to use the sampler for the tracer provider.
+// If none is specified, every span is sampled.
+func WithSampler(sampler sdktrace.Sampler) Option {
+	return optionFunc(func(cfg *config) {
+		if sampler != nil {
+			cfg.Sampler = sampler
+		}
+	})
+}
+
+// WithSampleRatio returns an Option to sample the given fraction of the
+// root spans. Child spans follow the sampling decision of their parent.
+func WithSampleRatio(ratio float64) Option {
+	return WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)))
+}
+
+// WithHeaders returns an Option to send the headers with every export
+// request to the collector, e.g. for authentication.
+func WithHeaders(headers map[string]string) Option {
+	return optionFunc(func(cfg *config) {
+		if cfg.Headers == nil {
+			cfg.Headers = make(map[string]string, len(headers))
+		}
+		for k, v := range headers {
+			cfg.Headers[k] = v
+		}
+	})
+}
+
+// WithLogLevel returns an Option to set the minimum level of the logs written
+// by the global logger. If none is specified, the debug level is used.
+func WithLogLevel(level zapcore.Level) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LogLevel = level
+	})
+}
+
+// WithDisabledSignals returns an Option to skip the initialization of the
+// given signals. Disabled signals keep using the no-op global providers.
+func WithDisabledSignals(signals ...Signal) Option {
+	return optionFunc(func(cfg *config) {
+		if cfg.DisabledSignals == nil {
+			cfg.DisabledSignals = make(map[Signal]bool, len(signals))
+		}
+		for _, s := range signals {
+			cfg.DisabledSignals[s] = true
+		}
+	})
+}
+
+// WithStdoutExporters returns an Option to write the spans and metrics to
+// stdout instead of sending them to the collector. Logs are already written
+// to stdout by the console logger, so the log records are not exported.
+func WithStdoutExporters(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.StdoutExporters = enabled
+	})
+}
+
+// WithTraceTree returns an Option to print every completed trace to w (stdout
+// if nil) as an indented tree of span names, durations, statuses and key
+// attributes, for local development without a tracing backend.
+func WithTraceTree(w io.Writer) Option {
+	return optionFunc(func(cfg *config) {
+		if w == nil {
+			w = os.Stdout
+		}
+		cfg.TraceTreeWriter = w
+	})
+}
+
+// WithStartupProbe returns an Option to wait until the collector is reachable
+// when the telemetry is initialized, failing the initialization if it is not
+// ready before the timeout. Without it, the connection is established lazily.
+func WithStartupProbe(timeout time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.StartupProbeTimeout = timeout
+	})
+}
+
+// WithCircuitBreaker returns an Option to stop exporting after threshold
+// consecutive export failures. While the circuit is open, the exports fail
+// immediately with ErrCircuitOpen and their telemetry is dropped, or buffered
+// with WithDiskBuffer; after the cooldown a single export is attempted to
+// probe whether the collector recovered.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		if threshold > 0 && cooldown > 0 {
+			cfg.BreakerThreshold = threshold
+			cfg.BreakerCooldown = cooldown
+		}
+	})
+}
+
+// WithReadinessThresholds returns an Option to tune when Readiness reports
+// the pipeline as degraded: once the exports of a signal, or the collector
+// connection, have been failing for longer than failureWindow, or once the
+// buffer of the asynchronous log path is more than saturation (a fraction
+// between 0 and 1) full. The defaults are 1 minute and 0.9.
+func WithReadinessThresholds(failureWindow time.Duration, saturation float64) Option {
+	return optionFunc(func(cfg *config) {
+		if failureWindow > 0 {
+			cfg.ReadinessFailureWindow = failureWindow
+		}
+		if saturation > 0 && saturation <= 1 {
+			cfg.ReadinessSaturation = saturation
+		}
+	})
+}
+
+// WithDiskBuffer returns an Option to buffer the spans and log records of
+// the failed exports in dir, up to maxBytes per signal, and to send them in
+// the background once the collector is reachable again. The exports rejected
+// by the circuit breaker are buffered too. When the limit is reached, the
+// oldest buffered telemetry is dropped first. The buffered requests the
+// collector rejects as invalid are dropped when sent.
+func WithDiskBuffer(dir string, maxBytes int64) Option {
+	return optionFunc(func(cfg *config) {
+		if dir != "" && maxBytes > 0 {
+			cfg.DiskBufferDir = dir
+			cfg.DiskBufferMaxBytes = maxBytes
+		}
+	})
+}
+
+// WithShutdownTimeout returns an Option to bound the time spent exporting the
+// remaining telemetry when the telemetry is shut down. If none is specified,
+// the remaining telemetry is exported for up to 10 seconds.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		if timeout > 0 {
+			cfg.ShutdownTimeout = timeout
+		}
+	})
+}
+
+// WithDisabled returns an Option to disable all the telemetry: no-op providers
+// are installed, and the middlewares and log helpers become pass-throughs.
+// The KGSOTEL_DISABLED environment variable has the same effect.
+func WithDisabled(disabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.Disabled = disabled
+	})
+}
+
+// WithLowOverhead returns an Option to trade some detail for speed on the hot
+// path: the log helpers skip the caller resolution and the formatting of the
+// fields, and the middlewares reuse per-route attribute sets, estimate the
+// request size from its Content-Length and drop the high-cardinality
+// attributes from the metrics. The KGSOTEL_LOW_OVERHEAD environment variable
+// has the same effect.
+func WithLowOverhead(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LowOverhead = enabled
+	})
+}
+
+// WithOverheadMeasurement returns an Option to report the cost of the
+// middlewares for each request, i.e. the time spent and the heap allocations
+// made outside of the handlers, as the kgsotel.overhead.duration,
+// kgsotel.overhead.allocations and kgsotel.overhead.allocated_bytes
+// histograms. The allocations are counted for the whole process, so they are
+// only accurate under a low concurrency, e.g. in a load test. The
+// KGSOTEL_MEASURE_OVERHEAD environment variable has the same effect.
+func WithOverheadMeasurement(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.MeasureOverhead = enabled
+	})
+}
+
+// WithTracerProviderOptions returns an Option to pass extra options, e.g.
+// span processors or an ID generator, to the SDK tracer provider.
+func WithTracerProviderOptions(opts ...sdktrace.TracerProviderOption) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.TracerProviderOptions = append(cfg.TracerProviderOptions, opts...)
+	})
+}
+
+// WithMeterProviderOptions returns an Option to pass extra options, e.g.
+// views or readers, to the SDK meter provider.
+func WithMeterProviderOptions(opts ...sdkmetric.Option) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.MeterProviderOptions = append(cfg.MeterProviderOptions, opts...)
+	})
+}
+
+// WithLoggerProviderOptions returns an Option to pass extra options, e.g.
+// log processors, to the SDK logger provider.
+func WithLoggerProviderOptions(opts ...sdklog.LoggerProviderOption) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LoggerProviderOptions = append(cfg.LoggerProviderOptions, opts...)
+	})
+}
+
+// newConfig creates a new config with the given options.
+// The preset of the environment is applied first, so the options can
+// override any of its fields.
+func newConfig(opts ...Option) *config {
+	probe := &config{}
+	for _, opt := range opts {
+		opt.apply(probe)
+	}
+	env := probe.Environment
+	if env == "" {
+		env = Environment(os.Getenv(envEnvironment))
+	}
+
+	cfg := &config{
+		LogLevel:        zapcore.DebugLevel,
+		Environment:     env,
+		ShutdownTimeout: defaultShutdownTimeout,
+		// The modes default to the environment, not to the global switches
+		// set by InitTelemetry, so a pipeline does not inherit another one's.
+		Disabled:        killswitch.DisabledByEnv(),
+		LowOverhead:     lowoverhead.EnabledByEnv(),
+		MeasureOverhead: overhead.EnabledByEnv(),
+	}
+	if preset, ok := presets[env]; ok {
+		preset(cfg)
+	}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	if cfg.TemporalitySelector == nil {
+		cfg.TemporalitySelector = sdkmetric.DefaultTemporalitySelector
+	}
+	if cfg.AggregationSelector == nil {
+		cfg.AggregationSelector = sdkmetric.DefaultAggregationSelector
+	}
+	if cfg.Sampler == nil {
+		cfg.Sampler = sdktrace.AlwaysSample() // We want to see all the spans
+	}
+	if interval, ok := cfg.MetricIntervals[cfg.Environment]; ok {
+		cfg.MetricInterval = interval
+	}
+
+	return cfg
+}
+
+// deltaTemporalitySelector uses delta temporality for monotonic instruments
+// and histograms, and cumulative temporality for UpDownCounters.
+func deltaTemporalitySelector(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindCounter,
+		sdkmetric.InstrumentKindObservableCounter,
+		sdkmetric.InstrumentKindHistogram:
+		return metricdata.DeltaTemporality
+	default:
+		return metricdata.CumulativeTemporality
+	}
+}
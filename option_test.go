@@ -0,0 +1,33 @@
+package kgsotel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetricTemporalityAndAggregation(t *testing.T) {
+	cfg := newConfig()
+	assert.Equal(t, metricdata.CumulativeTemporality, cfg.TemporalitySelector(sdkmetric.InstrumentKindCounter))
+	assert.Equal(t, sdkmetric.DefaultAggregationSelector(sdkmetric.InstrumentKindHistogram), cfg.AggregationSelector(sdkmetric.InstrumentKindHistogram))
+
+	// The UpDownCounters stay cumulative with the delta temporality.
+	cfg = newConfig(WithDeltaTemporality())
+	for kind, want := range map[sdkmetric.InstrumentKind]metricdata.Temporality{
+		sdkmetric.InstrumentKindCounter:           metricdata.DeltaTemporality,
+		sdkmetric.InstrumentKindObservableCounter: metricdata.DeltaTemporality,
+		sdkmetric.InstrumentKindHistogram:         metricdata.DeltaTemporality,
+		sdkmetric.InstrumentKindUpDownCounter:     metricdata.CumulativeTemporality,
+	} {
+		assert.Equal(t, want, cfg.TemporalitySelector(kind), kind)
+	}
+
+	exponential := func(sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+		return sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}
+	}
+	cfg = newConfig(WithAggregationSelector(exponential), WithAggregationSelector(nil), WithTemporalitySelector(nil))
+	assert.Equal(t, exponential(sdkmetric.InstrumentKindHistogram), cfg.AggregationSelector(sdkmetric.InstrumentKindHistogram))
+	assert.NotNil(t, cfg.TemporalitySelector)
+}
@@ -0,0 +1,198 @@
+package oteltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EnvUpdateGolden is the environment variable which makes AssertGoldenSpans
+// write the golden files instead of comparing them.
+const EnvUpdateGolden = "KGSOTEL_UPDATE_GOLDEN"
+
+// scrubbed replaces the values of the scrubbed attributes.
+const scrubbed = "<scrubbed>"
+
+// defaultScrubbedKeys are the attributes changing on every run.
+var defaultScrubbedKeys = []attribute.Key{
+	"traceID",
+	"spanID",
+	"caller",
+	"net.sock.peer.addr",
+	"net.sock.peer.port",
+	"net.host.port",
+	"user_agent.original",
+}
+
+// snapshotSpan is the normalized form of a span.
+type snapshotSpan struct {
+	Name       string          `json:"name"`
+	Trace      string          `json:"trace,omitempty"`
+	Kind       string          `json:"kind"`
+	Scope      string          `json:"scope,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Attributes map[string]any  `json:"attributes,omitempty"`
+	Events     []snapshotEvent `json:"events,omitempty"`
+	Children   []*snapshotSpan `json:"children,omitempty"`
+}
+
+type snapshotEvent struct {
+	Name       string         `json:"name"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// MarshalSpans serializes the spans to a normalized JSON form: the spans are
+// nested under their parent and ordered by name, the timestamps and IDs are
+// removed, and the values of the attributes changing on every run (the
+// default ones, e.g. the peer address, and the scrub ones) are replaced.
+func MarshalSpans(spans []sdktrace.ReadOnlySpan, scrub ...attribute.Key) ([]byte, error) {
+	scrubKeys := make(map[attribute.Key]bool, len(defaultScrubbedKeys)+len(scrub))
+	for _, k := range append(defaultScrubbedKeys, scrub...) {
+		scrubKeys[k] = true
+	}
+
+	ids := make(map[trace.SpanID]bool, len(spans))
+	for _, s := range spans {
+		ids[s.SpanContext().SpanID()] = true
+	}
+	nodes := make(map[trace.SpanID]*snapshotSpan, len(spans))
+	for _, s := range spans {
+		nodes[s.SpanContext().SpanID()] = newSnapshotSpan(s, scrubKeys)
+	}
+	var roots []*snapshotSpan
+	rootTraces := make(map[*snapshotSpan]trace.TraceID)
+	for _, s := range spans {
+		node := nodes[s.SpanContext().SpanID()]
+		if parent := s.Parent(); parent.IsValid() && ids[parent.SpanID()] {
+			nodes[parent.SpanID()].Children = append(nodes[parent.SpanID()].Children, node)
+		} else {
+			roots = append(roots, node)
+			rootTraces[node] = s.SpanContext().TraceID()
+		}
+	}
+
+	// Sort the spans, then number the traces in that order.
+	sortSnapshotSpans(roots)
+	traces := make(map[trace.TraceID]string)
+	for _, root := range roots {
+		id := rootTraces[root]
+		if _, ok := traces[id]; !ok {
+			traces[id] = fmt.Sprintf("trace-%d", len(traces)+1)
+		}
+		root.Trace = traces[id]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(roots); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// AssertGoldenSpans asserts that the normalized form of the spans (see
+// MarshalSpans) matches the golden file. If the KGSOTEL_UPDATE_GOLDEN
+// environment variable is set, the golden file is written instead.
+func AssertGoldenSpans(t TestingT, path string, spans []sdktrace.ReadOnlySpan, scrub ...attribute.Key) bool {
+	t.Helper()
+	got, err := MarshalSpans(spans, scrub...)
+	if err != nil {
+		t.Errorf("marshal spans: %v", err)
+		return false
+	}
+
+	if os.Getenv(EnvUpdateGolden) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Errorf("update golden file: %v", err)
+			return false
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Errorf("update golden file: %v", err)
+			return false
+		}
+		return true
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("golden file %s does not exist, run the test with %s=1 to create it", path, EnvUpdateGolden)
+		return false
+	}
+	if err != nil {
+		t.Errorf("read golden file: %v", err)
+		return false
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("spans do not match the golden file %s\n--- want\n%s\n--- got\n%s", path, want, got)
+		return false
+	}
+	return true
+}
+
+func newSnapshotSpan(s sdktrace.ReadOnlySpan, scrubKeys map[attribute.Key]bool) *snapshotSpan {
+	node := &snapshotSpan{
+		Name:       s.Name(),
+		Kind:       s.SpanKind().String(),
+		Scope:      s.InstrumentationScope().Name,
+		Attributes: snapshotAttributes(s.Attributes(), scrubKeys),
+	}
+	switch st := s.Status(); st.Code {
+	case codes.Error:
+		node.Status = "Error: " + st.Description
+	case codes.Ok:
+		node.Status = "Ok"
+	}
+	for _, e := range s.Events() {
+		node.Events = append(node.Events, snapshotEvent{
+			Name:       e.Name,
+			Attributes: snapshotAttributes(e.Attributes, scrubKeys),
+		})
+	}
+	return node
+}
+
+func snapshotAttributes(attrs []attribute.KeyValue, scrubKeys map[attribute.Key]bool) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(attrs))
+	for _, kv := range attrs {
+		if scrubKeys[kv.Key] {
+			m[string(kv.Key)] = scrubbed
+		} else {
+			m[string(kv.Key)] = kv.Value.AsInterface()
+		}
+	}
+	return m
+}
+
+// sortSnapshotSpans sorts the spans and their children by name, then by
+// their serialized form to break the ties.
+func sortSnapshotSpans(spans []*snapshotSpan) {
+	for _, s := range spans {
+		sortSnapshotSpans(s.Children)
+	}
+	keys := make(map[*snapshotSpan]string, len(spans))
+	for _, s := range spans {
+		b, _ := json.Marshal(s)
+		keys[s] = string(b)
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		if spans[i].Name != spans[j].Name {
+			return spans[i].Name < spans[j].Name
+		}
+		return keys[spans[i]] < keys[spans[j]]
+	})
+}
@@ -0,0 +1,40 @@
+package oteltest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestMarshalSpans(t *testing.T) {
+	r := NewRecorder()
+	tracer := r.TracerProvider.Tracer("test")
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, b := tracer.Start(ctx, "b")
+	b.SetAttributes(attribute.String("token", "secret"))
+	b.End()
+	_, a := tracer.Start(ctx, "a")
+	a.AddEvent("cache miss")
+	a.End()
+	root.End()
+
+	got, err := MarshalSpans(r.Spans(), "token")
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{
+		"name": "root", "trace": "trace-1", "kind": "internal", "scope": "test",
+		"children": [
+			{"name": "a", "kind": "internal", "scope": "test", "events": [{"name": "cache miss"}]},
+			{"name": "b", "kind": "internal", "scope": "test", "attributes": {"token": "<scrubbed>"}}
+		]
+	}]`, string(got))
+}
+
+func TestAssertGoldenSpansMissingFile(t *testing.T) {
+	ft := &fakeT{}
+	assert.False(t, AssertGoldenSpans(ft, filepath.Join(t.TempDir(), "missing.json"), nil))
+	assert.Len(t, ft.errors, 1)
+}
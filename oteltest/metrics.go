@@ -0,0 +1,183 @@
+package oteltest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// TestingT is the subset of testing.TB used by the assertions.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Collector collects the recorded metrics, e.g. a sdkmetric.ManualReader or
+// a Recorder.
+type Collector interface {
+	Collect(ctx context.Context, rm *metricdata.ResourceMetrics) error
+}
+
+// Collect collects the metrics recorded so far.
+func (r *Recorder) Collect(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	return r.reader.Collect(ctx, rm)
+}
+
+// AssertSumValue asserts that the data points of the counter (or up-down
+// counter) with the attributes add up to the value. The data points may have
+// more attributes than the given ones, e.g. the requests of a route are
+// counted across their statuses.
+func AssertSumValue[N int64 | float64](t TestingT, c Collector, name string, attrs []attribute.KeyValue, want N) bool {
+	t.Helper()
+	data, err := findData(c, name)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	sum, ok := data.(metricdata.Sum[N])
+	if !ok {
+		t.Errorf("metric %s is a %T, not a %T", name, data, sum)
+		return false
+	}
+	return assertValue(t, name, sum.DataPoints, attrs, want, true)
+}
+
+// AssertGaugeValue asserts that the data point of the gauge with the
+// attributes has the value. The data point may have more attributes than the
+// given ones, but the gauges do not add up, so the assertion fails when
+// several data points have them.
+func AssertGaugeValue[N int64 | float64](t TestingT, c Collector, name string, attrs []attribute.KeyValue, want N) bool {
+	t.Helper()
+	data, err := findData(c, name)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	gauge, ok := data.(metricdata.Gauge[N])
+	if !ok {
+		t.Errorf("metric %s is a %T, not a %T", name, data, gauge)
+		return false
+	}
+	return assertValue(t, name, gauge.DataPoints, attrs, want, false)
+}
+
+// AssertHistogramCount asserts that the data points of the histogram with the
+// attributes count the number of measurements in total. The data points may
+// have more attributes than the given ones.
+func AssertHistogramCount(t TestingT, c Collector, name string, attrs []attribute.KeyValue, want uint64) bool {
+	t.Helper()
+	data, err := findData(c, name)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+
+	var count uint64
+	var found bool
+	switch h := data.(type) {
+	case metricdata.Histogram[int64]:
+		count, found = histogramCount(h.DataPoints, attrs)
+	case metricdata.Histogram[float64]:
+		count, found = histogramCount(h.DataPoints, attrs)
+	default:
+		t.Errorf("metric %s is a %T, not a histogram", name, data)
+		return false
+	}
+	if !found {
+		t.Errorf("metric %s has no data point with the attributes %s", name, formatAttributes(attrs))
+		return false
+	}
+	if count != want {
+		t.Errorf("metric %s counts %d measurements, want %d", name, count, want)
+		return false
+	}
+	return true
+}
+
+// AssertNoMetric asserts that nothing was recorded by the instrument.
+func AssertNoMetric(t TestingT, c Collector, name string) bool {
+	t.Helper()
+	if _, err := findData(c, name); err == nil {
+		t.Errorf("metric %s was recorded", name)
+		return false
+	}
+	return true
+}
+
+// assertValue asserts on the sum of the values of the data points with the
+// attributes, or on the value of the only one unless additive.
+func assertValue[N int64 | float64](t TestingT, name string, dps []metricdata.DataPoint[N], attrs []attribute.KeyValue, want N, additive bool) bool {
+	t.Helper()
+	var value N
+	var matched int
+	for _, dp := range dps {
+		if hasAttributes(dp.Attributes, attrs) {
+			value += dp.Value
+			matched++
+		}
+	}
+	switch {
+	case matched == 0:
+		t.Errorf("metric %s has no data point with the attributes %s", name, formatAttributes(attrs))
+		return false
+	case matched > 1 && !additive:
+		t.Errorf("metric %s has %d data points with the attributes %s", name, matched, formatAttributes(attrs))
+		return false
+	case value != want:
+		t.Errorf("metric %s is %v, want %v", name, value, want)
+		return false
+	}
+	return true
+}
+
+// histogramCount returns the total count of the data points with the
+// attributes.
+func histogramCount[N int64 | float64](dps []metricdata.HistogramDataPoint[N], attrs []attribute.KeyValue) (uint64, bool) {
+	var count uint64
+	var found bool
+	for _, dp := range dps {
+		if hasAttributes(dp.Attributes, attrs) {
+			count += dp.Count
+			found = true
+		}
+	}
+	return count, found
+}
+
+// hasAttributes reports whether the set contains all the attributes.
+func hasAttributes(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if v, ok := set.Value(kv.Key); !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// formatAttributes formats the attributes as key=value pairs.
+func formatAttributes(attrs []attribute.KeyValue) string {
+	s := make([]string, 0, len(attrs))
+	for _, kv := range attrs {
+		s = append(s, string(kv.Key)+"="+kv.Value.Emit())
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// findData collects the metrics and returns the data of the named instrument.
+func findData(c Collector, name string) (metricdata.Aggregation, error) {
+	var rm metricdata.ResourceMetrics
+	if err := c.Collect(context.Background(), &rm); err != nil {
+		return nil, fmt.Errorf("collect metrics: %w", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m.Data, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("metric %s not found", name)
+}
@@ -0,0 +1,61 @@
+package oteltest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// fakeT records the errors of the assertions.
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	ctx := context.Background()
+	r := NewRecorder()
+	meter := r.MeterProvider.Meter("test")
+	counter, _ := meter.Int64Counter("requests")
+	hist, _ := meter.Float64Histogram("duration")
+	route := attribute.String("route", "/users")
+	counter.Add(ctx, 2, metric.WithAttributes(route, attribute.Int("status", 200)))
+	hist.Record(ctx, 1.5, metric.WithAttributes(route))
+	hist.Record(ctx, 2.5, metric.WithAttributes(route))
+	counter.Add(ctx, 1, metric.WithAttributes(route, attribute.Int("status", 500)))
+	hist.Record(ctx, 3.5, metric.WithAttributes(route, attribute.Int("status", 500)))
+	gauge, _ := meter.Int64Gauge("connections")
+	gauge.Record(ctx, 4, metric.WithAttributes(route, attribute.String("pool", "a")))
+	gauge.Record(ctx, 6, metric.WithAttributes(route, attribute.String("pool", "b")))
+
+	// The data points with the attributes add up.
+	assert.True(t, AssertSumValue(t, r, "requests", []attribute.KeyValue{route}, int64(3)))
+	assert.True(t, AssertSumValue(t, r, "requests", []attribute.KeyValue{attribute.Int("status", 200)}, int64(2)))
+	assert.True(t, AssertHistogramCount(t, r, "duration", []attribute.KeyValue{route}, 3))
+	assert.True(t, AssertHistogramCount(t, r, "duration", []attribute.KeyValue{attribute.Int("status", 500)}, 1))
+	assert.True(t, AssertGaugeValue(t, r, "connections", []attribute.KeyValue{attribute.String("pool", "b")}, int64(6)))
+	assert.True(t, AssertNoMetric(t, r, "errors"))
+
+	ft := &fakeT{}
+	assert.False(t, AssertSumValue(ft, r, "requests", nil, int64(2)))
+	assert.False(t, AssertSumValue(ft, r, "requests", nil, 2.0))
+	assert.False(t, AssertHistogramCount(ft, r, "duration", []attribute.KeyValue{attribute.String("route", "/")}, 2))
+	assert.False(t, AssertGaugeValue(ft, r, "missing", nil, int64(1)))
+	assert.False(t, AssertGaugeValue(ft, r, "connections", []attribute.KeyValue{route}, int64(10)))
+	assert.Equal(t, []string{
+		"metric requests is 3, want 2",
+		"metric requests is a metricdata.Sum[int64], not a metricdata.Sum[float64]",
+		"metric duration has no data point with the attributes [route=/]",
+		"metric missing not found",
+		"metric connections has 2 data points with the attributes [route=/users]",
+	}, ft.errors)
+}
@@ -0,0 +1,75 @@
+// Package oteltest records the telemetry produced by the instrumentations
+// in memory, so tests can assert on the spans and metrics.
+package oteltest
+
+import (
+	"context"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Recorder holds a tracer provider and a meter provider recording in memory.
+type Recorder struct {
+	TracerProvider *sdktrace.TracerProvider
+	MeterProvider  *sdkmetric.MeterProvider
+
+	spans  *tracetest.SpanRecorder
+	reader *sdkmetric.ManualReader
+}
+
+// NewRecorder returns a Recorder sampling every span.
+func NewRecorder() *Recorder {
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	return &Recorder{
+		TracerProvider: sdktrace.NewTracerProvider(
+			sdktrace.WithSampler(sdktrace.AlwaysSample()),
+			sdktrace.WithSpanProcessor(spans),
+		),
+		MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		spans:         spans,
+		reader:        reader,
+	}
+}
+
+// Spans returns the ended spans, in the order they ended.
+func (r *Recorder) Spans() []sdktrace.ReadOnlySpan {
+	return r.spans.Ended()
+}
+
+// Span returns the first ended span with the given name.
+func (r *Recorder) Span(name string) (sdktrace.ReadOnlySpan, bool) {
+	for _, s := range r.spans.Ended() {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Metrics collects the metrics recorded so far.
+func (r *Recorder) Metrics(ctx context.Context) (metricdata.ResourceMetrics, error) {
+	var rm metricdata.ResourceMetrics
+	err := r.reader.Collect(ctx, &rm)
+	return rm, err
+}
+
+// Metric collects the metrics recorded so far and returns the one with the
+// given name.
+func (r *Recorder) Metric(ctx context.Context, name string) (metricdata.Metrics, bool) {
+	rm, err := r.Metrics(ctx)
+	if err != nil {
+		return metricdata.Metrics{}, false
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
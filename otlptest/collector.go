@@ -0,0 +1,306 @@
+// Package otlptest runs an in-process OTLP gRPC receiver capturing the
+// export requests, to test the telemetry pipelines end-to-end.
+package otlptest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	logpb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // Accept the gzip compressed exports.
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/protobuf/proto"
+)
+
+// Signal names the signal of an export request.
+type Signal string
+
+const (
+	SignalTraces  Signal = "traces"
+	SignalMetrics Signal = "metrics"
+	SignalLogs    Signal = "logs"
+)
+
+// Export is an export request received by the collector.
+type Export struct {
+	Signal Signal
+	// Metadata holds the headers of the request.
+	Metadata metadata.MD
+	// Compression is the compression of the request, empty if uncompressed.
+	Compression string
+	// Request is a *ExportTraceServiceRequest, *ExportMetricsServiceRequest
+	// or *ExportLogsServiceRequest.
+	Request proto.Message
+}
+
+// Option configures a Collector.
+type Option interface {
+	apply(*config)
+}
+
+type config struct {
+	TLS        *tls.Config
+	UnixSocket string
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithTLS returns an Option to serve with TLS.
+func WithTLS(cfg *tls.Config) Option {
+	return optionFunc(func(c *config) {
+		c.TLS = cfg
+	})
+}
+
+// WithUnixSocket returns an Option to listen on a Unix socket at path
+// instead of a local port.
+func WithUnixSocket(path string) Option {
+	return optionFunc(func(c *config) {
+		c.UnixSocket = path
+	})
+}
+
+// Collector is a fake OTLP collector listening on a local port.
+type Collector struct {
+	// Addr is the endpoint the collector listens on: host:port, or
+	// unix://path with WithUnixSocket.
+	Addr string
+
+	server *grpc.Server
+	notify chan struct{}
+
+	mu      sync.Mutex
+	exports []Export
+	err     error
+}
+
+// NewCollector starts a collector listening on a random local port. Call
+// Close to stop it.
+func NewCollector(opts ...Option) (*Collector, error) {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+
+	network, address := "tcp", "127.0.0.1:0"
+	if cfg.UnixSocket != "" {
+		network, address = "unix", cfg.UnixSocket
+	}
+	lis, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	addr := lis.Addr().String()
+	if network == "unix" {
+		addr = "unix://" + addr
+	}
+
+	serverOpts := []grpc.ServerOption{grpc.StatsHandler(compressionHandler{})}
+	if cfg.TLS != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(cfg.TLS)))
+	}
+	c := &Collector{
+		Addr:   addr,
+		server: grpc.NewServer(serverOpts...),
+		notify: make(chan struct{}, 1),
+	}
+	coltracepb.RegisterTraceServiceServer(c.server, traceService{c: c})
+	colmetricpb.RegisterMetricsServiceServer(c.server, metricsService{c: c})
+	collogspb.RegisterLogsServiceServer(c.server, logsService{c: c})
+
+	go func() { _ = c.server.Serve(lis) }()
+	return c, nil
+}
+
+// Close stops the collector.
+func (c *Collector) Close() {
+	c.server.Stop()
+}
+
+// SetError makes the next export requests fail with err, until it is reset with nil.
+func (c *Collector) SetError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+}
+
+// Exports returns the export requests received so far.
+func (c *Collector) Exports() []Export {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Export(nil), c.exports...)
+}
+
+// Reset forgets the export requests received so far.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.exports = nil
+}
+
+// Spans returns the spans received so far.
+func (c *Collector) Spans() []*tracepb.Span {
+	var spans []*tracepb.Span
+	for _, e := range c.Exports() {
+		if req, ok := e.Request.(*coltracepb.ExportTraceServiceRequest); ok {
+			for _, rs := range req.GetResourceSpans() {
+				for _, ss := range rs.GetScopeSpans() {
+					spans = append(spans, ss.GetSpans()...)
+				}
+			}
+		}
+	}
+	return spans
+}
+
+// Metrics returns the metrics received so far.
+func (c *Collector) Metrics() []*metricpb.Metric {
+	var metrics []*metricpb.Metric
+	for _, e := range c.Exports() {
+		if req, ok := e.Request.(*colmetricpb.ExportMetricsServiceRequest); ok {
+			for _, rm := range req.GetResourceMetrics() {
+				for _, sm := range rm.GetScopeMetrics() {
+					metrics = append(metrics, sm.GetMetrics()...)
+				}
+			}
+		}
+	}
+	return metrics
+}
+
+// LogRecords returns the log records received so far.
+func (c *Collector) LogRecords() []*logpb.LogRecord {
+	var records []*logpb.LogRecord
+	for _, e := range c.Exports() {
+		if req, ok := e.Request.(*collogspb.ExportLogsServiceRequest); ok {
+			for _, rl := range req.GetResourceLogs() {
+				for _, sl := range rl.GetScopeLogs() {
+					records = append(records, sl.GetLogRecords()...)
+				}
+			}
+		}
+	}
+	return records
+}
+
+// WaitForExport blocks until an export request of the signal is received or
+// the context is done.
+func (c *Collector) WaitForExport(ctx context.Context, signal Signal) error {
+	for {
+		for _, e := range c.Exports() {
+			if e.Signal == signal {
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.notify:
+		}
+	}
+}
+
+// record stores the export request, or returns the error set with SetError.
+func (c *Collector) record(ctx context.Context, signal Signal, req proto.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var compression string
+	if info, _ := ctx.Value(compressionKey{}).(*compressionInfo); info != nil {
+		compression = info.name
+	}
+	c.exports = append(c.exports, Export{
+		Signal:      signal,
+		Metadata:    md,
+		Compression: compression,
+		Request:     req,
+	})
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+type traceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	c *Collector
+}
+
+func (s traceService) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	if err := s.c.record(ctx, SignalTraces, req); err != nil {
+		return nil, err
+	}
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+type metricsService struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	c *Collector
+}
+
+func (s metricsService) Export(ctx context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	if err := s.c.record(ctx, SignalMetrics, req); err != nil {
+		return nil, err
+	}
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+type logsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	c *Collector
+}
+
+func (s logsService) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	if err := s.c.record(ctx, SignalLogs, req); err != nil {
+		return nil, err
+	}
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+// compressionKey is the context key of the compression of a request.
+type compressionKey struct{}
+
+type compressionInfo struct {
+	name string
+}
+
+// compressionHandler records the compression of the incoming requests, which
+// is only available to the stats handlers.
+type compressionHandler struct{}
+
+func (compressionHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, compressionKey{}, &compressionInfo{})
+}
+
+func (compressionHandler) HandleRPC(ctx context.Context, rs stats.RPCStats) {
+	if h, ok := rs.(*stats.InHeader); ok {
+		if info, _ := ctx.Value(compressionKey{}).(*compressionInfo); info != nil {
+			info.name = h.Compression
+		}
+	}
+}
+
+func (compressionHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (compressionHandler) HandleConn(context.Context, stats.ConnStats) {}
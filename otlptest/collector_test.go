@@ -0,0 +1,50 @@
+package otlptest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestCollector(t *testing.T) {
+	c, err := NewCollector()
+	require.NoError(t, err)
+	defer c.Close()
+
+	conn, err := grpc.NewClient(c.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := coltracepb.NewTraceServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+		ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{Name: "op"}}}},
+	}}}
+	_, err = client.Export(metadata.AppendToOutgoingContext(ctx, "api-key", "secret"), req, grpc.UseCompressor(gzip.Name))
+	require.NoError(t, err)
+	require.NoError(t, c.WaitForExport(ctx, SignalTraces))
+
+	exports := c.Exports()
+	require.Len(t, exports, 1)
+	assert.Equal(t, []string{"secret"}, exports[0].Metadata.Get("api-key"))
+	assert.Equal(t, "gzip", exports[0].Compression)
+	require.Len(t, c.Spans(), 1)
+	assert.Equal(t, "op", c.Spans()[0].GetName())
+
+	c.SetError(status.Error(codes.Unavailable, "down"))
+	_, err = client.Export(ctx, req)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Len(t, c.Exports(), 1)
+}
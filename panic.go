@@ -0,0 +1,75 @@
+package kgsotel
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"kgs/otel/internal/killswitch"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// RecoverAndFlush, deferred at the top of main or of a goroutine, records a
+// panic on the span of ctx and ends it, logs it with its stack, reports it to
+// the error reporter, and flushes the traces, metrics and logs of the global
+// pipeline within the shutdown timeout before panicking again, so the crash
+// telemetry reaches the backend before the process dies.
+//
+//	ctx, span := kgsotel.StartTrace(ctx)
+//	defer span.End()
+//	defer kgsotel.RecoverAndFlush(ctx)
+func RecoverAndFlush(ctx context.Context) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	handlePanic(ctx, r, debug.Stack())
+	panic(r)
+}
+
+// handlePanic records the recovered value v and flushes the telemetry.
+func handlePanic(ctx context.Context, v any, stack []byte) {
+	if killswitch.Disabled() {
+		return
+	}
+	message := fmt.Sprintf("panic: %v", v)
+	err, ok := v.(error)
+	if !ok {
+		err = fmt.Errorf("%v", v)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err, trace.WithAttributes(semconv.ExceptionStacktrace(string(stack))))
+	span.SetStatus(codes.Error, message)
+	// The span is ended now, or it would be exported after the flush.
+	span.End()
+
+	fields := []zap.Field{zap.Error(err), zap.String("stacktrace", string(stack))}
+	if sc := span.SpanContext(); sc.IsValid() {
+		fields = append(fields, zap.String("traceID", sc.TraceID().String()), zap.String("spanID", sc.SpanID().String()))
+	}
+	loggerFor(ctx).Error(message, fields...)
+	reportError(ctx, span, message, []Field{NewFiled("error", err)})
+
+	flushGlobal(ctx)
+}
+
+// flushGlobal exports the telemetry of the global pipeline and flushes its
+// error reporter, within the shutdown timeout.
+func flushGlobal(ctx context.Context) {
+	t := Global()
+	if t == nil {
+		return
+	}
+	// The context of a failing request may already be canceled.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), t.cfg.ShutdownTimeout)
+	defer cancel()
+	_ = t.Flush(ctx)
+	if f, ok := t.cfg.ErrorReporter.(interface{ Flush(context.Context) error }); ok {
+		_ = f.Flush(ctx)
+	}
+}
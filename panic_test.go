@@ -0,0 +1,57 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRecoverAndFlush(t *testing.T) {
+	restoreGlobals(t)
+	core, logs := observer.New(zap.ErrorLevel)
+	zap.ReplaceGlobals(zap.New(core))
+
+	ctx := context.Background()
+	reporter := &flushingReporter{}
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Options:     []Option{WithStdoutExporters(true), WithErrorReporter(reporter)},
+	})
+	require.NoError(t, err)
+	defer tel.Shutdown(ctx)
+	current.Store(tel)
+	defer current.Store(nil)
+
+	recorder := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(ctx, "job")
+	assert.PanicsWithValue(t, "boom", func() {
+		defer span.End()
+		defer RecoverAndFlush(ctx)
+		panic("boom")
+	})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, "exception", spans[0].Events()[0].Name)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "panic: boom", entries[0].Message)
+	assert.Contains(t, entries[0].ContextMap()["stacktrace"], "TestRecoverAndFlush")
+	assert.True(t, reporter.flushed)
+}
+
+func TestRecoverAndFlushNoPanic(t *testing.T) {
+	assert.NotPanics(t, func() {
+		defer RecoverAndFlush(context.Background())
+	})
+}
@@ -0,0 +1,143 @@
+package kgsotel
+
+import (
+	"context"
+	"regexp"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// piiRedacted replaces the personal data and the secrets in the logs.
+const piiRedacted = "[REDACTED]"
+
+// piiPatterns are the built-in patterns of the PII scrubbing.
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	tokenPattern = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*` +
+		`|\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+` +
+		`|\b(?:sk|pk|rk|ghp|gho|ghs|xox[abpr])[-_][A-Za-z0-9_-]{16,}`)
+	cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// WithPIIScrubbing returns an Option to redact the email addresses, the
+// bearer, JWT and API tokens and the card numbers in the bodies and the
+// attributes of the exported log records, and the matches of the extra
+// patterns. It only applies to the logs, not to the spans nor the stdout
+// logs.
+func WithPIIScrubbing(extra ...*regexp.Regexp) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.PIIScrubbing = true
+		cfg.PIIPatterns = append(cfg.PIIPatterns, extra...)
+	})
+}
+
+// piiProcessor redacts the log records before they are batched.
+type piiProcessor struct {
+	passthroughProcessor
+	patterns []*regexp.Regexp
+}
+
+var _ sdklog.Processor = (*piiProcessor)(nil)
+
+func newPIIProcessor(extra []*regexp.Regexp) *piiProcessor {
+	return &piiProcessor{patterns: append([]*regexp.Regexp{emailPattern, tokenPattern}, extra...)}
+}
+
+// OnEmit redacts the body and the attributes of the record.
+func (p *piiProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	if body, changed := p.scrubValue(r.Body()); changed {
+		r.SetBody(body)
+	}
+
+	attrs := make([]log.KeyValue, 0, r.AttributesLen())
+	changed := false
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		v, c := p.scrubValue(kv.Value)
+		changed = changed || c
+		attrs = append(attrs, log.KeyValue{Key: kv.Key, Value: v})
+		return true
+	})
+	if changed {
+		r.SetAttributes(attrs...)
+	}
+	return nil
+}
+
+// scrubValue returns v redacted, and whether it changed.
+func (p *piiProcessor) scrubValue(v log.Value) (log.Value, bool) {
+	switch v.Kind() {
+	case log.KindString:
+		s, changed := p.scrub(v.AsString())
+		if !changed {
+			return v, false
+		}
+		return log.StringValue(s), true
+	case log.KindSlice:
+		values := v.AsSlice()
+		out := make([]log.Value, len(values))
+		changed := false
+		for i, e := range values {
+			var c bool
+			out[i], c = p.scrubValue(e)
+			changed = changed || c
+		}
+		if !changed {
+			return v, false
+		}
+		return log.SliceValue(out...), true
+	case log.KindMap:
+		kvs := v.AsMap()
+		out := make([]log.KeyValue, len(kvs))
+		changed := false
+		for i, kv := range kvs {
+			var c bool
+			out[i] = log.KeyValue{Key: kv.Key}
+			out[i].Value, c = p.scrubValue(kv.Value)
+			changed = changed || c
+		}
+		if !changed {
+			return v, false
+		}
+		return log.MapValue(out...), true
+	default:
+		return v, false
+	}
+}
+
+// scrub returns s with the matches of the patterns and the card numbers
+// redacted, and whether it changed.
+func (p *piiProcessor) scrub(s string) (string, bool) {
+	out := s
+	for _, re := range p.patterns {
+		out = re.ReplaceAllString(out, piiRedacted)
+	}
+	out = cardPattern.ReplaceAllStringFunc(out, func(m string) string {
+		if luhnValid(m) {
+			return piiRedacted
+		}
+		return m
+	})
+	return out, out != s
+}
+
+// luhnValid reports whether the digits of s pass the Luhn check of the card
+// numbers, so the other long numbers, e.g. timestamps, are kept.
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,64 @@
+package kgsotel
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// emitScrubbed emits the record through a PII processor with the patterns.
+func emitScrubbed(t *testing.T, record log.Record, extra ...*regexp.Regexp) sdklog.Record {
+	rec := &recordingProcessor{}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(newPIIProcessor(newConfig(WithPIIScrubbing(extra...)).PIIPatterns)),
+		sdklog.WithProcessor(rec),
+	)
+	lp.Logger("test").Emit(context.Background(), record)
+	require.Len(t, rec.records, 1)
+	return rec.records[0]
+}
+
+func TestPIIProcessor(t *testing.T) {
+	var record log.Record
+	record.SetBody(log.StringValue("mail sent to jane.doe@example.com"))
+	record.AddAttributes(
+		log.String("auth", "Bearer abc.DEF-123"),
+		log.String("card", "4111 1111 1111 1111"),
+		log.String("order.id", "1234567890123"),
+		log.Int("amount", 7),
+		log.Map("user", log.String("email", "john@example.org"), log.String("name", "John")),
+		log.Slice("tokens", log.StringValue("sk_live_abcdefghijklmnop1234")),
+	)
+	r := emitScrubbed(t, record)
+
+	assert.Equal(t, "mail sent to [REDACTED]", r.Body().AsString())
+	attrs := map[string]log.Value{}
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	assert.Equal(t, "[REDACTED]", attrs["auth"].AsString())
+	assert.Equal(t, "[REDACTED]", attrs["card"].AsString())
+	assert.Equal(t, "1234567890123", attrs["order.id"].AsString())
+	assert.Equal(t, int64(7), attrs["amount"].AsInt64())
+	assert.Equal(t, []log.KeyValue{log.String("email", "[REDACTED]"), log.String("name", "John")}, attrs["user"].AsMap())
+	assert.Equal(t, []log.Value{log.StringValue("[REDACTED]")}, attrs["tokens"].AsSlice())
+}
+
+func TestPIIProcessorExtraPatterns(t *testing.T) {
+	var record log.Record
+	record.SetBody(log.StringValue("customer FR7630006000011234567890189 paid"))
+	r := emitScrubbed(t, record, regexp.MustCompile(`\bFR\d{25}\b`))
+	assert.Equal(t, "customer [REDACTED] paid", r.Body().AsString())
+}
+
+func TestLuhnValid(t *testing.T) {
+	assert.True(t, luhnValid("4111-1111-1111-1111"))
+	assert.True(t, luhnValid("5500 0000 0000 0004"))
+	assert.False(t, luhnValid("4111 1111 1111 1112"))
+}
@@ -0,0 +1,293 @@
+package kgsotel
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// The metrics of the telemetry pipeline itself, tagged by SignalKey. The
+// batch processors are fed up to the size of their queue, the spans and log
+// records past it are counted as dropped instead of being lost silently, so
+// the queued items are exported, dropped or still pending.
+const (
+	// PipelineQueuedMetric counts the spans and log records handed to the pipeline.
+	PipelineQueuedMetric = "kgsotel.pipeline.queued"
+	// PipelineExportedMetric counts the spans and log records exported.
+	PipelineExportedMetric = "kgsotel.pipeline.exported"
+	// PipelineDroppedMetric counts the spans and log records dropped by a
+	// failed export, a full batch processor queue or a full asynchronous log
+	// buffer.
+	PipelineDroppedMetric = "kgsotel.pipeline.dropped"
+	// PipelineExportDurationMetric measures the duration of the exports.
+	PipelineExportDurationMetric = "kgsotel.pipeline.export.duration"
+	// PipelineExportErrorsMetric counts the failed exports.
+	PipelineExportErrorsMetric = "kgsotel.pipeline.export.errors"
+)
+
+// SignalKey is the attribute of the pipeline metrics naming the signal.
+const SignalKey = attribute.Key("kgsotel.signal")
+
+// pipelineMetrics are the instruments of the pipeline metrics.
+type pipelineMetrics struct {
+	queued   metric.Int64Counter
+	exported metric.Int64Counter
+	dropped  metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+// noopPipelineMetrics is used until the meter provider is created.
+var noopPipelineMetrics = &pipelineMetrics{
+	queued:   noop.Int64Counter{},
+	exported: noop.Int64Counter{},
+	dropped:  noop.Int64Counter{},
+	errors:   noop.Int64Counter{},
+	duration: noop.Float64Histogram{},
+}
+
+func newPipelineMetrics(mp metric.MeterProvider) *pipelineMetrics {
+	meter := mp.Meter(modulePath)
+	m := &pipelineMetrics{}
+
+	var err error
+	counters := []struct {
+		counter     *metric.Int64Counter
+		name        string
+		description string
+		unit        string
+	}{
+		{&m.queued, PipelineQueuedMetric, "Counts the spans and log records handed to the telemetry pipeline.", "{item}"},
+		{&m.exported, PipelineExportedMetric, "Counts the spans and log records exported.", "{item}"},
+		{&m.dropped, PipelineDroppedMetric, "Counts the spans and log records dropped by the telemetry pipeline.", "{item}"},
+		{&m.errors, PipelineExportErrorsMetric, "Counts the failed exports.", "{error}"},
+	}
+	for _, c := range counters {
+		*c.counter, err = meter.Int64Counter(c.name, metric.WithDescription(c.description), metric.WithUnit(c.unit))
+		if err != nil {
+			otel.Handle(err)
+			if *c.counter == nil {
+				*c.counter = noop.Int64Counter{}
+			}
+		}
+	}
+
+	m.duration, err = meter.Float64Histogram(PipelineExportDurationMetric,
+		metric.WithDescription("Measures the duration of the exports."),
+		metric.WithUnit("ms"))
+	if err != nil {
+		otel.Handle(err)
+		if m.duration == nil {
+			m.duration = noop.Float64Histogram{}
+		}
+	}
+	return m
+}
+
+// instruments returns the instruments of the pipeline metrics.
+func (s *pipelineStatus) instruments() *pipelineMetrics {
+	if m := s.metrics.Load(); m != nil {
+		return m
+	}
+	return noopPipelineMetrics
+}
+
+// signalAttrs returns the measurement option tagging the signal.
+func signalAttrs(signal Signal) metric.MeasurementOption {
+	return metric.WithAttributes(SignalKey.String(string(signal)))
+}
+
+// recordQueued counts n items of the signal handed to the pipeline.
+func (s *pipelineStatus) recordQueued(ctx context.Context, signal Signal, n int) {
+	s.instruments().queued.Add(ctx, int64(n), signalAttrs(signal))
+}
+
+// recordExport records the duration and result of an export of n items.
+func (s *pipelineStatus) recordExport(ctx context.Context, signal Signal, n int, durationMs float64, err error) {
+	m := s.instruments()
+	attrs := signalAttrs(signal)
+	m.duration.Record(ctx, durationMs, attrs)
+	if err != nil {
+		m.errors.Add(ctx, 1, attrs)
+		return
+	}
+	if n > 0 {
+		m.exported.Add(ctx, int64(n), attrs)
+	}
+}
+
+// recordDropped counts n items of the signal dropped by the pipeline.
+func (s *pipelineStatus) recordDropped(signal Signal, n int) {
+	switch signal {
+	case SignalTraces:
+		s.droppedSpans.Add(int64(n))
+	case SignalLogs:
+		s.droppedLogs.Add(int64(n))
+	}
+	if n > 0 {
+		s.instruments().dropped.Add(context.Background(), int64(n), signalAttrs(signal))
+	}
+}
+
+// queuedSpanCounter counts the sampled spans handed to the batch processor.
+type queuedSpanCounter struct {
+	status *pipelineStatus
+}
+
+var _ sdktrace.SpanProcessor = queuedSpanCounter{}
+
+func (queuedSpanCounter) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd counts the span if it is exported.
+func (c queuedSpanCounter) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		c.status.recordQueued(context.Background(), SignalTraces, 1)
+	}
+}
+
+func (queuedSpanCounter) Shutdown(context.Context) error   { return nil }
+func (queuedSpanCounter) ForceFlush(context.Context) error { return nil }
+
+// queuedLogCounter counts the log records handed to the processors.
+type queuedLogCounter struct {
+	status *pipelineStatus
+}
+
+var _ sdklog.Processor = queuedLogCounter{}
+
+// OnEmit counts the record.
+func (c queuedLogCounter) OnEmit(ctx context.Context, _ *sdklog.Record) error {
+	c.status.recordQueued(ctx, SignalLogs, 1)
+	return nil
+}
+
+func (queuedLogCounter) Shutdown(context.Context) error   { return nil }
+func (queuedLogCounter) ForceFlush(context.Context) error { return nil }
+
+// batchQueueSize is the size of the queue of the batch processors.
+const batchQueueSize = 2048
+
+// batchGate bounds the items waiting in a batch processor to the size of its
+// queue, which drops the items past it without a trace: the gate drops and
+// counts them instead. The items handed to the exporter leave the queue.
+// When the processor is shut down, the items still queued or being exported
+// are counted as dropped, as the connection is closed next.
+type batchGate struct {
+	signal    Signal
+	size      int64
+	status    *pipelineStatus
+	pending   atomic.Int64
+	exporting atomic.Int64
+	closed    atomic.Bool
+}
+
+func newBatchGate(signal Signal, status *pipelineStatus) *batchGate {
+	return &batchGate{signal: signal, size: batchQueueSize, status: status}
+}
+
+// admit reports whether an item fits in the queue, or counts it as dropped.
+func (g *batchGate) admit() bool {
+	if g.closed.Load() {
+		g.status.recordDropped(g.signal, 1)
+		return false
+	}
+	if g.pending.Add(1) > g.size {
+		g.pending.Add(-1)
+		g.status.recordDropped(g.signal, 1)
+		return false
+	}
+	return true
+}
+
+// export removes the n items handed to the exporter from the queue while
+// they are exported.
+func (g *batchGate) export(n int, fn func() error) error {
+	g.pending.Add(-int64(n))
+	g.exporting.Add(int64(n))
+	defer g.exporting.Add(-int64(n))
+	return fn()
+}
+
+// close counts the items left in the queue or being exported as dropped, and
+// drops the items arriving afterwards.
+func (g *batchGate) close() {
+	if g.closed.Swap(true) {
+		return
+	}
+	if n := g.pending.Swap(0) + g.exporting.Load(); n > 0 {
+		g.status.recordDropped(g.signal, int(n))
+	}
+}
+
+// gatedSpanProcessor hands the sampled spans admitted by its gate to the
+// batch span processor.
+type gatedSpanProcessor struct {
+	sdktrace.SpanProcessor
+	gate *batchGate
+}
+
+// OnEnd hands the span over if it is not exported or fits in the queue.
+func (p gatedSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() || p.gate.admit() {
+		p.SpanProcessor.OnEnd(s)
+	}
+}
+
+// Shutdown shuts the batch processor down, then closes the gate.
+func (p gatedSpanProcessor) Shutdown(ctx context.Context) error {
+	err := p.SpanProcessor.Shutdown(ctx)
+	p.gate.close()
+	return err
+}
+
+// gatedSpanExporter releases the exported spans from the gate.
+type gatedSpanExporter struct {
+	sdktrace.SpanExporter
+	gate *batchGate
+}
+
+func (e gatedSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	return e.gate.export(len(spans), func() error {
+		return e.SpanExporter.ExportSpans(ctx, spans)
+	})
+}
+
+// gatedLogProcessor hands the log records admitted by its gate to the batch
+// processor.
+type gatedLogProcessor struct {
+	sdklog.Processor
+	gate *batchGate
+}
+
+// OnEmit hands the record over if it fits in the queue.
+func (p gatedLogProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if !p.gate.admit() {
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, r)
+}
+
+// Shutdown shuts the batch processor down, then closes the gate.
+func (p gatedLogProcessor) Shutdown(ctx context.Context) error {
+	err := p.Processor.Shutdown(ctx)
+	p.gate.close()
+	return err
+}
+
+// gatedLogExporter releases the exported log records from the gate.
+type gatedLogExporter struct {
+	sdklog.Exporter
+	gate *batchGate
+}
+
+func (e gatedLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	return e.gate.export(len(records), func() error {
+		return e.Exporter.Export(ctx, records)
+	})
+}
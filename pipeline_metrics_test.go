@@ -0,0 +1,140 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// flakySpanExporter fails the exports while err is set.
+type flakySpanExporter struct {
+	tracetest.InMemoryExporter
+	err error
+}
+
+func (e *flakySpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.InMemoryExporter.ExportSpans(ctx, spans)
+}
+
+func TestPipelineMetrics(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	status := &pipelineStatus{}
+	status.metrics.Store(newPipelineMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	exporter := &flakySpanExporter{}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(diagSpanExporter{exporter, status}),
+		sdktrace.WithSpanProcessor(queuedSpanCounter{status}),
+	)
+	tracer := tp.Tracer("test")
+	for range 3 {
+		_, span := tracer.Start(ctx, "op")
+		span.End()
+	}
+	exporter.err = errors.New("unavailable")
+	_, span := tracer.Start(ctx, "op")
+	span.End()
+
+	traces := []attribute.KeyValue{SignalKey.String("traces")}
+	oteltest.AssertSumValue(t, reader, PipelineQueuedMetric, traces, int64(4))
+	oteltest.AssertSumValue(t, reader, PipelineExportedMetric, traces, int64(3))
+	oteltest.AssertSumValue(t, reader, PipelineDroppedMetric, traces, int64(1))
+	oteltest.AssertSumValue(t, reader, PipelineExportErrorsMetric, traces, int64(1))
+	oteltest.AssertHistogramCount(t, reader, PipelineExportDurationMetric, traces, uint64(4))
+	assert.Equal(t, int64(1), status.droppedSpans.Load())
+}
+
+func TestPipelineMetricsBeforeMeterProvider(t *testing.T) {
+	status := &pipelineStatus{}
+	require.NotPanics(t, func() {
+		status.recordQueued(context.Background(), SignalLogs, 1)
+		status.recordDropped(SignalLogs, 2)
+	})
+	assert.Equal(t, int64(2), status.droppedLogs.Load())
+}
+
+// blockingSpanExporter blocks the exports until release is closed.
+type blockingSpanExporter struct {
+	tracetest.InMemoryExporter
+	release chan struct{}
+}
+
+func (e *blockingSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	<-e.release
+	return e.InMemoryExporter.ExportSpans(ctx, spans)
+}
+
+func TestBatchGate(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	status := &pipelineStatus{}
+	status.metrics.Store(newPipelineMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	gate := newBatchGate(SignalTraces, status)
+	gate.size = 2
+	exporter := &blockingSpanExporter{release: make(chan struct{})}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(gatedSpanProcessor{
+		SpanProcessor: sdktrace.NewBatchSpanProcessor(gatedSpanExporter{exporter, gate}, sdktrace.WithMaxQueueSize(2)),
+		gate:          gate,
+	}))
+	tracer := tp.Tracer("test")
+	for range 5 {
+		_, span := tracer.Start(ctx, "op")
+		span.End()
+	}
+
+	// The spans past the queue are dropped and counted, not lost.
+	traces := []attribute.KeyValue{SignalKey.String("traces")}
+	oteltest.AssertSumValue(t, reader, PipelineDroppedMetric, traces, int64(3))
+	close(exporter.release)
+	require.NoError(t, tp.ForceFlush(ctx))
+	assert.Len(t, exporter.GetSpans(), 2)
+	assert.Zero(t, gate.pending.Load())
+
+	_, span := tracer.Start(ctx, "op")
+	span.End()
+	require.NoError(t, tp.ForceFlush(ctx))
+	assert.Len(t, exporter.GetSpans(), 3, "the exported spans leave room")
+}
+
+func TestBatchGateShutdown(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	status := &pipelineStatus{}
+	status.metrics.Store(newPipelineMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	gate := newBatchGate(SignalTraces, status)
+	exporter := &blockingSpanExporter{release: make(chan struct{})}
+	defer close(exporter.release)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(gatedSpanProcessor{
+		SpanProcessor: sdktrace.NewBatchSpanProcessor(gatedSpanExporter{exporter, gate}),
+		gate:          gate,
+	}))
+	for range 3 {
+		_, span := tp.Tracer("test").Start(context.Background(), "op")
+		span.End()
+	}
+
+	// The exporter hangs past the deadline: the spans queued or being
+	// exported are dropped.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, tp.Shutdown(ctx), context.DeadlineExceeded)
+	assert.Equal(t, int64(3), status.droppedSpans.Load())
+
+	assert.False(t, gate.admit(), "the gate is closed")
+	oteltest.AssertSumValue(t, reader, PipelineDroppedMetric, []attribute.KeyValue{SignalKey.String("traces")}, int64(4))
+}
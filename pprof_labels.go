@@ -0,0 +1,82 @@
+package kgsotel
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// PprofTraceIDLabel is the pprof label holding the trace ID of a span.
+	PprofTraceIDLabel = "trace_id"
+	// PprofSpanNameLabel is the pprof label holding the name of a span,
+	// e.g. the route of a request.
+	PprofSpanNameLabel = "span_name"
+)
+
+// WithPprofLabels returns an Option to label the goroutine with the trace ID
+// and the name of the spans started by StartTrace and the middlewares, for
+// their duration, so the CPU profiles can be sliced by endpoint and trace.
+// The goroutines started from the context of the span inherit the labels.
+func WithPprofLabels(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.PprofLabels = enabled
+	})
+}
+
+// pprofLabels reports whether the global pipeline labels the goroutines.
+var pprofLabels atomic.Bool
+
+func setPprofLabels(enabled bool) {
+	pprofLabels.Store(enabled)
+}
+
+// LabelGoroutine adds the pprof labels of the sampled span to ctx and sets
+// them on the current goroutine, if enabled by WithPprofLabels. The returned
+// function restores the labels of the parent context; call it when the span
+// ends, from the same goroutine.
+func LabelGoroutine(ctx context.Context, span trace.Span, name string) (context.Context, func()) {
+	ctx, restore := labelGoroutine(ctx, span, name)
+	if restore == nil {
+		return ctx, func() {}
+	}
+	return ctx, restore
+}
+
+// labelGoroutine is LabelGoroutine returning a nil function if the goroutine
+// is not labeled.
+func labelGoroutine(ctx context.Context, span trace.Span, name string) (context.Context, func()) {
+	if !pprofLabels.Load() || !span.SpanContext().IsSampled() {
+		return ctx, nil
+	}
+	parent := ctx
+	ctx = pprof.WithLabels(ctx, pprof.Labels(
+		PprofTraceIDLabel, span.SpanContext().TraceID().String(),
+		PprofSpanNameLabel, name,
+	))
+	pprof.SetGoroutineLabels(ctx)
+	return ctx, func() { pprof.SetGoroutineLabels(parent) }
+}
+
+// labelSpan labels the goroutine with the span, if enabled, returning a span
+// restoring the labels when it ends.
+func labelSpan(ctx context.Context, span trace.Span, name string) (context.Context, trace.Span) {
+	ctx, restore := labelGoroutine(ctx, span, name)
+	if restore == nil {
+		return ctx, span
+	}
+	return ctx, &labeledSpan{Span: span, restore: restore}
+}
+
+// labeledSpan restores the pprof labels of the goroutine when it ends.
+type labeledSpan struct {
+	trace.Span
+	restore func()
+}
+
+func (s *labeledSpan) End(opts ...trace.SpanEndOption) {
+	s.Span.End(opts...)
+	s.restore()
+}
@@ -0,0 +1,41 @@
+package kgsotel
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestPprofLabels(t *testing.T) {
+	restoreGlobals(t)
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	setPprofLabels(true)
+
+	base := pprof.WithLabels(context.Background(), pprof.Labels("worker", "1"))
+	pprof.SetGoroutineLabels(base)
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	ctx, span := StartTrace(base)
+	traceID, ok := pprof.Label(ctx, PprofTraceIDLabel)
+	assert.True(t, ok)
+	assert.Equal(t, span.SpanContext().TraceID().String(), traceID)
+	name, _ := pprof.Label(ctx, PprofSpanNameLabel)
+	assert.Equal(t, "kgs/otel.TestPprofLabels", name)
+	worker, _ := pprof.Label(ctx, "worker")
+	assert.Equal(t, "1", worker)
+	span.End()
+}
+
+func TestPprofLabelsDisabled(t *testing.T) {
+	restoreGlobals(t)
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ctx, span := StartTrace(context.Background())
+	defer span.End()
+	_, ok := pprof.Label(ctx, PprofTraceIDLabel)
+	assert.False(t, ok)
+}
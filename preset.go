@@ -0,0 +1,50 @@
+package kgsotel
+
+import (
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+// Environment is the deployment environment profile of the service.
+type Environment string
+
+const (
+	// EnvironmentDev writes the telemetry to stdout, samples every span
+	// and logs at debug level.
+	EnvironmentDev Environment = "dev"
+	// EnvironmentStaging exports to the collector, samples every span
+	// and logs at info level.
+	EnvironmentStaging Environment = "staging"
+	// EnvironmentProd exports to the collector, samples 10% of the root
+	// spans and logs at info level.
+	EnvironmentProd Environment = "prod"
+)
+
+// prodSampleRatio is the fraction of the root spans sampled in production.
+const prodSampleRatio = 0.1
+
+// presets are the built-in configurations of each environment.
+var presets = map[Environment]func(*config){
+	EnvironmentDev: func(cfg *config) {
+		cfg.StdoutExporters = true
+		cfg.Sampler = sdktrace.AlwaysSample()
+		cfg.LogLevel = zapcore.DebugLevel
+	},
+	EnvironmentStaging: func(cfg *config) {
+		cfg.Sampler = sdktrace.AlwaysSample()
+		cfg.LogLevel = zapcore.InfoLevel
+	},
+	EnvironmentProd: func(cfg *config) {
+		cfg.Sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(prodSampleRatio))
+		cfg.LogLevel = zapcore.InfoLevel
+	},
+}
+
+// WithEnvironment returns an Option to apply the preset of the environment.
+// If none is specified, the KGSOTEL_ENV environment variable is used.
+// Options passed along with it override the fields of the preset.
+func WithEnvironment(env Environment) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.Environment = env
+	})
+}
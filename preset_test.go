@@ -0,0 +1,31 @@
+package kgsotel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestEnvironmentPresets(t *testing.T) {
+	dev := newConfig(WithEnvironment(EnvironmentDev))
+	assert.True(t, dev.StdoutExporters)
+	assert.Equal(t, zapcore.DebugLevel, dev.LogLevel)
+
+	prod := newConfig(WithEnvironment(EnvironmentProd))
+	assert.False(t, prod.StdoutExporters)
+	assert.Equal(t, zapcore.InfoLevel, prod.LogLevel)
+	assert.Contains(t, prod.Sampler.Description(), "TraceIDRatioBased")
+}
+
+func TestEnvironmentPresetOverride(t *testing.T) {
+	t.Setenv(envEnvironment, string(EnvironmentProd))
+
+	cfg := newConfig(WithLogLevel(zapcore.WarnLevel))
+	assert.Equal(t, EnvironmentProd, cfg.Environment)
+	assert.Equal(t, zapcore.WarnLevel, cfg.LogLevel)
+
+	cfg = newConfig(WithEnvironment(EnvironmentDev), WithStdoutExporters(false))
+	assert.Equal(t, EnvironmentDev, cfg.Environment)
+	assert.False(t, cfg.StdoutExporters)
+}
@@ -0,0 +1,111 @@
+package otelprofiling
+
+import (
+	kgsotel "kgs/otel"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// defaultPeriod is the duration of the CPU profiles pushed.
+const defaultPeriod = 10 * time.Second
+
+// defaultPushTimeout bounds the pushes of the default HTTP client.
+const defaultPushTimeout = 10 * time.Second
+
+// config is a group of options for the profiler.
+type config struct {
+	ServiceName    string
+	ServiceVersion string
+	Tags           map[string]string
+	Period         time.Duration
+	Client         *http.Client
+}
+
+// Option applies an option value for a config.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithTelemetry returns an Option to label the profiles with the service
+// name and version of the resource of the telemetry pipeline.
+func WithTelemetry(t *kgsotel.Telemetry) Option {
+	return optionFunc(func(cfg *config) {
+		if t != nil {
+			WithResource(t.Resource()).apply(cfg)
+		}
+	})
+}
+
+// WithResource returns an Option to label the profiles with the service
+// name and version of the resource.
+func WithResource(res *resource.Resource) Option {
+	return optionFunc(func(cfg *config) {
+		if res == nil {
+			return
+		}
+		if v, ok := res.Set().Value(semconv.ServiceNameKey); ok {
+			cfg.ServiceName = v.AsString()
+		}
+		if v, ok := res.Set().Value(semconv.ServiceVersionKey); ok {
+			cfg.ServiceVersion = v.AsString()
+		}
+	})
+}
+
+// WithServiceName returns an Option to set the service name of the profiles.
+func WithServiceName(name string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ServiceName = name
+	})
+}
+
+// WithTags returns an Option to add tags to the profiles, e.g. the region.
+// Keep them few and of low cardinality.
+func WithTags(tags map[string]string) Option {
+	return optionFunc(func(cfg *config) {
+		if cfg.Tags == nil {
+			cfg.Tags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			cfg.Tags[k] = v
+		}
+	})
+}
+
+// WithPeriod returns an Option to set the duration of the CPU profiles
+// pushed. If none is specified, 10 seconds is used.
+func WithPeriod(period time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		if period > 0 {
+			cfg.Period = period
+		}
+	})
+}
+
+// WithHTTPClient returns an Option to push the profiles with the client,
+// e.g. to authenticate to the server. If none is specified, a client with a
+// timeout of 10 seconds is used.
+func WithHTTPClient(client *http.Client) Option {
+	return optionFunc(func(cfg *config) {
+		if client != nil {
+			cfg.Client = client
+		}
+	})
+}
+
+func newConfig(opts ...Option) *config {
+	cfg := &config{Period: defaultPeriod, Client: &http.Client{Timeout: defaultPushTimeout}}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	return cfg
+}
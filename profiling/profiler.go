@@ -0,0 +1,195 @@
+// Package otelprofiling pushes continuous CPU profiles to a Pyroscope
+// compatible server, and links the sampled traces to the profiles.
+package otelprofiling
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+// Profiler records CPU profiles and pushes them to the server.
+type Profiler struct {
+	cfg       *config
+	ingestURL string
+	// pushes holds the profiles waiting to be pushed, in the background so
+	// the next profile starts without a gap.
+	pushes chan profile
+	// ctx cancels the pushes when Stop gives up.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// profile is a recorded CPU profile.
+type profile struct {
+	data        []byte
+	from, until time.Time
+}
+
+// Start starts recording CPU profiles of the period and pushing them to the
+// ingest API of the server, e.g. http://pyroscope:4040, labeled with the
+// service name and version. Only one CPU profile can be recorded at a time
+// in a process, so it fails if another one is running. Call Stop to stop it.
+func Start(serverURL string, opts ...Option) (*Profiler, error) {
+	cfg := newConfig(opts...)
+	if cfg.ServiceName == "" {
+		return nil, errors.New("profiling: the service name is required")
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("profiling: parse server URL: %w", err)
+	}
+	u = u.JoinPath("ingest")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Profiler{
+		cfg:       cfg,
+		ingestURL: u.String(),
+		pushes:    make(chan profile, 1),
+		ctx:       ctx,
+		cancel:    cancel,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	// The first profile is started synchronously to report the errors.
+	buf := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		cancel()
+		return nil, fmt.Errorf("profiling: %w", err)
+	}
+	go p.run(buf)
+	go p.pushAll()
+	return p, nil
+}
+
+// run records the profile of each period until the profiler is stopped.
+// The next profile is started before the last one is pushed.
+func (p *Profiler) run(buf *bytes.Buffer) {
+	defer close(p.pushes)
+	ticker := time.NewTicker(p.cfg.Period)
+	defer ticker.Stop()
+	from := time.Now()
+	for {
+		var stopped bool
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			stopped = true
+		}
+		pprof.StopCPUProfile()
+		last := profile{data: buf.Bytes(), from: from, until: time.Now()}
+		if !stopped {
+			buf, from = &bytes.Buffer{}, last.until
+			if err := pprof.StartCPUProfile(buf); err != nil {
+				otel.Handle(fmt.Errorf("profiling: %w", err))
+				stopped = true
+			}
+		}
+		select {
+		case p.pushes <- last:
+		default:
+			otel.Handle(errors.New("profiling: the previous push is still running, profile dropped"))
+		}
+		if stopped {
+			return
+		}
+	}
+}
+
+// pushAll pushes the recorded profiles until the recording stops.
+func (p *Profiler) pushAll() {
+	defer close(p.done)
+	for pr := range p.pushes {
+		if err := p.push(p.ctx, pr.data, pr.from, pr.until); err != nil {
+			otel.Handle(err)
+		}
+	}
+}
+
+// appName returns the application name of the profiles, with their tags.
+func (p *Profiler) appName() string {
+	tags := map[string]string{}
+	for k, v := range p.cfg.Tags {
+		tags[k] = v
+	}
+	if p.cfg.ServiceVersion != "" {
+		tags["service_version"] = p.cfg.ServiceVersion
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + tags[k]
+	}
+	return p.cfg.ServiceName + ".cpu{" + strings.Join(pairs, ",") + "}"
+}
+
+// push sends the profile in the pprof format to the ingest API.
+func (p *Profiler) push(ctx context.Context, profile []byte, from, until time.Time) error {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(profile); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	q := url.Values{}
+	q.Set("name", p.appName())
+	q.Set("from", strconv.FormatInt(from.Unix(), 10))
+	q.Set("until", strconv.FormatInt(until.Unix(), 10))
+	q.Set("format", "pprof")
+	q.Set("spyName", "gospy")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.ingestURL+"?"+q.Encode(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("profiling: push: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("profiling: push: %s", resp.Status)
+	}
+	return nil
+}
+
+// Stop stops the profiler and pushes the last profile, waiting for it until
+// the context is done, when the pending pushes are canceled.
+func (p *Profiler) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,99 @@
+package otelprofiling
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+func TestProfiler(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		queries  []url.Values
+		profiles [][]byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ingest", r.URL.Path)
+		f, _, err := r.FormFile("profile")
+		if !assert.NoError(t, err) {
+			return
+		}
+		b, _ := io.ReadAll(f)
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, r.URL.Query())
+		profiles = append(profiles, b)
+	}))
+	defer srv.Close()
+
+	res := resource.NewSchemaless(semconv.ServiceName("orders"), semconv.ServiceVersion("1.2.3"))
+	p, err := Start(srv.URL, WithResource(res), WithTags(map[string]string{"region": "eu"}), WithPeriod(50*time.Millisecond))
+	require.NoError(t, err)
+
+	_, err = Start(srv.URL, WithServiceName("other"))
+	assert.Error(t, err, "only one CPU profile can run at a time")
+
+	time.Sleep(120 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, p.Stop(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.GreaterOrEqual(t, len(queries), 2)
+	assert.Equal(t, "orders.cpu{region=eu,service_version=1.2.3}", queries[0].Get("name"))
+	assert.Equal(t, "pprof", queries[0].Get("format"))
+	assert.NotEmpty(t, profiles[0])
+}
+
+func TestStartWithoutServiceName(t *testing.T) {
+	_, err := Start("http://localhost:4040")
+	assert.Error(t, err)
+}
+
+func TestProfilerStopWithHangingServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The cancellation of the request is noticed once the body is read.
+		_, _ = io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	p, err := Start(srv.URL, WithServiceName("orders"), WithPeriod(20*time.Millisecond))
+	require.NoError(t, err)
+	time.Sleep(70 * time.Millisecond)
+
+	// The pushes hang, but the profiles keep being recorded and Stop gives
+	// up at the deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	assert.ErrorIs(t, p.Stop(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(begin), time.Second)
+
+	// The CPU profile is released.
+	require.Eventually(t, func() bool {
+		p, err := Start(srv.URL, WithServiceName("orders"))
+		if err != nil {
+			return false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_ = p.Stop(ctx)
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestDefaultHTTPClientTimeout(t *testing.T) {
+	assert.Equal(t, defaultPushTimeout, newConfig().Client.Timeout)
+}
@@ -0,0 +1,70 @@
+package otelprofiling
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ProfileIDKey is the attribute of the local root spans linking them to the
+// CPU samples labeled with their span ID.
+const ProfileIDKey = attribute.Key("pyroscope.profile.id")
+
+// spanIDLabel is the pprof label of the CPU samples of a local root span.
+const spanIDLabel = "span_id"
+
+// TracerProvider wraps tp so that the sampled local root spans, e.g. the
+// spans of the incoming requests, label the CPU samples of their goroutine,
+// and of the goroutines it starts, with their span ID, and get it as the
+// pyroscope.profile.id attribute. The slow traces then link to their CPU
+// profiles.
+//
+//	otel.SetTracerProvider(otelprofiling.TracerProvider(otel.GetTracerProvider()))
+func TracerProvider(tp trace.TracerProvider) trace.TracerProvider {
+	return &tracerProvider{TracerProvider: tp}
+}
+
+type tracerProvider struct {
+	trace.TracerProvider
+}
+
+func (p *tracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &tracer{Tracer: p.TracerProvider.Tracer(name, opts...)}
+}
+
+type tracer struct {
+	trace.Tracer
+}
+
+// Start starts the span, and labels the goroutine if it is a sampled local
+// root span.
+func (t *tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := ctx
+	ctx, span := t.Tracer.Start(ctx, name, opts...)
+	sc := span.SpanContext()
+	if !sc.IsSampled() {
+		return ctx, span
+	}
+	if psc := trace.SpanContextFromContext(parent); psc.IsValid() && !psc.IsRemote() {
+		return ctx, span
+	}
+
+	id := sc.SpanID().String()
+	span.SetAttributes(ProfileIDKey.String(id))
+	ctx = pprof.WithLabels(ctx, pprof.Labels(spanIDLabel, id))
+	pprof.SetGoroutineLabels(ctx)
+	return ctx, &profiledSpan{Span: span, parent: parent}
+}
+
+// profiledSpan restores the labels of the goroutine when it ends.
+type profiledSpan struct {
+	trace.Span
+	parent context.Context
+}
+
+func (s *profiledSpan) End(opts ...trace.SpanEndOption) {
+	s.Span.End(opts...)
+	pprof.SetGoroutineLabels(s.parent)
+}
@@ -0,0 +1,43 @@
+package otelprofiling
+
+import (
+	"context"
+	"runtime/pprof"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracerProvider(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	tracer := TracerProvider(rec.TracerProvider).Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	id := root.SpanContext().SpanID().String()
+	label, ok := pprof.Label(ctx, spanIDLabel)
+	assert.True(t, ok)
+	assert.Equal(t, id, label)
+
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	root.End()
+
+	spans := rec.Spans()
+	require.Len(t, spans, 2)
+	for _, s := range spans {
+		var got string
+		for _, attr := range s.Attributes() {
+			if attr.Key == ProfileIDKey {
+				got = attr.Value.AsString()
+			}
+		}
+		if s.Name() == "root" {
+			assert.Equal(t, id, got)
+		} else {
+			assert.Empty(t, got)
+		}
+	}
+}
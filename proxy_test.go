@@ -0,0 +1,173 @@
+package kgsotel
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"kgs/otel/otlptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// connectProxy is an HTTP CONNECT proxy tunneling every request to target.
+type connectProxy struct {
+	lis    net.Listener
+	target string
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newConnectProxy(t *testing.T, target string) *connectProxy {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	return serveConnectProxy(t, lis, target)
+}
+
+// newTLSConnectProxy returns a connectProxy served over TLS, and the pool
+// trusting its certificate.
+func newTLSConnectProxy(t *testing.T, target string) (*connectProxy, *x509.CertPool) {
+	// httptest provides a certificate for 127.0.0.1.
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	certs := srv.TLS.Certificates
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	srv.Close()
+
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certs})
+	require.NoError(t, err)
+	return serveConnectProxy(t, lis, target), pool
+}
+
+func serveConnectProxy(t *testing.T, lis net.Listener, target string) *connectProxy {
+	t.Cleanup(func() { lis.Close() })
+	p := &connectProxy{lis: lis, target: target}
+	go p.serve()
+	return p
+}
+
+func (p *connectProxy) serve() {
+	for {
+		conn, err := p.lis.Accept()
+		if err != nil {
+			return
+		}
+		go p.tunnel(conn)
+	}
+}
+
+func (p *connectProxy) tunnel(conn net.Conn) {
+	defer conn.Close()
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	p.requests = append(p.requests, req)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer upstream.Close()
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+}
+
+func (p *connectProxy) lastRequest() *http.Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[len(p.requests)-1]
+}
+
+func TestWithProxy(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+	proxy := newConnectProxy(t, collector.Addr)
+
+	exportSpan(t, "collector.invalid:4317", WithProxy("http://user:secret@"+proxy.lis.Addr().String()))
+	assert.Len(t, collector.Spans(), 1)
+
+	req := proxy.lastRequest()
+	assert.Equal(t, http.MethodConnect, req.Method)
+	assert.Equal(t, "collector.invalid:4317", req.Host)
+	assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", req.Header.Get("Proxy-Authorization"))
+}
+
+func TestDoctorThroughProxy(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+	proxy := newConnectProxy(t, collector.Addr)
+
+	report := Doctor(context.Background(), "collector.invalid:4317", WithProxy("http://"+proxy.lis.Addr().String()))
+	assert.True(t, report.OK(), report.String())
+	assert.Equal(t, "proxy", report.Checks[0].Name)
+}
+
+func TestHTTPSProxy(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer echo.Close()
+	go func() {
+		conn, err := echo.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+	proxy, pool := newTLSConnectProxy(t, echo.Addr().String())
+	u, err := url.Parse("https://" + proxy.lis.Addr().String())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := proxyDialer(u, &tls.Config{RootCAs: pool})(ctx, "collector.invalid:4317")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "ping")
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+	assert.Equal(t, "collector.invalid:4317", proxy.lastRequest().Host)
+
+	// The certificate of the proxy is verified.
+	_, err = proxyDialer(u, nil)(ctx, "collector.invalid:4317")
+	assert.ErrorContains(t, err, "proxy tls")
+}
+
+func TestCollectorProxy(t *testing.T) {
+	cfg := newConfig()
+	proxy, err := collectorProxy("unix:///tmp/otlp.sock", cfg)
+	require.NoError(t, err)
+	assert.Nil(t, proxy)
+
+	proxy, err = collectorProxy("collector:4317", newConfig(WithProxy("http://proxy:3128")))
+	require.NoError(t, err)
+	assert.Equal(t, "proxy:3128", proxyAddr(proxy))
+
+	_, err = collectorProxy("collector:4317", newConfig(WithProxy("socks5://proxy:1080")))
+	assert.ErrorContains(t, err, "unsupported proxy scheme")
+}
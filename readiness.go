@@ -0,0 +1,176 @@
+package kgsotel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// ErrTelemetryDegraded is returned by CheckReadiness when the telemetry
+// pipeline is degraded.
+var ErrTelemetryDegraded = errors.New("kgsotel: telemetry pipeline is degraded")
+
+const (
+	defaultReadinessFailureWindow = time.Minute
+	defaultReadinessSaturation    = 0.9
+)
+
+// readinessThresholds are the limits past which the pipeline is degraded.
+type readinessThresholds struct {
+	failureWindow time.Duration
+	saturation    float64
+}
+
+// failureRun is a run of consecutive failed exports of a signal.
+type failureRun struct {
+	since time.Time
+	last  time.Time
+}
+
+// ReadinessStatus is the readiness of the telemetry pipeline served by
+// Readiness.
+type ReadinessStatus struct {
+	// Ready reports whether the pipeline is healthy.
+	Ready bool `json:"ready"`
+	// Reasons describes why the pipeline is degraded.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// degradedReasons returns why the pipeline is degraded, or nil if it is not.
+func (s *pipelineStatus) degradedReasons(now time.Time) []string {
+	thresholds := readinessThresholds{
+		failureWindow: defaultReadinessFailureWindow,
+		saturation:    defaultReadinessSaturation,
+	}
+	if th := s.readiness.Load(); th != nil {
+		if th.failureWindow > 0 {
+			thresholds.failureWindow = th.failureWindow
+		}
+		if th.saturation > 0 {
+			thresholds.saturation = th.saturation
+		}
+	}
+
+	var reasons []string
+
+	s.mu.Lock()
+	signals := make([]Signal, 0, len(s.failing))
+	for signal := range s.failing {
+		signals = append(signals, signal)
+	}
+	sort.Slice(signals, func(i, j int) bool { return signals[i] < signals[j] })
+	for _, signal := range signals {
+		if d := now.Sub(s.failing[signal].since); d >= thresholds.failureWindow {
+			reasons = append(reasons, fmt.Sprintf("%s exports failing for %s", signal, d.Round(time.Second)))
+		}
+	}
+	s.mu.Unlock()
+
+	if breaker := s.breaker.Load(); breaker != nil && breaker.currentState() != breakerClosed {
+		reasons = append(reasons, "exporter circuit breaker is "+breaker.currentState().String())
+	}
+
+	health := s.health.snapshot()
+	if !health.Healthy && health.State != connectivity.Shutdown.String() {
+		if d := now.Sub(health.Since); d >= thresholds.failureWindow {
+			reasons = append(reasons, fmt.Sprintf("collector connection %s for %s", health.State, d.Round(time.Second)))
+		}
+	}
+
+	if queue := s.logQueue.Load(); queue != nil && cap(queue.queue) > 0 {
+		if fill := float64(len(queue.queue)) / float64(cap(queue.queue)); fill >= thresholds.saturation {
+			reasons = append(reasons, fmt.Sprintf("async log buffer %.0f%% full", fill*100))
+		}
+	}
+
+	return reasons
+}
+
+// readinessStatus returns the readiness of the pipeline.
+func (s *pipelineStatus) readinessStatus() ReadinessStatus {
+	reasons := s.degradedReasons(time.Now())
+	return ReadinessStatus{Ready: len(reasons) == 0, Reasons: reasons}
+}
+
+// check returns an error wrapping ErrTelemetryDegraded if the pipeline is
+// degraded.
+func (s *pipelineStatus) check() error {
+	status := s.readinessStatus()
+	if status.Ready {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrTelemetryDegraded, strings.Join(status.Reasons, "; "))
+}
+
+// CheckReadiness returns an error wrapping ErrTelemetryDegraded when the
+// telemetry created by InitTelemetry is degraded: the exports are
+// persistently failing, the circuit breaker is open, the collector
+// connection is down or the asynchronous log buffer is saturated. It can be
+// registered as a check of the health library of the service.
+func CheckReadiness() error {
+	return diag.check()
+}
+
+// CheckReadiness returns an error wrapping ErrTelemetryDegraded when the
+// pipeline is degraded.
+func (t *Telemetry) CheckReadiness() error {
+	return t.status.check()
+}
+
+// ReadinessOption configures the handler returned by Readiness.
+type ReadinessOption func(*readinessConfig)
+
+type readinessConfig struct {
+	degradedStatus int
+}
+
+// DegradedStatus returns a ReadinessOption to answer with the status code,
+// e.g. http.StatusServiceUnavailable, rather than 200 when the pipeline is
+// degraded.
+func DegradedStatus(code int) ReadinessOption {
+	return func(cfg *readinessConfig) {
+		cfg.degradedStatus = code
+	}
+}
+
+// Readiness returns a handler serving the readiness of the telemetry created
+// by InitTelemetry as JSON. It answers 200 with the reasons in the body when
+// the pipeline is degraded, so a collector outage does not take every pod of
+// the service out of its endpoints when mounted as a Kubernetes readiness
+// probe; DegradedStatus(http.StatusServiceUnavailable) answers 503 instead,
+// for the dashboards and the startup probes.
+func Readiness(opts ...ReadinessOption) http.Handler {
+	return readinessHandler(diag, opts...)
+}
+
+// Readiness returns a handler serving the readiness of the pipeline.
+func (t *Telemetry) Readiness(opts ...ReadinessOption) http.Handler {
+	return readinessHandler(t.status, opts...)
+}
+
+func readinessHandler(s *pipelineStatus, opts ...ReadinessOption) http.Handler {
+	cfg := readinessConfig{degradedStatus: http.StatusOK}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		status := s.readinessStatus()
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Ready {
+			w.WriteHeader(cfg.degradedStatus)
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	})
+}
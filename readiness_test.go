@@ -0,0 +1,71 @@
+package kgsotel
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessExportFailures(t *testing.T) {
+	status := &pipelineStatus{}
+	status.readiness.Store(&readinessThresholds{failureWindow: time.Minute})
+	assert.NoError(t, status.check())
+
+	status.recordExportError(SignalTraces, errors.New("unavailable"), 1)
+	since := status.failing[SignalTraces].since
+	assert.Empty(t, status.degradedReasons(since.Add(30*time.Second)), "a short outage is not degraded")
+	assert.Equal(t, []string{"traces exports failing for 1m30s"}, status.degradedReasons(since.Add(90*time.Second)))
+
+	// An error recorded during the export, e.g. by the disk buffer, keeps
+	// the run going.
+	status.recordExportSuccess(SignalTraces, since.Add(-time.Second))
+	assert.Contains(t, status.failing, SignalTraces)
+
+	status.recordExportSuccess(SignalTraces, time.Now())
+	assert.Empty(t, status.degradedReasons(since.Add(90*time.Second)))
+}
+
+func TestReadinessBufferSaturation(t *testing.T) {
+	status := &pipelineStatus{}
+	queue := &asyncProcessor{queue: make(chan asyncItem, 10)}
+	status.logQueue.Store(queue)
+	for range 9 {
+		queue.queue <- asyncItem{}
+	}
+
+	err := status.check()
+	assert.ErrorIs(t, err, ErrTelemetryDegraded)
+	assert.ErrorContains(t, err, "async log buffer 90% full")
+
+	status.readiness.Store(&readinessThresholds{saturation: 0.95})
+	assert.NoError(t, status.check())
+}
+
+func TestReadinessHandler(t *testing.T) {
+	status := &pipelineStatus{}
+	status.breaker.Store(newCircuitBreaker(1, time.Minute))
+
+	rec := httptest.NewRecorder()
+	readinessHandler(status).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	status.breaker.Load().done(errors.New("unavailable"))
+	rec = httptest.NewRecorder()
+	readinessHandler(status).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "a degraded pipeline keeps the pod ready")
+
+	rec = httptest.NewRecorder()
+	readinessHandler(status, DegradedStatus(http.StatusServiceUnavailable)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var got ReadinessStatus
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.False(t, got.Ready)
+	assert.Equal(t, []string{"exporter circuit breaker is open"}, got.Reasons)
+}
@@ -0,0 +1,138 @@
+package kgsotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// WithServiceVersion returns an Option to set the service.version of the
+// resource. It is also added to every metric data point.
+func WithServiceVersion(version string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ServiceVersion = version
+	})
+}
+
+// WithDeploymentEnvironment returns an Option to set the
+// deployment.environment of the resource. It is also added to every metric
+// data point. If none is specified, the environment profile (see
+// WithEnvironment) is used.
+func WithDeploymentEnvironment(env string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.DeploymentEnvironment = env
+	})
+}
+
+// WithServiceInstanceID returns an Option to set the service.instance.id of
+// the resource. It is also added to every metric data point.
+func WithServiceInstanceID(id string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ServiceInstanceID = id
+	})
+}
+
+// serviceAttributes returns the service attributes of the resource which
+// are also added to the metric data points.
+func serviceAttributes(cfg *config) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	env := cfg.DeploymentEnvironment
+	if env == "" {
+		env = string(cfg.Environment)
+	}
+	if env != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(env))
+	}
+	if cfg.ServiceInstanceID != "" {
+		attrs = append(attrs, semconv.ServiceInstanceID(cfg.ServiceInstanceID))
+	}
+	return attrs
+}
+
+// newResource creates the resource describing the service.
+func newResource(ctx context.Context, serviceName string, cfg *config) (*resource.Resource, error) {
+	attrs := append([]attribute.KeyValue{semconv.ServiceName(serviceName)}, serviceAttributes(cfg)...)
+	opts := []resource.Option{
+		resource.WithAttributes(attrs...),
+		resource.WithHost(),
+		resource.WithProcess(),
+		resource.WithTelemetrySDK(),
+	}
+	if cfg.PodInfoDir != "" {
+		opts = append(opts, resource.WithDetectors(newK8sDetector(cfg.PodInfoDir)))
+	}
+	return resource.New(ctx, opts...)
+}
+
+// attrMetricExporter adds default attributes to every data point exported
+// by the wrapped exporter. The attributes recorded with the measurements
+// take precedence.
+type attrMetricExporter struct {
+	sdkmetric.Exporter
+	attrs []attribute.KeyValue
+}
+
+// Export adds the default attributes to the data points and exports them.
+func (e attrMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	for i := range rm.ScopeMetrics {
+		for j := range rm.ScopeMetrics[i].Metrics {
+			m := &rm.ScopeMetrics[i].Metrics[j]
+			m.Data = e.addAttrs(m.Data)
+		}
+	}
+	return e.Exporter.Export(ctx, rm)
+}
+
+func (e attrMetricExporter) addAttrs(data metricdata.Aggregation) metricdata.Aggregation {
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		addDataPointAttrs(d.DataPoints, e.attrs)
+	case metricdata.Sum[float64]:
+		addDataPointAttrs(d.DataPoints, e.attrs)
+	case metricdata.Gauge[int64]:
+		addDataPointAttrs(d.DataPoints, e.attrs)
+	case metricdata.Gauge[float64]:
+		addDataPointAttrs(d.DataPoints, e.attrs)
+	case metricdata.Histogram[int64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = mergeAttrs(d.DataPoints[i].Attributes, e.attrs)
+		}
+	case metricdata.Histogram[float64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = mergeAttrs(d.DataPoints[i].Attributes, e.attrs)
+		}
+	case metricdata.ExponentialHistogram[int64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = mergeAttrs(d.DataPoints[i].Attributes, e.attrs)
+		}
+	case metricdata.ExponentialHistogram[float64]:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = mergeAttrs(d.DataPoints[i].Attributes, e.attrs)
+		}
+	case metricdata.Summary:
+		for i := range d.DataPoints {
+			d.DataPoints[i].Attributes = mergeAttrs(d.DataPoints[i].Attributes, e.attrs)
+		}
+	}
+	return data
+}
+
+func addDataPointAttrs[N int64 | float64](dps []metricdata.DataPoint[N], attrs []attribute.KeyValue) {
+	for i := range dps {
+		dps[i].Attributes = mergeAttrs(dps[i].Attributes, attrs)
+	}
+}
+
+// mergeAttrs adds the default attributes to the set, keeping the values
+// already in the set.
+func mergeAttrs(set attribute.Set, defaults []attribute.KeyValue) attribute.Set {
+	kvs := append(append([]attribute.KeyValue{}, defaults...), set.ToSlice()...)
+	return attribute.NewSet(kvs...)
+}
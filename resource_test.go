@@ -0,0 +1,69 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+type captureMetricExporter struct {
+	sdkmetric.Exporter
+	rm *metricdata.ResourceMetrics
+}
+
+func (e *captureMetricExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.rm = rm
+	return nil
+}
+
+func TestServiceAttributes(t *testing.T) {
+	cfg := newConfig(WithEnvironment(EnvironmentStaging), WithServiceVersion("1.2.3"), WithServiceInstanceID("pod-1"))
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.ServiceVersion("1.2.3"),
+		semconv.DeploymentEnvironment("staging"),
+		semconv.ServiceInstanceID("pod-1"),
+	}, serviceAttributes(cfg))
+
+	cfg = newConfig(WithEnvironment(EnvironmentStaging), WithDeploymentEnvironment("staging-eu"))
+	assert.Equal(t, []attribute.KeyValue{semconv.DeploymentEnvironment("staging-eu")}, serviceAttributes(cfg))
+}
+
+func TestNewResource(t *testing.T) {
+	res, err := newResource(context.Background(), "svc", newConfig(WithServiceVersion("1.2.3")))
+	require.NoError(t, err)
+
+	v, ok := res.Set().Value(semconv.ServiceVersionKey)
+	assert.True(t, ok)
+	assert.Equal(t, "1.2.3", v.AsString())
+}
+
+func TestAttrMetricExporter(t *testing.T) {
+	capture := &captureMetricExporter{}
+	exp := attrMetricExporter{capture, []attribute.KeyValue{
+		semconv.ServiceVersion("1.2.3"),
+		attribute.String("route", "default"),
+	}}
+
+	rm := &metricdata.ResourceMetrics{ScopeMetrics: []metricdata.ScopeMetrics{{
+		Metrics: []metricdata.Metrics{
+			{Data: metricdata.Sum[int64]{DataPoints: []metricdata.DataPoint[int64]{
+				{Attributes: attribute.NewSet(attribute.String("route", "/users"))},
+			}}},
+			{Data: metricdata.Histogram[float64]{DataPoints: []metricdata.HistogramDataPoint[float64]{{}}}},
+		},
+	}}}
+	require.NoError(t, exp.Export(context.Background(), rm))
+
+	sum := capture.rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	assert.Equal(t, attribute.NewSet(semconv.ServiceVersion("1.2.3"), attribute.String("route", "/users")),
+		sum.DataPoints[0].Attributes)
+	hist := capture.rm.ScopeMetrics[0].Metrics[1].Data.(metricdata.Histogram[float64])
+	assert.Equal(t, attribute.NewSet(semconv.ServiceVersion("1.2.3"), attribute.String("route", "default")),
+		hist.DataPoints[0].Attributes)
+}
@@ -0,0 +1,125 @@
+package kgsotel
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sampler is the sampler of the tracer provider, it can be changed at runtime.
+var sampler = &dynamicSampler{}
+
+// dynamicSampler delegates the sampling decisions to a sampler that can be
+// replaced without recreating the tracer provider.
+type dynamicSampler struct {
+	delegate atomic.Pointer[sdktrace.Sampler]
+}
+
+var _ sdktrace.Sampler = (*dynamicSampler)(nil)
+
+// with replaces the delegate and returns the dynamic sampler.
+func (s *dynamicSampler) with(delegate sdktrace.Sampler) *dynamicSampler {
+	s.delegate.Store(&delegate)
+	return s
+}
+
+// ShouldSample returns the sampling decision of the delegate.
+func (s *dynamicSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	// The contexts flagged for debugging are always sampled.
+	if p.ParentContext != nil && DebugEnabled(p.ParentContext) {
+		return sdktrace.AlwaysSample().ShouldSample(p)
+	}
+	if delegate := s.delegate.Load(); delegate != nil {
+		return (*delegate).ShouldSample(p)
+	}
+	return sdktrace.AlwaysSample().ShouldSample(p)
+}
+
+// Description returns the description of the delegate.
+func (s *dynamicSampler) Description() string {
+	if delegate := s.delegate.Load(); delegate != nil {
+		return (*delegate).Description()
+	}
+	return sdktrace.AlwaysSample().Description()
+}
+
+// SetSampler replaces the sampler of the tracer provider created by
+// InitTelemetry, without restarting the process.
+func SetSampler(s sdktrace.Sampler) {
+	if s != nil {
+		sampler.with(s)
+	}
+}
+
+// SetSampleRatio samples the given fraction of the root spans from now on.
+// Child spans follow the sampling decision of their parent.
+func SetSampleRatio(ratio float64) {
+	SetSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)))
+}
+
+// SamplerDescription returns the description of the current sampler.
+func SamplerDescription() string {
+	return sampler.Description()
+}
+
+// SetLogLevel changes the minimum level of the global logger.
+func SetLogLevel(level zapcore.Level) {
+	logLevel.SetLevel(level)
+}
+
+// LogLevel returns the current minimum level of the global logger.
+func LogLevel() zapcore.Level {
+	return logLevel.Level()
+}
+
+// ReloadOnSignal re-reads the configuration file at path every time the
+// process receives one of the signals (SIGHUP if none are given), and applies
+// its sampler ratio and log level. It stops when ctx is done.
+func ReloadOnSignal(ctx context.Context, path string, sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				if err := reloadConfig(path); err != nil {
+					zap.L().Error("reload telemetry config", zap.String("path", path), zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// reloadConfig applies the runtime reconfigurable settings of the file.
+func reloadConfig(path string) error {
+	fc, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if fc.Sampler.Ratio != nil {
+		SetSampleRatio(*fc.Sampler.Ratio)
+	}
+	if fc.Log.Level != "" {
+		level, err := zapcore.ParseLevel(fc.Log.Level)
+		if err != nil {
+			return err
+		}
+		SetLogLevel(level)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package kgsotel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSetSampleRatio(t *testing.T) {
+	defer SetSampler(sdktrace.AlwaysSample())
+
+	params := sdktrace.SamplingParameters{TraceID: trace.TraceID{0xff}, Name: "span"}
+
+	SetSampleRatio(0)
+	assert.Equal(t, sdktrace.Drop, sampler.ShouldSample(params).Decision)
+
+	SetSampleRatio(1)
+	assert.Equal(t, sdktrace.RecordAndSample, sampler.ShouldSample(params).Decision)
+}
+
+func TestSetLogLevel(t *testing.T) {
+	defer SetLogLevel(LogLevel())
+
+	SetLogLevel(zapcore.ErrorLevel)
+	assert.Equal(t, zapcore.ErrorLevel, LogLevel())
+	assert.False(t, logLevel.Enabled(zapcore.WarnLevel))
+}
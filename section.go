@@ -0,0 +1,60 @@
+package kgsotel
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"kgs/otel/internal/killswitch"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SectionDurationKey is the duration in milliseconds of a section recorded
+// as a span event by StartSection.
+const SectionDurationKey = attribute.Key("kgs.section.duration_ms")
+
+// WithSectionSpans returns an Option to record the sections of StartSection
+// as child spans instead of span events. The events are cheaper and keep the
+// traces small; the spans show the sections on the trace timeline.
+func WithSectionSpans(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SectionSpans = enabled
+	})
+}
+
+// sectionSpans reports whether the global pipeline records the sections as
+// spans.
+var sectionSpans atomic.Bool
+
+func setSectionSpans(enabled bool) {
+	sectionSpans.Store(enabled)
+}
+
+// StartSection starts timing a phase of the work of the span in ctx, e.g.
+// parsing, querying or rendering, and returns the function ending it:
+//
+//	defer kgsotel.StartSection(ctx, "render")()
+//
+// The section is recorded as an event named name, stamped with its start and
+// carrying its duration in kgs.section.duration_ms, or as a child span with
+// WithSectionSpans. Nothing is recorded if the span in ctx is not recording.
+func StartSection(ctx context.Context, name string) func() {
+	span := trace.SpanFromContext(ctx)
+	if killswitch.Disabled() || !span.IsRecording() {
+		return func() {}
+	}
+
+	if sectionSpans.Load() {
+		_, section := span.TracerProvider().Tracer(modulePath).Start(ctx, name)
+		return func() { section.End() }
+	}
+
+	start := time.Now()
+	return func() {
+		span.AddEvent(name,
+			trace.WithTimestamp(start),
+			trace.WithAttributes(SectionDurationKey.Float64(float64(time.Since(start))/float64(time.Millisecond))))
+	}
+}
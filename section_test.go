@@ -0,0 +1,55 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStartSection(t *testing.T) {
+	restoreGlobals(t)
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	ctx, span := tracer.Start(context.Background(), "handler")
+	end := StartSection(ctx, "parse")
+	time.Sleep(10 * time.Millisecond)
+	end()
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Len(t, spans[0].Events(), 1)
+	event := spans[0].Events()[0]
+	assert.Equal(t, "parse", event.Name)
+	require.Len(t, event.Attributes, 1)
+	assert.Equal(t, SectionDurationKey, event.Attributes[0].Key)
+	assert.GreaterOrEqual(t, event.Attributes[0].Value.AsFloat64(), 10.0)
+	assert.True(t, event.Time.Before(spans[0].EndTime().Add(-10*time.Millisecond)), "stamped with the start")
+}
+
+func TestStartSectionSpans(t *testing.T) {
+	restoreGlobals(t)
+	setSectionSpans(true)
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	ctx, span := tracer.Start(context.Background(), "handler")
+	StartSection(ctx, "render")()
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "render", spans[0].Name())
+	assert.Equal(t, span.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Empty(t, spans[1].Events())
+}
+
+func TestStartSectionNotRecording(t *testing.T) {
+	assert.NotPanics(t, StartSection(context.Background(), "parse"))
+}
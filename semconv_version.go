@@ -0,0 +1,29 @@
+package kgsotel
+
+import "kgs/otel/internal/semconvutil"
+
+// SemconvVersion is a version of the semantic conventions of the attributes
+// emitted by the gin, gRPC and HTTP client middlewares.
+type SemconvVersion = semconvutil.Version
+
+const (
+	// SemconvV1_20 is the default version, e.g. http.method and
+	// net.sock.peer.addr.
+	SemconvV1_20 = semconvutil.V1_20
+	// SemconvV1_26 has the stable HTTP conventions, e.g. http.request.method,
+	// url.scheme and network.peer.address.
+	SemconvV1_26 = semconvutil.V1_26
+)
+
+// WithSemconv returns an Option to select the version of the semantic
+// conventions of the middlewares created after the initialization, and the
+// schema URL of their tracers and meters, so all the signals follow one
+// version. Each middleware can override it with its own WithSemconv option.
+// The unsupported versions are ignored.
+func WithSemconv(version SemconvVersion) Option {
+	return optionFunc(func(cfg *config) {
+		if version.Valid() {
+			cfg.Semconv = version
+		}
+	})
+}
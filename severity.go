@@ -0,0 +1,108 @@
+package kgsotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.uber.org/zap/zapcore"
+)
+
+// bodyMessageKey is the key of the message when fields are moved into the
+// body of the log records.
+const bodyMessageKey = "message"
+
+// WithSeverityMapping returns an Option to override the severities of the
+// exported logs by zap level, e.g. to export the DPanic logs as errors
+// instead of SeverityFatal1. The unmapped levels keep the otelzap mapping,
+// and the severity text stays the zap level.
+func WithSeverityMapping(mapping map[zapcore.Level]log.Severity) Option {
+	return optionFunc(func(cfg *config) {
+		if cfg.SeverityMapping == nil {
+			cfg.SeverityMapping = make(map[zapcore.Level]log.Severity, len(mapping))
+		}
+		for level, severity := range mapping {
+			cfg.SeverityMapping[level] = severity
+		}
+	})
+}
+
+// WithLogBodyFields returns an Option to move the fields of the exported
+// logs into their body, a map of the message and of the fields, instead of
+// their attributes.
+func WithLogBodyFields(keys ...string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LogBodyFields = append(cfg.LogBodyFields, keys...)
+	})
+}
+
+// passthroughProcessor is embedded by the processors modifying the records
+// for the next ones: they have nothing to shut down or flush, the records
+// are exported by the last processor.
+type passthroughProcessor struct{}
+
+func (passthroughProcessor) Shutdown(context.Context) error   { return nil }
+func (passthroughProcessor) ForceFlush(context.Context) error { return nil }
+
+// severityProcessor remaps the severities of the log records.
+type severityProcessor struct {
+	passthroughProcessor
+	mapping map[zapcore.Level]log.Severity
+}
+
+var _ sdklog.Processor = (*severityProcessor)(nil)
+
+// OnEmit sets the severity mapped to the zap level of the severity text.
+func (p *severityProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	level, err := zapcore.ParseLevel(r.SeverityText())
+	if err != nil {
+		return nil
+	}
+	if severity, ok := p.mapping[level]; ok {
+		r.SetSeverity(severity)
+	}
+	return nil
+}
+
+// bodyFieldsProcessor moves attributes of the log records into their body.
+type bodyFieldsProcessor struct {
+	passthroughProcessor
+	keys map[string]bool
+}
+
+var _ sdklog.Processor = (*bodyFieldsProcessor)(nil)
+
+func newBodyFieldsProcessor(keys []string) *bodyFieldsProcessor {
+	p := &bodyFieldsProcessor{keys: make(map[string]bool, len(keys))}
+	for _, k := range keys {
+		p.keys[k] = true
+	}
+	return p
+}
+
+// OnEmit moves the body fields into the body, added to it if it is already
+// a map.
+func (p *bodyFieldsProcessor) OnEmit(_ context.Context, r *sdklog.Record) error {
+	var moved, kept []log.KeyValue
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		if p.keys[kv.Key] {
+			moved = append(moved, kv)
+		} else {
+			kept = append(kept, kv)
+		}
+		return true
+	})
+	if len(moved) == 0 {
+		return nil
+	}
+
+	var body []log.KeyValue
+	if b := r.Body(); b.Kind() == log.KindMap {
+		body = append(b.AsMap(), moved...)
+	} else {
+		body = append([]log.KeyValue{{Key: bodyMessageKey, Value: b}}, moved...)
+	}
+	r.SetBody(log.MapValue(body...))
+	r.SetAttributes(kept...)
+	return nil
+}
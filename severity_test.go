@@ -0,0 +1,58 @@
+package kgsotel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// emitZap logs through the OTLP core with the processors and returns the
+// records.
+func emitZap(t *testing.T, processors []sdklog.Processor, write func(*zap.Logger)) []sdklog.Record {
+	rec := &recordingProcessor{}
+	var opts []sdklog.LoggerProviderOption
+	for _, p := range append(processors, rec) {
+		opts = append(opts, sdklog.WithProcessor(p))
+	}
+	logger := initLogger("test", sdklog.NewLoggerProvider(opts...), zap.NewAtomicLevelAt(zap.DebugLevel), loggerConfig{})
+	write(logger)
+	return rec.records
+}
+
+func TestSeverityMapping(t *testing.T) {
+	cfg := newConfig(WithSeverityMapping(map[zapcore.Level]log.Severity{
+		zapcore.WarnLevel:   log.SeverityInfo4,
+		zapcore.DPanicLevel: log.SeverityError,
+	}))
+	records := emitZap(t, []sdklog.Processor{&severityProcessor{mapping: cfg.SeverityMapping}}, func(l *zap.Logger) {
+		l.Info("started")
+		l.Warn("slow")
+		l.DPanic("invariant broken")
+	})
+	require.Len(t, records, 3)
+	assert.Equal(t, log.SeverityInfo, records[0].Severity())
+	assert.Equal(t, log.SeverityInfo4, records[1].Severity())
+	assert.Equal(t, "warn", records[1].SeverityText())
+	assert.Equal(t, log.SeverityError, records[2].Severity())
+}
+
+func TestLogBodyFields(t *testing.T) {
+	records := emitZap(t, []sdklog.Processor{newBodyFieldsProcessor([]string{"payload"})}, func(l *zap.Logger) {
+		l.Info("received", zap.String("payload", "{}"), zap.String("team", "shop"))
+		l.Info("idle")
+	})
+	require.Len(t, records, 2)
+	assert.Equal(t, []log.KeyValue{log.String("message", "received"), log.String("payload", "{}")}, records[0].Body().AsMap())
+	var attrs []string
+	records[0].WalkAttributes(func(kv log.KeyValue) bool {
+		attrs = append(attrs, kv.Key)
+		return true
+	})
+	assert.Equal(t, []string{"team"}, attrs)
+	assert.Equal(t, "idle", records[1].Body().AsString())
+}
@@ -0,0 +1,47 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// shutdownHooks holds the functions registered by OnShutdown.
+var shutdownHooks struct {
+	sync.Mutex
+	funcs []func(context.Context) error
+}
+
+// OnShutdown registers fn to be called by the shutdown returned by
+// InitTelemetry, before the providers are shut down, so the last telemetry
+// of the application, e.g. the final state of a worker or the buffered
+// records of its own exporters, is still exported:
+//
+//	kgsotel.OnShutdown(func(ctx context.Context) error {
+//		return worker.Flush(ctx)
+//	})
+//
+// The functions are called in the order of their registration, with the
+// context of the shutdown bounded by its drain deadline (see
+// WithShutdownTimeout), and only once. Their errors are returned by the
+// shutdown.
+func OnShutdown(fn func(ctx context.Context) error) {
+	shutdownHooks.Lock()
+	defer shutdownHooks.Unlock()
+	shutdownHooks.funcs = append(shutdownHooks.funcs, fn)
+}
+
+// runShutdownHooks calls the functions registered by OnShutdown and
+// unregisters them. The errors from the calls are joined.
+func runShutdownHooks(ctx context.Context) error {
+	shutdownHooks.Lock()
+	funcs := shutdownHooks.funcs
+	shutdownHooks.funcs = nil
+	shutdownHooks.Unlock()
+
+	var err error
+	for _, fn := range funcs {
+		err = errors.Join(err, fn(ctx))
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOnShutdown(t *testing.T) {
+	restoreGlobals(t)
+
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	shutdown, err := InitTelemetry(ctx, "svc", "", WithStdoutExporters(true),
+		WithTracerProviderOptions(sdktrace.WithSpanProcessor(recorder)))
+	require.NoError(t, err)
+
+	var calls []string
+	OnShutdown(func(ctx context.Context) error {
+		calls = append(calls, "worker")
+		_, span := otel.Tracer("test").Start(ctx, "final state")
+		span.End()
+		return nil
+	})
+	flushErr := errors.New("flush failed")
+	OnShutdown(func(context.Context) error {
+		calls = append(calls, "queue")
+		return flushErr
+	})
+
+	err = shutdown(ctx)
+	assert.ErrorIs(t, err, flushErr)
+	assert.Equal(t, []string{"worker", "queue"}, calls)
+	require.Len(t, recorder.Ended(), 1, "the last span is recorded")
+	assert.Equal(t, "final state", recorder.Ended()[0].Name())
+
+	// The functions are only called once.
+	require.NoError(t, shutdown(ctx))
+	assert.NoError(t, runShutdownHooks(ctx))
+	assert.Len(t, calls, 2)
+}
+
+func TestOnShutdownDeadline(t *testing.T) {
+	restoreGlobals(t)
+
+	ctx := context.Background()
+	shutdown, err := InitTelemetry(ctx, "svc", "", WithStdoutExporters(true), WithShutdownTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+
+	// A hook hanging on its context does not hang the shutdown.
+	OnShutdown(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	start := time.Now()
+	err = shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
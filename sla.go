@@ -0,0 +1,66 @@
+package kgsotel
+
+import (
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// The attributes of the spans exceeding their SLA target.
+const (
+	SLABreachedKey = attribute.Key("sla.breached")
+	SLATargetKey   = attribute.Key("sla.target_ms")
+)
+
+// WithSLATargets returns an Option to mark the exported spans lasting longer
+// than the SLA target of their operation, keyed by span name, with
+// sla.breached=true and the target in sla.target_ms. The alerts and the
+// trace searches then share the definition of the targets:
+//
+//	kgsotel.WithSLATargets(map[string]time.Duration{
+//		"/checkout":        300 * time.Millisecond,
+//		"shop.Orders/Get": 50 * time.Millisecond,
+//	})
+func WithSLATargets(targets map[string]time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SLATargets = make(map[string]time.Duration, len(targets))
+		for name, target := range targets {
+			if target > 0 {
+				cfg.SLATargets[name] = target
+			}
+		}
+	})
+}
+
+// slaProcessor stamps the spans breaching their SLA target before passing
+// them to the next processor, the one exporting them. The ended spans are
+// read-only, so the stamped attributes are only seen by the next processor.
+type slaProcessor struct {
+	sdktrace.SpanProcessor
+	targets map[string]time.Duration
+}
+
+var _ sdktrace.SpanProcessor = slaProcessor{}
+
+func (p slaProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	target, ok := p.targets[s.Name()]
+	if ok && s.EndTime().Sub(s.StartTime()) > target {
+		s = slaSpan{ReadOnlySpan: s, attrs: append(slices.Clip(s.Attributes()),
+			SLABreachedKey.Bool(true),
+			SLATargetKey.Float64(float64(target)/float64(time.Millisecond)),
+		)}
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// slaSpan is an ended span with the SLA attributes.
+type slaSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs []attribute.KeyValue
+}
+
+func (s slaSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
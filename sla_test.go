@@ -0,0 +1,39 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSLAProcessor(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	cfg := newConfig(WithSLATargets(map[string]time.Duration{
+		"/checkout": 300 * time.Millisecond,
+		"/cart":     time.Second,
+		"/disabled": 0,
+	}))
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(
+		slaProcessor{sdktrace.NewSimpleSpanProcessor(exporter), cfg.SLATargets}))
+	tracer := tp.Tracer("test")
+
+	start := time.Now()
+	for _, name := range []string{"/checkout", "/cart", "/disabled", "/other"} {
+		_, span := tracer.Start(context.Background(), name, trace.WithTimestamp(start))
+		span.End(trace.WithTimestamp(start.Add(500 * time.Millisecond)))
+	}
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 4)
+	assert.Contains(t, spans[0].Attributes, SLABreachedKey.Bool(true))
+	assert.Contains(t, spans[0].Attributes, SLATargetKey.Float64(300))
+	for _, span := range spans[1:] {
+		assert.NotContains(t, span.Attributes, SLABreachedKey.Bool(true), span.Name)
+	}
+}
@@ -0,0 +1,35 @@
+package kgsotel
+
+import (
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithSpanEventLevel returns an Option to set the minimum level of the logs
+// added as events to the span of their context, e.g. zapcore.WarnLevel to
+// keep the spans of chatty code paths small. It does not change the level of
+// the logs written. If none is specified, every log at the info level or
+// above adds an event.
+func WithSpanEventLevel(level zapcore.Level) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SpanEventLevel = level
+	})
+}
+
+// spanEventLevel is the minimum level of the span events of the global
+// pipeline.
+var spanEventLevel atomic.Int32
+
+func setSpanEventLevel(level zapcore.Level) {
+	spanEventLevel.Store(int32(level))
+}
+
+// addSpanEvent adds the message as an event to the span if the level is
+// enabled for the span events.
+func addSpanEvent(span trace.Span, level zapcore.Level, message string) {
+	if int32(level) >= spanEventLevel.Load() {
+		span.AddEvent(message)
+	}
+}
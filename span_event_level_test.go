@@ -0,0 +1,45 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSpanEventLevel(t *testing.T) {
+	restoreGlobals(t)
+	core, logs := observer.New(zap.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	setSpanEventLevel(zapcore.WarnLevel)
+
+	recorder := tracetest.NewSpanRecorder()
+	ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(context.Background(), "op")
+	Info(ctx, "polling", NewFiled("attempt", 3))
+	FromContext(ctx).Info("still polling")
+	Warn(ctx, "slow backend")
+	FromContext(ctx).Error("backend down")
+	span.End()
+
+	assert.Len(t, logs.All(), 4)
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	var events []string
+	for _, e := range spans[0].Events() {
+		events = append(events, e.Name)
+	}
+	assert.Equal(t, []string{"slow backend", "backend down"}, events)
+}
+
+func TestFileConfigSpanEventLevel(t *testing.T) {
+	fc := &FileConfig{Log: LogConfig{SpanEventLevel: "error"}}
+	opts, err := fc.Options()
+	require.NoError(t, err)
+	assert.Equal(t, zapcore.ErrorLevel, newConfig(opts...).SpanEventLevel)
+}
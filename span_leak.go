@@ -0,0 +1,186 @@
+package kgsotel
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// maxLeakStackDepth bounds the stack recorded for each tracked span.
+const maxLeakStackDepth = 32
+
+// minLeakCheckInterval bounds the frequency of the checks of the open spans.
+const minLeakCheckInterval = 100 * time.Millisecond
+
+// WithSpanLeakDetector returns an Option to log a warning, with the stack
+// that started it, for every span started by StartTrace and still not ended
+// after maxAge, e.g. because of a missing defer span.End(). Recording the
+// stacks is costly, so the detector is meant for development. Only the
+// sampled spans are tracked.
+func WithSpanLeakDetector(maxAge time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		if maxAge > 0 {
+			cfg.SpanLeakMaxAge = maxAge
+		}
+	})
+}
+
+// activeLeakDetectors is the number of running detectors. StartTrace only
+// marks its spans for the detectors while there is one.
+var activeLeakDetectors atomic.Int32
+
+// startTraceKey marks the context passed by StartTrace to the tracer.
+type startTraceKey struct{}
+
+// markStartTrace returns the context to start a span of StartTrace with.
+func markStartTrace(ctx context.Context) context.Context {
+	if activeLeakDetectors.Load() == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, startTraceKey{}, true)
+}
+
+// openSpan is a span tracked by the spanLeakDetector.
+type openSpan struct {
+	name    string
+	traceID trace.TraceID
+	start   time.Time
+	pcs     []uintptr
+}
+
+// spanLeakDetector is a span processor tracking the spans of StartTrace
+// until they end, and reporting the ones open for longer than maxAge. The
+// reported spans are no longer tracked, so the leaked spans do not pile up.
+type spanLeakDetector struct {
+	maxAge time.Duration
+	// logger reports the leaks, zap.L() if nil.
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	spans map[trace.SpanID]*openSpan
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+var _ sdktrace.SpanProcessor = (*spanLeakDetector)(nil)
+
+// newSpanLeakDetector returns a detector checking the open spans every
+// maxAge/2, but at most every minLeakCheckInterval, until it is shut down.
+func newSpanLeakDetector(maxAge time.Duration) *spanLeakDetector {
+	d := &spanLeakDetector{
+		maxAge: maxAge,
+		spans:  make(map[trace.SpanID]*openSpan),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	activeLeakDetectors.Add(1)
+	go d.run(max(maxAge/2, minLeakCheckInterval))
+	return d
+}
+
+func (d *spanLeakDetector) run(interval time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.check(now)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// OnStart records the stack starting a span of StartTrace.
+func (d *spanLeakDetector) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	if ctx.Value(startTraceKey{}) == nil {
+		return
+	}
+	pcs := make([]uintptr, maxLeakStackDepth)
+	pcs = pcs[:runtime.Callers(2, pcs)]
+
+	d.mu.Lock()
+	d.spans[s.SpanContext().SpanID()] = &openSpan{
+		name:    s.Name(),
+		traceID: s.SpanContext().TraceID(),
+		start:   s.StartTime(),
+		pcs:     pcs,
+	}
+	d.mu.Unlock()
+}
+
+// OnEnd stops tracking the span.
+func (d *spanLeakDetector) OnEnd(s sdktrace.ReadOnlySpan) {
+	d.mu.Lock()
+	delete(d.spans, s.SpanContext().SpanID())
+	d.mu.Unlock()
+}
+
+// check warns once about each span open for longer than maxAge at now, and
+// stops tracking it.
+func (d *spanLeakDetector) check(now time.Time) {
+	var leaks []*openSpan
+	d.mu.Lock()
+	for id, s := range d.spans {
+		if now.Sub(s.start) > d.maxAge {
+			delete(d.spans, id)
+			leaks = append(leaks, s)
+		}
+	}
+	d.mu.Unlock()
+
+	logger := d.logger
+	if logger == nil {
+		logger = zap.L()
+	}
+	for _, s := range leaks {
+		logger.Warn("span not ended, is span.End() missing?",
+			zap.String("span", s.name),
+			zap.String("traceID", s.traceID.String()),
+			zap.Duration("age", now.Sub(s.start)),
+			zap.String("stack", formatStack(s.pcs)),
+		)
+	}
+}
+
+// Shutdown stops the checks.
+func (d *spanLeakDetector) Shutdown(context.Context) error {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		activeLeakDetectors.Add(-1)
+	})
+	<-d.done
+	return nil
+}
+
+// ForceFlush does nothing, the detector does not export anything.
+func (d *spanLeakDetector) ForceFlush(context.Context) error {
+	return nil
+}
+
+// formatStack formats the stack starting a span, without the frames of the
+// OpenTelemetry SDK.
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "go.opentelemetry.io/otel/") {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		}
+		if !more {
+			return b.String()
+		}
+	}
+}
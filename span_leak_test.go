@@ -0,0 +1,57 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func startLeakySpan(tp trace.TracerProvider) trace.Span {
+	_, span := tp.Tracer("").Start(markStartTrace(context.Background()), "leaky")
+	return span
+}
+
+func TestSpanLeakDetector(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	d := newSpanLeakDetector(time.Hour)
+	d.logger = zap.New(core)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(d))
+	defer tp.Shutdown(context.Background())
+
+	startLeakySpan(tp)
+	_, ended := tp.Tracer("").Start(markStartTrace(context.Background()), "ended")
+	ended.End()
+	_, other := tp.Tracer("").Start(context.Background(), "other")
+	defer other.End()
+
+	d.check(time.Now())
+	assert.Zero(t, logs.Len())
+
+	d.check(time.Now().Add(2 * time.Hour))
+	require.Equal(t, 1, logs.Len())
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "leaky", fields["span"])
+	assert.Contains(t, fields["stack"], "startLeakySpan")
+	assert.NotContains(t, fields["stack"], "go.opentelemetry.io/otel/sdk")
+
+	// A leak is reported once, and no longer tracked.
+	d.check(time.Now().Add(3 * time.Hour))
+	assert.Equal(t, 1, logs.Len())
+	d.mu.Lock()
+	assert.Empty(t, d.spans)
+	d.mu.Unlock()
+}
+
+func TestSpanLeakDetectorTinyMaxAge(t *testing.T) {
+	// maxAge/2 is 0, which the ticker rejects.
+	d := newSpanLeakDetector(time.Nanosecond)
+	d.logger = zap.NewNop()
+	assert.NoError(t, d.Shutdown(context.Background()))
+}
@@ -0,0 +1,63 @@
+package otelsql
+
+// config is a group of options for the statement attributes.
+type config struct {
+	RawStatements bool
+	Dialect       Dialect
+}
+
+// Dialect is the SQL dialect of the statements, which tells the quoted
+// identifiers from the string literals and how the strings are escaped.
+type Dialect int
+
+const (
+	// DialectGeneric, the default, redacts the double-quoted strings like
+	// the single-quoted ones, since they are string literals in MySQL:
+	// the double-quoted identifiers are redacted too. The strings escape
+	// their quotes by doubling them, as in standard SQL.
+	DialectGeneric Dialect = iota
+	// DialectANSI keeps the double-quoted strings as identifiers, as in
+	// PostgreSQL, SQLite, Oracle or SQL Server, or MySQL in ANSI_QUOTES mode.
+	DialectANSI
+	// DialectMySQL redacts the double-quoted strings like DialectGeneric,
+	// and the backslashes of the strings escape the next character, as in
+	// MySQL and MariaDB unless NO_BACKSLASH_ESCAPES is set.
+	DialectMySQL
+)
+
+func newConfig(opts []Option) *config {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	return cfg
+}
+
+// Option applies an option value for a config.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithRawStatements returns an Option to keep the statements as they are,
+// literals included, in db.statement. It is meant for development only: the
+// literals may hold personal data or secrets, and every distinct statement
+// becomes a distinct value.
+func WithRawStatements(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.RawStatements = enabled
+	})
+}
+
+// WithDialect returns an Option to sanitize the statements of the dialect.
+// If none is specified, DialectGeneric is used.
+func WithDialect(d Dialect) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.Dialect = d
+	})
+}
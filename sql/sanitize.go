@@ -0,0 +1,289 @@
+// Package otelsql normalizes the SQL statements into low-cardinality span
+// attributes for the database instrumentations.
+package otelsql
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+// Attributes returns the db.statement and db.operation attributes of the
+// statement. The statement is sanitized unless WithRawStatements is set.
+func Attributes(query string, opts ...Option) []attribute.KeyValue {
+	cfg := newConfig(opts)
+	statement := query
+	if !cfg.RawStatements {
+		statement = sanitize(query, cfg.Dialect)
+	}
+	attrs := []attribute.KeyValue{semconv.DBStatement(statement)}
+	if op := Operation(query); op != "" {
+		attrs = append(attrs, semconv.DBOperation(op))
+	}
+	return attrs
+}
+
+// Operation returns the operation of the statement, its first keyword in
+// upper case, e.g. SELECT. The comments before it are skipped.
+func Operation(query string) string {
+	s := newScanner(query)
+	for !s.done() {
+		switch {
+		case s.skipSpaceOrComment():
+		case isIdentStart(s.peek()):
+			return strings.ToUpper(s.word())
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// Sanitize normalizes the statement: the string and number literals and the
+// placeholders are replaced by ?, the lists of them, e.g. IN (1, 2, 3), are
+// collapsed to (?), the comments are removed and the whitespace is
+// collapsed. The identifiers are kept, and the double-quoted ones with
+// WithDialect(DialectANSI) only. The backslashes escape the quotes of the
+// strings with WithDialect(DialectMySQL) only. The dollar-quoted strings of
+// PostgreSQL, $$...$$ or $tag$...$tag$, are string literals.
+func Sanitize(query string, opts ...Option) string {
+	return sanitize(query, newConfig(opts).Dialect)
+}
+
+func sanitize(query string, dialect Dialect) string {
+	s := newScanner(query)
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	write := func(tok string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(tok)
+	}
+	for !s.done() {
+		c := s.peek()
+		switch {
+		case s.skipSpaceOrComment():
+			space = true
+		case c == '\'' || (c == '"' && dialect != DialectANSI):
+			// Only the strings of MySQL escape with backslashes: in standard
+			// SQL, '\' is a complete string.
+			s.quoted(c, dialect == DialectMySQL)
+			write("?")
+		case c == '$' && s.dollarQuoted():
+			write("?")
+		case c == '"' || c == '`' || c == '[':
+			write(s.quoted(closing(c), false))
+		case isDigit(c) || (c == '.' && isDigit(s.peekAt(1))):
+			s.number()
+			write("?")
+		case c == '$' && isDigit(s.peekAt(1)):
+			s.pos++
+			s.number()
+			write("?")
+		case (c == ':' || c == '@') && isIdentStart(s.peekAt(1)) && s.peekAt(-1) != ':':
+			s.pos++
+			s.word()
+			write("?")
+		case c == '?':
+			s.pos++
+			write("?")
+		case isIdentStart(c):
+			write(s.word())
+		default:
+			s.pos++
+			write(string(c))
+		}
+	}
+	return collapseLists(b.String())
+}
+
+// collapseLists collapses the parenthesized lists of placeholders.
+func collapseLists(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] == '(' {
+			if j := placeholderList(s[i+1:]); j > 0 {
+				b.WriteString("(?)")
+				i += j + 1
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// placeholderList returns the length of the list of placeholders and its
+// closing parenthesis at the start of s, or 0.
+func placeholderList(s string) int {
+	i, n := 0, 0
+	for i < len(s) {
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i >= len(s) || s[i] != '?' {
+			return 0
+		}
+		i++
+		n++
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+		if i < len(s) && s[i] == ')' && n > 1 {
+			return i + 1
+		}
+		if i >= len(s) || s[i] != ',' {
+			return 0
+		}
+		i++
+	}
+	return 0
+}
+
+// scanner reads the tokens of a statement.
+type scanner struct {
+	src string
+	pos int
+}
+
+func newScanner(src string) *scanner {
+	return &scanner{src: src}
+}
+
+func (s *scanner) done() bool {
+	return s.pos >= len(s.src)
+}
+
+func (s *scanner) peek() byte {
+	return s.peekAt(0)
+}
+
+func (s *scanner) peekAt(offset int) byte {
+	i := s.pos + offset
+	if i < 0 || i >= len(s.src) {
+		return 0
+	}
+	return s.src[i]
+}
+
+// skipSpaceOrComment skips the whitespace or comment at the position,
+// reporting whether there was one.
+func (s *scanner) skipSpaceOrComment() bool {
+	switch c := s.peek(); {
+	case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		s.pos++
+	case c == '-' && s.peekAt(1) == '-':
+		if i := strings.IndexByte(s.src[s.pos:], '\n'); i >= 0 {
+			s.pos += i + 1
+		} else {
+			s.pos = len(s.src)
+		}
+	case c == '/' && s.peekAt(1) == '*':
+		if i := strings.Index(s.src[s.pos+2:], "*/"); i >= 0 {
+			s.pos += i + 4
+		} else {
+			s.pos = len(s.src)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// quoted reads the quoted token at the position, the doubled closing quotes
+// and, with backslash, the backslashed characters being escapes, and returns
+// it.
+func (s *scanner) quoted(end byte, backslash bool) string {
+	start := s.pos
+	s.pos++
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		s.pos++
+		if c == '\\' && backslash {
+			s.pos++
+			continue
+		}
+		if c == end {
+			if s.peek() == end && end != ']' {
+				s.pos++
+				continue
+			}
+			break
+		}
+	}
+	if s.pos > len(s.src) {
+		s.pos = len(s.src)
+	}
+	return s.src[start:s.pos]
+}
+
+// dollarQuoted reads the dollar-quoted string at the position, $$...$$ or
+// $tag$...$tag$, reporting whether there was one.
+func (s *scanner) dollarQuoted() bool {
+	end := s.pos + 1
+	for end < len(s.src) && s.src[end] != '$' {
+		if c := s.src[end]; !isIdentStart(c) && (end == s.pos+1 || !isDigit(c)) {
+			return false
+		}
+		end++
+	}
+	if end >= len(s.src) {
+		return false
+	}
+	tag := s.src[s.pos : end+1]
+	if i := strings.Index(s.src[end+1:], tag); i >= 0 {
+		s.pos = end + 1 + i + len(tag)
+	} else {
+		s.pos = len(s.src)
+	}
+	return true
+}
+
+// number reads the number at the position, in decimal, scientific or
+// hexadecimal notation.
+func (s *scanner) number() {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case isDigit(c) || c == '.' || c == 'x' || c == 'X' ||
+			(c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'):
+		case (c == '+' || c == '-') && (s.peekAt(-1) == 'e' || s.peekAt(-1) == 'E'):
+		default:
+			return
+		}
+		s.pos++
+	}
+}
+
+// word reads the identifier at the position and returns it.
+func (s *scanner) word() string {
+	start := s.pos
+	for s.pos < len(s.src) && isIdentPart(s.src[s.pos]) {
+		s.pos++
+	}
+	return s.src[start:s.pos]
+}
+
+func closing(c byte) byte {
+	if c == '[' {
+		return ']'
+	}
+	return c
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$' || c == '.'
+}
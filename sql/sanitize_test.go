@@ -0,0 +1,72 @@
+package otelsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM users WHERE id = 42", "SELECT * FROM users WHERE id = ?"},
+		{"SELECT * FROM users WHERE email = 'a@b.c' AND name = 'O''Brien'", "SELECT * FROM users WHERE email = ? AND name = ?"},
+		{"SELECT * FROM t WHERE id IN (1, 2, 3)", "SELECT * FROM t WHERE id IN (?)"},
+		{"SELECT * FROM t WHERE id IN ($1,$2, $3)", "SELECT * FROM t WHERE id IN (?)"},
+		{"INSERT INTO t (a, b) VALUES (?, ?), (?, ?)", "INSERT INTO t (a, b) VALUES (?), (?)"},
+		{"UPDATE t SET v = :value WHERE id = @id", "UPDATE t SET v = ? WHERE id = ?"},
+		{"SELECT \"Weird 1\", `col2`, [col 3] FROM t2", "SELECT ?, `col2`, [col 3] FROM t2"},
+		{`SELECT * FROM users WHERE password = "hunter2" AND name = "a""b"`, "SELECT * FROM users WHERE password = ? AND name = ?"},
+		{`SELECT * FROM t WHERE a = '\' AND b = 'hunter2'`, "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{"SELECT $$it's secret$$, $body$a $$ b$body$, $1 FROM t", "SELECT ?, ?, ? FROM t"},
+		{"SELECT price$ FROM t WHERE a = $x$open", "SELECT price$ FROM t WHERE a = ?"},
+		{"SELECT a::int, 1.5e-3, 0xFF FROM t", "SELECT a::int, ?, ? FROM t"},
+		{"/* app */ SELECT  a\n\tFROM t -- trailing\nWHERE b = -1", "SELECT a FROM t WHERE b = -?"},
+		{"SELECT count(id) FROM t", "SELECT count(id) FROM t"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Sanitize(tt.query), tt.query)
+	}
+}
+
+func TestSanitizeANSIDialect(t *testing.T) {
+	assert.Equal(t, `SELECT "Weird 1", "a\" FROM t WHERE b = ?`,
+		Sanitize(`SELECT "Weird 1", "a\" FROM t WHERE b = 'x'`, WithDialect(DialectANSI)))
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.DBStatement(`SELECT "id" FROM "users"`),
+		semconv.DBOperation("SELECT"),
+	}, Attributes(`SELECT "id" FROM "users"`, WithDialect(DialectANSI)))
+}
+
+func TestSanitizeBackslashes(t *testing.T) {
+	// A backslash does not escape the quote of a standard SQL string.
+	assert.Equal(t, "SELECT * FROM t WHERE a = ? AND b = ?",
+		Sanitize(`SELECT * FROM t WHERE a = '\' AND b = 'hunter2'`, WithDialect(DialectANSI)))
+	assert.Equal(t, "SELECT * FROM users WHERE password = ? AND name = ?",
+		Sanitize(`SELECT * FROM users WHERE password = "hunter2" AND name = "a\"b"`, WithDialect(DialectMySQL)))
+	assert.Equal(t, "SELECT * FROM t WHERE a = ? AND b = ?",
+		Sanitize(`SELECT * FROM t WHERE a = 'it\'s' AND b = 'hunter2'`, WithDialect(DialectMySQL)))
+}
+
+func TestOperation(t *testing.T) {
+	assert.Equal(t, "SELECT", Operation("select 1"))
+	assert.Equal(t, "INSERT", Operation("-- comment\n /* x */ insert into t values (1)"))
+	assert.Equal(t, "", Operation("(SELECT 1)"))
+	assert.Equal(t, "", Operation(""))
+}
+
+func TestAttributes(t *testing.T) {
+	query := "SELECT * FROM users WHERE id = 42"
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.DBStatement("SELECT * FROM users WHERE id = ?"),
+		semconv.DBOperation("SELECT"),
+	}, Attributes(query))
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.DBStatement(query),
+		semconv.DBOperation("SELECT"),
+	}, Attributes(query, WithRawStatements(true)))
+}
@@ -0,0 +1,86 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// stdoutSpanExporter writes the spans as indented JSON, for local development.
+type stdoutSpanExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+var _ sdktrace.SpanExporter = (*stdoutSpanExporter)(nil)
+
+func newStdoutSpanExporter(w io.Writer) *stdoutSpanExporter {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return &stdoutSpanExporter{enc: enc}
+}
+
+// ExportSpans writes the spans to the writer.
+func (e *stdoutSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, stub := range tracetest.SpanStubsFromReadOnlySpans(spans) {
+		if err := e.enc.Encode(stub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown is a no-op, the writer is owned by the caller.
+func (e *stdoutSpanExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// stdoutMetricExporter writes the metrics as indented JSON, for local development.
+type stdoutMetricExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	cfg *config
+}
+
+var _ sdkmetric.Exporter = (*stdoutMetricExporter)(nil)
+
+func newStdoutMetricExporter(w io.Writer, cfg *config) *stdoutMetricExporter {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return &stdoutMetricExporter{enc: enc, cfg: cfg}
+}
+
+// Temporality returns the temporality selected for the instrument kind.
+func (e *stdoutMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return e.cfg.TemporalitySelector(kind)
+}
+
+// Aggregation returns the aggregation selected for the instrument kind.
+func (e *stdoutMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return e.cfg.AggregationSelector(kind)
+}
+
+// Export writes the metrics to the writer.
+func (e *stdoutMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(rm)
+}
+
+// ForceFlush is a no-op, the metrics are written synchronously.
+func (e *stdoutMetricExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// Shutdown is a no-op, the writer is owned by the caller.
+func (e *stdoutMetricExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
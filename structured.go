@@ -0,0 +1,385 @@
+package kgsotel
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// zapField converts the field to a zap field keeping the structure of its
+// value: the maps and the structs are encoded as objects and the slices as
+// arrays, so the OTLP log records get nested attributes of native types
+// instead of formatted strings. Like the JSON encoding, the struct fields
+// follow their json tags and the json.Marshaler values encode themselves.
+func zapField(field Field) zap.Field {
+	// The scalars, most of the fields, are encoded without reflection.
+	switch v := field.Value.(type) {
+	case string:
+		return zap.String(field.Key, v)
+	case bool:
+		return zap.Bool(field.Key, v)
+	case int:
+		return zap.Int(field.Key, v)
+	case int64:
+		return zap.Int64(field.Key, v)
+	case int32:
+		return zap.Int32(field.Key, v)
+	case uint:
+		return zap.Uint(field.Key, v)
+	case uint64:
+		return zap.Uint64(field.Key, v)
+	case uint32:
+		return zap.Uint32(field.Key, v)
+	case float64:
+		return zap.Float64(field.Key, v)
+	case float32:
+		return zap.Float32(field.Key, v)
+	case nil, []byte, error, fmt.Stringer, time.Time, time.Duration,
+		zapcore.ObjectMarshaler, zapcore.ArrayMarshaler, json.Marshaler:
+		return zap.Any(field.Key, field.Value)
+	}
+	rv := reflect.ValueOf(field.Value)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array, reflect.Pointer, reflect.Interface:
+	default:
+		return zap.Any(field.Key, field.Value)
+	}
+	w := &walker{seen: make(map[visit]bool)}
+	v, visits, _ := w.check(rv)
+	switch v.Kind() {
+	case reflect.Map, reflect.Struct:
+		return zap.Object(field.Key, structuredObject{v, w, visits})
+	case reflect.Slice, reflect.Array:
+		return zap.Array(field.Key, structuredArray{v, w, visits})
+	default:
+		return zap.Any(field.Key, field.Value)
+	}
+}
+
+// maxStructuredDepth bounds the nesting of the encoded values.
+const maxStructuredDepth = 32
+
+// The values replacing the ones not encoded.
+const (
+	structuredCycle   = "<cycle>"
+	structuredTooDeep = "<max depth>"
+)
+
+// visit is a pointer, map or slice being encoded.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// walker guards the encoding of a value against the cycles of its
+// references and too deep nesting.
+type walker struct {
+	depth int
+	seen  map[visit]bool
+}
+
+// check dereferences v and returns its references, or the reason v is not
+// encoded if it refers to a value being encoded or is nested too deep.
+func (w *walker) check(v reflect.Value) (e reflect.Value, visits []visit, reason string) {
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		if v.Kind() == reflect.Pointer {
+			visits = append(visits, visit{v.Pointer(), v.Type()})
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && !v.IsNil() {
+		visits = append(visits, visit{v.Pointer(), v.Type()})
+	}
+	if w.depth >= maxStructuredDepth {
+		return v, nil, structuredTooDeep
+	}
+	for _, vis := range visits {
+		if w.seen[vis] {
+			return v, nil, structuredCycle
+		}
+	}
+	return v, visits, ""
+}
+
+// enter marks the references of a value as being encoded until the
+// returned function is called. Each encoder walks the value again, so the
+// marks last for the encoding of the value only.
+func (w *walker) enter(visits []visit) func() {
+	for _, vis := range visits {
+		w.seen[vis] = true
+	}
+	w.depth++
+	return func() {
+		w.depth--
+		for _, vis := range visits {
+			delete(w.seen, vis)
+		}
+	}
+}
+
+// indirect dereferences the pointers and the interfaces of v.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// structuredObject encodes a map or the exported fields of a struct.
+type structuredObject struct {
+	v      reflect.Value
+	w      *walker
+	visits []visit
+}
+
+func (o structuredObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	defer o.w.enter(o.visits)()
+	if o.v.Kind() == reflect.Struct {
+		t := o.v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, ok := jsonName(f)
+			if !ok {
+				continue
+			}
+			if err := o.w.add(enc, name, o.v.Field(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	keys := o.v.MapKeys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = fmt.Sprint(k.Interface())
+	}
+	// The keys are sorted so the records are deterministic.
+	sort.Sort(byName{names, keys})
+	for i, k := range keys {
+		if err := o.w.add(enc, names[i], o.v.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonName returns the name of the struct field f in the JSON encoding, or
+// false if it is not encoded: unexported or tagged json:"-".
+func jsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return f.Name, true
+}
+
+// byName sorts the map keys by their names.
+type byName struct {
+	names []string
+	keys  []reflect.Value
+}
+
+func (b byName) Len() int           { return len(b.names) }
+func (b byName) Less(i, j int) bool { return b.names[i] < b.names[j] }
+func (b byName) Swap(i, j int) {
+	b.names[i], b.names[j] = b.names[j], b.names[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
+
+// structuredArray encodes a slice or an array.
+type structuredArray struct {
+	v      reflect.Value
+	w      *walker
+	visits []visit
+}
+
+func (a structuredArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	defer a.w.enter(a.visits)()
+	for i := 0; i < a.v.Len(); i++ {
+		if err := a.w.append(enc, a.v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonMarshaler returns the value of v encoding itself in JSON.
+func jsonMarshaler(v reflect.Value) (json.Marshaler, bool) {
+	for v.IsValid() && v.CanInterface() {
+		if m, ok := v.Interface().(json.Marshaler); ok {
+			if v.Kind() == reflect.Pointer && v.IsNil() {
+				return nil, false
+			}
+			return m, true
+		}
+		if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface || v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	return nil, false
+}
+
+// scalar returns the value of v converted to bool, int64, uint64, float64 or
+// string, or false if v is not a scalar.
+func scalar(v reflect.Value) (any, bool) {
+	if v = indirect(v); !v.IsValid() {
+		return nil, false
+	}
+	if v.CanInterface() {
+		switch i := v.Interface().(type) {
+		case time.Time, time.Duration:
+			return nil, false
+		case error:
+			return i.Error(), true
+		case fmt.Stringer:
+			return i.String(), true
+		case encoding.TextMarshaler:
+			if text, err := i.MarshalText(); err == nil {
+				return string(text), true
+			}
+		}
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return v.String(), true
+	}
+	return nil, false
+}
+
+func (w *walker) add(enc zapcore.ObjectEncoder, key string, v reflect.Value) error {
+	if s, ok := scalar(v); ok {
+		switch s := s.(type) {
+		case bool:
+			enc.AddBool(key, s)
+		case int64:
+			enc.AddInt64(key, s)
+		case uint64:
+			enc.AddUint64(key, s)
+		case float64:
+			enc.AddFloat64(key, s)
+		case string:
+			enc.AddString(key, s)
+		}
+		return nil
+	}
+	e := indirect(v)
+	if t, ok := asTime(e); ok {
+		enc.AddTime(key, t)
+		return nil
+	}
+	if m, ok := jsonMarshaler(v); ok {
+		return enc.AddReflected(key, m)
+	}
+	switch e.Kind() {
+	case reflect.Map, reflect.Struct:
+		e, visits, reason := w.check(v)
+		if reason != "" {
+			enc.AddString(key, reason)
+			return nil
+		}
+		return enc.AddObject(key, structuredObject{e, w, visits})
+	case reflect.Slice, reflect.Array:
+		if b, ok := e.Interface().([]byte); ok {
+			enc.AddBinary(key, b)
+			return nil
+		}
+		e, visits, reason := w.check(v)
+		if reason != "" {
+			enc.AddString(key, reason)
+			return nil
+		}
+		return enc.AddArray(key, structuredArray{e, w, visits})
+	case reflect.Invalid, reflect.Pointer, reflect.Interface:
+		// nil values are skipped.
+		return nil
+	}
+	if d, ok := e.Interface().(time.Duration); ok {
+		enc.AddDuration(key, d)
+		return nil
+	}
+	enc.AddString(key, fmt.Sprint(e.Interface()))
+	return nil
+}
+
+func (w *walker) append(enc zapcore.ArrayEncoder, v reflect.Value) error {
+	if s, ok := scalar(v); ok {
+		switch s := s.(type) {
+		case bool:
+			enc.AppendBool(s)
+		case int64:
+			enc.AppendInt64(s)
+		case uint64:
+			enc.AppendUint64(s)
+		case float64:
+			enc.AppendFloat64(s)
+		case string:
+			enc.AppendString(s)
+		}
+		return nil
+	}
+	e := indirect(v)
+	if t, ok := asTime(e); ok {
+		enc.AppendTime(t)
+		return nil
+	}
+	if m, ok := jsonMarshaler(v); ok {
+		return enc.AppendReflected(m)
+	}
+	switch e.Kind() {
+	case reflect.Map, reflect.Struct:
+		e, visits, reason := w.check(v)
+		if reason != "" {
+			enc.AppendString(reason)
+			return nil
+		}
+		return enc.AppendObject(structuredObject{e, w, visits})
+	case reflect.Slice, reflect.Array:
+		e, visits, reason := w.check(v)
+		if reason != "" {
+			enc.AppendString(reason)
+			return nil
+		}
+		return enc.AppendArray(structuredArray{e, w, visits})
+	case reflect.Invalid, reflect.Pointer, reflect.Interface:
+		enc.AppendString("<nil>")
+		return nil
+	}
+	if d, ok := e.Interface().(time.Duration); ok {
+		enc.AppendDuration(d)
+		return nil
+	}
+	enc.AppendString(fmt.Sprint(e.Interface()))
+	return nil
+}
+
+// asTime returns the time of v if it is a time.Time.
+func asTime(v reflect.Value) (time.Time, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return time.Time{}, false
+	}
+	t, ok := v.Interface().(time.Time)
+	return t, ok
+}
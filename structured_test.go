@@ -0,0 +1,140 @@
+package kgsotel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.uber.org/zap"
+)
+
+type structuredOrder struct {
+	ID     int
+	Paid   bool
+	Items  []string
+	Labels map[string]any
+	secret string
+}
+
+// emitFields logs the fields through the OTLP core and returns the record
+// attributes by key.
+func emitFields(t *testing.T, fields ...Field) map[string]log.Value {
+	rec := &recordingProcessor{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(rec))
+	logger := initLogger("test", lp, zap.NewAtomicLevelAt(zap.InfoLevel), loggerConfig{})
+	zapFields := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		zapFields[i] = zapField(f)
+	}
+	logger.Info("structured", zapFields...)
+	require.Len(t, rec.records, 1)
+
+	attrs := map[string]log.Value{}
+	rec.records[0].WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	return attrs
+}
+
+func TestZapFieldStructured(t *testing.T) {
+	order := &structuredOrder{
+		ID:     42,
+		Paid:   true,
+		Items:  []string{"book", "pen"},
+		Labels: map[string]any{"weight": float32(1.5), "count": uint8(3)},
+		secret: "hidden",
+	}
+	attrs := emitFields(t,
+		Field{Key: "order", Value: order},
+		Field{Key: "sizes", Value: []any{int32(1), "m", nil}},
+		Field{Key: "amount", Value: int16(7)},
+		Field{Key: "err", Value: errors.New("boom")},
+		Field{Key: "elapsed", Value: time.Second},
+	)
+
+	require.Equal(t, log.KindMap, attrs["order"].Kind())
+	assert.Equal(t, []log.KeyValue{
+		log.Int64("ID", 42),
+		log.Bool("Paid", true),
+		log.Slice("Items", log.StringValue("book"), log.StringValue("pen")),
+		log.Map("Labels", log.Int64("count", 3), log.Float64("weight", 1.5)),
+	}, attrs["order"].AsMap())
+	assert.Equal(t, []log.Value{log.Int64Value(1), log.StringValue("m"), log.StringValue("<nil>")}, attrs["sizes"].AsSlice())
+	assert.Equal(t, int64(7), attrs["amount"].AsInt64())
+	assert.Equal(t, "boom", attrs["err"].AsString())
+	assert.Equal(t, int64(time.Second), attrs["elapsed"].AsInt64())
+}
+
+type structuredNode struct {
+	Name   string
+	Parent *structuredNode
+}
+
+type structuredUser struct {
+	Name     string `json:"name"`
+	Password string `json:"-"`
+	Balance  structuredMoney
+}
+
+type structuredMoney struct {
+	cents int64
+}
+
+func (m structuredMoney) MarshalJSON() ([]byte, error) {
+	return []byte(`{"amount":"1.50"}`), nil
+}
+
+func TestZapFieldStructuredJSON(t *testing.T) {
+	attrs := emitFields(t, Field{Key: "user", Value: structuredUser{Name: "ann", Password: "hunter2", Balance: structuredMoney{150}}})
+
+	user := map[string]log.Value{}
+	for _, kv := range attrs["user"].AsMap() {
+		user[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "ann", user["name"].AsString())
+	assert.NotContains(t, user, "Password")
+	assert.NotContains(t, user, "Name")
+	require.Contains(t, user, "Balance")
+	assert.NotEqual(t, log.KindEmpty, user["Balance"].Kind())
+}
+
+func TestZapFieldStructuredCycle(t *testing.T) {
+	n := &structuredNode{Name: "root"}
+	n.Parent = n
+	attrs := emitFields(t, Field{Key: "node", Value: n})
+	assert.Equal(t, []log.KeyValue{
+		log.String("Name", "root"),
+		log.String("Parent", "<cycle>"),
+	}, attrs["node"].AsMap())
+
+	// The same value twice is not a cycle.
+	shared := &structuredNode{Name: "shared"}
+	attrs = emitFields(t, Field{Key: "nodes", Value: []*structuredNode{shared, shared}})
+	require.Len(t, attrs["nodes"].AsSlice(), 2)
+	assert.Equal(t, log.KindMap, attrs["nodes"].AsSlice()[1].Kind())
+
+	deep := &structuredNode{Name: "0"}
+	for i := 0; i < 100; i++ {
+		deep = &structuredNode{Name: "n", Parent: deep}
+	}
+	require.NotPanics(t, func() { emitFields(t, Field{Key: "deep", Value: deep}) })
+}
+
+func TestZapFieldScalars(t *testing.T) {
+	assert.Equal(t, zap.String("user", "ada"), zapField(Field{Key: "user", Value: "ada"}))
+	assert.Equal(t, zap.Int("attempt", 3), zapField(Field{Key: "attempt", Value: 3}))
+	assert.Equal(t, zap.Bool("paid", true), zapField(Field{Key: "paid", Value: true}))
+	assert.Equal(t, zap.Float64("ratio", 0.5), zapField(Field{Key: "ratio", Value: 0.5}))
+
+	// The scalars do not go through the walker of the structured values.
+	allocs := testing.AllocsPerRun(100, func() {
+		zapField(Field{Key: "user", Value: "ada"})
+		zapField(Field{Key: "attempt", Value: 3})
+	})
+	assert.Zero(t, allocs)
+}
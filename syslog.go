@@ -0,0 +1,247 @@
+package kgsotel
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SyslogFacility is the facility of the syslog messages.
+type SyslogFacility int
+
+const (
+	SyslogUser   SyslogFacility = 1
+	SyslogDaemon SyslogFacility = 3
+	SyslogLocal0 SyslogFacility = 16
+	SyslogLocal1 SyslogFacility = 17
+	SyslogLocal2 SyslogFacility = 18
+	SyslogLocal3 SyslogFacility = 19
+	SyslogLocal4 SyslogFacility = 20
+	SyslogLocal5 SyslogFacility = 21
+	SyslogLocal6 SyslogFacility = 22
+	SyslogLocal7 SyslogFacility = 23
+)
+
+// syslogSDID is the ID of the structured data element holding the fields of
+// the logs, under the private enterprise number reserved for documentation.
+const syslogSDID = "kgsotel@32473"
+
+// syslogLocalSockets are the paths of the local syslog daemon socket.
+var syslogLocalSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// WithSyslog returns an Option to also write the logs to syslog in the RFC
+// 5424 format, with the fields of the logs as structured data and the zap
+// levels mapped to the syslog severities. The network is "udp", "tcp",
+// "unix" or "unixgram", or empty for the local syslog daemon, in which case
+// addr is ignored. Over streams, the messages are framed by octet counting
+// (RFC 6587). The connection is opened by the first log, an unreachable
+// server drops the logs without failing the initialization.
+func WithSyslog(network, addr string, facility SyslogFacility) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.LogCores = append(cfg.LogCores, func(serviceName string, level zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+			w := newSyslogWriter(network, addr)
+			return newSyslogCore(serviceName, facility, level, w), w.Close, nil
+		})
+	})
+}
+
+// syslogCore is a zap core writing the entries as RFC 5424 messages.
+type syslogCore struct {
+	zapcore.LevelEnabler
+	facility SyslogFacility
+	// header holds the HOSTNAME APP-NAME PROCID MSGID fields.
+	header string
+	fields []zapcore.Field
+	w      *syslogWriter
+}
+
+func newSyslogCore(serviceName string, facility SyslogFacility, level zapcore.LevelEnabler, w *syslogWriter) *syslogCore {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "-"
+	}
+	header := fmt.Sprintf("%s %s %d -", syslogName(host, 255), syslogName(serviceName, 48), os.Getpid())
+	return &syslogCore{LevelEnabler: level, facility: facility, header: header, w: w}
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.w.write(c.message(ent, fields))
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
+
+// message returns the RFC 5424 message of the entry.
+func (c *syslogCore) message(ent zapcore.Entry, fields []zapcore.Field) []byte {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	var b strings.Builder
+	pri := int(c.facility)*8 + syslogSeverity(ent.Level)
+	fmt.Fprintf(&b, "<%d>1 %s %s ", pri, ent.Time.UTC().Format(time.RFC3339Nano), c.header)
+	if len(enc.Fields) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[" + syslogSDID)
+		for _, k := range slices.Sorted(maps.Keys(enc.Fields)) {
+			fmt.Fprintf(&b, ` %s="%s"`, syslogParamName(k), syslogEscape(fmt.Sprint(enc.Fields[k])))
+		}
+		b.WriteString("]")
+	}
+	b.WriteString(" ")
+	b.WriteString(ent.Message)
+	return []byte(b.String())
+}
+
+// syslogName returns the printable ASCII characters of s, without spaces,
+// truncated to max characters, or the nil value if none is left.
+func syslogName(s string, max int) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(name) > max {
+		name = name[:max]
+	}
+	if name == "" {
+		return "-"
+	}
+	return name
+}
+
+// syslogParamName returns a valid structured data parameter name for key.
+func syslogParamName(key string) string {
+	return syslogName(strings.NewReplacer("=", "_", "]", "_", `"`, "_").Replace(key), 32)
+}
+
+// syslogEscape escapes the characters of a structured data value.
+func syslogEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "]", `\]`).Replace(s)
+}
+
+// syslogSeverity maps a zap level to a syslog severity.
+func syslogSeverity(l zapcore.Level) int {
+	switch l {
+	case zapcore.DebugLevel:
+		return 7 // debug
+	case zapcore.InfoLevel:
+		return 6 // informational
+	case zapcore.WarnLevel:
+		return 4 // warning
+	case zapcore.ErrorLevel:
+		return 3 // error
+	default:
+		return 2 // critical
+	}
+}
+
+// syslogWriter sends the messages to syslog, one per datagram or framed by
+// octet counting over streams. The connection is opened by the first message,
+// and a stream connection is reopened after an error.
+type syslogWriter struct {
+	network string
+	addr    string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	dialer sinkDialer
+	closed bool
+}
+
+func newSyslogWriter(network, addr string) *syslogWriter {
+	return &syslogWriter{network: network, addr: addr}
+}
+
+// connect opens the connection, to the local syslog daemon if no network is set.
+func (w *syslogWriter) connect() error {
+	conn, err := w.dialer.dial(func() (net.Conn, error) {
+		if w.network != "" {
+			return net.DialTimeout(w.network, w.addr, sinkDialTimeout)
+		}
+		for _, path := range syslogLocalSockets {
+			for _, network := range []string{"unixgram", "unix"} {
+				if conn, err := net.DialTimeout(network, path, sinkDialTimeout); err == nil {
+					w.network, w.addr = network, path
+					return conn, nil
+				}
+			}
+		}
+		return nil, errors.New("no local syslog daemon found")
+	})
+	if err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// isStream reports whether the messages must be framed.
+func (w *syslogWriter) isStream() bool {
+	return w.network == "tcp" || w.network == "unix"
+}
+
+func (w *syslogWriter) write(msg []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return errors.New("syslog: writer closed")
+	}
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return err
+		}
+	}
+	if w.isStream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	if err := sinkWrite(w.conn, msg); err != nil {
+		w.conn.Close()
+		w.conn = nil
+		return fmt.Errorf("syslog: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection.
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,99 @@
+package kgsotel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	w := newSyslogWriter("udp", conn.LocalAddr().String())
+	defer w.Close()
+	logger := zap.New(newSyslogCore("my svc", SyslogLocal0, zapcore.DebugLevel, w))
+	logger.With(zap.String("traceID", "abc")).Warn("disk full", zap.String("path", `C:\data "x"]`))
+	logger.Info("no fields")
+
+	host, _ := os.Hostname()
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	parts := strings.SplitN(string(buf[:n]), " ", 7)
+	require.Len(t, parts, 7)
+	assert.Equal(t, "<132>1", parts[0]) // local0 (16) * 8 + warning (4)
+	_, err = time.Parse(time.RFC3339Nano, parts[1])
+	assert.NoError(t, err)
+	assert.Equal(t, host, parts[2])
+	assert.Equal(t, "mysvc", parts[3])
+	assert.Equal(t, strconv.Itoa(os.Getpid()), parts[4])
+	assert.Equal(t, "-", parts[5])
+	assert.Equal(t, `[kgsotel@32473 path="C:\\data \"x\"\]" traceID="abc"] disk full`, parts[6])
+
+	n, _, err = conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<134>1 "))
+	assert.True(t, strings.HasSuffix(string(buf[:n]), " - no fields"))
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	w := newSyslogWriter("tcp", lis.Addr().String())
+	defer w.Close()
+	logger := zap.New(newSyslogCore("svc", SyslogUser, zapcore.DebugLevel, w))
+	logger.Error("first")
+	logger.Error("second")
+
+	conn, err := lis.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"first", "second"} {
+		var size int
+		_, err := fmt.Fscanf(r, "%d ", &size)
+		require.NoError(t, err)
+		msg := make([]byte, size)
+		_, err = io.ReadFull(r, msg)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(msg), "<11>1 "), string(msg))
+		assert.True(t, strings.HasSuffix(string(msg), " - "+want), string(msg))
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	assert.Equal(t, 7, syslogSeverity(zapcore.DebugLevel))
+	assert.Equal(t, 6, syslogSeverity(zapcore.InfoLevel))
+	assert.Equal(t, 4, syslogSeverity(zapcore.WarnLevel))
+	assert.Equal(t, 3, syslogSeverity(zapcore.ErrorLevel))
+	assert.Equal(t, 2, syslogSeverity(zapcore.FatalLevel))
+}
+
+func TestSyslogUnreachable(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	w := newSyslogWriter("tcp", addr)
+	defer w.Close()
+	assert.Error(t, w.write([]byte("msg")))
+	assert.ErrorIs(t, w.write([]byte("msg")), errSinkUnreachable, "the dial is not retried at once")
+}
@@ -0,0 +1,467 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"kgs/otel/internal/semconvutil"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	lognoop "go.opentelemetry.io/otel/log/noop"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+)
+
+// scopeName is the instrumentation scope name of the package own telemetry.
+const scopeName = "kgs/otel"
+
+// Config is the configuration of a Telemetry created by New.
+type Config struct {
+	// Name identifies the pipeline when several of them run in the process.
+	// Named pipelines can be retrieved with Lookup until they are shut down.
+	Name string
+	// ServiceName is the service.name of the resource. It is required.
+	ServiceName string
+	// Endpoint is the address of the OTLP collector. It is required unless
+	// the telemetry is written to stdout.
+	Endpoint string
+	// Options are the same options accepted by InitTelemetry.
+	Options []Option
+}
+
+// Validate returns an error describing every invalid field of the configuration.
+func (c Config) Validate() error {
+	return c.validate(newConfig(c.Options...))
+}
+
+func (c Config) validate(cfg *config) error {
+	var err error
+	if c.ServiceName == "" {
+		err = errors.Join(err, errors.New("service name is required"))
+	}
+	if c.Endpoint == "" && !cfg.StdoutExporters {
+		err = errors.Join(err, errors.New("endpoint is required"))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// Telemetry owns the providers of a telemetry pipeline. Unlike InitTelemetry,
+// it does not touch the global providers, which makes it suitable for
+// libraries and tests.
+type Telemetry struct {
+	name string
+	cfg  *config
+	conn *grpc.ClientConn
+
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  *sdkmetric.MeterProvider
+	loggerProvider *sdklog.LoggerProvider
+	logger         *zap.Logger
+	logDedup       *logDedup
+	resource       *resource.Resource
+
+	sampler  *dynamicSampler
+	logLevel zap.AtomicLevel
+	status   *pipelineStatus
+
+	propagator propagation.TextMapPropagator
+
+	global        bool
+	closed        atomic.Bool
+	shutdownFuncs []func(context.Context) error
+}
+
+// registry holds the named pipelines created by New. A nil pipeline reserves
+// the name of one being created.
+var registry = struct {
+	sync.Mutex
+	pipelines map[string]*Telemetry
+}{pipelines: make(map[string]*Telemetry)}
+
+// New validates the configuration and creates the providers of a new
+// telemetry pipeline. Call Shutdown to release them.
+// The names of the pipelines must be unique.
+func New(ctx context.Context, c Config) (*Telemetry, error) {
+	if c.Name != "" {
+		// The name is reserved while the pipeline is created, which may dial
+		// the collector, so the registry is not locked meanwhile.
+		registry.Lock()
+		if _, ok := registry.pipelines[c.Name]; ok {
+			registry.Unlock()
+			return nil, fmt.Errorf("telemetry %q already exists", c.Name)
+		}
+		registry.pipelines[c.Name] = nil
+		registry.Unlock()
+	}
+
+	t, err := newTelemetry(ctx, c, &dynamicSampler{}, zap.NewAtomicLevel(), &pipelineStatus{})
+	if c.Name != "" {
+		registry.Lock()
+		if err != nil {
+			delete(registry.pipelines, c.Name)
+		} else {
+			registry.pipelines[c.Name] = t
+		}
+		registry.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Lookup returns the pipeline created by New with the given name.
+func Lookup(name string) (*Telemetry, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+	t := registry.pipelines[name]
+	return t, t != nil
+}
+
+func newTelemetry(ctx context.Context, c Config, sampler *dynamicSampler, level zap.AtomicLevel, status *pipelineStatus) (
+	t *Telemetry, err error) {
+
+	cfg := newConfig(c.Options...)
+	t = &Telemetry{
+		name:       c.Name,
+		cfg:        cfg,
+		sampler:    sampler,
+		logLevel:   level,
+		status:     status,
+		propagator: newPropagator(cfg),
+	}
+	if cfg.Disabled {
+		// Every provider is a no-op, the logs of the application are only
+		// written to the console.
+		t.logLevel.SetLevel(cfg.LogLevel)
+		t.logger = initLogger(c.ServiceName, t.LoggerProvider(), t.logLevel, loggerConfig{
+			encoderConfig: cfg.ZapEncoderConfig,
+			options:       cfg.ZapOptions,
+		})
+		return t, nil
+	}
+	if err := c.validate(cfg); err != nil {
+		return nil, err
+	}
+
+	t.logLevel.SetLevel(cfg.LogLevel)
+	t.status.readiness.Store(&readinessThresholds{
+		failureWindow: cfg.ReadinessFailureWindow,
+		saturation:    cfg.ReadinessSaturation,
+	})
+	t.status.logQueue.Store(nil)
+	t.status.metrics.Store(nil)
+	if cfg.BreakerThreshold > 0 {
+		t.status.breaker.Store(newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown))
+	}
+
+	// HandleErr calls shutdown for cleanup and makes sure that all errors are returned.
+	handleErr := func(inErr error) (*Telemetry, error) {
+		return nil, errors.Join(inErr, t.shutdown(ctx))
+	}
+
+	// Create a new gRPC client connection
+	if !cfg.StdoutExporters {
+		t.conn, err = initConn(c.Endpoint, cfg)
+		if err != nil {
+			return handleErr(err)
+		}
+		t.status.conn.Store(t.conn)
+		t.shutdownFuncs = append(t.shutdownFuncs, func(context.Context) error {
+			return t.conn.Close()
+		})
+
+		if cfg.StartupProbeTimeout > 0 {
+			if err := probeConn(ctx, t.conn, cfg.StartupProbeTimeout); err != nil {
+				return handleErr(err)
+			}
+		}
+	}
+
+	// Set up a resource with a service name attribute
+	res, err := newResource(ctx, c.ServiceName, cfg)
+	if err != nil {
+		return handleErr(err)
+	}
+	t.resource = res
+
+	// Initialize the trace provider
+	if !cfg.DisabledSignals[SignalTraces] {
+		tp, err := initTracerProvider(ctx, res, t.conn, cfg, t.sampler, t.status)
+		if err != nil {
+			return handleErr(err)
+		}
+		t.tracerProvider = tp
+		t.shutdownFuncs = append(t.shutdownFuncs, tp.Shutdown)
+	}
+
+	// Initialize the meter provider
+	if !cfg.DisabledSignals[SignalMetrics] {
+		mp, err := initMeterProvider(ctx, res, t.conn, cfg, t.status)
+		if err != nil {
+			return handleErr(err)
+		}
+		t.meterProvider = mp
+		t.shutdownFuncs = append(t.shutdownFuncs, mp.Shutdown)
+	}
+
+	// The pipeline metrics are exported by the meter provider, the exports
+	// before its creation are not measured.
+	t.status.metrics.Store(newPipelineMetrics(t.MeterProvider()))
+
+	// Initialize the logger provider
+	// The console logger already writes the logs to stdout.
+	if !cfg.DisabledSignals[SignalLogs] && !cfg.StdoutExporters {
+		lp, err := initLoggerProvider(ctx, res, t.conn, cfg, t.MeterProvider(), t.status)
+		if err != nil {
+			return handleErr(err)
+		}
+		t.loggerProvider = lp
+		t.shutdownFuncs = append(t.shutdownFuncs, lp.Shutdown)
+	}
+
+	// Initialize the logger
+	var cores []zapcore.Core
+	for _, newCore := range cfg.LogCores {
+		// The level of the logger is applied on top of the cores.
+		core, closeCore, err := newCore(c.ServiceName, zapcore.DebugLevel)
+		if err != nil {
+			return handleErr(fmt.Errorf("init log core: %w", err))
+		}
+		cores = append(cores, core)
+		t.shutdownFuncs = append(t.shutdownFuncs, func(context.Context) error { return closeCore() })
+	}
+	if cfg.LogDedupWindow > 0 {
+		t.logDedup = newLogDedup(cfg.LogDedupWindow)
+		t.shutdownFuncs = append(t.shutdownFuncs, func(context.Context) error {
+			t.logDedup.close()
+			return nil
+		})
+	}
+	t.logger = initLogger(c.ServiceName, t.LoggerProvider(), t.logLevel, loggerConfig{
+		encoderConfig: cfg.ZapEncoderConfig,
+		options:       cfg.ZapOptions,
+		dedup:         t.logDedup,
+	}, cores...)
+
+	if f, ok := cfg.ErrorReporter.(interface{ Flush(context.Context) error }); ok {
+		t.shutdownFuncs = append(t.shutdownFuncs, f.Flush)
+	}
+
+	// Watch the connection to the collector
+	if t.conn != nil {
+		watchCtx, stopWatch := context.WithCancel(context.Background())
+		go watchConn(watchCtx, t.conn, &t.status.health, t.MeterProvider())
+		t.shutdownFuncs = append(t.shutdownFuncs, func(context.Context) error {
+			stopWatch()
+			return nil
+		})
+	}
+
+	return t, nil
+}
+
+// Name returns the name of the pipeline.
+func (t *Telemetry) Name() string {
+	return t.name
+}
+
+// Propagator returns the propagator of the pipeline, which injects and
+// extracts the trace context and the baggage.
+func (t *Telemetry) Propagator() propagation.TextMapPropagator {
+	return t.propagator
+}
+
+// TracerProvider returns the tracer provider of the pipeline, or a no-op
+// provider if traces are disabled.
+func (t *Telemetry) TracerProvider() trace.TracerProvider {
+	if t.tracerProvider == nil {
+		return tracenoop.NewTracerProvider()
+	}
+	return t.tracerProvider
+}
+
+// MeterProvider returns the meter provider of the pipeline, or a no-op
+// provider if metrics are disabled.
+func (t *Telemetry) MeterProvider() metric.MeterProvider {
+	if t.meterProvider == nil {
+		return metricnoop.NewMeterProvider()
+	}
+	return t.meterProvider
+}
+
+// LoggerProvider returns the logger provider of the pipeline, or a no-op
+// provider if logs are disabled.
+func (t *Telemetry) LoggerProvider() log.LoggerProvider {
+	if t.loggerProvider == nil {
+		return lognoop.NewLoggerProvider()
+	}
+	return t.loggerProvider
+}
+
+// Resource returns the resource of the telemetry of the pipeline, or nil if
+// the telemetry is disabled.
+func (t *Telemetry) Resource() *resource.Resource {
+	return t.resource
+}
+
+// SDKTracerProvider returns the SDK tracer provider of the pipeline, e.g. to
+// register extra span processors, or nil if traces are disabled.
+func (t *Telemetry) SDKTracerProvider() *sdktrace.TracerProvider {
+	return t.tracerProvider
+}
+
+// SDKMeterProvider returns the SDK meter provider of the pipeline, or nil if
+// metrics are disabled. Views and readers can only be added at creation, see
+// WithMeterProviderOptions.
+func (t *Telemetry) SDKMeterProvider() *sdkmetric.MeterProvider {
+	return t.meterProvider
+}
+
+// SDKLoggerProvider returns the SDK logger provider of the pipeline, or nil
+// if logs are disabled.
+func (t *Telemetry) SDKLoggerProvider() *sdklog.LoggerProvider {
+	return t.loggerProvider
+}
+
+// Logger returns the zap logger writing to the console and the logger provider.
+func (t *Telemetry) Logger() *zap.Logger {
+	return t.logger
+}
+
+// Flush exports all the telemetry recorded so far.
+func (t *Telemetry) Flush(ctx context.Context) error {
+	var err error
+	// The summaries of the suppressed logs are written before the logs are exported.
+	if t.logDedup != nil {
+		t.logDedup.flush()
+	}
+	if t.tracerProvider != nil {
+		err = errors.Join(err, t.tracerProvider.ForceFlush(ctx))
+	}
+	if t.meterProvider != nil {
+		err = errors.Join(err, t.meterProvider.ForceFlush(ctx))
+	}
+	if t.loggerProvider != nil {
+		err = errors.Join(err, t.loggerProvider.ForceFlush(ctx))
+	}
+	return err
+}
+
+// Shutdown calls the functions registered by OnShutdown if the pipeline is
+// the global one, stops accepting new telemetry, exports the remaining telemetry
+// then shuts the providers and the collector connection down, all within the
+// drain deadline (see WithShutdownTimeout). The
+// returned error reports the telemetry dropped during the shutdown, e.g. left
+// in the queues when the deadline is exceeded. Calling it more than once is a no-op.
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	if !t.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	if t.name != "" {
+		registry.Lock()
+		if registry.pipelines[t.name] == t {
+			delete(registry.pipelines, t.name)
+		}
+		registry.Unlock()
+	}
+	// The drain deadline bounds the whole shutdown, including the hooks.
+	drainCtx, cancel := context.WithTimeout(ctx, t.cfg.ShutdownTimeout)
+	defer cancel()
+
+	// The application emits its last telemetry before the providers stop
+	// accepting it.
+	var hookErr error
+	if t.global {
+		hookErr = runShutdownHooks(drainCtx)
+	}
+	droppedSpans, droppedLogs := t.status.droppedSpans.Load(), t.status.droppedLogs.Load()
+
+	// Stop accepting new telemetry through the global providers.
+	if t.global {
+		current.CompareAndSwap(t, nil)
+		otel.SetTracerProvider(tracenoop.NewTracerProvider())
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+		global.SetLoggerProvider(lognoop.NewLoggerProvider())
+	}
+
+	// When the application is shuting down, we want to send all the remaining
+	err := t.Flush(drainCtx)
+	if err != nil {
+		err = fmt.Errorf("drain telemetry: %w", err)
+	}
+
+	err = errors.Join(hookErr, err, t.shutdown(drainCtx))
+
+	droppedSpans = t.status.droppedSpans.Load() - droppedSpans
+	droppedLogs = t.status.droppedLogs.Load() - droppedLogs
+	if droppedSpans > 0 || droppedLogs > 0 {
+		err = errors.Join(err, fmt.Errorf("shutdown dropped %d spans and %d log records", droppedSpans, droppedLogs))
+	}
+	return err
+}
+
+// shutdown calls the registered cleanup functions in the reverse order of
+// their registration, so the providers are shut down before the connection
+// they export to. The errors from the calls are joined.
+// Each registered cleanup will be invoked once.
+func (t *Telemetry) shutdown(ctx context.Context) error {
+	var err error
+	for i := len(t.shutdownFuncs) - 1; i >= 0; i-- {
+		err = errors.Join(err, t.shutdownFuncs[i](ctx))
+	}
+	t.shutdownFuncs = nil
+	return err
+}
+
+// setGlobals registers the providers, the propagator and the logger as the global ones.
+func (t *Telemetry) setGlobals() {
+	t.global = true
+	otel.SetTextMapPropagator(t.propagator)
+	if t.cfg.Disabled {
+		otel.SetTracerProvider(t.TracerProvider())
+		otel.SetMeterProvider(t.MeterProvider())
+		global.SetLoggerProvider(t.LoggerProvider())
+	}
+	if t.tracerProvider != nil {
+		var tp trace.TracerProvider = t.tracerProvider
+		if install := t.cfg.OpenTracingInstaller; install != nil {
+			if wrapped := install(t.tracerProvider, t.propagator); wrapped != nil {
+				tp = wrapped
+			}
+		}
+		otel.SetTracerProvider(tp)
+	}
+	if t.meterProvider != nil {
+		otel.SetMeterProvider(t.meterProvider)
+	}
+	if t.loggerProvider != nil {
+		global.SetLoggerProvider(t.loggerProvider)
+	}
+	zap.ReplaceGlobals(t.logger)
+	setDebugLogger(withoutLevel(t.logger))
+	setErrorReporter(t.cfg.ErrorReporter)
+	setErrorFingerprint(t.cfg.ErrorFingerprint)
+	setSpanEventLevel(t.cfg.SpanEventLevel)
+	setPprofLabels(t.cfg.PprofLabels)
+	setSectionSpans(t.cfg.SectionSpans)
+	semconvutil.SetDefault(t.cfg.Semconv)
+}
@@ -2,200 +2,258 @@ package kgsotel
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/lowoverhead"
+	"kgs/otel/internal/overhead"
 
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
+// InitTelemetry initializes the tracer, meter and logger providers exporting
+// to the collector at otelUrl, and registers them as the global providers.
+// The returned shutdown sends all the remaining telemetry and releases the providers.
 func InitTelemetry(
-	ctx context.Context, serviceName string, otelUrl string) (
+	ctx context.Context, serviceName string, otelUrl string, opts ...Option) (
 	shutdown func(context.Context) error, err error) {
 
-	var shutdownFuncs []func(context.Context) error
-
-	// Shutdown calls cleanup functions registered via shutdownFuncs.
-	// The errors from the calls are joined.
-	// Each registered cleanup will be invoked once.
-	finalShutdown := func(ctx context.Context) error {
-		var err error
-		for _, fn := range shutdownFuncs {
-			err = errors.Join(err, fn(ctx))
-		}
-		shutdownFuncs = nil
-		return err
-	}
-
-	// When the application is shuting down, we want to send all the remaining
-	// If an error occurs during the initialization phase, only need to execute `shutdown｀
-	sendAllBeforeShutdown := func(ctx context.Context) error {
-		// Send all span before shutdown
-		if sdkTP, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); ok {
-			sdkTP.ForceFlush(ctx)
-		}
-
-		// Send all metrics before shutdown
-		if sdkMP, ok := otel.GetMeterProvider().(*sdkmetric.MeterProvider); ok {
-			sdkMP.ForceFlush(ctx)
-		}
-
-		// Send all logs before shutdown
-		if sdkLog, ok := global.GetLoggerProvider().(*sdklog.LoggerProvider); ok {
-			sdkLog.ForceFlush(ctx)
-		}
-		return finalShutdown(ctx)
-	}
-
-	// HandleErr calls shutdown for cleanup and makes sure that all errors are returned.
-	handleErr := func(inErr error) {
-		err = errors.Join(inErr, finalShutdown(ctx))
-	}
-
-	// Create a new gRPC client connection
-	conn, err := initConn(otelUrl)
+	t, err := newTelemetry(ctx, Config{
+		ServiceName: serviceName,
+		Endpoint:    otelUrl,
+		Options:     opts,
+	}, sampler, logLevel, diag)
 	if err != nil {
-		handleErr(err)
-		return finalShutdown, err
+		return func(context.Context) error { return nil }, err
 	}
+	killswitch.SetDisabled(t.cfg.Disabled)
+	lowoverhead.SetEnabled(t.cfg.LowOverhead)
+	overhead.SetEnabled(t.cfg.MeasureOverhead)
 
-	// Initialize the propagator
-	initPropagator()
-
-	// Set up a resource with a service name attribute
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			attribute.KeyValue{Key: "service.name", Value: attribute.StringValue(serviceName)},
-		),
-		resource.WithHost(),
-		resource.WithProcess(),
-		resource.WithTelemetrySDK(),
-	)
-	if err != nil {
-		handleErr(err)
-		return finalShutdown, err
-	}
+	// Register the providers, the propagator and the logger as the global ones
+	t.setGlobals()
+	current.Store(t)
 
-	// Initialize the trace provider
-	shutdownTracer, err := initTracerProvider(ctx, res, conn)
-	if err != nil {
-		handleErr(err)
-		return shutdown, err
-	}
-	shutdownFuncs = append(shutdownFuncs, shutdownTracer)
-
-	// Initialize the meter provider
-	shutdownMeter, err := initMeterProvider(ctx, res, conn)
-	if err != nil {
-		handleErr(err)
-		return finalShutdown, err
-	}
-	shutdownFuncs = append(shutdownFuncs, shutdownMeter)
-
-	// Initialize the logger provider
-	shutdownLogger, err := initLoggerProvider(ctx, res, conn, serviceName)
-	if err != nil {
-		handleErr(err)
-		return finalShutdown, err
-	}
-	shutdownFuncs = append(shutdownFuncs, shutdownLogger)
-
-	// Initialize the logger
-	initLogger(serviceName)
-
-	return sendAllBeforeShutdown, nil
+	return t.Shutdown, nil
 }
 
-// Initializes a gRPC client connection to the OpenTelemetry collector.
-func initConn(otelUrl string) (*grpc.ClientConn, error) {
-	// Create a new gRPC client connection
-	conn, err := grpc.NewClient(otelUrl,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("init conn: %w", err)
-	}
+// current is the pipeline created by the last call to InitTelemetry.
+var current atomic.Pointer[Telemetry]
 
-	return conn, nil
+// Global returns the pipeline created by InitTelemetry, or nil if the
+// telemetry is not initialized. Its SDK providers can be used to register
+// extra span processors or bridges.
+func Global() *Telemetry {
+	return current.Load()
 }
 
-func initPropagator() {
-	props := propagation.NewCompositeTextMapPropagator(
+// newPropagator returns the propagator of the trace context and the baggage.
+// The Datadog and X-Ray headers come first, so the W3C ones win when both
+// are present.
+func newPropagator(cfg *config) propagation.TextMapPropagator {
+	var propagators []propagation.TextMapPropagator
+	if cfg.DatadogPropagation != 0 {
+		propagators = append(propagators, NewDatadogPropagator(cfg.DatadogPropagation))
+	}
+	if cfg.XRayPropagation {
+		propagators = append(propagators, NewXRayPropagator())
+	}
+	return propagation.NewCompositeTextMapPropagator(append(propagators,
 		propagation.TraceContext{},
 		propagation.Baggage{},
-	)
-	otel.SetTextMapPropagator(props)
+	)...)
 }
 
 // Initializes an OTLP exporter, and configures the corresponding tracer provider.
-func initTracerProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn) (func(context.Context) error, error) {
+func initTracerProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn, cfg *config, sampler *dynamicSampler, status *pipelineStatus) (*sdktrace.TracerProvider, error) {
 	// Set up a trace exporter
-	traceExporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(conn))
-	if err != nil {
-		return nil, fmt.Errorf("init trace exporter: %w", err)
+	var traceExporter sdktrace.SpanExporter
+	breaker := status.breaker.Load()
+	if cfg.StdoutExporters {
+		traceExporter = newStdoutSpanExporter(os.Stdout)
+	} else {
+		var client otlptrace.Client = otlptracegrpc.NewClient(
+			otlptracegrpc.WithGRPCConn(conn),
+			otlptracegrpc.WithHeaders(cfg.Headers),
+		)
+		if cfg.DiskBufferDir != "" {
+			queue, err := newDiskQueue(filepath.Join(cfg.DiskBufferDir, string(SignalTraces)), cfg.DiskBufferMaxBytes)
+			if err != nil {
+				return nil, fmt.Errorf("init trace exporter: %w", err)
+			}
+			// The breaker is inside the persistence, so the uploads it
+			// rejects are buffered.
+			client = &persistentTraceClient{client, queue, status, breaker}
+			breaker = nil
+		}
+		otlpExporter, err := otlptrace.New(ctx, client)
+		if err != nil {
+			return nil, fmt.Errorf("init trace exporter: %w", err)
+		}
+		traceExporter = otlpExporter
+	}
+	if breaker != nil {
+		traceExporter = breakerSpanExporter{traceExporter, breaker}
 	}
+	traceExporter = diagSpanExporter{traceExporter, status}
 
 	// Register the trace exporter with a TracerProvider, using a batch
 	// span processor to aggregate spans before export.
-	bsp := sdktrace.NewBatchSpanProcessor(traceExporter)
-	tracerProvider := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()), // We want to see all the spans
+	gate := newBatchGate(SignalTraces, status)
+	var bsp sdktrace.SpanProcessor = gatedSpanProcessor{
+		SpanProcessor: sdktrace.NewBatchSpanProcessor(gatedSpanExporter{traceExporter, gate}, sdktrace.WithMaxQueueSize(batchQueueSize)),
+		gate:          gate,
+	}
+	if len(cfg.SLATargets) > 0 {
+		bsp = slaProcessor{bsp, cfg.SLATargets}
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sampler.with(cfg.Sampler)),
 		sdktrace.WithResource(res),
 		sdktrace.WithSpanProcessor(bsp),
-	)
-
-	otel.SetTracerProvider(tracerProvider)
+		sdktrace.WithSpanProcessor(queuedSpanCounter{status}),
+	}
+	if cfg.IDGenerator != nil {
+		opts = append(opts, sdktrace.WithIDGenerator(cfg.IDGenerator))
+	}
+	if cfg.CaptureDir != "" {
+		capture, err := newCaptureProcessor(ctx, cfg.CaptureDir, cfg.CaptureMatcher, status)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithSpanProcessor(capture))
+	}
+	if cfg.SpanLeakMaxAge > 0 {
+		opts = append(opts, sdktrace.WithSpanProcessor(newSpanLeakDetector(cfg.SpanLeakMaxAge)))
+	}
+	if cfg.TraceTreeWriter != nil {
+		opts = append(opts, sdktrace.WithSyncer(newTraceTreeExporter(cfg.TraceTreeWriter)))
+	}
+	tracerProvider := sdktrace.NewTracerProvider(append(opts, cfg.TracerProviderOptions...)...)
 
-	return traceExporter.Shutdown, nil
+	return tracerProvider, nil
 }
 
 // Initializes an OTLP exporter, and configures the corresponding meter provider.
-func initMeterProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn) (func(context.Context) error, error) {
-	metricExporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(conn))
-	if err != nil {
-		return nil, fmt.Errorf("create metrics exporter: %w", err)
+func initMeterProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn, cfg *config, status *pipelineStatus) (*sdkmetric.MeterProvider, error) {
+	var metricExporter sdkmetric.Exporter
+	if cfg.StdoutExporters {
+		metricExporter = newStdoutMetricExporter(os.Stdout, cfg)
+	} else {
+		otlpExporter, err := otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithGRPCConn(conn),
+			otlpmetricgrpc.WithHeaders(cfg.Headers),
+			otlpmetricgrpc.WithTemporalitySelector(cfg.TemporalitySelector),
+			otlpmetricgrpc.WithAggregationSelector(cfg.AggregationSelector),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create metrics exporter: %w", err)
+		}
+		metricExporter = otlpExporter
+	}
+	if breaker := status.breaker.Load(); breaker != nil {
+		metricExporter = breakerMetricExporter{metricExporter, breaker}
+	}
+	if attrs := serviceAttributes(cfg); len(attrs) > 0 {
+		metricExporter = attrMetricExporter{metricExporter, attrs}
+	}
+	metricExporter = diagMetricExporter{metricExporter, status}
+
+	var readerOpts []sdkmetric.PeriodicReaderOption
+	if cfg.MetricInterval > 0 {
+		readerOpts = append(readerOpts, sdkmetric.WithInterval(cfg.MetricInterval))
 	}
 
 	// Create a new meter provider
-	meterProvider := sdkmetric.NewMeterProvider(
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+	meterProvider := sdkmetric.NewMeterProvider(append([]sdkmetric.Option{
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, readerOpts...)),
 		sdkmetric.WithResource(res),
-	)
+	}, cfg.MeterProviderOptions...)...)
 
-	// Register the meter provider with the global meter provider
-	otel.SetMeterProvider(meterProvider)
-
-	return meterProvider.Shutdown, nil
+	return meterProvider, nil
 }
 
-func initLoggerProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn, serviceName string) (func(context.Context) error, error) {
+func initLoggerProvider(ctx context.Context, res *resource.Resource, conn *grpc.ClientConn, cfg *config, mp metric.MeterProvider, status *pipelineStatus) (*sdklog.LoggerProvider, error) {
 	// Set up a logger exporter
-	loggerExporter, err := otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(conn))
+	loggerExporter, err := otlploggrpc.New(ctx,
+		otlploggrpc.WithGRPCConn(conn),
+		otlploggrpc.WithHeaders(cfg.Headers),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("init logger exporter: %w", err)
 	}
 
+	var exporter sdklog.Exporter = loggerExporter
+	breaker := status.breaker.Load()
+	if cfg.DiskBufferDir != "" {
+		queue, err := newDiskQueue(filepath.Join(cfg.DiskBufferDir, string(SignalLogs)), cfg.DiskBufferMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("init logger exporter: %w", err)
+		}
+		exporter = &persistentLogExporter{
+			Exporter: exporter,
+			client:   collogspb.NewLogsServiceClient(conn),
+			headers:  metadata.New(cfg.Headers),
+			queue:    queue,
+			status:   status,
+			// The breaker is inside the persistence, so the exports it
+			// rejects are buffered.
+			breaker: breaker,
+		}
+		breaker = nil
+	}
+	if breaker != nil {
+		exporter = breakerLogExporter{exporter, breaker}
+	}
+
 	// Create a log record processor pipeline
-	processor := sdklog.NewBatchProcessor(loggerExporter)
-	loggerProvider := sdklog.NewLoggerProvider(
-		sdklog.WithResource(res),
-		sdklog.WithProcessor(processor),
-	)
+	processors := []sdklog.Processor{queuedLogCounter{status}}
+	if len(cfg.SeverityMapping) > 0 {
+		processors = append(processors, &severityProcessor{mapping: cfg.SeverityMapping})
+	}
+	// The records are scrubbed before the Loki processor moves the
+	// attributes into the body.
+	if cfg.PIIScrubbing {
+		processors = append(processors, newPIIProcessor(cfg.PIIPatterns))
+	}
+	if len(cfg.LokiLabels) > 0 {
+		processors = append(processors, newLokiProcessor(cfg.LokiLabels))
+	}
+	if len(cfg.LogBodyFields) > 0 {
+		processors = append(processors, newBodyFieldsProcessor(cfg.LogBodyFields))
+	}
+	gate := newBatchGate(SignalLogs, status)
+	processors = append(processors, gatedLogProcessor{
+		Processor: sdklog.NewBatchProcessor(gatedLogExporter{diagLogExporter{exporter, status}, gate}, sdklog.WithMaxQueueSize(batchQueueSize)),
+		gate:      gate,
+	})
+	if cfg.AsyncLogBuffer > 0 {
+		async := newAsyncProcessor(cfg.AsyncLogBuffer, mp, status, processors...)
+		status.logQueue.Store(async)
+		processors = []sdklog.Processor{async}
+	}
 
-	// Register the logger provider with the global logger
-	global.SetLoggerProvider(loggerProvider)
+	opts := []sdklog.LoggerProviderOption{sdklog.WithResource(res)}
+	for _, p := range processors {
+		opts = append(opts, sdklog.WithProcessor(p))
+	}
+	loggerProvider := sdklog.NewLoggerProvider(append(opts, cfg.LoggerProviderOptions...)...)
 
-	return loggerProvider.Shutdown, nil
+	return loggerProvider, nil
 }
@@ -0,0 +1,278 @@
+package kgsotel
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/lowoverhead"
+	"kgs/otel/internal/overhead"
+	"kgs/otel/otlptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestConfigValidate(t *testing.T) {
+	err := Config{}.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "service name is required")
+	assert.Contains(t, err.Error(), "endpoint is required")
+
+	assert.NoError(t, Config{ServiceName: "svc", Options: []Option{WithStdoutExporters(true)}}.Validate())
+}
+
+func TestNewDoesNotTouchGlobals(t *testing.T) {
+	ctx := context.Background()
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Options:     []Option{WithStdoutExporters(true), WithDisabledSignals(SignalMetrics)},
+	})
+	require.NoError(t, err)
+
+	_, ok := tel.TracerProvider().(*sdktrace.TracerProvider)
+	assert.True(t, ok)
+	assert.NotSame(t, tel.TracerProvider(), otel.GetTracerProvider())
+	assert.NotNil(t, tel.Logger())
+
+	assert.NoError(t, tel.Flush(ctx))
+	assert.NoError(t, tel.Shutdown(ctx))
+	assert.NoError(t, tel.Shutdown(ctx))
+}
+
+func TestNewDisabled(t *testing.T) {
+	ctx := context.Background()
+	tel, err := New(ctx, Config{Options: []Option{WithDisabled(true)}})
+	require.NoError(t, err)
+
+	_, ok := tel.TracerProvider().(*sdktrace.TracerProvider)
+	assert.False(t, ok)
+	assert.NotNil(t, tel.Logger())
+	assert.NoError(t, tel.Shutdown(ctx))
+}
+
+func TestNewIgnoresGlobalSwitches(t *testing.T) {
+	// As set by InitTelemetry with WithDisabled and WithLowOverhead.
+	killswitch.SetDisabled(true)
+	lowoverhead.SetEnabled(true)
+	overhead.SetEnabled(true)
+	t.Cleanup(func() {
+		killswitch.SetDisabled(false)
+		lowoverhead.SetEnabled(false)
+		overhead.SetEnabled(false)
+	})
+
+	cfg := newConfig()
+	assert.False(t, cfg.Disabled)
+	assert.False(t, cfg.LowOverhead)
+	assert.False(t, cfg.MeasureOverhead)
+
+	t.Setenv(killswitch.EnvDisabled, "true")
+	assert.True(t, newConfig().Disabled)
+}
+
+func TestNewNamedPipelines(t *testing.T) {
+	ctx := context.Background()
+	newPipeline := func(name string) (*Telemetry, error) {
+		return New(ctx, Config{
+			Name:        name,
+			ServiceName: name,
+			Options:     []Option{WithStdoutExporters(true)},
+		})
+	}
+
+	orders, err := newPipeline("orders")
+	require.NoError(t, err)
+	billing, err := newPipeline("billing")
+	require.NoError(t, err)
+	assert.NotSame(t, orders.TracerProvider(), billing.TracerProvider())
+
+	_, err = newPipeline("orders")
+	assert.Error(t, err)
+
+	got, ok := Lookup("billing")
+	assert.True(t, ok)
+	assert.Same(t, billing, got)
+
+	require.NoError(t, billing.Shutdown(ctx))
+	_, ok = Lookup("billing")
+	assert.False(t, ok)
+	require.NoError(t, orders.Shutdown(ctx))
+
+	// A failed creation releases the name.
+	_, err = New(ctx, Config{Name: "shipping"})
+	require.Error(t, err)
+	shipping, err := newPipeline("shipping")
+	require.NoError(t, err)
+	require.NoError(t, shipping.Shutdown(ctx))
+}
+
+func TestLookupReservedName(t *testing.T) {
+	// The name of a pipeline being created is reserved, but not found.
+	registry.Lock()
+	registry.pipelines["reserved"] = nil
+	registry.Unlock()
+	t.Cleanup(func() {
+		registry.Lock()
+		delete(registry.pipelines, "reserved")
+		registry.Unlock()
+	})
+
+	_, ok := Lookup("reserved")
+	assert.False(t, ok)
+	_, err := New(context.Background(), Config{Name: "reserved", ServiceName: "svc", Options: []Option{WithStdoutExporters(true)}})
+	assert.Error(t, err)
+}
+
+func TestSDKProviders(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Options: []Option{
+			WithStdoutExporters(true),
+			WithMeterProviderOptions(sdkmetric.WithReader(reader)),
+		},
+	})
+	require.NoError(t, err)
+	defer tel.Shutdown(ctx)
+
+	recorder := tracetest.NewSpanRecorder()
+	tel.SDKTracerProvider().RegisterSpanProcessor(recorder)
+	_, span := tel.TracerProvider().Tracer("test").Start(ctx, "op")
+	span.End()
+	assert.Len(t, recorder.Ended(), 1)
+
+	counter, err := tel.SDKMeterProvider().Meter("test").Int64Counter("calls")
+	require.NoError(t, err)
+	counter.Add(ctx, 1)
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	var scopes []string
+	for _, sm := range rm.ScopeMetrics {
+		scopes = append(scopes, sm.Scope.Name)
+	}
+	assert.Contains(t, scopes, "test")
+
+	assert.Nil(t, tel.SDKLoggerProvider())
+}
+
+func TestNewExportsToCollector(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Endpoint:    collector.Addr,
+		Options:     []Option{WithHeaders(map[string]string{"api-key": "secret"})},
+	})
+	require.NoError(t, err)
+	defer tel.Shutdown(ctx)
+
+	_, span := tel.TracerProvider().Tracer("test").Start(ctx, "op")
+	span.End()
+	require.NoError(t, tel.Flush(ctx))
+
+	spans := collector.Spans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "op", spans[0].GetName())
+	for _, e := range collector.Exports() {
+		assert.Equal(t, []string{"secret"}, e.Metadata.Get("api-key"))
+	}
+}
+
+func TestNewExportsTemporalityAndAggregation(t *testing.T) {
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Endpoint:    collector.Addr,
+		Options: []Option{
+			WithDeltaTemporality(),
+			WithAggregationSelector(func(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+				if kind == sdkmetric.InstrumentKindHistogram {
+					return sdkmetric.AggregationExplicitBucketHistogram{Boundaries: []float64{1, 2}}
+				}
+				return sdkmetric.DefaultAggregationSelector(kind)
+			}),
+		},
+	})
+	require.NoError(t, err)
+	defer tel.Shutdown(ctx)
+
+	meter := tel.MeterProvider().Meter("test")
+	jobs, err := meter.Int64Counter("jobs")
+	require.NoError(t, err)
+	jobs.Add(ctx, 3)
+	queued, err := meter.Int64UpDownCounter("queued")
+	require.NoError(t, err)
+	queued.Add(ctx, 2)
+	latency, err := meter.Float64Histogram("latency")
+	require.NoError(t, err)
+	latency.Record(ctx, 1.5)
+	require.NoError(t, tel.Flush(ctx))
+
+	metrics := make(map[string]*metricpb.Metric)
+	for _, m := range collector.Metrics() {
+		metrics[m.GetName()] = m
+	}
+	require.Contains(t, metrics, "jobs")
+	require.Contains(t, metrics, "queued")
+	require.Contains(t, metrics, "latency")
+	assert.Equal(t, metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, metrics["jobs"].GetSum().GetAggregationTemporality())
+	assert.Equal(t, metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, metrics["queued"].GetSum().GetAggregationTemporality(),
+		"the UpDownCounters stay cumulative")
+	histogram := metrics["latency"].GetHistogram()
+	assert.Equal(t, metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, histogram.GetAggregationTemporality())
+	require.Len(t, histogram.GetDataPoints(), 1)
+	assert.Equal(t, []float64{1, 2}, histogram.GetDataPoints()[0].GetExplicitBounds())
+}
+
+func TestShutdownDrainDeadline(t *testing.T) {
+	// The collector accepts the connections and never answers.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx := context.Background()
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Endpoint:    lis.Addr().String(),
+		Options:     []Option{WithShutdownTimeout(200 * time.Millisecond)},
+	})
+	require.NoError(t, err)
+	for range 2 {
+		_, span := tel.TracerProvider().Tracer("test").Start(ctx, "op")
+		span.End()
+	}
+
+	start := time.Now()
+	err = tel.Shutdown(ctx)
+	assert.Less(t, time.Since(start), 2*time.Second, "the shutdown is bounded by the drain deadline")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "shutdown dropped 2 spans and 0 log records")
+}
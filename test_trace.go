@@ -0,0 +1,24 @@
+package kgsotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContextWithTestTrace returns a copy of ctx whose parent is a remote span
+// with the given IDs, as if it was extracted from an incoming request. It
+// lets integration tests and tools check the propagation end-to-end with
+// deterministic IDs, e.g. with trace.TraceIDFromHex.
+func ContextWithTestTrace(ctx context.Context, traceID trace.TraceID, spanID trace.SpanID, sampled bool) context.Context {
+	var flags trace.TraceFlags
+	if sampled {
+		flags = trace.FlagsSampled
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
@@ -0,0 +1,137 @@
+package kgsotel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc/credentials"
+)
+
+// WithTLSFiles returns an Option to connect to the collector with TLS. The
+// server certificate is verified with the CAs of caFile, or of the system if
+// empty, and the client certificate of certFile and keyFile, if set, is
+// presented for mutual TLS. The files are read again when they change, on the
+// next handshake, so the rotated certificates are used without a restart.
+func WithTLSFiles(certFile, keyFile, caFile string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.TLS = &tlsFiles{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	})
+}
+
+// tlsFiles holds the TLS configuration read from the files, reloaded when
+// their modification time changes.
+type tlsFiles struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu      sync.Mutex
+	modTime map[string]time.Time
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+}
+
+// credentials returns the transport credentials using the files.
+func (f *tlsFiles) credentials() (credentials.TransportCredentials, error) {
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := f.current()
+			if cert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+		// The server certificate is verified by VerifyConnection, with the
+		// current roots.
+		InsecureSkipVerify: true,
+		VerifyConnection:   f.verify,
+	}), nil
+}
+
+// current returns the certificate and roots, reloaded if the files changed.
+// A failed reload keeps the previous ones, so a rotation in progress does not
+// break the connection.
+func (f *tlsFiles) current() (*tls.Certificate, *x509.CertPool) {
+	if err := f.reload(); err != nil {
+		otel.Handle(fmt.Errorf("reload tls files: %w", err))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.cert, f.roots
+}
+
+// reload reads the files whose modification time changed.
+func (f *tlsFiles) reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.modTime == nil {
+		f.modTime = make(map[string]time.Time)
+	}
+
+	if f.certFile != "" && (f.changed(f.certFile) || f.changed(f.keyFile)) {
+		cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+		if err != nil {
+			return err
+		}
+		f.cert = &cert
+		f.touch(f.certFile)
+		f.touch(f.keyFile)
+	}
+	if f.caFile != "" && f.changed(f.caFile) {
+		pem, err := os.ReadFile(f.caFile)
+		if err != nil {
+			return err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", f.caFile)
+		}
+		f.roots = roots
+		f.touch(f.caFile)
+	}
+	return nil
+}
+
+// changed reports whether the file changed since it was read.
+func (f *tlsFiles) changed(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return true
+	}
+	return !info.ModTime().Equal(f.modTime[path])
+}
+
+// touch records the modification time of the file read.
+func (f *tlsFiles) touch(path string) {
+	if info, err := os.Stat(path); err == nil {
+		f.modTime[path] = info.ModTime()
+	}
+}
+
+// verify verifies the certificate chain of the server with the current roots.
+func (f *tlsFiles) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: no server certificate")
+	}
+	_, roots := f.current()
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -0,0 +1,142 @@
+package kgsotel
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kgs/otel/otlptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues the certificates of the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate and its key, in PEM.
+func (ca *testCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeFile writes the file with a modification time in the future, so a
+// rewrite in the same clock tick is seen as a change.
+func writeFile(t *testing.T, path string, data []byte, mtime time.Time) {
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func TestWithTLSFilesMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	serverPair, err := tls.X509KeyPair(serverCert, serverKey)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	collector, err := otlptest.NewCollector(otlptest.WithTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverPair},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}))
+	require.NoError(t, err)
+	defer collector.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	clientCert, clientKey := ca.issue(t, 3, x509.ExtKeyUsageClientAuth)
+	now := time.Now()
+	writeFile(t, certFile, clientCert, now)
+	writeFile(t, keyFile, clientKey, now)
+	writeFile(t, caFile, ca.pem, now)
+
+	exportSpan(t, collector.Addr, WithTLSFiles(certFile, keyFile, caFile))
+	assert.Len(t, collector.Spans(), 1)
+}
+
+func TestTLSFilesReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	now := time.Now()
+	cert, key := ca.issue(t, 2, x509.ExtKeyUsageClientAuth)
+	writeFile(t, certFile, cert, now)
+	writeFile(t, keyFile, key, now)
+	writeFile(t, caFile, ca.pem, now)
+
+	f := &tlsFiles{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	_, err := f.credentials()
+	require.NoError(t, err)
+	first, _ := f.current()
+	require.NotNil(t, first)
+
+	// A half-done rotation keeps the previous certificate.
+	cert, key = ca.issue(t, 3, x509.ExtKeyUsageClientAuth)
+	writeFile(t, certFile, cert, now.Add(time.Minute))
+	current, _ := f.current()
+	assert.Same(t, first, current)
+
+	writeFile(t, keyFile, key, now.Add(time.Minute))
+	current, roots := f.current()
+	assert.NotSame(t, first, current)
+	leaf, err := x509.ParseCertificate(current.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), leaf.SerialNumber.Int64())
+	assert.NotNil(t, roots)
+}
+
+func TestTLSFilesInvalidCA(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	writeFile(t, caFile, []byte("not a certificate"), time.Now())
+	_, err := (&tlsFiles{caFile: caFile}).credentials()
+	assert.Error(t, err)
+}
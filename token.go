@@ -0,0 +1,241 @@
+package kgsotel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before its expiry a token is refreshed, or
+// half its lifetime if shorter.
+const tokenExpiryMargin = time.Minute
+
+// defaultTokenTTL is the lifetime assumed for the tokens of the token
+// endpoints which do not return expires_in.
+const defaultTokenTTL = 10 * time.Minute
+
+// Token is an access token sent to the collector.
+type Token struct {
+	AccessToken string
+	// Type is the scheme of the authorization header, Bearer if empty.
+	Type string
+	// Expiry is when the token expires. A token without expiry is requested
+	// again from its source for every export.
+	Expiry time.Time
+}
+
+// TokenSource provides the access tokens sent to the collector.
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// WithTokenSource returns an Option to authenticate the exports with the
+// tokens of src, in the authorization header. The tokens are cached until
+// shortly before they expire. Use it with TLS, see WithTLSFiles, as the
+// tokens are sent in clear text otherwise.
+func WithTokenSource(src TokenSource) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.TokenSource = src
+	})
+}
+
+// tokenCredentials are the gRPC per-RPC credentials of a token source.
+type tokenCredentials struct {
+	src TokenSource
+
+	mu      sync.Mutex
+	token   Token
+	fetched time.Time
+	// refreshing is closed when the token being fetched is stored, nil if
+	// none is.
+	refreshing chan struct{}
+}
+
+// GetRequestMetadata returns the authorization header of the current token,
+// refreshed if it is about to expire. The token is fetched without holding
+// the lock, once for all the concurrent exports: they keep sending the
+// current token until it expires, or wait for the new one.
+func (c *tokenCredentials) GetRequestMetadata(ctx context.Context, _ ...string) (map[string]string, error) {
+	for {
+		c.mu.Lock()
+		now := time.Now()
+		if c.fresh(now) || (c.refreshing != nil && c.valid(now)) {
+			token := c.token
+			c.mu.Unlock()
+			return authorization(token), nil
+		}
+		if done := c.refreshing; done != nil {
+			c.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return nil, fmt.Errorf("get token: %w", ctx.Err())
+			}
+		}
+		done := make(chan struct{})
+		c.refreshing = done
+		c.mu.Unlock()
+
+		token, err := c.src.Token(ctx)
+		c.mu.Lock()
+		if err == nil {
+			c.token, c.fetched = token, now
+		}
+		c.refreshing = nil
+		c.mu.Unlock()
+		close(done)
+		if err != nil {
+			return nil, fmt.Errorf("get token: %w", err)
+		}
+		return authorization(token), nil
+	}
+}
+
+// fresh reports whether the token is not refreshed yet at now: it expires
+// after the margin, at most half its lifetime so the short-lived tokens are
+// cached too. The tokens without expiry are always refreshed.
+func (c *tokenCredentials) fresh(now time.Time) bool {
+	if c.token.AccessToken == "" || c.token.Expiry.IsZero() {
+		return false
+	}
+	margin := min(tokenExpiryMargin, c.token.Expiry.Sub(c.fetched)/2)
+	return c.token.Expiry.Sub(now) >= margin
+}
+
+// valid reports whether the token can still be sent at now.
+func (c *tokenCredentials) valid(now time.Time) bool {
+	return c.token.AccessToken != "" && (c.token.Expiry.IsZero() || now.Before(c.token.Expiry))
+}
+
+// authorization returns the authorization header of the token.
+func authorization(token Token) map[string]string {
+	scheme := token.Type
+	if scheme == "" {
+		scheme = "Bearer"
+	}
+	return map[string]string{"authorization": scheme + " " + token.AccessToken}
+}
+
+// RequireTransportSecurity allows the plaintext connections, e.g. to a
+// collector sidecar.
+func (c *tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// ClientCredentialsTokenSource returns a TokenSource requesting the tokens
+// from the OAuth2 token endpoint at tokenURL with the client credentials
+// grant. The tokens returned without expires_in are assumed to expire in
+// 10 minutes, so they are not requested again for every export.
+func ClientCredentialsTokenSource(tokenURL, clientID, clientSecret string, scopes ...string) TokenSource {
+	return &clientCredentials{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type clientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	client       *http.Client
+}
+
+// Token requests a new token from the token endpoint.
+func (c *clientCredentials) Token(ctx context.Context) (Token, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Token{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Token{}, fmt.Errorf("token endpoint: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	var payload struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return Token{}, fmt.Errorf("token endpoint: %w", err)
+	}
+	if payload.AccessToken == "" {
+		return Token{}, fmt.Errorf("token endpoint: no access token")
+	}
+	token := Token{AccessToken: payload.AccessToken}
+	// The type is case-insensitive, but some servers only accept "Bearer".
+	if !strings.EqualFold(payload.TokenType, "bearer") {
+		token.Type = payload.TokenType
+	}
+	ttl := defaultTokenTTL
+	if payload.ExpiresIn > 0 {
+		ttl = time.Duration(payload.ExpiresIn) * time.Second
+	}
+	token.Expiry = time.Now().Add(ttl)
+	return token, nil
+}
+
+// FileTokenSource returns a TokenSource reading the token from the file at
+// path, e.g. a projected service account token, again whenever it changes.
+func FileTokenSource(path string) TokenSource {
+	return &fileToken{path: path}
+}
+
+type fileToken struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	token   string
+}
+
+// Token returns the token of the file, read again if it changed.
+func (f *fileToken) Token(context.Context) (Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return Token{}, err
+	}
+	if f.token == "" || !info.ModTime().Equal(f.modTime) {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			return Token{}, err
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return Token{}, fmt.Errorf("empty token file %s", f.path)
+		}
+		f.token, f.modTime = token, info.ModTime()
+	}
+	return Token{AccessToken: f.token}, nil
+}
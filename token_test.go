@@ -0,0 +1,188 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kgs/otel/otlptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTokenServer returns an OAuth2 token endpoint issuing tokens that expire
+// in expiresIn seconds and counting the requests.
+func newTokenServer(t *testing.T, expiresIn int, requests *atomic.Int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		assert.Equal(t, "write:traces write:logs", r.FormValue("scope"))
+		n := requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "token-" + string(rune('0'+n)),
+			"token_type":   "bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWithTokenSourceClientCredentials(t *testing.T) {
+	var requests atomic.Int32
+	srv := newTokenServer(t, 3600, &requests)
+	collector, err := otlptest.NewCollector()
+	require.NoError(t, err)
+	defer collector.Close()
+
+	exportSpan(t, collector.Addr,
+		WithTokenSource(ClientCredentialsTokenSource(srv.URL, "client", "secret", "write:traces", "write:logs")))
+
+	exports := collector.Exports()
+	require.NotEmpty(t, exports)
+	for _, export := range exports {
+		assert.Equal(t, []string{"Bearer token-1"}, export.Metadata.Get("authorization"))
+	}
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestTokenCredentialsRefresh(t *testing.T) {
+	ctx := context.Background()
+
+	var requests atomic.Int32
+	creds := &tokenCredentials{src: ClientCredentialsTokenSource(newTokenServer(t, 3600, &requests).URL, "client", "secret", "write:traces", "write:logs")}
+	for range 3 {
+		md, err := creds.GetRequestMetadata(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer token-1", md["authorization"])
+	}
+	assert.Equal(t, int32(1), requests.Load(), "cached until expiry")
+
+	// The tokens living less than twice the margin are cached for half their
+	// lifetime.
+	requests.Store(0)
+	creds = &tokenCredentials{src: ClientCredentialsTokenSource(newTokenServer(t, 30, &requests).URL, "client", "secret", "write:traces", "write:logs")}
+	for range 2 {
+		md, err := creds.GetRequestMetadata(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer token-1", md["authorization"])
+	}
+	creds.fetched = creds.fetched.Add(-20 * time.Second)
+	creds.token.Expiry = creds.token.Expiry.Add(-20 * time.Second)
+	md, err := creds.GetRequestMetadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token-2", md["authorization"])
+}
+
+// slowTokenSource blocks the fetches until release is closed.
+type slowTokenSource struct {
+	fetches atomic.Int32
+	release chan struct{}
+}
+
+func (s *slowTokenSource) Token(context.Context) (Token, error) {
+	n := s.fetches.Add(1)
+	<-s.release
+	return Token{AccessToken: "token-" + string(rune('0'+n)), Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func TestTokenCredentialsConcurrentRefresh(t *testing.T) {
+	ctx := context.Background()
+	src := &slowTokenSource{release: make(chan struct{})}
+	creds := &tokenCredentials{src: src}
+
+	// The concurrent exports wait for a single fetch.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			md, err := creds.GetRequestMetadata(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, "Bearer token-1", md["authorization"])
+		}()
+	}
+	require.Eventually(t, func() bool { return src.fetches.Load() == 1 }, time.Second, time.Millisecond)
+	close(src.release)
+	wg.Wait()
+	assert.Equal(t, int32(1), src.fetches.Load())
+
+	// While the token about to expire is refreshed, the exports keep sending it.
+	src.release = make(chan struct{})
+	creds.mu.Lock()
+	creds.fetched = time.Now().Add(-time.Hour)
+	creds.token.Expiry = time.Now().Add(30 * time.Second)
+	creds.mu.Unlock()
+	refreshed := make(chan struct{})
+	go func() {
+		defer close(refreshed)
+		md, err := creds.GetRequestMetadata(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer token-2", md["authorization"])
+	}()
+	require.Eventually(t, func() bool { return src.fetches.Load() == 2 }, time.Second, time.Millisecond)
+	md, err := creds.GetRequestMetadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token-1", md["authorization"])
+	close(src.release)
+	<-refreshed
+}
+
+func TestClientCredentialsDefaultTTL(t *testing.T) {
+	ctx := context.Background()
+
+	var requests atomic.Int32
+	creds := &tokenCredentials{src: ClientCredentialsTokenSource(newTokenServer(t, 0, &requests).URL, "client", "secret", "write:traces", "write:logs")}
+	for range 3 {
+		md, err := creds.GetRequestMetadata(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer token-1", md["authorization"])
+	}
+	assert.Equal(t, int32(1), requests.Load(), "cached without expires_in")
+	assert.WithinDuration(t, time.Now().Add(defaultTokenTTL), creds.token.Expiry, time.Minute)
+}
+
+func TestClientCredentialsError(t *testing.T) {
+	var requests atomic.Int32
+	src := ClientCredentialsTokenSource(newTokenServer(t, 3600, &requests).URL, "client", "wrong")
+
+	_, err := (&tokenCredentials{src: src}).GetRequestMetadata(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+	assert.Contains(t, err.Error(), "invalid_client")
+}
+
+func TestFileTokenSource(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "token")
+	now := time.Now()
+	writeFile(t, path, []byte("first\n"), now)
+
+	creds := &tokenCredentials{src: FileTokenSource(path)}
+	md, err := creds.GetRequestMetadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer first", md["authorization"])
+
+	// A rotated token is picked up on the next request.
+	writeFile(t, path, []byte("second\n"), now.Add(time.Minute))
+	md, err = creds.GetRequestMetadata(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer second", md["authorization"])
+
+	require.NoError(t, os.Remove(path))
+	_, err = creds.GetRequestMetadata(ctx)
+	assert.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package kgsotel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxPendingTraces bounds the number of traces waiting for their root span.
+const maxPendingTraces = 1000
+
+// treeAttributeKeys are the attributes printed next to the spans of the tree.
+var treeAttributeKeys = []attribute.Key{
+	"http.method",
+	"http.route",
+	"http.status_code",
+	"rpc.service",
+	"rpc.method",
+	"rpc.grpc.status_code",
+	"db.system",
+	"messaging.destination.name",
+}
+
+// traceTreeExporter prints the completed traces as indented trees, for local
+// development. The spans are kept until the local root span of their trace
+// ends.
+type traceTreeExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	traces traceAssembler
+}
+
+var _ sdktrace.SpanExporter = (*traceTreeExporter)(nil)
+
+func newTraceTreeExporter(w io.Writer) *traceTreeExporter {
+	return &traceTreeExporter{w: w}
+}
+
+// ExportSpans buffers the spans and prints the traces whose root span ended.
+func (e *traceTreeExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, s := range spans {
+		if spans, ok := e.traces.add(s); ok {
+			if _, err := io.WriteString(e.w, formatTraceTree(s.SpanContext().TraceID(), spans)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Shutdown drops the traces whose root span did not end.
+func (e *traceTreeExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.traces.reset()
+	return nil
+}
+
+// traceAssembler groups the ended spans by trace until the local root span
+// of the trace ends. It is not safe for concurrent use.
+type traceAssembler struct {
+	pending map[trace.TraceID]*pendingTrace
+}
+
+type pendingTrace struct {
+	spans   []sdktrace.ReadOnlySpan
+	updated time.Time
+}
+
+// add adds the span to its trace and returns the spans of the trace if the
+// span is the local root one.
+func (a *traceAssembler) add(s sdktrace.ReadOnlySpan) ([]sdktrace.ReadOnlySpan, bool) {
+	if a.pending == nil {
+		a.pending = make(map[trace.TraceID]*pendingTrace)
+	}
+	id := s.SpanContext().TraceID()
+	pt, ok := a.pending[id]
+	if !ok {
+		a.evict()
+		pt = &pendingTrace{}
+		a.pending[id] = pt
+	}
+	pt.spans = append(pt.spans, s)
+	pt.updated = time.Now()
+
+	if parent := s.Parent(); parent.IsValid() && !parent.IsRemote() {
+		return nil, false
+	}
+	delete(a.pending, id)
+	return pt.spans, true
+}
+
+// evict drops the least recently updated trace when too many are pending,
+// e.g. because their root span is never ended.
+func (a *traceAssembler) evict() {
+	if len(a.pending) < maxPendingTraces {
+		return
+	}
+	var (
+		oldest   trace.TraceID
+		oldestAt time.Time
+	)
+	for id, pt := range a.pending {
+		if oldestAt.IsZero() || pt.updated.Before(oldestAt) {
+			oldest, oldestAt = id, pt.updated
+		}
+	}
+	delete(a.pending, oldest)
+}
+
+// reset drops the pending traces.
+func (a *traceAssembler) reset() {
+	a.pending = nil
+}
+
+// formatTraceTree formats the spans of the trace as a tree ordered by start time.
+func formatTraceTree(id trace.TraceID, spans []sdktrace.ReadOnlySpan) string {
+	ids := make(map[trace.SpanID]bool, len(spans))
+	for _, s := range spans {
+		ids[s.SpanContext().SpanID()] = true
+	}
+	children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan)
+	var roots []sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		if parent := s.Parent(); parent.IsValid() && ids[parent.SpanID()] {
+			children[parent.SpanID()] = append(children[parent.SpanID()], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "trace %s\n", id)
+	var write func(spans []sdktrace.ReadOnlySpan, indent string)
+	write = func(spans []sdktrace.ReadOnlySpan, indent string) {
+		sort.Slice(spans, func(i, j int) bool { return spans[i].StartTime().Before(spans[j].StartTime()) })
+		for i, s := range spans {
+			branch, next := "├─ ", "│  "
+			if i == len(spans)-1 {
+				branch, next = "└─ ", "   "
+			}
+			b.WriteString(indent + branch + formatTreeSpan(s) + "\n")
+			write(children[s.SpanContext().SpanID()], indent+next)
+		}
+	}
+	write(roots, "")
+	return b.String()
+}
+
+// formatTreeSpan formats the name, duration, status and key attributes of the span.
+func formatTreeSpan(s sdktrace.ReadOnlySpan) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", s.Name(), s.EndTime().Sub(s.StartTime()).Round(time.Microsecond))
+	switch st := s.Status(); st.Code {
+	case codes.Error:
+		fmt.Fprintf(&b, " [ERROR %s]", st.Description)
+	case codes.Ok:
+		b.WriteString(" [OK]")
+	}
+
+	set := attribute.NewSet(s.Attributes()...)
+	for _, k := range treeAttributeKeys {
+		if v, ok := set.Value(k); ok {
+			fmt.Fprintf(&b, " %s=%s", k, v.Emit())
+		}
+	}
+	return b.String()
+}
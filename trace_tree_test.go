@@ -0,0 +1,44 @@
+package kgsotel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceTreeExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(newTraceTreeExporter(&buf)))
+	tracer := tp.Tracer("test")
+	start := time.Now()
+	at := func(ms int) trace.SpanEventOption {
+		return trace.WithTimestamp(start.Add(time.Duration(ms) * time.Millisecond))
+	}
+
+	ctx, root := tracer.Start(context.Background(), "GET /users/:id", trace.WithTimestamp(start),
+		trace.WithAttributes(attribute.String("http.route", "/users/:id"), attribute.String("ignored", "x")))
+	ctx1, auth := tracer.Start(ctx, "auth", trace.WithTimestamp(start))
+	_, token := tracer.Start(ctx1, "token", trace.WithTimestamp(start))
+	token.End(at(1))
+	auth.End(at(2))
+	_, query := tracer.Start(ctx, "query", trace.WithTimestamp(start.Add(2*time.Millisecond)))
+	query.SetStatus(codes.Error, errors.New("timeout").Error())
+	query.End(at(5))
+	assert.Empty(t, buf.String(), "the trace is printed when the root span ends")
+	root.End(at(6))
+
+	want := "trace " + root.SpanContext().TraceID().String() + "\n" +
+		"└─ GET /users/:id 6ms http.route=/users/:id\n" +
+		"   ├─ auth 2ms\n" +
+		"   │  └─ token 1ms\n" +
+		"   └─ query 3ms [ERROR timeout]\n"
+	assert.Equal(t, want, buf.String())
+}
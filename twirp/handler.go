@@ -0,0 +1,27 @@
+// Package oteltwirp instruments the Twirp services and clients with the
+// spans and rpc.* metrics of otelgrpc, the Twirp error codes being mapped to
+// the gRPC status codes.
+//
+// The Twirp servers and clients are HTTP handlers and clients, so the
+// package wraps them at the HTTP level, where the hooks of the servers and
+// the clients would see the same RPCs, and does not depend on Twirp.
+package oteltwirp
+
+import (
+	"net/http"
+
+	"kgs/otel/internal/httprpc"
+	"kgs/otel/internal/killswitch"
+)
+
+// Handler wraps a Twirp server so each RPC it serves is a server span,
+// measured by the rpc.server.* metrics:
+//
+//	server := userv1.NewUserServiceServer(svc)
+//	mux.Handle(server.PathPrefix(), oteltwirp.Handler(server))
+func Handler(h http.Handler, opts ...Option) http.Handler {
+	if killswitch.Disabled() {
+		return h
+	}
+	return httprpc.Handler(h, twirpProtocol{}, httprpc.NewConfig(ScopeName, "server", opts))
+}
@@ -0,0 +1,90 @@
+package oteltwirp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// userService answers like a Twirp server: GetUser succeeds, and the other
+// methods fail with a Twirp error.
+var userService = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/GetUser"):
+		_, _ = io.WriteString(w, `{"name":"ada"}`)
+	case strings.HasSuffix(r.URL.Path, "/DeleteUser"):
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"code":"not_found","msg":"no such user"}`)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, `{"code":"internal","msg":"database down"}`)
+	}
+})
+
+func TestHandler(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	opts := []Option{
+		WithTracerProvider(rec.TracerProvider),
+		WithMeterProvider(rec.MeterProvider),
+		WithPropagators(propagation.TraceContext{}),
+	}
+	srv := httptest.NewServer(Handler(userService, opts...))
+	defer srv.Close()
+	client := NewClient(opts...)
+
+	for _, method := range []string{"GetUser", "DeleteUser", "ListUsers"} {
+		resp, err := client.Post(srv.URL+"/twirp/acme.user.v1.UserService/"+method, "application/json", strings.NewReader(`{"id":1}`))
+		require.NoError(t, err)
+		_, _ = io.ReadAll(resp.Body)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	spans := map[trace.SpanKind]map[string]trace.SpanContext{trace.SpanKindServer: {}, trace.SpanKindClient: {}}
+	for _, s := range rec.Spans() {
+		assert.Contains(t, s.Attributes(), semconv.RPCSystemKey.String("twirp"))
+		assert.Contains(t, s.Attributes(), semconv.RPCService("acme.user.v1.UserService"))
+		switch s.Name() {
+		case "acme.user.v1.UserService/GetUser":
+			assert.Contains(t, s.Attributes(), GRPCStatusCodeKey.Int(0))
+			assert.Equal(t, codes.Unset, s.Status().Code)
+		case "acme.user.v1.UserService/DeleteUser":
+			assert.Contains(t, s.Attributes(), GRPCStatusCodeKey.Int(5))
+			if s.SpanKind() == trace.SpanKindServer {
+				assert.Equal(t, codes.Unset, s.Status().Code, "NotFound is not a server error")
+			} else {
+				assert.Equal(t, codes.Error, s.Status().Code)
+				assert.Equal(t, "no such user", s.Status().Description)
+			}
+		case "acme.user.v1.UserService/ListUsers":
+			assert.Contains(t, s.Attributes(), GRPCStatusCodeKey.Int(13))
+			assert.Equal(t, codes.Error, s.Status().Code)
+		}
+		if s.SpanKind() == trace.SpanKindServer {
+			spans[s.SpanKind()][s.Name()] = s.Parent()
+		} else {
+			spans[s.SpanKind()][s.Name()] = s.SpanContext()
+		}
+	}
+	require.Len(t, spans[trace.SpanKindServer], 3)
+	for name, parent := range spans[trace.SpanKindServer] {
+		assert.Equal(t, spans[trace.SpanKindClient][name].SpanID(), parent.SpanID(), name)
+	}
+
+	attrs := []attribute.KeyValue{semconv.RPCMethod("DeleteUser"), GRPCStatusCodeKey.Int(5)}
+	oteltest.AssertHistogramCount(t, rec, "rpc.server.duration", attrs, 1)
+	oteltest.AssertHistogramCount(t, rec, "rpc.client.duration", attrs, 1)
+}
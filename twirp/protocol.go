@@ -0,0 +1,105 @@
+package oteltwirp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"kgs/otel/internal/httprpc"
+
+	grpcCodes "google.golang.org/grpc/codes"
+)
+
+const (
+	// ScopeName is the instrumentation scope name.
+	ScopeName = "kgs/otel/twirp"
+	// GRPCStatusCodeKey is the numeric gRPC status code of the RPC, the
+	// Twirp error codes being mapped to the ones of gRPC like otelgrpc
+	// records them.
+	GRPCStatusCodeKey = httprpc.StatusCodeKey
+)
+
+// twirpProtocol reads the RPCs of the Twirp protocol.
+type twirpProtocol struct{}
+
+func (twirpProtocol) System(*http.Request) string {
+	return "twirp"
+}
+
+// Procedure returns the service and method of the path, after the prefix of
+// the routes, /twirp by default.
+func (twirpProtocol) Procedure(r *http.Request) string {
+	path := r.URL.Path
+	i := strings.LastIndexByte(path, '/')
+	if i <= 0 {
+		return path
+	}
+	if j := strings.LastIndexByte(path[:i], '/'); j >= 0 {
+		return path[j:]
+	}
+	return path
+}
+
+// KeepErrorBody keeps the error bodies, holding the Twirp error code.
+func (twirpProtocol) KeepErrorBody(_ string, status int) bool {
+	return status != http.StatusOK
+}
+
+func (twirpProtocol) Status(_ string, status int, _ http.Header, body []byte) (grpcCodes.Code, string) {
+	if status == http.StatusOK {
+		return grpcCodes.OK, ""
+	}
+	var twirpErr struct {
+		Code string `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if json.Unmarshal(body, &twirpErr) == nil {
+		if code, ok := twirpCodes[twirpErr.Code]; ok {
+			return code, twirpErr.Msg
+		}
+	}
+	return httpCode(status), ""
+}
+
+// twirpCodes are the gRPC codes of the Twirp error codes.
+var twirpCodes = map[string]grpcCodes.Code{
+	"canceled":            grpcCodes.Canceled,
+	"unknown":             grpcCodes.Unknown,
+	"invalid_argument":    grpcCodes.InvalidArgument,
+	"malformed":           grpcCodes.InvalidArgument,
+	"deadline_exceeded":   grpcCodes.DeadlineExceeded,
+	"not_found":           grpcCodes.NotFound,
+	"bad_route":           grpcCodes.Unimplemented,
+	"already_exists":      grpcCodes.AlreadyExists,
+	"permission_denied":   grpcCodes.PermissionDenied,
+	"unauthenticated":     grpcCodes.Unauthenticated,
+	"resource_exhausted":  grpcCodes.ResourceExhausted,
+	"failed_precondition": grpcCodes.FailedPrecondition,
+	"aborted":             grpcCodes.Aborted,
+	"out_of_range":        grpcCodes.OutOfRange,
+	"unimplemented":       grpcCodes.Unimplemented,
+	"internal":            grpcCodes.Internal,
+	"unavailable":         grpcCodes.Unavailable,
+	"dataloss":            grpcCodes.DataLoss,
+}
+
+// httpCode returns the code of an HTTP status without a Twirp error, e.g.
+// from a proxy.
+func httpCode(status int) grpcCodes.Code {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcCodes.InvalidArgument
+	case http.StatusUnauthorized:
+		return grpcCodes.Unauthenticated
+	case http.StatusForbidden:
+		return grpcCodes.PermissionDenied
+	case http.StatusNotFound:
+		return grpcCodes.Unimplemented
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return grpcCodes.DeadlineExceeded
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpcCodes.Unavailable
+	default:
+		return grpcCodes.Unknown
+	}
+}
@@ -0,0 +1,25 @@
+package oteltwirp
+
+import (
+	"net/http"
+
+	"kgs/otel/internal/httprpc"
+)
+
+// Transport is an http.RoundTripper making each RPC of a Twirp client a
+// client span, measured by the rpc.client.* metrics.
+type Transport = httprpc.Transport
+
+// NewTransport returns a Transport wrapping base, or http.DefaultTransport
+// if base is nil.
+func NewTransport(base http.RoundTripper, opts ...Option) *Transport {
+	return httprpc.NewTransport(base, twirpProtocol{}, httprpc.NewConfig(ScopeName, "client", opts))
+}
+
+// NewClient returns an http.Client whose transport is a Transport wrapping
+// http.DefaultTransport, for the generated Twirp clients:
+//
+//	client := userv1.NewUserServiceProtobufClient(baseURL, oteltwirp.NewClient())
+func NewClient(opts ...Option) *http.Client {
+	return &http.Client{Transport: NewTransport(nil, opts...)}
+}
@@ -4,12 +4,18 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"sync"
+
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/lowoverhead"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 type Field struct {
@@ -25,29 +31,55 @@ func NewFiled(key string, value interface{}) Field {
 }
 
 func Info(ctx context.Context, message string, fields ...Field) {
+	if killswitch.Disabled() {
+		zap.L().Info(message, plainZapFields(fields)...)
+		return
+	}
 	span, zapFields := setSpanAttrsAndZapFields(ctx, fields...)
-	span.AddEvent(message)
-	zap.L().Info(message, zapFields...)
+	addSpanEvent(span, zapcore.InfoLevel, message)
+	loggerFor(ctx).Info(message, zapFields...)
 }
 
 func Warn(ctx context.Context, message string, fields ...Field) {
+	if killswitch.Disabled() {
+		zap.L().Warn(message, plainZapFields(fields)...)
+		return
+	}
 	span, zapFields := setSpanAttrsAndZapFields(ctx, fields...)
-	span.AddEvent(message)
+	addSpanEvent(span, zapcore.WarnLevel, message)
 	span.SetStatus(codes.Error, message)
-	zap.L().Warn(message, zapFields...)
+	loggerFor(ctx).Warn(message, zapFields...)
 }
 
 func Error(ctx context.Context, message string, fields ...Field) {
+	if killswitch.Disabled() {
+		zap.L().Error(message, plainZapFields(withErrorFields(fields))...)
+		return
+	}
+	fields = withFingerprint(message, withErrorFields(fields), 2)
 	span, zapFields := setSpanAttrsAndZapFields(ctx, fields...)
-	span.AddEvent(message)
+	addSpanEvent(span, zapcore.ErrorLevel, message)
 	span.SetStatus(codes.Error, message)
-	zap.L().Error(message, zapFields...)
+	loggerFor(ctx).Error(message, zapFields...)
+	reportError(ctx, span, message, fields)
 }
 
 func StartTrace(ctx context.Context) (context.Context, trace.Span) {
+	if killswitch.Disabled() {
+		// Not the span of ctx, which the deferred End of the caller would end.
+		return ctx, noop.Span{}
+	}
 	tracer := otel.Tracer("") // The name of the tracer is not important
+	// The span is started from a marked context for the span leak detector,
+	// but the mark is not passed on to the children.
+	if lowoverhead.Enabled() {
+		name := callerFuncName(2)
+		_, span := tracer.Start(markStartTrace(ctx), name)
+		return labelSpan(trace.ContextWithSpan(ctx, span), span, name)
+	}
 	caller, funcName := getCaller(2)
-	ctx, span := tracer.Start(ctx, funcName)
+	_, span := tracer.Start(markStartTrace(ctx), funcName)
+	ctx, span = labelSpan(trace.ContextWithSpan(ctx, span), span, funcName)
 	traceID := span.SpanContext().TraceID().String()
 	spanID := span.SpanContext().SpanID().String()
 
@@ -63,7 +95,20 @@ func StartTrace(ctx context.Context) (context.Context, trace.Span) {
 	return ctx, span
 }
 
+// plainZapFields converts the fields to zap fields for the logs written
+// without telemetry, while the kill switch is on.
+func plainZapFields(fields []Field) []zap.Field {
+	zapFields := make([]zap.Field, len(fields))
+	for i, field := range fields {
+		zapFields[i] = zapField(field)
+	}
+	return zapFields
+}
+
 func setSpanAttrsAndZapFields(ctx context.Context, fields ...Field) (span trace.Span, zapFields []zap.Field) {
+	if lowoverhead.Enabled() {
+		return fastSpanAttrsAndZapFields(ctx, fields...)
+	}
 	span = trace.SpanFromContext(ctx)
 	traceID := span.SpanContext().TraceID().String()
 	spanID := span.SpanContext().SpanID().String()
@@ -86,7 +131,7 @@ func setSpanAttrsAndZapFields(ctx context.Context, fields ...Field) (span trace.
 
 	for _, field := range fields {
 		attributes = append(attributes, attribute.String(field.Key, fmt.Sprintf("%v", field.Value)))
-		zapFields = append(zapFields, zap.Any(field.Key, field.Value))
+		zapFields = append(zapFields, zapField(field))
 	}
 	span.SetAttributes(attributes...)
 
@@ -102,3 +147,71 @@ func getCaller(skip int) (caller string, funcName string) {
 	fn := runtime.FuncForPC(pc)
 	return fmt.Sprintf("%s:%d", file, line), fn.Name()
 }
+
+// fastSpanAttrsAndZapFields is the low-overhead version of
+// setSpanAttrsAndZapFields: the caller is not resolved, the IDs are only
+// formatted if the log is written and the fields keep their type.
+func fastSpanAttrsAndZapFields(ctx context.Context, fields ...Field) (span trace.Span, zapFields []zap.Field) {
+	span = trace.SpanFromContext(ctx)
+	zapFields = make([]zap.Field, 0, len(fields)+2)
+	if sc := span.SpanContext(); sc.IsValid() {
+		zapFields = append(zapFields, zap.Stringer("traceID", sc.TraceID()), zap.Stringer("spanID", sc.SpanID()))
+	}
+
+	var attributes []attribute.KeyValue
+	if span.IsRecording() {
+		attributes = make([]attribute.KeyValue, 0, len(fields))
+	}
+	for _, field := range fields {
+		if attributes != nil {
+			attributes = append(attributes, fieldAttribute(field))
+		}
+		zapFields = append(zapFields, zapField(field))
+	}
+	if len(attributes) > 0 {
+		span.SetAttributes(attributes...)
+	}
+
+	return span, zapFields
+}
+
+// fieldAttribute converts the field to an attribute, formatting its value
+// only if it has no attribute type.
+func fieldAttribute(field Field) attribute.KeyValue {
+	switch v := field.Value.(type) {
+	case string:
+		return attribute.String(field.Key, v)
+	case bool:
+		return attribute.Bool(field.Key, v)
+	case int:
+		return attribute.Int(field.Key, v)
+	case int64:
+		return attribute.Int64(field.Key, v)
+	case float64:
+		return attribute.Float64(field.Key, v)
+	case error:
+		return attribute.String(field.Key, v.Error())
+	case fmt.Stringer:
+		return attribute.String(field.Key, v.String())
+	default:
+		return attribute.String(field.Key, fmt.Sprint(v))
+	}
+}
+
+// funcNames caches the function names by program counter.
+var funcNames sync.Map
+
+// callerFuncName returns the name of the function skip frames above the
+// caller, like getCaller, caching the names.
+func callerFuncName(skip int) string {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+1, pcs[:]) == 0 {
+		return "unknown"
+	}
+	if name, ok := funcNames.Load(pcs[0]); ok {
+		return name.(string)
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	funcNames.Store(pcs[0], frame.Function)
+	return frame.Function
+}
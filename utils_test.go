@@ -0,0 +1,75 @@
+package kgsotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kgs/otel/internal/killswitch"
+	"kgs/otel/internal/lowoverhead"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFieldAttribute(t *testing.T) {
+	assert.Equal(t, attribute.String("k", "v"), fieldAttribute(NewFiled("k", "v")))
+	assert.Equal(t, attribute.Int("k", 3), fieldAttribute(NewFiled("k", 3)))
+	assert.Equal(t, attribute.Bool("k", true), fieldAttribute(NewFiled("k", true)))
+	assert.Equal(t, attribute.String("k", "boom"), fieldAttribute(NewFiled("k", errors.New("boom"))))
+	assert.Equal(t, attribute.String("k", "1s"), fieldAttribute(NewFiled("k", time.Second)))
+	assert.Equal(t, attribute.String("k", "[1 2]"), fieldAttribute(NewFiled("k", []int{1, 2})))
+}
+
+func TestStartTraceLowOverhead(t *testing.T) {
+	lowoverhead.SetEnabled(true)
+	defer lowoverhead.SetEnabled(false)
+
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(prev)
+
+	for range 2 {
+		ctx, span := StartTrace(context.Background())
+		Info(ctx, "hello", NewFiled("user", 42))
+		span.End()
+	}
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "kgs/otel.TestStartTraceLowOverhead", spans[1].Name())
+	assert.Equal(t, []attribute.KeyValue{attribute.Int("user", 42)}, spans[1].Attributes())
+}
+
+func TestKillSwitch(t *testing.T) {
+	restoreGlobals(t)
+	killswitch.SetDisabled(true)
+	defer killswitch.SetDisabled(false)
+
+	core, logs := observer.New(zap.InfoLevel)
+	zap.ReplaceGlobals(zap.New(core))
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+
+	_, span := StartTrace(ctx)
+	span.End()
+	assert.True(t, parent.IsRecording(), "the span of the caller is not ended")
+	assert.Empty(t, recorder.Ended())
+
+	// The logs are still written, without telemetry.
+	Info(ctx, "order created", NewFiled("order", 42))
+	Error(ctx, "order failed")
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, map[string]any{"order": int64(42)}, logs.All()[0].ContextMap())
+	assert.Equal(t, "order failed", logs.All()[1].Message)
+	assert.Empty(t, parent.(sdktrace.ReadOnlySpan).Events())
+}
@@ -0,0 +1,246 @@
+// Package otelwebsocket instruments the gorilla/websocket connections with a
+// span covering the lifetime of the connection, an event or a child span per
+// message, and message count and size metrics.
+//
+// The package depends on the message methods of websocket.Conn only, so it
+// does not import gorilla/websocket.
+package otelwebsocket
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ScopeName is the instrumentation scope name.
+	ScopeName = "kgs/otel/websocket"
+
+	// MessageTypeKey is the WebSocket type of a message: text, binary,
+	// close, ping or pong.
+	MessageTypeKey = attribute.Key("websocket.message.type")
+	// DirectionKey is the direction of a message: sent or received.
+	DirectionKey = attribute.Key("websocket.message.direction")
+)
+
+// Conn is a WebSocket connection, e.g. a *websocket.Conn.
+type Conn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// TracedConn is a connection recording its messages in the span of its
+// lifetime.
+type TracedConn struct {
+	conn Conn
+	cfg  *config
+	ctx  context.Context
+	span trace.Span
+
+	tracer   trace.Tracer
+	messages metric.Int64Counter
+	sizes    metric.Int64Histogram
+
+	mu       sync.Mutex
+	readSpan trace.Span
+	closed   bool
+}
+
+// Wrap returns the connection upgraded from the request of ctx, starting its
+// span as a child of the span of the request:
+//
+//	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+//	if err != nil {
+//		return
+//	}
+//	conn := otelwebsocket.Wrap(c.Request.Context(), ws)
+//	defer conn.Close()
+//
+// The span ends when the connection is closed or fails to read.
+func Wrap(ctx context.Context, conn Conn, opts ...Option) *TracedConn {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt.apply(cfg)
+	}
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = otel.GetTracerProvider()
+	}
+	if cfg.MeterProvider == nil {
+		cfg.MeterProvider = otel.GetMeterProvider()
+	}
+
+	c := &TracedConn{conn: conn, cfg: cfg, tracer: cfg.TracerProvider.Tracer(ScopeName)}
+	c.ctx, c.span = c.tracer.Start(ctx, "WebSocket connection",
+		trace.WithAttributes(cfg.SpanAttributes...),
+	)
+	meter := cfg.MeterProvider.Meter(ScopeName)
+
+	var err error
+	// Count the messages by direction and type.
+	c.messages, err = meter.Int64Counter("websocket.messages",
+		metric.WithDescription("Counts the WebSocket messages sent and received."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		otel.Handle(err)
+		if c.messages == nil {
+			c.messages = noop.Int64Counter{}
+		}
+	}
+
+	// Measure the size of the messages.
+	c.sizes, err = meter.Int64Histogram("websocket.message.size",
+		metric.WithDescription("Measures the size of the WebSocket messages sent and received."),
+		metric.WithUnit("By"))
+	if err != nil {
+		otel.Handle(err)
+		if c.sizes == nil {
+			c.sizes = noop.Int64Histogram{}
+		}
+	}
+
+	return c
+}
+
+// Context returns the context of the connection span, to handle the
+// messages in.
+func (c *TracedConn) Context() context.Context {
+	return c.ctx
+}
+
+// Span returns the connection span.
+func (c *TracedConn) Span() trace.Span {
+	return c.span
+}
+
+// ReadMessage reads the next message. A read error, such as the close of
+// the connection by the peer, ends the connection span.
+func (c *TracedConn) ReadMessage() (int, []byte, error) {
+	c.endReadSpan()
+	messageType, p, err := c.conn.ReadMessage()
+	if err != nil {
+		c.fail(err)
+		return messageType, p, err
+	}
+	c.record(semconv.MessageTypeReceived, messageType, len(p), true)
+	return messageType, p, nil
+}
+
+// WriteMessage writes the message.
+func (c *TracedConn) WriteMessage(messageType int, data []byte) error {
+	err := c.conn.WriteMessage(messageType, data)
+	if err != nil {
+		c.span.RecordError(err)
+		return err
+	}
+	c.record(semconv.MessageTypeSent, messageType, len(data), false)
+	return nil
+}
+
+// Close closes the connection and ends its span.
+func (c *TracedConn) Close() error {
+	err := c.conn.Close()
+	c.end()
+	return err
+}
+
+// record records a message in the connection span and the metrics.
+func (c *TracedConn) record(direction attribute.KeyValue, messageType, size int, read bool) {
+	typ := MessageTypeKey.String(typeName(messageType))
+	attrs := []attribute.KeyValue{direction, typ, semconv.MessageUncompressedSize(size)}
+	if c.cfg.MessageSpans {
+		name := "WebSocket receive"
+		if !read {
+			name = "WebSocket send"
+		}
+		_, span := c.tracer.Start(c.ctx, name, trace.WithAttributes(attrs...))
+		if read {
+			c.mu.Lock()
+			c.readSpan = span
+			c.mu.Unlock()
+		} else {
+			span.End()
+		}
+	} else {
+		c.span.AddEvent("message", trace.WithAttributes(attrs...))
+	}
+
+	metricAttrs := make([]attribute.KeyValue, 0, len(c.cfg.MetricAttributes)+2)
+	metricAttrs = append(append(metricAttrs, DirectionKey.String(directionName(direction)), typ), c.cfg.MetricAttributes...)
+	opt := metric.WithAttributeSet(attribute.NewSet(metricAttrs...))
+	c.messages.Add(c.ctx, 1, opt)
+	c.sizes.Record(c.ctx, int64(size), opt)
+}
+
+// fail ends the connection span on a read error, an error unless it is a
+// normal close.
+func (c *TracedConn) fail(err error) {
+	if !isNormalClose(err) {
+		c.span.RecordError(err)
+		c.span.SetStatus(codes.Error, err.Error())
+	} else {
+		c.span.AddEvent("close", trace.WithAttributes(attribute.String("websocket.close.reason", err.Error())))
+	}
+	c.end()
+}
+
+func (c *TracedConn) endReadSpan() {
+	c.mu.Lock()
+	span := c.readSpan
+	c.readSpan = nil
+	c.mu.Unlock()
+	if span != nil {
+		span.End()
+	}
+}
+
+func (c *TracedConn) end() {
+	c.endReadSpan()
+	c.mu.Lock()
+	closed := c.closed
+	c.closed = true
+	c.mu.Unlock()
+	if !closed {
+		c.span.End()
+	}
+}
+
+// isNormalClose reports whether err is a gorilla/websocket close error with
+// the normal closure or going away code.
+func isNormalClose(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "websocket: close 1000 ") || strings.HasPrefix(msg, "websocket: close 1001 ")
+}
+
+// typeName returns the name of the message type of gorilla/websocket.
+func typeName(messageType int) string {
+	switch messageType {
+	case 1:
+		return "text"
+	case 2:
+		return "binary"
+	case 8:
+		return "close"
+	case 9:
+		return "ping"
+	case 10:
+		return "pong"
+	default:
+		return "unknown"
+	}
+}
+
+func directionName(direction attribute.KeyValue) string {
+	if direction == semconv.MessageTypeSent {
+		return "sent"
+	}
+	return "received"
+}
@@ -0,0 +1,92 @@
+package otelwebsocket
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kgs/otel/oteltest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// fakeConn replays the messages, then fails with the error.
+type fakeConn struct {
+	messages [][]byte
+	err      error
+	written  [][]byte
+	closed   bool
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	if len(c.messages) == 0 {
+		return -1, nil, c.err
+	}
+	m := c.messages[0]
+	c.messages = c.messages[1:]
+	return 1, m, nil
+}
+
+func (c *fakeConn) WriteMessage(_ int, data []byte) error {
+	c.written = append(c.written, data)
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestTracedConn(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	ctx, parent := rec.TracerProvider.Tracer("test").Start(context.Background(), "GET /ws")
+	fake := &fakeConn{messages: [][]byte{[]byte("hello")}, err: errors.New("websocket: close 1000 (normal)")}
+	conn := Wrap(ctx, fake, WithTracerProvider(rec.TracerProvider), WithMeterProvider(rec.MeterProvider))
+
+	_, p, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(2, append(p, " world"...)))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err)
+	require.NoError(t, conn.Close())
+	assert.True(t, fake.closed)
+	parent.End()
+
+	span, ok := rec.Span("WebSocket connection")
+	require.True(t, ok)
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Equal(t, codes.Unset, span.Status().Code, "a normal close is not an error")
+	var events []string
+	for _, e := range span.Events() {
+		events = append(events, e.Name)
+	}
+	assert.Equal(t, []string{"message", "message", "close"}, events)
+	assert.Contains(t, span.Events()[1].Attributes, MessageTypeKey.String("binary"))
+
+	oteltest.AssertSumValue(t, rec, "websocket.messages", []attribute.KeyValue{DirectionKey.String("received"), MessageTypeKey.String("text")}, int64(1))
+	oteltest.AssertHistogramCount(t, rec, "websocket.message.size", []attribute.KeyValue{DirectionKey.String("sent")}, 1)
+}
+
+func TestTracedConnMessageSpans(t *testing.T) {
+	rec := oteltest.NewRecorder()
+	fake := &fakeConn{messages: [][]byte{[]byte("a"), []byte("b")}, err: errors.New("websocket: close 1006 (abnormal closure): unexpected EOF")}
+	conn := Wrap(context.Background(), fake, WithTracerProvider(rec.TracerProvider), WithMessageSpans(true))
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+		require.NoError(t, conn.WriteMessage(1, []byte("ack")))
+	}
+
+	var names []string
+	for _, s := range rec.Spans() {
+		names = append(names, s.Name())
+	}
+	assert.Equal(t, []string{"WebSocket send", "WebSocket receive", "WebSocket send", "WebSocket receive", "WebSocket connection"}, names)
+	span, _ := rec.Span("WebSocket connection")
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
@@ -0,0 +1,73 @@
+package otelwebsocket
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// config is a group of options for the connections.
+type config struct {
+	TracerProvider   trace.TracerProvider
+	MeterProvider    metric.MeterProvider
+	SpanAttributes   []attribute.KeyValue
+	MetricAttributes []attribute.KeyValue
+	MessageSpans     bool
+}
+
+// Option applies an option value for a config.
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (o optionFunc) apply(c *config) {
+	o(c)
+}
+
+// WithTracerProvider returns an Option to use the tracer provider.
+// If none is specified, the global provider is used.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.TracerProvider = provider
+		}
+	})
+}
+
+// WithMeterProvider returns an Option to use the meter provider.
+// If none is specified, the global provider is used.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return optionFunc(func(cfg *config) {
+		if provider != nil {
+			cfg.MeterProvider = provider
+		}
+	})
+}
+
+// WithSpanAttributes returns an Option to add attributes to the connection
+// spans, e.g. the channel subscribed to.
+func WithSpanAttributes(attrs ...attribute.KeyValue) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.SpanAttributes = attrs
+	})
+}
+
+// WithMetricAttributes returns an Option to add attributes to every
+// recorded datapoint.
+func WithMetricAttributes(attrs ...attribute.KeyValue) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.MetricAttributes = attrs
+	})
+}
+
+// WithMessageSpans returns an Option to record each message as a child span
+// of the connection span instead of an event. The spans of the messages
+// read cover their handling, until the next read; the spans of the messages
+// written cover the write.
+func WithMessageSpans(enabled bool) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.MessageSpans = enabled
+	})
+}
@@ -0,0 +1,133 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// xrayHeader is the header carrying the X-Ray trace context, e.g.
+// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+const xrayHeader = "X-Amzn-Trace-Id"
+
+// WithXRay returns an Option to make the traces compatible with AWS X-Ray:
+// the trace IDs start with their timestamp, as X-Ray requires, and the trace
+// context is propagated in the X-Amzn-Trace-Id header too. The telemetry is
+// still exported to the collector with OTLP. When a request has both, the W3C
+// traceparent header wins.
+func WithXRay() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.IDGenerator = NewXRayIDGenerator()
+		cfg.XRayPropagation = true
+	})
+}
+
+// NewXRayIDGenerator returns an ID generator of trace IDs valid for X-Ray,
+// whose first 4 bytes are the Unix time in seconds.
+func NewXRayIDGenerator() sdktrace.IDGenerator {
+	return xrayIDGenerator{}
+}
+
+type xrayIDGenerator struct{}
+
+// NewIDs returns a trace ID starting with the current time and a random span ID.
+func (xrayIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	var tid trace.TraceID
+	binary.BigEndian.PutUint32(tid[:4], uint32(time.Now().Unix()))
+	binary.BigEndian.PutUint32(tid[4:8], rand.Uint32())
+	binary.BigEndian.PutUint64(tid[8:], rand.Uint64())
+	return tid, newRandomSpanID()
+}
+
+// NewSpanID returns a random span ID.
+func (xrayIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	return newRandomSpanID()
+}
+
+func newRandomSpanID() trace.SpanID {
+	var sid trace.SpanID
+	for !sid.IsValid() {
+		binary.BigEndian.PutUint64(sid[:], rand.Uint64())
+	}
+	return sid
+}
+
+// NewXRayPropagator returns a propagator of the trace context in the
+// X-Amzn-Trace-Id header. A trace without sampling decision is considered
+// sampled, as the decision is left to the receiver. The header of a load
+// balancer, which has no parent, is ignored.
+func NewXRayPropagator() propagation.TextMapPropagator {
+	return xrayPropagator{}
+}
+
+type xrayPropagator struct{}
+
+var _ propagation.TextMapPropagator = xrayPropagator{}
+
+// Inject sets the X-Amzn-Trace-Id header of the span context of ctx.
+func (xrayPropagator) Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	traceID := sc.TraceID()
+	sampled := 0
+	if sc.IsSampled() {
+		sampled = 1
+	}
+	carrier.Set(xrayHeader, fmt.Sprintf("Root=1-%s-%s;Parent=%s;Sampled=%d",
+		hex.EncodeToString(traceID[:4]), hex.EncodeToString(traceID[4:]), sc.SpanID(), sampled))
+}
+
+// Extract returns ctx with the remote span context of the X-Amzn-Trace-Id header.
+func (xrayPropagator) Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	var (
+		traceID trace.TraceID
+		spanID  trace.SpanID
+		flags   = trace.FlagsSampled
+		err     error
+	)
+	for _, part := range strings.Split(carrier.Get(xrayHeader), ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "Root":
+			version, id, ok := strings.Cut(v, "-")
+			if !ok || version != "1" {
+				return ctx
+			}
+			if traceID, err = trace.TraceIDFromHex(strings.Replace(id, "-", "", 1)); err != nil {
+				return ctx
+			}
+		case "Parent":
+			if spanID, err = trace.SpanIDFromHex(v); err != nil {
+				return ctx
+			}
+		case "Sampled":
+			if v == "0" {
+				flags = 0
+			}
+		}
+	}
+	if !traceID.IsValid() || !spanID.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		Remote:     true,
+	}))
+}
+
+// Fields returns the header the propagator sets.
+func (xrayPropagator) Fields() []string {
+	return []string{xrayHeader}
+}
@@ -0,0 +1,50 @@
+package kgsotel
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestXRayIDGenerator(t *testing.T) {
+	before := time.Now().Unix()
+	traceID, spanID := NewXRayIDGenerator().NewIDs(context.Background())
+	assert.True(t, traceID.IsValid())
+	assert.True(t, spanID.IsValid())
+	assert.InDelta(t, before, int64(binary.BigEndian.Uint32(traceID[:4])), 1)
+}
+
+func TestXRayPropagator(t *testing.T) {
+	p := NewXRayPropagator()
+	header := "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+	ctx := p.Extract(context.Background(), propagation.MapCarrier{"X-Amzn-Trace-Id": header})
+	sc := trace.SpanContextFromContext(ctx)
+	assert.Equal(t, "5759e988bd862e3fe1be46a994272793", sc.TraceID().String())
+	assert.Equal(t, "53995c3f42cd8ad8", sc.SpanID().String())
+	assert.True(t, sc.IsSampled())
+	assert.True(t, sc.IsRemote())
+
+	carrier := propagation.MapCarrier{}
+	p.Inject(ctx, carrier)
+	assert.Equal(t, header, carrier.Get("X-Amzn-Trace-Id"))
+
+	extract := func(h string) trace.SpanContext {
+		return trace.SpanContextFromContext(p.Extract(context.Background(), propagation.MapCarrier{"X-Amzn-Trace-Id": h}))
+	}
+	assert.False(t, extract("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=0").IsSampled())
+	assert.True(t, extract("Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8").IsSampled())
+	assert.False(t, extract("Root=1-5759e988-bd862e3fe1be46a994272793").IsValid())
+	assert.False(t, extract("Root=2-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8").IsValid())
+	assert.False(t, extract("").IsValid())
+}
+
+func TestWithXRay(t *testing.T) {
+	cfg := newConfig(WithXRay())
+	assert.IsType(t, xrayIDGenerator{}, cfg.IDGenerator)
+	assert.Contains(t, newPropagator(cfg).Fields(), "X-Amzn-Trace-Id")
+}
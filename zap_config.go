@@ -0,0 +1,37 @@
+package kgsotel
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WithZapEncoderConfig returns an Option to replace the encoder
+// configuration of the console logger. If none is specified, the colored
+// configuration of getConsoleConfig is used.
+func WithZapEncoderConfig(encoderConfig zapcore.EncoderConfig) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ZapEncoderConfig = &encoderConfig
+	})
+}
+
+// WithZapOptions returns an Option to add zap options to the global logger,
+// e.g. zap.AddStacktrace or zap.WrapCore for sampling. With zap.AddCaller,
+// zap.AddCallerSkip(1) reports the caller of kgsotel.Info and the others.
+func WithZapOptions(opts ...zap.Option) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.ZapOptions = append(cfg.ZapOptions, opts...)
+	})
+}
+
+// WithZapCores returns an Option to also write the logs to the cores, along
+// with the console and the OTLP ones. The cores keep their own level, and
+// are not closed on shutdown.
+func WithZapCores(cores ...zapcore.Core) Option {
+	return optionFunc(func(cfg *config) {
+		for _, core := range cores {
+			cfg.LogCores = append(cfg.LogCores, func(string, zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+				return core, func() error { return nil }, nil
+			})
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package kgsotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapPassthrough(t *testing.T) {
+	ctx := context.Background()
+	core, logs := observer.New(zapcore.WarnLevel)
+	encoderConfig := zap.NewProductionEncoderConfig()
+	tel, err := New(ctx, Config{
+		ServiceName: "svc",
+		Options: []Option{
+			WithStdoutExporters(true),
+			WithZapEncoderConfig(encoderConfig),
+			WithZapOptions(zap.Fields(zap.String("region", "eu"))),
+			WithZapCores(core),
+		},
+	})
+	require.NoError(t, err)
+	defer tel.Shutdown(ctx)
+
+	tel.Logger().Info("ignored by the core level")
+	tel.Logger().Warn("disk almost full")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "disk almost full", entries[0].Message)
+	assert.Equal(t, "eu", entries[0].ContextMap()["region"])
+}
+
+func TestZapEncoderConfig(t *testing.T) {
+	encoderConfig := zap.NewDevelopmentEncoderConfig()
+	cfg := newConfig(WithZapEncoderConfig(encoderConfig))
+	require.NotNil(t, cfg.ZapEncoderConfig)
+	assert.Equal(t, encoderConfig.MessageKey, cfg.ZapEncoderConfig.MessageKey)
+	assert.Nil(t, newConfig().ZapEncoderConfig)
+}